@@ -0,0 +1,109 @@
+// Command export-catalog writes videos, categories, ads or settings as JSON,
+// CSV or NDJSON, as described in the exporter package. Video exports are
+// manifests for import-videos, naming the stored files relative to the
+// server's working directory; -media=false leaves those out.
+//
+//	go run ./cmd/export-catalog -format csv -o catalog.csv videos
+//	go run ./cmd/export-catalog -category music -from 2024-01-01 -media=false ads
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/exporter"
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+func main() {
+	format := flag.String("format", "", "export format, json, csv or ndjson (default: from -o's extension, or json)")
+	category := flag.String("category", "", "only export videos, or the category, with this category ID")
+	from := flag.String("from", "", "only export videos and ads created on or after this date (YYYY-MM-DD or RFC 3339)")
+	to := flag.String("to", "", "only export videos and ads created before this date")
+	media := flag.Bool("media", true, "include the paths of stored video files, thumbnails and ad images; without them stored videos can't be imported")
+	output := flag.String("o", "", "file to write (default: standard output)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] videos|categories|ads|settings\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	entity := flag.Arg(0)
+
+	if *format == "" {
+		*format = exporter.FormatJSON
+		for _, f := range []string{exporter.FormatCSV, exporter.FormatNDJSON} {
+			if strings.HasSuffix(strings.ToLower(*output), "."+f) {
+				*format = f
+			}
+		}
+	}
+	if err := exporter.Valid(entity, *format); err != nil {
+		log.Fatalf("%v", err)
+	}
+	opts := exporter.Options{Format: *format, Category: *category, IncludeMedia: *media}
+	opts.From = parseDate("from", *from)
+	opts.To = parseDate("to", *to)
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+	config := utils.LoadConfig()
+
+	db, err := database.InitDB(config.DatabaseURL, config.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Make sure the columns read exist on SQLite databases the server hasn't migrated yet
+	if config.DatabaseURL == "" {
+		if err := database.RunMigrations(db); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+	}
+
+	exp := exporter.NewExporter(
+		models.NewVideoRepository(db), models.NewCategoryRepository(db),
+		models.NewAdRepository(db), models.NewSettingsRepository(db),
+	)
+	err = exp.Export(out, entity, opts)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	if *output != "" {
+		log.Printf("Exported %s to %s", entity, *output)
+	}
+}
+
+func parseDate(name, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := utils.ParseDate(value)
+	if err != nil {
+		log.Fatalf("Invalid -%s date %q; use YYYY-MM-DD or RFC 3339", name, value)
+	}
+	return &t
+}
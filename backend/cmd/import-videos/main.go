@@ -0,0 +1,115 @@
+// Command import-videos creates videos in bulk from a CSV, JSON or NDJSON
+// manifest, as described in the importer package. Local files are resolved
+// from the manifest's directory unless -dir says otherwise, and are copied
+// into storage. HLS packaging is queued for the server's workers.
+//
+//	go run ./cmd/import-videos -dry-run catalog.csv
+//	go run ./cmd/import-videos catalog.csv
+//
+// It exits with status 1 if any row is invalid, in which case nothing was
+// imported. Running it again on the same manifest skips the videos it
+// already created.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/importer"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "validate the manifest without importing anything")
+	dir := flag.String("dir", "", "directory relative file paths are resolved from (default: the manifest's)")
+	format := flag.String("format", "", "manifest format, csv, json or ndjson (default: from the file extension)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] manifest.csv|manifest.json|manifest.ndjson\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	manifestPath := flag.Arg(0)
+
+	if *format == "" {
+		var ok bool
+		if *format, ok = importer.FormatFromName(manifestPath); !ok {
+			log.Fatalf("Can't tell the format of %s; use -format", manifestPath)
+		}
+	}
+	if *dir == "" {
+		*dir = filepath.Dir(manifestPath)
+	}
+
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to open manifest: %v", err)
+	}
+	rows, err := importer.ParseManifest(file, *format)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+	config := utils.LoadConfig()
+
+	db, err := database.InitDB(config.DatabaseURL, config.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Make sure external_id exists on SQLite databases the server hasn't migrated yet
+	if config.DatabaseURL == "" {
+		if err := database.RunMigrations(db); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	videoRepo := models.NewVideoRepository(db)
+	storageService := services.NewStorageService(config.VideoPath, config.ThumbnailPath, config.AdPath, config.CaptionPath)
+	jobQueue := services.NewJobQueue(models.NewJobRepository(db, database.GetDBDriver(db)), config.JobWorkers, config.JobMaxAttempts)
+	services.RegisterVideoJobs(jobQueue, videoRepo, storageService)
+
+	imp := importer.NewImporter(videoRepo, models.NewCategoryRepository(db), storageService, jobQueue)
+	report, err := imp.Import(rows, importer.Options{DryRun: *dryRun, Dir: *dir})
+	if err != nil {
+		log.Fatalf("Import failed, nothing was imported: %v", err)
+	}
+
+	for _, row := range report.Rows {
+		line := fmt.Sprintf("line %d\t%s\t%s", row.Line, row.Status, row.ExternalID)
+		if row.VideoID != 0 {
+			line += fmt.Sprintf("\tvideo %d", row.VideoID)
+		}
+		if len(row.Errors) > 0 {
+			line += "\t" + strings.Join(row.Errors, "; ")
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d created, %d ready, %d already imported, %d invalid\n",
+		report.Created, report.Ready, report.Existing, report.Invalid)
+
+	switch {
+	case report.Invalid > 0:
+		log.Println("Nothing was imported; fix the invalid rows and run again")
+		os.Exit(1)
+	case *dryRun:
+		log.Println("Dry run, nothing was imported")
+	}
+}
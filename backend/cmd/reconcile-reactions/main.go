@@ -0,0 +1,40 @@
+// Command reconcile-reactions recomputes the likes/dislikes counters on
+// videos from the video_reactions table. Run it after restoring backups or
+// whenever the counters look off; it is safe to run while the server is up.
+package main
+
+import (
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+	config := utils.LoadConfig()
+
+	db, err := database.InitDB(config.DatabaseURL, config.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Make sure video_reactions exists on SQLite databases the server hasn't migrated yet
+	if config.DatabaseURL == "" {
+		if err := database.RunMigrations(db); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	fixed, err := models.NewReactionRepository(db).Reconcile()
+	if err != nil {
+		log.Fatalf("Failed to reconcile reactions: %v", err)
+	}
+	log.Printf("Reconciled reaction counters: %d videos corrected", fixed)
+}
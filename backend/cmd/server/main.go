@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+	"github.com/joho/godotenv"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/exporter"
+	"titan-backend/internal/handlers"
+	"titan-backend/internal/importer"
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	// Load configuration
+	config := utils.LoadConfig()
+
+	// Client IPs are only taken from proxy headers sent by these proxies
+	if err := utils.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Initialize database
+	db, err := database.InitDB(config.DatabaseURL, config.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Run migrations only for SQLite (PostgreSQL uses migrate tool in Docker)
+	if config.DatabaseURL == "" {
+		log.Println("Running SQLite migrations...")
+		if err := database.RunMigrations(db); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	} else {
+		log.Println("Using PostgreSQL - migrations handled by migrate tool")
+	}
+
+	// Seed default data (admin user creation)
+	if err := database.SeedDefaultData(db, config.DefaultAdminUser, config.DefaultAdminPass); err != nil {
+		log.Fatalf("Failed to seed default data: %v", err)
+	}
+
+	// Initialize repositories
+	driver := database.GetDBDriver(db)
+	userRepo := models.NewUserRepository(db)
+	videoRepo := models.NewVideoRepository(db)
+	videoRepo.SetSearchEngine(models.NewVideoSearchEngine(db, driver))
+	viewLogRepo := models.NewViewLogRepository(db)
+	categoryRepo := models.NewCategoryRepository(db)
+	adRepo := models.NewAdRepository(db)
+	settingsRepo := models.NewSettingsRepository(db)
+	serverLogRepo := models.NewServerLogRepository(db)
+	fileRepo := models.NewFileRepository(db)
+	jobRepo := models.NewJobRepository(db, driver)
+	reactionRepo := models.NewReactionRepository(db)
+	commentRepo := models.NewCommentRepository(db)
+	playlistRepo := models.NewPlaylistRepository(db)
+	tagRepo := models.NewTagRepository(db)
+	similarityRepo := models.NewSimilarityRepository(db)
+	captionRepo := models.NewCaptionRepository(db)
+	chapterRepo := models.NewChapterRepository(db)
+	creatorRepo := models.NewCreatorRepository(db)
+
+	// Initialize services
+	authService := services.NewAuthService(config.JWTSecret, config.JWTExpiryHours)
+	storageService := services.NewStorageService(config.VideoPath, config.ThumbnailPath, config.AdPath, config.CaptionPath)
+	analyticsService := services.NewAnalyticsService(db)
+	serverService := services.NewServerService(db, serverLogRepo)
+	fileService := services.NewFileService(config.StoragePath)
+	uploadService := services.NewUploadService(config.UploadPath, int64(config.MaxVideoSizeMB)<<20, time.Duration(config.UploadTTLHours)*time.Hour)
+
+	// Signed /storage links
+	urlSigningSecret := config.URLSigningSecret
+	if urlSigningSecret == "" {
+		urlSigningSecret = config.JWTSecret
+	}
+	urlSigner := services.NewURLSigner(urlSigningSecret, time.Duration(config.URLExpiryMinutes)*time.Minute, config.URLBindClientIP, settingsRepo)
+
+	// Background job queue
+	jobQueue := services.NewJobQueue(jobRepo, config.JobWorkers, config.JobMaxAttempts)
+	services.RegisterMaintenanceJobs(jobQueue, jobRepo, fileRepo, storageService, uploadService)
+	services.RegisterVideoJobs(jobQueue, videoRepo, storageService)
+	services.RegisterRecommendationJobs(jobQueue, similarityRepo, time.Duration(config.RelatedRefreshMinutes)*time.Minute)
+	services.RegisterTrendingJobs(jobQueue, videoRepo, time.Duration(config.TrendingRefreshMinutes)*time.Minute)
+
+	// Initialize handlers
+	healthHandler := handlers.NewHealthHandler(db)
+	authHandler := handlers.NewAuthHandler(userRepo, authService)
+	videoHandler := handlers.NewVideoHandler(videoRepo, viewLogRepo, storageService, jobQueue, urlSigner, playlistRepo, tagRepo, captionRepo, chapterRepo, creatorRepo, time.Duration(config.VersionGraceHours)*time.Hour)
+	uploadHandler := handlers.NewUploadHandler(uploadService, storageService, videoHandler)
+	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
+	adHandler := handlers.NewAdHandler(adRepo, storageService, jobQueue, urlSigner)
+	settingsHandler := handlers.NewSettingsHandler(settingsRepo, urlSigner)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
+	serverHandler := handlers.NewServerHandler(serverService, serverLogRepo)
+	fileOpsHandler := handlers.NewFileOperations(fileRepo, fileService)
+	directoryHandler := handlers.NewDirectoryHandler(fileService)
+	terminalHandler := handlers.NewTerminalHandler(authService) // Pass authService for authentication
+	securityHandler := handlers.NewSecurityHandler()
+	jobHandler := handlers.NewJobHandler(jobRepo, jobQueue)
+	reactionHandler := handlers.NewReactionHandler(reactionRepo, videoRepo)
+	commentHandler := handlers.NewCommentHandler(commentRepo, videoRepo, settingsRepo)
+	playlistHandler := handlers.NewPlaylistHandler(playlistRepo, videoRepo, urlSigner)
+	historyHandler := handlers.NewHistoryHandler(viewLogRepo, videoRepo, urlSigner)
+	tagHandler := handlers.NewTagHandler(tagRepo, videoRepo, urlSigner)
+	captionHandler := handlers.NewCaptionHandler(captionRepo, videoRepo, storageService, jobQueue, urlSigner)
+	chapterHandler := handlers.NewChapterHandler(chapterRepo, videoRepo, urlSigner)
+	creatorHandler := handlers.NewCreatorHandler(creatorRepo, videoRepo, urlSigner)
+	importHandler := handlers.NewImportHandler(importer.NewImporter(videoRepo, categoryRepo, storageService, jobQueue), config.ImportPath)
+	feedHandler := handlers.NewFeedHandler(videoRepo, categoryRepo, creatorRepo, settingsRepo, urlSigner, config.PublicURL, config.FrontendURL)
+	embedHandler := handlers.NewEmbedHandler(videoRepo, settingsRepo, storageService, urlSigner, config.PublicURL, config.FrontendURL)
+	exportHandler := handlers.NewExportHandler(exporter.NewExporter(videoRepo, categoryRepo, adRepo, settingsRepo))
+	sitemapHandler := handlers.NewSitemapHandler(videoRepo, categoryRepo, creatorRepo, urlSigner, config.PublicURL, config.FrontendURL,
+		time.Duration(config.SitemapCacheMinutes)*time.Minute)
+
+	// Create router
+	r := chi.NewRouter()
+
+	// Initialize rate limiters
+	generalLimiter := middleware.NewRateLimiter(100, 1*time.Minute)      // 100 req/min for general API
+	loginLimiter := middleware.NewRateLimiter(5, 1*time.Minute)          // 5 req/min for login
+	uploadLimiter := middleware.NewRateLimiter(10, 1*time.Hour)          // 10 req/hour for uploads
+
+	// Middleware
+	r.Use(middleware.Recovery)
+	r.Use(middleware.Logger)
+	r.Use(middleware.SecurityValidationMiddleware()) // Security validation
+	r.Use(middleware.RateLimitMiddleware(generalLimiter)) // General rate limiting
+
+	// CORS Middleware - Environment-aware security configuration
+	r.Use(cors.Handler(cors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			// Get environment mode
+			env := os.Getenv("ENV")
+
+			// Development mode: Allow localhost and local network
+			if env == "development" {
+				if origin == "" {
+					return true // Allow same-origin requests
+				}
+				// Allow localhost and local IPs
+				if strings.HasPrefix(origin, "http://localhost:") ||
+					strings.HasPrefix(origin, "http://127.0.0.1:") ||
+					strings.HasPrefix(origin, "http://0.0.0.0:") ||
+					strings.HasPrefix(origin, "http://10.") ||
+					strings.HasPrefix(origin, "http://192.168.") ||
+					strings.HasPrefix(origin, "http://172.") {
+					return true
+				}
+			}
+
+			// Production mode: Only allow configured origins
+			allowedOrigins := strings.Split(config.AllowedOrigins, ",")
+			for _, allowed := range allowedOrigins {
+				allowed = strings.TrimSpace(allowed)
+				if allowed != "" && allowed != "*" && origin == allowed {
+					return true
+				}
+			}
+
+			// Also check FRONTEND_URL environment variable
+			frontendURL := os.Getenv("FRONTEND_URL")
+			if frontendURL != "" && origin == frontendURL {
+				return true
+			}
+
+			log.Printf("[CORS] SECURITY: Blocked request from unauthorized origin: %s", origin)
+			return false
+		},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH", "HEAD"},
+		AllowedHeaders: []string{
+			"Accept", "Authorization", "Content-Type", "X-Requested-With", "X-Device-ID",
+			// tus resumable upload headers
+			"Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset",
+		},
+		ExposedHeaders: []string{
+			"Link", "Location",
+			"Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-Video-Id",
+		},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}))
+
+	// Request counting middleware
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serverService.IncrementRequestCount()
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	// Health check endpoints
+	r.Get("/health", healthHandler.HealthCheck)           // Basic health check
+	r.Get("/health/ready", healthHandler.ReadinessCheck)  // Detailed readiness check
+	r.Get("/health/live", healthHandler.LivenessCheck)    // Kubernetes liveness probe
+
+	// RSS and Atom feeds of published videos
+	feedHandler.RegisterRoutes(r)
+
+	// oEmbed, the embeddable player and link preview tags
+	embedHandler.RegisterRoutes(r)
+
+	// XML sitemaps of the watch, category and creator pages
+	sitemapHandler.RegisterRoutes(r)
+
+	// WebSocket routes (no auth required for real-time streaming)
+	serverHandler.RegisterWebSocketRoutes(r)
+
+	// Terminal WebSocket (for interactive shell)
+	r.Get("/ws/terminal", terminalHandler.HandleTerminal)
+
+	// API routes
+	r.Route("/api", func(r chi.Router) {
+		// Public auth routes - with stricter rate limiting
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RateLimitMiddleware(loginLimiter))
+			r.Post("/auth/login", authHandler.Login)
+		})
+
+		// Public per-viewer routes - keyed by user when signed in, device ID
+		// otherwise. Admins also see unpublished videos.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.OptionalAuth(authService))
+			r.Get("/videos", videoHandler.GetAll)
+			r.Get("/videos/search", videoHandler.Search)
+			r.Get("/videos/trending", videoHandler.Trending)
+			r.Get("/videos/{id}", videoHandler.GetByID) // ?playlist= checks playlist visibility
+			r.Post("/videos/{id}/view", videoHandler.IncrementView)
+			reactionHandler.RegisterRoutes(r)
+			commentHandler.RegisterPublicRoutes(r)
+			captionHandler.RegisterPublicRoutes(r)
+			chapterHandler.RegisterPublicRoutes(r)
+			creatorHandler.RegisterPublicRoutes(r)
+			playlistHandler.RegisterRoutes(r)
+			historyHandler.RegisterRoutes(r)
+		})
+
+		// Public category routes
+		r.Get("/categories", categoryHandler.GetAll)
+
+		// Public tag routes
+		tagHandler.RegisterRoutes(r)
+
+		// Public ad routes
+		r.Get("/ads", adHandler.GetAll)
+		r.Get("/ads/stats", adHandler.GetStats)
+		r.Get("/ads/{id}", adHandler.GetByID)
+		r.Post("/ads/{id}/click", adHandler.TrackClick)
+		r.Post("/ads/{id}/impression", adHandler.TrackImpression)
+
+		// Public settings routes
+		r.Get("/settings", settingsHandler.Get)
+
+		// Public security routes
+		r.Get("/check-vpn", securityHandler.CheckVPN)
+
+		// Public file sharing routes
+		fileOpsHandler.RegisterPublicRoutes(r)
+
+		// Protected routes
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AuthMiddleware(authService))
+
+			// Auth verification
+			r.Get("/auth/verify", authHandler.Verify)
+
+			// Video management - with upload rate limiting
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RateLimitMiddleware(uploadLimiter))
+				r.Post("/videos", videoHandler.Create)
+				r.Put("/videos/{id}/media", videoHandler.ReplaceMedia)
+				uploadHandler.RegisterCreateRoute(r)
+			})
+			r.Put("/videos/{id}", videoHandler.Update)
+			r.Delete("/videos/{id}", videoHandler.Delete)
+			r.Post("/videos/{id}/hls", videoHandler.PackageHLS)
+			r.Put("/videos/{id}/thumbnail", videoHandler.ReplaceThumbnail)
+			r.Get("/videos/{id}/versions", videoHandler.Versions)
+			importHandler.RegisterRoutes(r)
+			exportHandler.RegisterRoutes(r)
+			captionHandler.RegisterRoutes(r)
+			chapterHandler.RegisterRoutes(r)
+			creatorHandler.RegisterRoutes(r)
+
+			// Resumable (tus) upload chunks - not rate limited per chunk
+			uploadHandler.RegisterRoutes(r)
+
+			// Category management
+			r.Post("/categories", categoryHandler.Create)
+			r.Put("/categories/{id}", categoryHandler.Update)
+			r.Delete("/categories/{id}", categoryHandler.Delete)
+
+			// Ad management
+			r.Post("/ads", adHandler.Create)
+			r.Put("/ads/{id}", adHandler.Update)
+			r.Patch("/ads/{id}/toggle", adHandler.Toggle)
+			r.Delete("/ads/{id}", adHandler.Delete)
+
+			// Settings management
+			r.Put("/settings", settingsHandler.Update)
+
+			// Analytics
+			r.Get("/analytics", analyticsHandler.GetAnalytics)
+
+			// Server management (protected)
+			serverHandler.RegisterRoutes(r)
+
+			// File management (protected)
+			fileOpsHandler.RegisterRoutes(r)
+
+			// Directory management (protected)
+			directoryHandler.RegisterRoutes(r)
+
+			// Background jobs
+			jobHandler.RegisterRoutes(r)
+
+			// Comment moderation
+			commentHandler.RegisterRoutes(r)
+		})
+	})
+
+	// Serve static files from storage directory; everything outside the public
+	// directories needs a signed link
+	// HLS and WebVTT types aren't in Go's default table; Safari needs the playlist type to play natively
+	mime.AddExtensionType(".m3u8", "application/vnd.apple.mpegurl")
+	mime.AddExtensionType(".m4s", "video/iso.segment")
+	mime.AddExtensionType(".vtt", "text/vtt; charset=utf-8")
+	fileServer := http.FileServer(http.Dir("./storage"))
+	r.With(middleware.SignedStorage(urlSigner)).Handle("/storage/*", http.StripPrefix("/storage/", fileServer))
+
+	// Log server startup
+	serverService.Log("info", "Server starting on port "+config.Port, "main")
+
+	// Start server with graceful shutdown
+	addr := "0.0.0.0:" + config.Port
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      r,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// Server startup info
+	log.Printf("Server starting on http://0.0.0.0:%s", config.Port)
+	log.Printf("Server accessible at http://<your-ip>:%s", config.Port)
+	log.Printf("Environment: %s", config.Env)
+	if config.DatabaseURL != "" {
+		log.Printf("Database: PostgreSQL")
+	} else {
+		log.Printf("Database: SQLite (%s)", config.DatabasePath)
+	}
+	log.Printf("Admin user: %s", config.DefaultAdminUser)
+	log.Printf("Health endpoints:")
+	log.Printf("  - /health (basic)")
+	log.Printf("  - /health/ready (detailed)")
+	log.Printf("  - /health/live (liveness)")
+
+	// Start background workers
+	jobQueue.Start()
+
+	// Start server in goroutine
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// Graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Server is shutting down gracefully...")
+	serverService.Log("info", "Server shutdown initiated", "main")
+
+	// Create shutdown context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Shutdown server
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+		serverService.Log("error", "Server forced shutdown: "+err.Error(), "main")
+	}
+
+	// Let running jobs finish; unfinished ones are requeued for the next start
+	if err := jobQueue.Shutdown(ctx); err != nil {
+		log.Printf("Job queue forced to stop: %v", err)
+	}
+
+	log.Println("Server stopped")
+	serverService.Log("info", "Server stopped successfully", "main")
+}
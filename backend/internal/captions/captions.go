@@ -0,0 +1,246 @@
+// Package captions reads SubRip (SRT) and WebVTT subtitle files and writes
+// WebVTT, the only format HTML5 <track> elements play. SRT cues are converted
+// by re-timing them in WebVTT syntax and translating their markup; WebVTT
+// files are normalized through the same parser so every stored track has
+// validated cue timing.
+package captions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalid is returned, wrapped with the offending line, for files that
+// aren't well-formed SRT or WebVTT
+var ErrInvalid = errors.New("captions: invalid subtitle file")
+
+// Format names returned by Parse
+const (
+	FormatSRT    = "srt"
+	FormatWebVTT = "vtt"
+)
+
+// Cue is one timed piece of text
+type Cue struct {
+	ID       string // Optional identifier, the sequence number in SRT files
+	Start    time.Duration
+	End      time.Duration
+	Settings string // WebVTT cue settings such as "line:0 align:start"
+	Text     string // WebVTT cue text, lines separated by \n
+}
+
+// Parse detects whether data is WebVTT (it starts with the WEBVTT signature)
+// or SRT, and parses it. The cues are in WebVTT syntax either way.
+func Parse(data []byte) ([]Cue, string, error) {
+	lines, err := splitLines(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if isWebVTT(lines) {
+		cues, err := parseVTT(lines)
+		return cues, FormatWebVTT, err
+	}
+	cues, err := parseSRT(lines)
+	return cues, FormatSRT, err
+}
+
+// ParseSRT parses a SubRip file
+func ParseSRT(data []byte) ([]Cue, error) {
+	lines, err := splitLines(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseSRT(lines)
+}
+
+// ParseVTT parses a WebVTT file
+func ParseVTT(data []byte) ([]Cue, error) {
+	lines, err := splitLines(data)
+	if err != nil {
+		return nil, err
+	}
+	if !isWebVTT(lines) {
+		return nil, invalidAt(1, "missing WEBVTT signature")
+	}
+	return parseVTT(lines)
+}
+
+// WriteVTT writes cues as a WebVTT file
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		bw.WriteString("\n")
+		if c.ID != "" {
+			bw.WriteString(c.ID + "\n")
+		}
+		bw.WriteString(FormatTimestamp(c.Start) + " --> " + FormatTimestamp(c.End))
+		if c.Settings != "" {
+			bw.WriteString(" " + c.Settings)
+		}
+		bw.WriteString("\n" + c.Text + "\n")
+	}
+	return bw.Flush()
+}
+
+// FormatTimestamp formats d as a WebVTT timestamp, HH:MM:SS.mmm
+func FormatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// EscapeText escapes plain text, such as a title, for use as WebVTT cue text
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// PlainText strips the markup from cue text and joins its lines, for search
+func PlainText(text string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range text {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
+
+// line is a line of the file with its 1-based number, for error messages
+type line struct {
+	n    int
+	text string
+}
+
+// splitLines checks that data is UTF-8 and splits it into lines, dropping a
+// byte order mark and accepting \r\n, \n and \r line endings
+func splitLines(data []byte) ([]line, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%w: not UTF-8 text", ErrInvalid)
+	}
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	raw := strings.Split(text, "\n")
+	lines := make([]line, len(raw))
+	for i, s := range raw {
+		lines[i] = line{n: i + 1, text: s}
+	}
+	return lines, nil
+}
+
+// blocks groups lines into runs separated by blank lines
+func blocks(lines []line) [][]line {
+	var out [][]line
+	var current []line
+	for _, l := range lines {
+		if strings.TrimSpace(l.text) == "" {
+			if len(current) > 0 {
+				out = append(out, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, l)
+	}
+	if len(current) > 0 {
+		out = append(out, current)
+	}
+	return out
+}
+
+func invalidAt(n int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalid, n, fmt.Sprintf(format, args...))
+}
+
+// cueTiming parses "start --> end[ settings]" with the given timestamp parser
+func cueTiming(l line, parseTimestamp func(string) (time.Duration, bool)) (start, end time.Duration, settings string, err error) {
+	left, right, ok := strings.Cut(l.text, "-->")
+	if !ok {
+		return 0, 0, "", invalidAt(l.n, "expected a cue timing line")
+	}
+	fields := strings.Fields(right)
+	if len(fields) == 0 {
+		return 0, 0, "", invalidAt(l.n, "missing cue end time")
+	}
+	if start, ok = parseTimestamp(strings.TrimSpace(left)); !ok {
+		return 0, 0, "", invalidAt(l.n, "invalid start time %q", strings.TrimSpace(left))
+	}
+	if end, ok = parseTimestamp(fields[0]); !ok {
+		return 0, 0, "", invalidAt(l.n, "invalid end time %q", fields[0])
+	}
+	return start, end, strings.Join(fields[1:], " "), nil
+}
+
+// parseClock reads [H:]MM:SS followed by sep and exactly three millisecond
+// digits. Hours may have any number of digits; minutes and seconds have two.
+func parseClock(s string, sep byte, hoursRequired bool) (time.Duration, bool) {
+	i := strings.IndexByte(s, sep)
+	if i < 0 || len(s)-i-1 != 3 {
+		return 0, false
+	}
+	ms, ok := digits(s[i+1:])
+	if !ok {
+		return 0, false
+	}
+
+	parts := strings.Split(s[:i], ":")
+	if len(parts) < 2 || len(parts) > 3 || (hoursRequired && len(parts) != 3) {
+		return 0, false
+	}
+	var total int64
+	for j, part := range parts {
+		n, ok := digits(part)
+		if !ok {
+			return 0, false
+		}
+		if j > 0 || len(parts) == 2 {
+			// Minutes and seconds
+			if len(part) != 2 || n > 59 {
+				return 0, false
+			}
+		}
+		total = total*60 + n
+	}
+	return time.Duration(total*1000+ms) * time.Millisecond, true
+}
+
+func digits(s string) (int64, bool) {
+	if s == "" || len(s) > 9 {
+		return 0, false
+	}
+	var n int64
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(s[i]-'0')
+	}
+	return n, true
+}
+
+// checkTiming enforces what players rely on: every cue ends after it starts,
+// and cues are in start time order
+func checkTiming(c Cue, previous *Cue, n int) error {
+	if c.End <= c.Start {
+		return invalidAt(n, "cue ends at %s, not after its start at %s", FormatTimestamp(c.End), FormatTimestamp(c.Start))
+	}
+	if previous != nil && c.Start < previous.Start {
+		return invalidAt(n, "cue starts at %s, before the previous cue at %s", FormatTimestamp(c.Start), FormatTimestamp(previous.Start))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package captions
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_ConvertsSRTToWebVTT(t *testing.T) {
+	srt := "\uFEFF1\r\n00:00:01,000 --> 00:00:03,500\r\n<i>Hello</i> & <font color=\"red\">welcome</font>\r\n\r\n" +
+		"2\r\n00:00:04,000 --> 00:00:06,000 X1:10 X2:100 Y1:10 Y2:50\r\n{\\an8}Fish <3 chips -> 5 > 4\r\n\r\n" +
+		"3\r\n00:00:06,000 --> 01:02:03,004\r\nFirst paragraph\r\n\r\nafter a stray blank line &amp; more\r\n\r\n" +
+		"4\r\n00:00:07,000 --> 00:00:08,000\r\n\r\n"
+
+	cues, format, err := Parse([]byte(srt))
+	require.NoError(t, err)
+	assert.Equal(t, FormatSRT, format)
+	require.Len(t, cues, 3)
+
+	assert.Equal(t, "1", cues[0].ID)
+	assert.Equal(t, time.Second, cues[0].Start)
+	assert.Equal(t, 3500*time.Millisecond, cues[0].End)
+	assert.Equal(t, "<i>Hello</i> &amp; welcome", cues[0].Text)
+	assert.Equal(t, "", cues[1].Settings)
+	assert.Equal(t, "Fish &lt;3 chips -&gt; 5 &gt; 4", cues[1].Text)
+	assert.Equal(t, "First paragraph\nafter a stray blank line &amp; more", cues[2].Text)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteVTT(&out, cues[1:]))
+	assert.Equal(t, "WEBVTT\n\n"+
+		"2\n00:00:04.000 --> 00:00:06.000\nFish &lt;3 chips -&gt; 5 &gt; 4\n\n"+
+		"3\n00:00:06.000 --> 01:02:03.004\nFirst paragraph\nafter a stray blank line &amp; more\n", out.String())
+
+	assert.Equal(t, "Hello & welcome", PlainText(cues[0].Text))
+}
+
+func TestParse_ReadsWebVTT(t *testing.T) {
+	vtt := "WEBVTT - with a title\nKind: captions\n\n" +
+		"STYLE\n::cue { color: yellow }\n\n" +
+		"NOTE this is a comment\nspanning lines\n\n" +
+		"intro\n00:01.000 --> 00:04.000 align:start line:0\n<v Ann>Hi <b>there</b>\nsecond line\n\n" +
+		"1:00:00.250 --> 1:00:01.000\nLate\n"
+
+	cues, format, err := Parse([]byte(vtt))
+	require.NoError(t, err)
+	assert.Equal(t, FormatWebVTT, format)
+	require.Len(t, cues, 2)
+	assert.Equal(t, Cue{
+		ID:       "intro",
+		Start:    time.Second,
+		End:      4 * time.Second,
+		Settings: "align:start line:0",
+		Text:     "<v Ann>Hi <b>there</b>\nsecond line",
+	}, cues[0])
+	assert.Equal(t, time.Hour+250*time.Millisecond, cues[1].Start)
+	assert.Equal(t, "Hi there second line", PlainText(cues[0].Text))
+
+	var out bytes.Buffer
+	require.NoError(t, WriteVTT(&out, cues))
+	again, err := ParseVTT(out.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, cues, again)
+}
+
+func TestParse_RejectsBadTiming(t *testing.T) {
+	tests := []struct {
+		name, input, message string
+	}{
+		{"end before start", "1\n00:00:05,000 --> 00:00:04,000\nText\n", "line 2: cue ends at 00:00:04.000, not after its start at 00:00:05.000"},
+		{"out of order", "1\n00:00:05,000 --> 00:00:06,000\nA\n\n2\n00:00:01,000 --> 00:00:02,000\nB\n", "line 6: cue starts at 00:00:01.000, before the previous cue"},
+		{"bad seconds", "1\n00:00:75,000 --> 00:00:76,000\nText\n", `line 2: invalid start time "00:00:75,000"`},
+		{"vtt needs dot", "WEBVTT\n\n00:01,000 --> 00:02.000\nText\n", `line 3: invalid start time "00:01,000"`},
+		{"srt needs hours", "1\n00:01,000 --> 00:00:02,000\nText\n", `line 2: invalid start time "00:01,000"`},
+		{"missing timing", "1\nText without timing\n", "line 1: expected a cue number followed by a timing line"},
+		{"merged vtt cues", "WEBVTT\n\n00:01.000 --> 00:02.000\nA\n00:02.000 --> 00:03.000\nB\n", "line 5: cue text can't contain"},
+		{"empty", "WEBVTT\n\nNOTE nothing here\n", "no cues"},
+		{"not utf-8", "1\n00:00:01,000 --> 00:00:02,000\nCaf\xe9\n", "not UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Parse([]byte(tt.input))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalid))
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package captions
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// parseSRT reads SubRip cues: an optional sequence number, a timing line
+// with comma (or, in some exporters' output, dot) milliseconds, and text.
+// Cues without text are dropped.
+func parseSRT(lines []line) ([]Cue, error) {
+	var cues []Cue
+	for _, block := range blocks(lines) {
+		timing := 0
+		if !strings.Contains(block[0].text, "-->") {
+			timing = 1
+		}
+		if timing >= len(block) || !strings.Contains(block[timing].text, "-->") {
+			// A blank line inside a cue's text splits it in two; the part
+			// after it belongs to the previous cue
+			if len(cues) > 0 && !blockHasTiming(block) {
+				previous := &cues[len(cues)-1]
+				previous.Text += "\n" + srtText(block)
+				continue
+			}
+			return nil, invalidAt(block[0].n, "expected a cue number followed by a timing line")
+		}
+
+		// Anything after the end time is SRT's X1:Y1 box coordinates, which
+		// have no WebVTT equivalent
+		start, end, _, err := cueTiming(block[timing], parseSRTTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		cue := Cue{Start: start, End: end, Text: srtText(block[timing+1:])}
+		if timing == 1 {
+			cue.ID = strings.TrimSpace(block[0].text)
+		}
+		if cue.Text == "" {
+			continue
+		}
+
+		var previous *Cue
+		if len(cues) > 0 {
+			previous = &cues[len(cues)-1]
+		}
+		if err := checkTiming(cue, previous, block[timing].n); err != nil {
+			return nil, err
+		}
+		cues = append(cues, cue)
+	}
+
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("%w: no cues", ErrInvalid)
+	}
+	return cues, nil
+}
+
+func blockHasTiming(block []line) bool {
+	for _, l := range block {
+		if strings.Contains(l.text, "-->") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSRTTimestamp(s string) (time.Duration, bool) {
+	if d, ok := parseClock(s, ',', true); ok {
+		return d, true
+	}
+	return parseClock(s, '.', true)
+}
+
+// srtText converts the lines of an SRT cue to WebVTT cue text
+func srtText(lines []line) string {
+	var out []string
+	for _, l := range lines {
+		if text := srtMarkup(l.text); text != "" {
+			out = append(out, text)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// srtMarkup translates one line of SRT text. <b>, <i> and <u> mean the same
+// in WebVTT and are kept; <font> tags and {\an8} style positioning overrides
+// have no equivalent and are dropped. Everything else is escaped, since a
+// bare < or & would start WebVTT markup and "-->" would end the cue.
+func srtMarkup(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				tag := strings.ToLower(strings.TrimSpace(s[i+1 : i+end]))
+				name := strings.TrimPrefix(tag, "/")
+				if name == "b" || name == "i" || name == "u" {
+					b.WriteString("<" + tag + ">")
+					i += end + 1
+					continue
+				}
+				if name == "font" || strings.HasPrefix(name, "font ") {
+					i += end + 1
+					continue
+				}
+			}
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '&':
+			if entity := entityAt(s[i:]); entity != "" {
+				b.WriteString(entity)
+				i += len(entity)
+				continue
+			}
+			b.WriteString("&amp;")
+		case '{':
+			if strings.HasPrefix(s[i:], `{\`) {
+				if end := strings.IndexByte(s[i:], '}'); end > 0 {
+					i += end + 1
+					continue
+				}
+			}
+			b.WriteByte('{')
+		default:
+			b.WriteByte(s[i])
+		}
+		i++
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// entityAt returns the character reference at the start of s, such as &amp;
+// or &#233;, or "" if s doesn't start with one
+func entityAt(s string) string {
+	end := strings.IndexByte(s, ';')
+	if end < 2 || end > 10 {
+		return ""
+	}
+	for i := 1; i < end; i++ {
+		c := s[i]
+		if !(c == '#' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return ""
+		}
+	}
+	if html.UnescapeString(s[:end+1]) == s[:end+1] {
+		return ""
+	}
+	return s[:end+1]
+}
@@ -0,0 +1,73 @@
+package captions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func isWebVTT(lines []line) bool {
+	return len(lines) > 0 && isKeywordLine(lines[0].text, "WEBVTT")
+}
+
+// isKeywordLine reports whether s is keyword alone or followed by a space or tab
+func isKeywordLine(s, keyword string) bool {
+	rest, ok := strings.CutPrefix(s, keyword)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// parseVTT reads WebVTT cues after the header block. Comments, style sheets
+// and region definitions are dropped: they aren't cues, and stored tracks
+// are styled by the player.
+func parseVTT(lines []line) ([]Cue, error) {
+	var cues []Cue
+	for _, block := range blocks(lines)[1:] {
+		first := block[0].text
+		if isKeywordLine(first, "NOTE") || isKeywordLine(first, "STYLE") || isKeywordLine(first, "REGION") {
+			continue
+		}
+
+		timing := 0
+		if !strings.Contains(first, "-->") {
+			timing = 1
+		}
+		if timing >= len(block) {
+			return nil, invalidAt(block[0].n, "expected a cue timing line")
+		}
+		start, end, settings, err := cueTiming(block[timing], parseVTTTimestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		text := make([]string, 0, len(block)-timing-1)
+		for _, l := range block[timing+1:] {
+			if strings.Contains(l.text, "-->") {
+				return nil, invalidAt(l.n, "cue text can't contain \"-->\"; is a blank line missing before this cue?")
+			}
+			text = append(text, l.text)
+		}
+		cue := Cue{Start: start, End: end, Settings: settings, Text: strings.Join(text, "\n")}
+		if timing == 1 {
+			cue.ID = first
+		}
+
+		var previous *Cue
+		if len(cues) > 0 {
+			previous = &cues[len(cues)-1]
+		}
+		if err := checkTiming(cue, previous, block[timing].n); err != nil {
+			return nil, err
+		}
+		cues = append(cues, cue)
+	}
+
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("%w: no cues", ErrInvalid)
+	}
+	return cues, nil
+}
+
+// parseVTTTimestamp reads [HH:]MM:SS.mmm
+func parseVTTTimestamp(s string) (time.Duration, bool) {
+	return parseClock(s, '.', false)
+}
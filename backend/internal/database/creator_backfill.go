@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"titan-backend/internal/models"
+)
+
+// backfillCreators moves videos from free-text creator names to the creators
+// table. Names with the same slug ("John Doe", "john doe ") become one
+// creator, named by their most used spelling and verified if any of their
+// videos was; the videos get that spelling too. The per-video verified
+// column is dropped once every video has a creator.
+func backfillCreators(db *sql.DB) error {
+	var hasVerified int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info('videos') WHERE name = 'verified'",
+	).Scan(&hasVerified); err != nil {
+		return err
+	}
+	verifiedColumn := "0"
+	if hasVerified > 0 {
+		verifiedColumn = "COALESCE(verified, 0)"
+	}
+
+	rows, err := db.Query("SELECT id, creator, " + verifiedColumn + " FROM videos WHERE creator_id IS NULL ORDER BY id")
+	if err != nil {
+		return err
+	}
+
+	type group struct {
+		spellings map[string]int
+		first     []string // Spellings in order of appearance, for ties
+		verified  bool
+		videoIDs  []int
+	}
+	groups := map[string]*group{}
+	var slugs []string
+	videos := 0
+	for rows.Next() {
+		var id, verified int
+		var name string
+		if err := rows.Scan(&id, &name, &verified); err != nil {
+			rows.Close()
+			return err
+		}
+		name = strings.TrimSpace(name)
+		slug := models.Slugify(name)
+		if slug == "" {
+			slug, name = "unknown", "Unknown"
+		}
+		g, ok := groups[slug]
+		if !ok {
+			g = &group{spellings: map[string]int{}}
+			groups[slug] = g
+			slugs = append(slugs, slug)
+		}
+		if g.spellings[name] == 0 {
+			g.first = append(g.first, name)
+		}
+		g.spellings[name]++
+		g.verified = g.verified || verified == 1
+		g.videoIDs = append(g.videoIDs, id)
+		videos++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(slugs) > 0 {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, slug := range slugs {
+			g := groups[slug]
+			name := g.first[0]
+			for _, spelling := range g.first {
+				if g.spellings[spelling] > g.spellings[name] {
+					name = spelling
+				}
+			}
+			verified := 0
+			if g.verified {
+				verified = 1
+			}
+
+			// A creator made since the last run keeps their name and flag
+			if _, err := tx.Exec(
+				"INSERT INTO creators (slug, name, verified) VALUES (?, ?, ?) ON CONFLICT (slug) DO NOTHING",
+				slug, name, verified,
+			); err != nil {
+				return err
+			}
+			var creatorID int
+			if err := tx.QueryRow("SELECT id, name FROM creators WHERE slug = ?", slug).Scan(&creatorID, &name); err != nil {
+				return err
+			}
+			for _, id := range g.videoIDs {
+				if _, err := tx.Exec("UPDATE videos SET creator_id = ?, creator = ? WHERE id = ?", creatorID, name, id); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Moved %d videos to %d creators", videos, len(slugs))
+	}
+
+	if hasVerified > 0 {
+		if _, err := db.Exec("ALTER TABLE videos DROP COLUMN verified"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+
+	"titan-backend/internal/mediaprobe"
+)
+
+// backfillDurationSeconds parses the text duration into duration_seconds for
+// videos that were never probed (external URLs, uploads from before media
+// probing), so duration filters and sorting include them. Durations that
+// don't parse are left at zero and match no duration bucket.
+func backfillDurationSeconds(db *sql.DB) error {
+	rows, err := db.Query(
+		`SELECT id, duration FROM videos
+		 WHERE COALESCE(duration_seconds, 0) = 0 AND COALESCE(duration, '') != ''`,
+	)
+	if err != nil {
+		return err
+	}
+
+	durations := map[int]float64{}
+	for rows.Next() {
+		var id int
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return err
+		}
+		if seconds, ok := mediaprobe.ParseDuration(text); ok {
+			durations[id] = seconds
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, seconds := range durations {
+		if _, err := db.Exec("UPDATE videos SET duration_seconds = ? WHERE id = ?", seconds, id); err != nil {
+			return err
+		}
+	}
+	if len(durations) > 0 {
+		log.Printf("Parsed durations for %d videos", len(durations))
+	}
+	return nil
+}
@@ -141,6 +141,187 @@ func RunMigrations(db *sql.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_file_shares_token ON file_shares(token)`,
 		`CREATE INDEX IF NOT EXISTS idx_file_shares_path ON file_shares(file_path)`,
+
+		// Background jobs table
+		`CREATE TABLE IF NOT EXISTS jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL DEFAULT '{}',
+			status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'running', 'succeeded', 'dead', 'cancelled')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 5,
+			last_error TEXT,
+			run_at DATETIME NOT NULL,
+			locked_at DATETIME,
+			finished_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(status, run_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs(type, status)`,
+
+		// Video reactions table (one like/dislike per viewer per video)
+		`CREATE TABLE IF NOT EXISTS video_reactions (
+			video_id INTEGER NOT NULL,
+			viewer_id TEXT NOT NULL,
+			reaction TEXT NOT NULL CHECK(reaction IN ('like', 'dislike')),
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (video_id, viewer_id),
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_reactions_viewer ON video_reactions(viewer_id)`,
+
+		// Comments table (threaded via parent_id, soft deleted via deleted_at)
+		`CREATE TABLE IF NOT EXISTS comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			video_id INTEGER NOT NULL,
+			parent_id INTEGER,
+			author_id TEXT NOT NULL,
+			author_name TEXT NOT NULL,
+			body TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'approved' CHECK(status IN ('pending', 'approved', 'rejected')),
+			pinned INTEGER DEFAULT 0,
+			edited_at DATETIME,
+			deleted_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+			FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_video ON comments(video_id, parent_id, status, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_status ON comments(status, created_at)`,
+
+		// Previous versions of edited comments
+		`CREATE TABLE IF NOT EXISTS comment_edits (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			comment_id INTEGER NOT NULL,
+			body TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_comment_edits_comment ON comment_edits(comment_id)`,
+
+		// Playlists table (owner is a viewer ID: user:<id> or device:<id>)
+		`CREATE TABLE IF NOT EXISTS playlists (
+			id TEXT PRIMARY KEY,
+			owner_id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			visibility TEXT NOT NULL DEFAULT 'private' CHECK(visibility IN ('public', 'unlisted', 'private')),
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_playlists_owner ON playlists(owner_id, updated_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_playlists_visibility ON playlists(visibility, updated_at)`,
+
+		// Playlist items, ordered by position (0-based)
+		`CREATE TABLE IF NOT EXISTS playlist_items (
+			playlist_id TEXT NOT NULL,
+			video_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (playlist_id, video_id),
+			FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE,
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_playlist_items_position ON playlist_items(playlist_id, position)`,
+		`CREATE INDEX IF NOT EXISTS idx_playlist_items_video ON playlist_items(video_id)`,
+
+		// Tags, identified by their normalized slug
+		`CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug TEXT UNIQUE NOT NULL,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS video_tags (
+			video_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (video_id, tag_id),
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_tags_tag ON video_tags(tag_id, video_id)`,
+
+		// Co-view recommendations, rebuilt from view_logs by a background job
+		`CREATE TABLE IF NOT EXISTS video_similarity (
+			video_id INTEGER NOT NULL,
+			similar_video_id INTEGER NOT NULL,
+			score REAL NOT NULL,
+			co_viewers INTEGER NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (video_id, similar_video_id),
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+			FOREIGN KEY (similar_video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_view_logs_viewed_at ON view_logs(viewed_at)`,
+
+		// Caption tracks, stored as WebVTT files
+		`CREATE TABLE IF NOT EXISTS video_captions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			video_id INTEGER NOT NULL,
+			language TEXT NOT NULL,
+			label TEXT NOT NULL,
+			kind TEXT NOT NULL DEFAULT 'subtitles',
+			is_default INTEGER DEFAULT 0,
+			url TEXT NOT NULL,
+			cue_count INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (video_id, language, kind),
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		// Cue text without markup, for searching inside a video
+		`CREATE TABLE IF NOT EXISTS video_caption_cues (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			caption_id INTEGER NOT NULL,
+			video_id INTEGER NOT NULL,
+			start_ms INTEGER NOT NULL,
+			end_ms INTEGER NOT NULL,
+			text TEXT NOT NULL,
+			FOREIGN KEY (caption_id) REFERENCES video_captions(id) ON DELETE CASCADE,
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_caption_cues_caption ON video_caption_cues(caption_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_caption_cues_video ON video_caption_cues(video_id, start_ms)`,
+
+		// Chapters set through the API; without them they come from the description
+		`CREATE TABLE IF NOT EXISTS video_chapters (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			video_id INTEGER NOT NULL,
+			start_seconds REAL NOT NULL,
+			title TEXT NOT NULL,
+			thumbnail TEXT DEFAULT '',
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_chapters_video ON video_chapters(video_id, start_seconds)`,
+		// Media files and thumbnails replaced in place, kept until purge_at
+		`CREATE TABLE IF NOT EXISTS video_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			video_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			url TEXT NOT NULL,
+			hls_url TEXT DEFAULT '',
+			file_size INTEGER DEFAULT 0,
+			replaced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			purge_at DATETIME NOT NULL,
+			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_video_versions_video ON video_versions(video_id, replaced_at)`,
+		// Creators, identified by the slug of their name
+		`CREATE TABLE IF NOT EXISTS creators (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			avatar TEXT DEFAULT '',
+			bio TEXT DEFAULT '',
+			verified INTEGER DEFAULT 0,
+			links TEXT DEFAULT '[]',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
 	}
 
 	for _, migration := range migrations {
@@ -154,6 +335,40 @@ func RunMigrations(db *sql.DB) error {
 	optionalMigrations := []string{
 		`ALTER TABLE ads ADD COLUMN clicks INTEGER DEFAULT 0`,
 		`ALTER TABLE ads ADD COLUMN impressions INTEGER DEFAULT 0`,
+		// Media metadata extracted by mediaprobe
+		`ALTER TABLE videos ADD COLUMN duration_seconds REAL`,
+		`ALTER TABLE videos ADD COLUMN width INTEGER`,
+		`ALTER TABLE videos ADD COLUMN height INTEGER`,
+		`ALTER TABLE videos ADD COLUMN video_codec TEXT`,
+		`ALTER TABLE videos ADD COLUMN audio_codec TEXT`,
+		`ALTER TABLE videos ADD COLUMN bitrate INTEGER`,
+		`ALTER TABLE videos ADD COLUMN file_size INTEGER`,
+		`ALTER TABLE videos ADD COLUMN hls_url TEXT`,
+		// Per-viewer watch progress on view logs
+		`ALTER TABLE view_logs ADD COLUMN viewer_id TEXT`,
+		`ALTER TABLE view_logs ADD COLUMN position_seconds REAL DEFAULT 0`,
+		`ALTER TABLE view_logs ADD COLUMN duration_seconds REAL DEFAULT 0`,
+		`ALTER TABLE view_logs ADD COLUMN completed INTEGER DEFAULT 0`,
+		`ALTER TABLE view_logs ADD COLUMN updated_at DATETIME`,
+		`CREATE INDEX IF NOT EXISTS idx_view_logs_viewer ON view_logs(viewer_id, video_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_videos_duration ON videos(duration_seconds)`,
+		// Tag names copied onto the video for full-text search
+		`ALTER TABLE videos ADD COLUMN search_tags TEXT DEFAULT ''`,
+		// Decayed recent views, refreshed by a background job
+		`ALTER TABLE videos ADD COLUMN trending_score REAL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_videos_trending ON videos(trending_score)`,
+		// Publishing lifecycle; existing videos stay public
+		`ALTER TABLE videos ADD COLUMN status TEXT NOT NULL DEFAULT 'published'`,
+		`ALTER TABLE videos ADD COLUMN publish_at DATETIME`,
+		`CREATE INDEX IF NOT EXISTS idx_videos_status ON videos(status, publish_at)`,
+		// Caption text copied onto the video for full-text search
+		`ALTER TABLE videos ADD COLUMN search_captions TEXT DEFAULT ''`,
+		// The video's creator; the creator column keeps a copy of their name
+		`ALTER TABLE videos ADD COLUMN creator_id INTEGER REFERENCES creators(id)`,
+		`CREATE INDEX IF NOT EXISTS idx_videos_creator_id ON videos(creator_id, status)`,
+		// ID of the video in the catalog it was imported from, so re-imports skip it
+		`ALTER TABLE videos ADD COLUMN external_id TEXT`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_videos_external_id ON videos(external_id)`,
 	}
 
 	for _, migration := range optionalMigrations {
@@ -161,6 +376,18 @@ func RunMigrations(db *sql.DB) error {
 		db.Exec(migration)
 	}
 
+	if err := backfillDurationSeconds(db); err != nil {
+		return err
+	}
+
+	if err := backfillCreators(db); err != nil {
+		return err
+	}
+
+	if err := setupVideoSearch(db); err != nil {
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// videoSearchTriggers keep videos_fts in sync with the videos table. The
+// index uses videos as its external content, so only the tokens are stored.
+var videoSearchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS videos_fts_insert AFTER INSERT ON videos BEGIN
+		INSERT INTO videos_fts(rowid, title, creator, description, search_tags, search_captions)
+		VALUES (new.id, new.title, new.creator, new.description, new.search_tags, new.search_captions);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS videos_fts_delete AFTER DELETE ON videos BEGIN
+		INSERT INTO videos_fts(videos_fts, rowid, title, creator, description, search_tags, search_captions)
+		VALUES ('delete', old.id, old.title, old.creator, old.description, old.search_tags, old.search_captions);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS videos_fts_update AFTER UPDATE OF title, creator, description, search_tags, search_captions ON videos BEGIN
+		INSERT INTO videos_fts(videos_fts, rowid, title, creator, description, search_tags, search_captions)
+		VALUES ('delete', old.id, old.title, old.creator, old.description, old.search_tags, old.search_captions);
+		INSERT INTO videos_fts(rowid, title, creator, description, search_tags, search_captions)
+		VALUES (new.id, new.title, new.creator, new.description, new.search_tags, new.search_captions);
+	END`,
+}
+
+// videoSearchTriggerNames lists the triggers for dropping them
+var videoSearchTriggerNames = []string{"videos_fts_insert", "videos_fts_delete", "videos_fts_update"}
+
+// setupVideoSearch creates the FTS5 index over video titles, creators,
+// descriptions, tags and caption text. FTS5 is only compiled into go-sqlite3
+// with the sqlite_fts5 build tag; without it the sync triggers are dropped
+// (they would make every write to videos fail) and search falls back to LIKE
+// matching. The index is rebuilt whenever the triggers are (re)created, so it
+// catches up with any changes made while they were missing.
+func setupVideoSearch(db *sql.DB) error {
+	if err := dropOutdatedVideoSearch(db); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS videos_fts USING fts5(
+		title, creator, description, search_tags, search_captions,
+		content='videos', content_rowid='id',
+		tokenize='porter unicode61 remove_diacritics 2'
+	)`)
+	if err != nil {
+		if !strings.Contains(err.Error(), "no such module") {
+			return err
+		}
+		log.Println("WARNING: SQLite was built without FTS5 (build with -tags sqlite_fts5); video search falls back to LIKE matching")
+		return dropVideoSearchTriggers(db)
+	}
+
+	var triggers int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'videos_fts_%'",
+	).Scan(&triggers); err != nil {
+		return err
+	}
+	if triggers == len(videoSearchTriggers) {
+		return nil
+	}
+
+	for _, trigger := range videoSearchTriggers {
+		if _, err := db.Exec(trigger); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec("INSERT INTO videos_fts(videos_fts) VALUES ('rebuild')"); err != nil {
+		return err
+	}
+	log.Println("Video search index rebuilt")
+	return nil
+}
+
+func dropVideoSearchTriggers(db *sql.DB) error {
+	for _, name := range videoSearchTriggerNames {
+		if _, err := db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropOutdatedVideoSearch drops an index created before tags or captions were
+// searchable. FTS5 tables can't gain columns, so it is recreated and rebuilt.
+func dropOutdatedVideoSearch(db *sql.DB) error {
+	var schema string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'videos_fts'").Scan(&schema)
+	if err == sql.ErrNoRows || (err == nil && strings.Contains(schema, "search_captions")) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := dropVideoSearchTriggers(db); err != nil {
+		return err
+	}
+	_, err = db.Exec("DROP TABLE videos_fts")
+	return err
+}
@@ -0,0 +1,272 @@
+// Package exporter writes the catalog — videos, categories, ads and
+// settings — as JSON, CSV or NDJSON. Records are read through the model
+// repositories and written as they're read, videos a page at a time, so
+// exports of any size stream in constant memory.
+//
+// Video records use the columns of the importer's manifests, so an export
+// can be imported into another site: each video's external ID is the one it
+// was imported with, or "titan-" and its ID.
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+// Export formats
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson" // One JSON object per line
+)
+
+// Exported entities
+const (
+	EntityVideos     = "videos"
+	EntityCategories = "categories"
+	EntityAds        = "ads"
+	EntitySettings   = "settings"
+)
+
+// ContentTypes maps the formats to their media types
+var ContentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+var (
+	// ErrUnknownEntity is returned for entities other than the Entity constants
+	ErrUnknownEntity = errors.New("unknown export entity")
+	// ErrUnknownFormat is returned for formats other than the Format constants
+	ErrUnknownFormat = errors.New("unknown export format")
+)
+
+// pageSize is how many videos are read per query
+const pageSize = 500
+
+// columns lists each entity's fields, as JSON keys; CSV headers are the
+// snake_case of the same names
+var columns = map[string][]string{
+	EntityVideos: {
+		// The importer's manifest columns
+		"externalId", "title", "creator", "category", "description", "file", "url", "thumbnail", "duration",
+		"status", "publishAt",
+		// Skipped on import
+		"id", "views", "likes", "dislikes", "createdAt",
+	},
+	EntityCategories: {"id", "name", "icon", "videoCount", "createdAt"},
+	EntityAds:        {"id", "title", "imageUrl", "targetUrl", "placement", "enabled", "clicks", "impressions", "createdAt", "updatedAt"},
+	EntitySettings: {
+		"siteName", "siteDescription", "maintenanceMode", "allowNewUploads", "featuredVideoId",
+		"commentModeration", "publicStoragePaths",
+	},
+}
+
+// Options selects the format and the records of an export. Category narrows
+// videos and categories; From and To narrow videos and ads by creation time.
+type Options struct {
+	Format   string
+	Category string
+	From     *time.Time
+	To       *time.Time // Exclusive
+	// IncludeMedia adds the paths of stored files: video files, thumbnails
+	// and ad images. External URLs are always included. Without it, stored
+	// videos have neither a file nor a URL, so the export can't be imported.
+	IncludeMedia bool
+}
+
+// Exporter writes catalog exports
+type Exporter struct {
+	videoRepo    *models.VideoRepository
+	categoryRepo *models.CategoryRepository
+	adRepo       *models.AdRepository
+	settingsRepo *models.SettingsRepository
+}
+
+// NewExporter creates an exporter
+func NewExporter(
+	videoRepo *models.VideoRepository,
+	categoryRepo *models.CategoryRepository,
+	adRepo *models.AdRepository,
+	settingsRepo *models.SettingsRepository,
+) *Exporter {
+	return &Exporter{
+		videoRepo:    videoRepo,
+		categoryRepo: categoryRepo,
+		adRepo:       adRepo,
+		settingsRepo: settingsRepo,
+	}
+}
+
+// Valid checks an entity and format before anything is written
+func Valid(entity, format string) error {
+	if _, ok := columns[entity]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownEntity, entity)
+	}
+	if _, ok := ContentTypes[format]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	return nil
+}
+
+// Export writes the entity's records to w. Videos of every status are
+// included, oldest first.
+func (e *Exporter) Export(w io.Writer, entity string, opts Options) error {
+	if err := Valid(entity, opts.Format); err != nil {
+		return err
+	}
+	rw, err := newRecordWriter(w, opts.Format, columns[entity])
+	if err != nil {
+		return err
+	}
+
+	switch entity {
+	case EntityVideos:
+		err = e.exportVideos(rw, opts)
+	case EntityCategories:
+		err = e.exportCategories(rw, opts)
+	case EntityAds:
+		err = e.exportAds(rw, opts)
+	case EntitySettings:
+		err = e.exportSettings(rw)
+	}
+	if err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+func (e *Exporter) exportVideos(rw recordWriter, opts Options) error {
+	q := models.VideoQuery{
+		VideoFilter: models.VideoFilter{
+			Category:    opts.Category,
+			From:        opts.From,
+			To:          opts.To,
+			AllStatuses: true,
+		},
+		PaginationParams: utils.PaginationParams{Page: 1, Limit: pageSize},
+		Sort:             "created_at",
+		Order:            "asc",
+	}
+	for {
+		videos, meta, err := e.videoRepo.GetAll(q)
+		if err != nil {
+			return err
+		}
+		for i := range videos {
+			if err := rw.Write(videoRecord(&videos[i], opts.IncludeMedia)); err != nil {
+				return err
+			}
+		}
+		if !meta.HasNext {
+			return nil
+		}
+		if q.Cursor, err = utils.DecodeCursor(meta.NextCursor); err != nil {
+			return err
+		}
+	}
+}
+
+func videoRecord(v *models.Video, includeMedia bool) []interface{} {
+	externalID := v.ExternalID
+	if externalID == "" {
+		externalID = fmt.Sprintf("titan-%d", v.ID)
+	}
+
+	// Stored videos go in the file column, external links in url
+	file, url := mediaPath(v.URL, includeMedia), v.URL
+	if !isWebURL(v.URL) {
+		url = ""
+	} else {
+		file = ""
+	}
+
+	// Scheduled videos whose time has passed are about to be published, and
+	// the importer only schedules videos for the future
+	status, publishAt := v.Status, ""
+	if status == models.VideoStatusScheduled && v.PublishAt != nil {
+		if v.PublishAt.After(time.Now()) {
+			publishAt = v.PublishAt.UTC().Format(time.RFC3339)
+		} else {
+			status = models.VideoStatusPublished
+		}
+	}
+
+	return []interface{}{
+		externalID, v.Title, v.Creator, v.Category, v.Description, file, url,
+		mediaPath(v.Thumbnail, includeMedia), v.Duration, status, publishAt,
+		v.ID, v.Views, v.Likes, v.Dislikes, v.CreatedAt,
+	}
+}
+
+func (e *Exporter) exportCategories(rw recordWriter, opts Options) error {
+	categories, err := e.categoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		if opts.Category != "" && c.ID != opts.Category {
+			continue
+		}
+		if err := rw.Write([]interface{}{c.ID, c.Name, c.Icon, c.VideoCount, c.CreatedAt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) exportAds(rw recordWriter, opts Options) error {
+	ads, err := e.adRepo.GetAll("", nil)
+	if err != nil {
+		return err
+	}
+	// The repository lists the newest first
+	for i := len(ads) - 1; i >= 0; i-- {
+		a := &ads[i]
+		if (opts.From != nil && a.CreatedAt.Before(*opts.From)) || (opts.To != nil && !a.CreatedAt.Before(*opts.To)) {
+			continue
+		}
+		if err := rw.Write([]interface{}{
+			a.ID, a.Title, mediaPath(a.ImageURL, opts.IncludeMedia), a.TargetURL, a.Placement, a.Enabled,
+			a.Clicks, a.Impressions, a.CreatedAt, a.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) exportSettings(rw recordWriter) error {
+	s, err := e.settingsRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	return rw.Write([]interface{}{
+		s.SiteName, s.SiteDescription, s.MaintenanceMode, s.AllowNewUploads, s.FeaturedVideoID,
+		s.CommentModeration, s.PublicStoragePaths,
+	})
+}
+
+// mediaPath returns a file reference for export. External URLs are kept;
+// stored files become paths relative to the server's working directory, as
+// the importer reads them, and only with includeMedia.
+func mediaPath(value string, includeMedia bool) string {
+	switch {
+	case value == "", isWebURL(value):
+		return value
+	case includeMedia:
+		return strings.TrimPrefix(value, "/")
+	}
+	return ""
+}
+
+func isWebURL(value string) bool {
+	return strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://")
+}
@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/importer"
+	"titan-backend/internal/models"
+)
+
+func newTestExporter(t *testing.T) (*Exporter, *models.VideoRepository) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "export.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.RunMigrations(db))
+
+	categoryRepo := models.NewCategoryRepository(db)
+	require.NoError(t, categoryRepo.Create(&models.Category{ID: "music", Name: "Music", Icon: "🎵"}))
+	videoRepo := models.NewVideoRepository(db)
+	return NewExporter(videoRepo, categoryRepo, models.NewAdRepository(db), models.NewSettingsRepository(db)), videoRepo
+}
+
+func TestExport_VideosRoundTrip(t *testing.T) {
+	exp, videoRepo := newTestExporter(t)
+	publishAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	overdue := time.Now().Add(-time.Hour)
+	require.NoError(t, videoRepo.CreateBatch([]*models.Video{
+		{
+			ExternalID: "a1", Title: `Live, "unplugged"`, Creator: "Ann", Category: "music",
+			Description: "Two\nlines", URL: "https://cdn.example.com/1.mp4", Duration: "3:05",
+		},
+		{
+			Title: "Stored", Creator: "Bob", Category: "music",
+			URL: "/uploads/videos/2.mp4", Thumbnail: "/uploads/thumbnails/2.jpg", Status: models.VideoStatusDraft,
+		},
+		{
+			ExternalID: "s1", Title: "Soon", Creator: "Cy", Category: "music", URL: "https://cdn.example.com/3.mp4",
+			Status: models.VideoStatusScheduled, PublishAt: &publishAt,
+		},
+		{
+			ExternalID: "s2", Title: "Overdue", Creator: "Cy", Category: "music", URL: "https://cdn.example.com/4.mp4",
+			Status: models.VideoStatusScheduled, PublishAt: &overdue,
+		},
+	}))
+
+	for _, format := range []string{FormatJSON, FormatCSV, FormatNDJSON} {
+		var buf bytes.Buffer
+		require.NoError(t, exp.Export(&buf, EntityVideos, Options{Format: format, IncludeMedia: true}), format)
+
+		rows, err := importer.ParseManifest(&buf, format)
+		require.NoError(t, err, format)
+		require.Len(t, rows, 4, format)
+
+		first, second := rows[0], rows[1]
+		first.Line, second.Line = 0, 0
+		assert.Equal(t, importer.Row{
+			ExternalID: "a1", Title: `Live, "unplugged"`, Creator: "Ann", Category: "music",
+			Description: "Two\nlines", URL: "https://cdn.example.com/1.mp4", Duration: "3:05", Status: "published",
+		}, first, format)
+		assert.Equal(t, "draft", second.Status, format)
+		assert.Equal(t, "scheduled", rows[2].Status, format)
+		assert.Equal(t, publishAt.Format(time.RFC3339), rows[2].PublishAt, format)
+		// Overdue videos are about to be published
+		assert.Equal(t, "published", rows[3].Status, format)
+		assert.Empty(t, rows[3].PublishAt, format)
+		assert.Equal(t, "Stored", second.Title, format)
+		assert.Regexp(t, `^titan-\d+$`, second.ExternalID, format)
+		assert.Equal(t, "uploads/videos/2.mp4", second.File, format)
+		assert.Equal(t, "uploads/thumbnails/2.jpg", second.Thumbnail, format)
+		assert.Empty(t, second.URL, format)
+	}
+
+	// Without media, stored files are left out
+	var buf bytes.Buffer
+	require.NoError(t, exp.Export(&buf, EntityVideos, Options{Format: FormatNDJSON, Category: "music"}))
+	rows, err := importer.ParseManifest(&buf, FormatNDJSON)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Empty(t, rows[1].File)
+	assert.Empty(t, rows[1].Thumbnail)
+
+	buf.Reset()
+	require.NoError(t, exp.Export(&buf, EntityVideos, Options{Format: FormatJSON, Category: "news"}))
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestExport_Settings(t *testing.T) {
+	exp, _ := newTestExporter(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, exp.Export(&buf, EntitySettings, Options{Format: FormatJSON}))
+	var settings []map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &settings))
+	require.Len(t, settings, 1)
+	assert.Contains(t, settings[0], "siteName")
+	assert.Contains(t, settings[0], "publicStoragePaths")
+
+	buf.Reset()
+	require.NoError(t, exp.Export(&buf, EntityCategories, Options{Format: FormatCSV}))
+	assert.Regexp(t, `^id,name,icon,video_count,created_at\nmusic,Music,🎵,0,\d{4}-`, buf.String())
+
+	assert.ErrorIs(t, exp.Export(&buf, "users", Options{Format: FormatJSON}), ErrUnknownEntity)
+	assert.ErrorIs(t, exp.Export(&buf, EntityAds, Options{Format: "xml"}), ErrUnknownFormat)
+}
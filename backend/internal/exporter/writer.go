@@ -0,0 +1,152 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// recordWriter writes the records of one entity. Values are in the order of
+// the entity's columns.
+type recordWriter interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+func newRecordWriter(w io.Writer, format string, columns []string) (recordWriter, error) {
+	switch format {
+	case FormatJSON, FormatNDJSON:
+		return &jsonWriter{w: bufio.NewWriter(w), columns: columns, lines: format == FormatNDJSON}, nil
+	case FormatCSV:
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = snakeCase(column)
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// jsonWriter writes a JSON array of objects, or with lines set, one object
+// per line. Keys are written in column order, which a map wouldn't keep.
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+	lines   bool
+	n       int
+}
+
+func (j *jsonWriter) Write(values []interface{}) error {
+	switch {
+	case j.lines:
+	case j.n == 0:
+		j.w.WriteString("[\n")
+	default:
+		j.w.WriteString(",\n")
+	}
+	j.n++
+
+	j.w.WriteByte('{')
+	for i, column := range j.columns {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		value, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		j.w.WriteString(strconv.Quote(column) + ":")
+		j.w.Write(value)
+	}
+	j.w.WriteByte('}')
+	if j.lines {
+		j.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	switch {
+	case j.lines:
+	case j.n == 0:
+		j.w.WriteString("[]\n")
+	default:
+		j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
+
+// csvWriter writes a header row of snake_case column names, then a row per
+// record
+type csvWriter struct {
+	w   *csv.Writer
+	row []string
+}
+
+func (c *csvWriter) Write(values []interface{}) error {
+	c.row = c.row[:0]
+	for _, value := range values {
+		c.row = append(c.row, csvValue(value))
+	}
+	return c.w.Write(c.row)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvValue formats a value for a CSV cell. Lists are comma separated, like
+// they're entered in the admin settings.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// snakeCase turns a JSON key such as externalId into a CSV header such as
+// external_id
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Namespace string      `xml:"xmlns,attr"`
+	Media     string      `xml:"xmlns:media,attr"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	ID        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    atomPerson  `xml:"author"`
+	Logo      string      `xml:"logo,omitempty"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string        `xml:"title"`
+	ID        string        `xml:"id"`
+	Links     []atomLink    `xml:"link"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	Author    *atomPerson   `xml:"author"`
+	Category  *atomCategory `xml:"category"`
+	Summary   *atomText     `xml:"summary"`
+	Thumbnail *urlAttr      `xml:"media:thumbnail"`
+}
+
+func atomDocument(f *Feed) *atomFeed {
+	// Atom requires a feed author unless every entry has one
+	author := f.Author
+	if author == "" {
+		author = f.Title
+	}
+
+	doc := &atomFeed{
+		Namespace: nsAtom,
+		Media:     nsMedia,
+		Title:     f.Title,
+		Subtitle:  f.Description,
+		ID:        f.Self,
+		Updated:   atomTime(f.Updated),
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+		},
+		Author:    atomPerson{Name: author},
+		Logo:      f.Image,
+		Generator: "Titan",
+		Entries:   make([]atomEntry, len(f.Items)),
+	}
+
+	for i := range f.Items {
+		item := &f.Items[i]
+		out := &doc.Entries[i]
+		*out = atomEntry{
+			Title:     item.Title,
+			ID:        item.ID,
+			Links:     []atomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+			Published: atomTime(item.Published),
+			Updated:   atomTime(item.Updated),
+		}
+		if item.Updated.IsZero() {
+			out.Updated = out.Published
+		}
+		if item.Enclosure != nil {
+			out.Links = append(out.Links, atomLink{
+				Href: item.Enclosure.URL, Rel: "enclosure", Type: item.Enclosure.Type, Length: item.Enclosure.Length,
+			})
+		}
+		if item.Author != "" {
+			out.Author = &atomPerson{Name: item.Author}
+		}
+		if item.Category != "" {
+			out.Category = &atomCategory{Term: item.Category}
+		}
+		if item.Description != "" {
+			out.Summary = &atomText{Type: "text", Value: item.Description}
+		}
+		if item.Thumbnail != "" {
+			out.Thumbnail = &urlAttr{URL: item.Thumbnail}
+		}
+	}
+	return doc
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
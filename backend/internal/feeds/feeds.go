@@ -0,0 +1,90 @@
+// Package feeds renders syndication feeds as RSS 2.0, with Media RSS
+// thumbnails and iTunes podcast tags, or Atom 1.0. Rendering is
+// deterministic — the same Feed always gives the same bytes — so callers can
+// derive an ETag from the output.
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// Feed formats, also the file extensions feeds are served under
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+)
+
+// ContentTypes maps the formats to their media types
+var ContentTypes = map[string]string{
+	FormatRSS:  "application/rss+xml; charset=utf-8",
+	FormatAtom: "application/atom+xml; charset=utf-8",
+}
+
+// Feed is a channel of items, newest first
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // The site page the feed follows
+	Self        string // The feed's own URL, also its Atom ID
+	Author      string
+	Image       string
+	Updated     time.Time // Latest change to the feed; zero if it has no items
+	Items       []Item
+}
+
+// Item is one entry of a feed
+type Item struct {
+	ID          string // Stable and unique, such as the item's permalink
+	Title       string
+	Link        string
+	Description string // Plain text
+	Author      string
+	Category    string
+	Published   time.Time
+	Updated     time.Time
+	Thumbnail   string
+	Enclosure   *Enclosure
+	Duration    time.Duration // Zero if unknown
+	// Audio marks podcast episodes, which get iTunes tags in RSS
+	Audio bool
+}
+
+// Enclosure is the media file of an item
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64 // In bytes; zero if unknown
+}
+
+// Podcast reports whether the feed has audio items, and so is a podcast
+func (f *Feed) Podcast() bool {
+	for i := range f.Items {
+		if f.Items[i].Audio {
+			return true
+		}
+	}
+	return false
+}
+
+// Render writes the feed in the given format, FormatRSS or FormatAtom.
+// Any other format renders RSS.
+func Render(f *Feed, format string) ([]byte, error) {
+	var doc interface{}
+	if format == FormatAtom {
+		doc = atomDocument(f)
+	} else {
+		doc = rssDocument(f)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:   "Music & more",
+		Link:    "https://videos.example.com/",
+		Self:    "https://api.example.com/feeds/categories/music.rss",
+		Author:  "Titan",
+		Updated: published.Add(time.Hour),
+		Items: []Item{
+			{
+				ID:          "https://videos.example.com/watch/2",
+				Title:       "<Live> set",
+				Link:        "https://videos.example.com/watch/2",
+				Description: "Recorded live",
+				Author:      "Ann",
+				Category:    "music",
+				Published:   published,
+				Updated:     published.Add(time.Hour),
+				Thumbnail:   "https://api.example.com/storage/thumbnails/2.jpg",
+				Enclosure:   &Enclosure{URL: "https://api.example.com/feeds/media/2/video.mp4", Type: "video/mp4", Length: 1234},
+				Duration:    95 * time.Second,
+			},
+			{
+				ID:        "https://videos.example.com/watch/1",
+				Title:     "First",
+				Link:      "https://videos.example.com/watch/1",
+				Published: published.Add(-time.Hour),
+			},
+		},
+	}
+}
+
+func TestRender_RSS(t *testing.T) {
+	f := testFeed()
+	body, err := Render(f, FormatRSS)
+	require.NoError(t, err)
+
+	var doc struct {
+		Channel struct {
+			Title         string `xml:"title"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title     string `xml:"title"`
+				GUID      string `xml:"guid"`
+				PubDate   string `xml:"pubDate"`
+				Enclosure struct {
+					URL    string `xml:"url,attr"`
+					Length int64  `xml:"length,attr"`
+				} `xml:"enclosure"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	assert.Equal(t, "Music & more", doc.Channel.Title)
+	assert.Equal(t, "Fri, 01 Mar 2024 13:00:00 +0000", doc.Channel.LastBuildDate)
+	require.Len(t, doc.Channel.Items, 2)
+	assert.Equal(t, "<Live> set", doc.Channel.Items[0].Title)
+	assert.Equal(t, "https://videos.example.com/watch/2", doc.Channel.Items[0].GUID)
+	assert.Equal(t, int64(1234), doc.Channel.Items[0].Enclosure.Length)
+	assert.Equal(t, "Fri, 01 Mar 2024 11:00:00 +0000", doc.Channel.Items[1].PubDate)
+
+	// Video feeds aren't podcasts
+	assert.NotContains(t, string(body), "itunes")
+
+	// The same feed renders the same bytes
+	again, err := Render(testFeed(), FormatRSS)
+	require.NoError(t, err)
+	assert.Equal(t, body, again)
+}
+
+func TestRender_Podcast(t *testing.T) {
+	f := testFeed()
+	f.Items[0].Audio = true
+	body, err := Render(f, FormatRSS)
+	require.NoError(t, err)
+
+	out := string(body)
+	assert.Contains(t, out, `xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"`)
+	assert.Contains(t, out, "<itunes:explicit>false</itunes:explicit>")
+	assert.Contains(t, out, "<itunes:duration>95</itunes:duration>")
+	assert.Contains(t, out, `<itunes:image href="https://api.example.com/storage/thumbnails/2.jpg">`)
+	// Only the audio item is an episode
+	assert.Equal(t, 1, strings.Count(out, "<itunes:author>Ann</itunes:author>"))
+}
+
+func TestRender_Atom(t *testing.T) {
+	body, err := Render(testFeed(), FormatAtom)
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Author  string   `xml:"author>name"`
+		Entries []struct {
+			Updated string `xml:"updated"`
+			Links   []struct {
+				Href string `xml:"href,attr"`
+				Rel  string `xml:"rel,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	assert.Equal(t, "https://api.example.com/feeds/categories/music.rss", doc.ID)
+	assert.Equal(t, "2024-03-01T13:00:00Z", doc.Updated)
+	assert.Equal(t, "Titan", doc.Author)
+	require.Len(t, doc.Entries, 2)
+	require.Len(t, doc.Entries[0].Links, 2)
+	assert.Equal(t, "enclosure", doc.Entries[0].Links[1].Rel)
+	// Entries that were never edited were last updated when published
+	assert.Equal(t, "2024-03-01T11:00:00Z", doc.Entries[1].Updated)
+}
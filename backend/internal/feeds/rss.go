@@ -0,0 +1,150 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// Namespaces of the RSS extensions used
+const (
+	nsAtom   = "http://www.w3.org/2005/Atom"
+	nsDC     = "http://purl.org/dc/elements/1.1/"
+	nsMedia  = "http://search.yahoo.com/mrss/"
+	nsITunes = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Media   string     `xml:"xmlns:media,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr,omitempty"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string    `xml:"title"`
+	Link           string    `xml:"link"`
+	Description    string    `xml:"description"`
+	Self           rssLink   `xml:"atom:link"`
+	LastBuildDate  string    `xml:"lastBuildDate,omitempty"`
+	Image          *rssImage `xml:"image"`
+	Generator      string    `xml:"generator"`
+	ITunesAuthor   string    `xml:"itunes:author,omitempty"`
+	ITunesImage    *hrefAttr `xml:"itunes:image"`
+	ITunesExplicit string    `xml:"itunes:explicit,omitempty"`
+	Items          []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type hrefAttr struct {
+	Href string `xml:"href,attr"`
+}
+
+type urlAttr struct {
+	URL string `xml:"url,attr"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title          string        `xml:"title"`
+	Link           string        `xml:"link"`
+	GUID           rssGUID       `xml:"guid"`
+	Description    string        `xml:"description,omitempty"`
+	Creator        string        `xml:"dc:creator,omitempty"` // RSS's own author element wants an email address
+	Category       string        `xml:"category,omitempty"`
+	PubDate        string        `xml:"pubDate"`
+	Enclosure      *rssEnclosure `xml:"enclosure"`
+	Thumbnail      *urlAttr      `xml:"media:thumbnail"`
+	ITunesAuthor   string        `xml:"itunes:author,omitempty"`
+	ITunesImage    *hrefAttr     `xml:"itunes:image"`
+	ITunesDuration string        `xml:"itunes:duration,omitempty"`
+}
+
+func rssDocument(f *Feed) *rss {
+	doc := &rss{
+		Version: "2.0",
+		Atom:    nsAtom,
+		DC:      nsDC,
+		Media:   nsMedia,
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Self:        rssLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+			Generator:   "Titan",
+			Items:       make([]rssItem, len(f.Items)),
+		},
+	}
+	channel := &doc.Channel
+	if !f.Updated.IsZero() {
+		channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	if f.Image != "" {
+		channel.Image = &rssImage{URL: f.Image, Title: f.Title, Link: f.Link}
+	}
+
+	// Podcast directories read the iTunes tags, and require an explicit flag
+	podcast := f.Podcast()
+	if podcast {
+		doc.ITunes = nsITunes
+		channel.ITunesAuthor = f.Author
+		channel.ITunesExplicit = "false"
+		if f.Image != "" {
+			channel.ITunesImage = &hrefAttr{Href: f.Image}
+		}
+	}
+
+	for i := range f.Items {
+		item := &f.Items[i]
+		out := &channel.Items[i]
+		*out = rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID, IsPermaLink: item.ID == item.Link},
+			Description: item.Description,
+			Creator:     item.Author,
+			Category:    item.Category,
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+		}
+		if item.Enclosure != nil {
+			out.Enclosure = &rssEnclosure{URL: item.Enclosure.URL, Length: item.Enclosure.Length, Type: item.Enclosure.Type}
+		}
+		if item.Thumbnail != "" {
+			out.Thumbnail = &urlAttr{URL: item.Thumbnail}
+		}
+		if podcast && item.Audio {
+			out.ITunesAuthor = item.Author
+			if item.Thumbnail != "" {
+				out.ITunesImage = &hrefAttr{Href: item.Thumbnail}
+			}
+			if item.Duration > 0 {
+				out.ITunesDuration = strconv.Itoa(int(item.Duration.Seconds()))
+			}
+		}
+	}
+	return doc
+}
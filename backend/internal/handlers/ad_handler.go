@@ -10,22 +10,32 @@ import (
 
 	"titan-backend/internal/models"
 	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
 )
 
 // AdHandler handles ad-related HTTP requests
 type AdHandler struct {
 	adRepo         *models.AdRepository
 	storageService *services.StorageService
+	jobQueue       *services.JobQueue
+	urlSigner      *services.URLSigner
 }
 
 // NewAdHandler creates a new ad handler
-func NewAdHandler(adRepo *models.AdRepository, storageService *services.StorageService) *AdHandler {
+func NewAdHandler(adRepo *models.AdRepository, storageService *services.StorageService, jobQueue *services.JobQueue, urlSigner *services.URLSigner) *AdHandler {
 	return &AdHandler{
 		adRepo:         adRepo,
 		storageService: storageService,
+		jobQueue:       jobQueue,
+		urlSigner:      urlSigner,
 	}
 }
 
+// signAd replaces a stored /storage image path with a signed link for a response
+func (h *AdHandler) signAd(r *http.Request, ad *models.Ad) {
+	ad.ImageURL = h.urlSigner.SignURL(ad.ImageURL, utils.ClientIP(r))
+}
+
 // GetAll retrieves all ads with optional filtering
 // GET /api/ads?placement=home-banner&enabled=true
 func (h *AdHandler) GetAll(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +53,10 @@ func (h *AdHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for i := range ads {
+		h.signAd(r, &ads[i])
+	}
+
 	models.RespondSuccess(w, "", map[string]interface{}{
 		"ads": ads,
 	}, http.StatusOK)
@@ -63,6 +77,7 @@ func (h *AdHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.signAd(r, ad)
 	models.RespondSuccess(w, "", map[string]interface{}{
 		"ad": ad,
 	}, http.StatusOK)
@@ -104,7 +119,7 @@ func (h *AdHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	// Check if imageUrl was provided (from drive)
 	if imgURL := r.FormValue("imageUrl"); imgURL != "" {
-		imageURL = imgURL
+		imageURL = utils.StripStorageSignature(imgURL)
 	} else {
 		// Get image file
 		imageFile, imageHeader, err := r.FormFile("image")
@@ -139,6 +154,7 @@ func (h *AdHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.signAd(r, ad)
 	models.RespondSuccess(w, "Ad created successfully", map[string]interface{}{
 		"ad": ad,
 	}, http.StatusCreated)
@@ -205,6 +221,7 @@ func (h *AdHandler) Update(w http.ResponseWriter, r *http.Request) {
 			existing = updated
 		}
 
+		h.signAd(r, existing)
 		models.RespondSuccess(w, "Ad updated successfully", map[string]interface{}{
 			"ad": existing,
 		}, http.StatusOK)
@@ -236,10 +253,10 @@ func (h *AdHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Handle new image/media - check for URL first, then file upload
-	if imgURL := r.FormValue("imageUrl"); imgURL != "" {
-		// URL provided from drive - only delete old if it's a local file
-		if !strings.HasPrefix(existing.ImageURL, "http") && !strings.HasPrefix(existing.ImageURL, "/share") {
-			h.storageService.DeleteFile(existing.ImageURL)
+	if imgURL := utils.StripStorageSignature(r.FormValue("imageUrl")); imgURL != "" {
+		// URL provided from drive - only delete old if it's a local file that's being replaced
+		if imgURL != existing.ImageURL && !strings.HasPrefix(existing.ImageURL, "http") && !strings.HasPrefix(existing.ImageURL, "/share") {
+			services.DeleteFilesInBackground(h.jobQueue, h.storageService, existing.ImageURL)
 		}
 		existing.ImageURL = imgURL
 	} else {
@@ -251,7 +268,7 @@ func (h *AdHandler) Update(w http.ResponseWriter, r *http.Request) {
 			if err == nil {
 				// Delete old image only if it's a local file
 				if !strings.HasPrefix(existing.ImageURL, "http") && !strings.HasPrefix(existing.ImageURL, "/share") {
-					h.storageService.DeleteFile(existing.ImageURL)
+					services.DeleteFilesInBackground(h.jobQueue, h.storageService, existing.ImageURL)
 				}
 				existing.ImageURL = newImageURL
 			}
@@ -269,6 +286,7 @@ func (h *AdHandler) Update(w http.ResponseWriter, r *http.Request) {
 		existing = updated
 	}
 
+	h.signAd(r, existing)
 	models.RespondSuccess(w, "Ad updated successfully", map[string]interface{}{
 		"ad": existing,
 	}, http.StatusOK)
@@ -304,6 +322,7 @@ func (h *AdHandler) Toggle(w http.ResponseWriter, r *http.Request) {
 		status = "enabled"
 	}
 
+	h.signAd(r, existing)
 	models.RespondSuccess(w, "Ad "+status+" successfully", map[string]interface{}{
 		"ad": existing,
 	}, http.StatusOK)
@@ -330,8 +349,8 @@ func (h *AdHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete image file
-	h.storageService.DeleteFile(existing.ImageURL)
+	// Delete image file in the background
+	services.DeleteFilesInBackground(h.jobQueue, h.storageService, existing.ImageURL)
 
 	models.RespondSuccess(w, "Ad deleted successfully", map[string]interface{}{
 		"deletedId": id,
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/captions"
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// maxCaptionSize caps uploaded subtitle files; a feature film's are a few hundred KB
+const maxCaptionSize = 2 << 20
+
+// CaptionHandler manages a video's subtitle and caption tracks. Uploads may
+// be SRT or WebVTT and are stored as WebVTT.
+type CaptionHandler struct {
+	captionRepo    *models.CaptionRepository
+	videoRepo      *models.VideoRepository
+	storageService *services.StorageService
+	jobQueue       *services.JobQueue
+	urlSigner      *services.URLSigner
+}
+
+// NewCaptionHandler creates a new caption handler
+func NewCaptionHandler(
+	captionRepo *models.CaptionRepository,
+	videoRepo *models.VideoRepository,
+	storageService *services.StorageService,
+	jobQueue *services.JobQueue,
+	urlSigner *services.URLSigner,
+) *CaptionHandler {
+	return &CaptionHandler{
+		captionRepo:    captionRepo,
+		videoRepo:      videoRepo,
+		storageService: storageService,
+		jobQueue:       jobQueue,
+		urlSigner:      urlSigner,
+	}
+}
+
+// RegisterPublicRoutes registers the viewer-facing routes. The router should
+// run middleware.OptionalAuth so admins can see tracks of unpublished videos.
+func (h *CaptionHandler) RegisterPublicRoutes(r chi.Router) {
+	r.Get("/videos/{id}/captions", h.List)
+	r.Get("/videos/{id}/captions/search", h.Search)
+}
+
+// RegisterRoutes registers the admin routes
+func (h *CaptionHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/videos/{id}/captions", h.Create)
+	r.Put("/videos/{id}/captions/{captionId}", h.Update)
+	r.Delete("/videos/{id}/captions/{captionId}", h.Delete)
+}
+
+// List returns a video's caption tracks with signed links to their WebVTT files
+// GET /api/videos/{id}/captions
+func (h *CaptionHandler) List(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.captionRepo.ListForVideo(video.ID)
+	if err != nil {
+		log.Printf("[Caption] ERROR: Failed to list captions of video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to fetch captions", http.StatusInternalServerError)
+		return
+	}
+	h.urlSigner.SignCaptions(list, utils.ClientIP(r))
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"captions": list,
+	}, http.StatusOK)
+}
+
+// Search finds the cues of a video mentioning any word of q, so the player
+// can jump to them
+// GET /api/videos/{id}/captions/search?q=volcano
+func (h *CaptionHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		models.RespondError(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	matches, err := h.captionRepo.SearchCues(video.ID, query)
+	if err != nil {
+		log.Printf("[Caption] ERROR: Caption search for %q in video %d failed: %v", query, video.ID, err)
+		models.RespondError(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"matches": matches,
+		"query":   query,
+	}, http.StatusOK)
+}
+
+// Create uploads a caption track. The file (an .srt or .vtt) is converted to
+// WebVTT with its cue timing checked; language is a BCP 47 tag, label
+// defaults to the language, kind to subtitles.
+// POST /api/videos/{id}/captions (multipart: file, language, label, kind, default)
+func (h *CaptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxCaptionSize); err != nil {
+		models.RespondError(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		models.RespondError(w, "Caption file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext != ".srt" && ext != ".vtt" {
+		models.RespondError(w, "Caption files must be .srt or .vtt", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxCaptionSize+1))
+	if err != nil {
+		models.RespondError(w, "Failed to read caption file", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxCaptionSize {
+		models.RespondError(w, "Caption file is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	cues, _, err := captions.Parse(data)
+	if err != nil {
+		if errors.Is(err, captions.ErrInvalid) {
+			models.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		models.RespondError(w, "Failed to read caption file", http.StatusInternalServerError)
+		return
+	}
+
+	caption := &models.Caption{
+		VideoID:   video.ID,
+		Language:  middleware.SanitizeString(r.FormValue("language")),
+		Label:     middleware.SanitizeString(r.FormValue("label")),
+		Kind:      middleware.SanitizeString(r.FormValue("kind")),
+		IsDefault: r.FormValue("default") == "true",
+	}
+	if msg := validateCaption(caption); msg != "" {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var vtt bytes.Buffer
+	if err := captions.WriteVTT(&vtt, cues); err != nil {
+		models.RespondError(w, "Failed to convert caption file", http.StatusInternalServerError)
+		return
+	}
+	if caption.URL, err = h.storageService.SaveCaption(vtt.Bytes(), header.Filename); err != nil {
+		log.Printf("[Caption] ERROR: Failed to save captions for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to save caption file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.captionRepo.Create(caption, cues); err != nil {
+		h.storageService.DeleteFile(caption.URL)
+		if errors.Is(err, models.ErrCaptionExists) {
+			models.RespondError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("[Caption] ERROR: Failed to create caption for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to save captions", http.StatusInternalServerError)
+		return
+	}
+
+	caption.URL = h.urlSigner.SignURL(caption.URL, utils.ClientIP(r))
+	models.RespondSuccess(w, "Captions uploaded successfully", map[string]interface{}{
+		"caption": caption,
+	}, http.StatusCreated)
+}
+
+// Update changes a track's language, label, kind or default flag. To replace
+// the cues, delete the track and upload it again.
+// PUT /api/videos/{id}/captions/{captionId}
+func (h *CaptionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Language  *string `json:"language"`
+		Label     *string `json:"label"`
+		Kind      *string `json:"kind"`
+		IsDefault *bool   `json:"default"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	caption, ok := h.loadCaption(w, r)
+	if !ok {
+		return
+	}
+	if req.Language != nil {
+		caption.Language = middleware.SanitizeString(*req.Language)
+	}
+	if req.Label != nil {
+		caption.Label = middleware.SanitizeString(*req.Label)
+	}
+	if req.Kind != nil {
+		caption.Kind = middleware.SanitizeString(*req.Kind)
+	}
+	if req.IsDefault != nil {
+		caption.IsDefault = *req.IsDefault
+	}
+	if msg := validateCaption(caption); msg != "" {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.captionRepo.Update(caption); err != nil {
+		if errors.Is(err, models.ErrCaptionExists) {
+			models.RespondError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("[Caption] ERROR: Failed to update caption %d: %v", caption.ID, err)
+		models.RespondError(w, "Failed to update captions", http.StatusInternalServerError)
+		return
+	}
+
+	caption.URL = h.urlSigner.SignURL(caption.URL, utils.ClientIP(r))
+	models.RespondSuccess(w, "Captions updated successfully", map[string]interface{}{
+		"caption": caption,
+	}, http.StatusOK)
+}
+
+// Delete removes a track and its WebVTT file
+// DELETE /api/videos/{id}/captions/{captionId}
+func (h *CaptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	caption, ok := h.loadCaption(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.captionRepo.Delete(caption); err != nil {
+		log.Printf("[Caption] ERROR: Failed to delete caption %d: %v", caption.ID, err)
+		models.RespondError(w, "Failed to delete captions", http.StatusInternalServerError)
+		return
+	}
+	services.DeleteFilesInBackground(h.jobQueue, h.storageService, caption.URL)
+
+	models.RespondSuccess(w, "Captions deleted successfully", map[string]interface{}{
+		"deletedId": caption.ID,
+	}, http.StatusOK)
+}
+
+// validateCaption fills in the default label and kind and checks the fields,
+// returning an error message or ""
+func validateCaption(c *models.Caption) string {
+	if !middleware.ValidateLanguageTag(c.Language) {
+		return "Language must be a language tag such as en or pt-BR"
+	}
+	if c.Label == "" {
+		c.Label = c.Language
+	}
+	if !middleware.ValidateDisplayName(c.Label) {
+		return "Label must be 1-50 characters of plain text"
+	}
+	if c.Kind == "" {
+		c.Kind = models.CaptionKindSubtitles
+	}
+	if !slices.Contains(models.CaptionKinds, c.Kind) {
+		return "Kind must be one of: " + strings.Join(models.CaptionKinds, ", ")
+	}
+	return ""
+}
+
+// loadVideo resolves the {id} video, writing an error response on failure
+func (h *CaptionHandler) loadVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !canWatch(r, video) {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
+
+// loadCaption resolves the {captionId} track of the {id} video
+func (h *CaptionHandler) loadCaption(w http.ResponseWriter, r *http.Request) (*models.Caption, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "captionId"))
+	if err != nil {
+		models.RespondError(w, "Invalid caption ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	caption, err := h.captionRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch captions", http.StatusInternalServerError)
+		return nil, false
+	}
+	if caption == nil || strconv.Itoa(caption.VideoID) != chi.URLParam(r, "id") {
+		models.RespondError(w, "Captions not found", http.StatusNotFound)
+		return nil, false
+	}
+	return caption, true
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/captions"
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// openChapterLength ends the last chapter in the WebVTT track when the
+// video's duration isn't known; cues need an end, and players stop at the
+// end of the video anyway
+const openChapterLength = 24 * time.Hour
+
+// ChapterHandler serves a video's chapters, as JSON and as a WebVTT chapters
+// track, and lets admins set them
+type ChapterHandler struct {
+	chapterRepo *models.ChapterRepository
+	videoRepo   *models.VideoRepository
+	urlSigner   *services.URLSigner
+}
+
+// NewChapterHandler creates a new chapter handler
+func NewChapterHandler(chapterRepo *models.ChapterRepository, videoRepo *models.VideoRepository, urlSigner *services.URLSigner) *ChapterHandler {
+	return &ChapterHandler{
+		chapterRepo: chapterRepo,
+		videoRepo:   videoRepo,
+		urlSigner:   urlSigner,
+	}
+}
+
+// RegisterPublicRoutes registers the viewer-facing routes. The router should
+// run middleware.OptionalAuth so admins can see chapters of unpublished videos.
+func (h *ChapterHandler) RegisterPublicRoutes(r chi.Router) {
+	r.Get("/videos/{id}/chapters", h.List)
+	r.Get("/videos/{id}/chapters.vtt", h.WebVTT)
+}
+
+// RegisterRoutes registers the admin routes
+func (h *ChapterHandler) RegisterRoutes(r chi.Router) {
+	r.Put("/videos/{id}/chapters", h.Set)
+	r.Delete("/videos/{id}/chapters", h.Clear)
+}
+
+// List returns the video's chapters and whether they were set through the
+// API ("manual") or parsed from the description
+// GET /api/videos/{id}/chapters
+func (h *ChapterHandler) List(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+	h.respond(w, r, video, "")
+}
+
+// WebVTT returns the chapters as a WebVTT file for a <track kind="chapters">
+// GET /api/videos/{id}/chapters.vtt
+func (h *ChapterHandler) WebVTT(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	chapters, _, err := h.chapterRepo.ForVideo(video)
+	if err != nil {
+		log.Printf("[Chapter] ERROR: Failed to fetch chapters for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to fetch chapters", http.StatusInternalServerError)
+		return
+	}
+
+	cues := make([]captions.Cue, len(chapters))
+	for i, c := range chapters {
+		start := secondsDuration(c.Start)
+		end := start + openChapterLength
+		if c.End > c.Start {
+			end = secondsDuration(c.End)
+		}
+		cues[i] = captions.Cue{ID: strconv.Itoa(i + 1), Start: start, End: end, Text: captions.EscapeText(c.Title)}
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	if err := captions.WriteVTT(w, cues); err != nil {
+		log.Printf("[Chapter] ERROR: Failed to write chapters of video %d: %v", video.ID, err)
+	}
+}
+
+// Set replaces the video's chapters. They are sorted by start; an empty list
+// is the same as Clear.
+// PUT /api/videos/{id}/chapters
+func (h *ChapterHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Chapters []models.Chapter `json:"chapters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+	if msg := validateChapters(req.Chapters, video.DurationSeconds); msg != "" {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.chapterRepo.Set(video.ID, req.Chapters); err != nil {
+		log.Printf("[Chapter] ERROR: Failed to set chapters for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to save chapters", http.StatusInternalServerError)
+		return
+	}
+	h.respond(w, r, video, "Chapters saved successfully")
+}
+
+// Clear removes the chapters set through the API, so those in the
+// description, if any, apply again
+// DELETE /api/videos/{id}/chapters
+func (h *ChapterHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.chapterRepo.Set(video.ID, nil); err != nil {
+		log.Printf("[Chapter] ERROR: Failed to clear chapters for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to clear chapters", http.StatusInternalServerError)
+		return
+	}
+	h.respond(w, r, video, "Chapters cleared successfully")
+}
+
+func (h *ChapterHandler) respond(w http.ResponseWriter, r *http.Request, video *models.Video, message string) {
+	chapters, source, err := h.chapterRepo.ForVideo(video)
+	if err != nil {
+		log.Printf("[Chapter] ERROR: Failed to fetch chapters for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to fetch chapters", http.StatusInternalServerError)
+		return
+	}
+	h.urlSigner.SignChapters(chapters, utils.ClientIP(r))
+
+	models.RespondSuccess(w, message, map[string]interface{}{
+		"chapters": chapters,
+		"source":   source,
+	}, http.StatusOK)
+}
+
+// validateChapters trims and sorts chapters and checks them against the
+// video's duration (0 if unknown), returning an error message or ""
+func validateChapters(chapters []models.Chapter, duration float64) string {
+	if len(chapters) > models.MaxChapters {
+		return fmt.Sprintf("A video can have at most %d chapters", models.MaxChapters)
+	}
+
+	for i := range chapters {
+		c := &chapters[i]
+		c.Title = middleware.SanitizeString(c.Title)
+		c.Thumbnail = strings.TrimSpace(c.Thumbnail)
+		c.End = 0
+
+		if c.Title == "" || utf8.RuneCountInString(c.Title) > models.MaxChapterTitleLength {
+			return fmt.Sprintf("Chapter titles must be 1-%d characters", models.MaxChapterTitleLength)
+		}
+		if strings.IndexFunc(c.Title, unicode.IsControl) >= 0 {
+			return "Chapter titles cannot contain control characters"
+		}
+		if c.Start < 0 || math.IsNaN(c.Start) || math.IsInf(c.Start, 0) {
+			return "Chapter start must be a number of seconds from 0"
+		}
+		if duration > 0 && c.Start >= duration {
+			return fmt.Sprintf("Chapter %q starts after the end of the video", c.Title)
+		}
+
+		// Stored thumbnails come back signed; keep only the path
+		if strings.HasPrefix(c.Thumbnail, "/storage/") {
+			c.Thumbnail = strings.SplitN(c.Thumbnail, "?", 2)[0]
+		} else if c.Thumbnail != "" && !strings.HasPrefix(c.Thumbnail, "https://") && !strings.HasPrefix(c.Thumbnail, "http://") {
+			return "Chapter thumbnails must be http(s) URLs or /storage paths"
+		}
+	}
+
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+	for i := 1; i < len(chapters); i++ {
+		if chapters[i].Start == chapters[i-1].Start {
+			return "Two chapters can't start at the same time"
+		}
+	}
+	return ""
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds*1000)) * time.Millisecond
+}
+
+// loadVideo resolves the {id} video, writing an error response on failure
+func (h *ChapterHandler) loadVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !canWatch(r, video) {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+// CommentHandler handles video comments and their moderation
+type CommentHandler struct {
+	commentRepo  *models.CommentRepository
+	videoRepo    *models.VideoRepository
+	settingsRepo *models.SettingsRepository
+}
+
+// NewCommentHandler creates a new comment handler
+func NewCommentHandler(commentRepo *models.CommentRepository, videoRepo *models.VideoRepository, settingsRepo *models.SettingsRepository) *CommentHandler {
+	return &CommentHandler{
+		commentRepo:  commentRepo,
+		videoRepo:    videoRepo,
+		settingsRepo: settingsRepo,
+	}
+}
+
+// RegisterPublicRoutes registers the viewer-facing routes. The router should
+// run middleware.OptionalAuth so signed in users comment under their account.
+func (h *CommentHandler) RegisterPublicRoutes(r chi.Router) {
+	r.Get("/videos/{id}/comments", h.List)
+	r.Post("/videos/{id}/comments", h.Create)
+	r.Get("/videos/{id}/comments/{commentId}/replies", h.ListReplies)
+	r.Put("/videos/{id}/comments/{commentId}", h.Edit)
+	r.Delete("/videos/{id}/comments/{commentId}", h.DeleteOwn)
+}
+
+// RegisterRoutes registers the admin moderation routes
+func (h *CommentHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/comments", h.Queue)
+	r.Get("/comments/{commentId}/history", h.History)
+	r.Post("/comments/{commentId}/approve", h.Approve)
+	r.Post("/comments/{commentId}/reject", h.Reject)
+	r.Post("/comments/{commentId}/pin", h.Pin)
+	r.Delete("/comments/{commentId}/pin", h.Unpin)
+	r.Delete("/comments/{commentId}", h.Delete)
+}
+
+var validCommentStatuses = map[string]bool{
+	models.CommentStatusPending:  true,
+	models.CommentStatusApproved: true,
+	models.CommentStatusRejected: true,
+}
+
+// List returns a page of a video's top-level comments, pinned first
+// GET /api/videos/{id}/comments?sort=newest|oldest&page=1&limit=20
+func (h *CommentHandler) List(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	pagination := utils.GetPaginationParams(r)
+	oldestFirst := r.URL.Query().Get("sort") == "oldest"
+
+	comments, total, err := h.commentRepo.ListByVideo(video.ID, oldestFirst, pagination.Page, pagination.Limit)
+	if err != nil {
+		log.Printf("[Comments] ERROR: Failed to list comments for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to fetch comments", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"comments":   comments,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total),
+	}, http.StatusOK)
+}
+
+// ListReplies returns a page of replies to a comment, oldest first
+// GET /api/videos/{id}/comments/{commentId}/replies?page=1&limit=20
+func (h *CommentHandler) ListReplies(w http.ResponseWriter, r *http.Request) {
+	parent, ok := h.loadVideoComment(w, r)
+	if !ok {
+		return
+	}
+
+	pagination := utils.GetPaginationParams(r)
+	replies, total, err := h.commentRepo.ListReplies(parent.ID, pagination.Page, pagination.Limit)
+	if err != nil {
+		log.Printf("[Comments] ERROR: Failed to list replies to comment %d: %v", parent.ID, err)
+		models.RespondError(w, "Failed to fetch replies", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"comments":   replies,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total),
+	}, http.StatusOK)
+}
+
+// Create posts a comment or, with parentId, a reply
+// POST /api/videos/{id}/comments
+func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body       string `json:"body"`
+		ParentID   *int   `json:"parentId"`
+		AuthorName string `json:"authorName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = middleware.SanitizeString(req.Body)
+	if valid, msg := middleware.ValidateComment(req.Body); !valid {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	viewerID, ok := middleware.ViewerID(r)
+	if !ok {
+		models.RespondError(w, "Sign in or send an "+middleware.DeviceIDHeader+" header", http.StatusBadRequest)
+		return
+	}
+
+	// Signed in users always comment under their username
+	authorName := middleware.SanitizeString(req.AuthorName)
+	if claims := middleware.GetUserFromContext(r); claims != nil {
+		authorName = claims.Username
+	} else if authorName == "" {
+		authorName = "Anonymous"
+	} else if !middleware.ValidateDisplayName(authorName) {
+		models.RespondError(w, "Invalid author name", http.StatusBadRequest)
+		return
+	}
+
+	video, ok := h.loadVideo(w, r)
+	if !ok {
+		return
+	}
+
+	if req.ParentID != nil {
+		parent, err := h.commentRepo.GetByID(*req.ParentID)
+		if err != nil {
+			models.RespondError(w, "Failed to fetch parent comment", http.StatusInternalServerError)
+			return
+		}
+		if parent == nil || parent.VideoID != video.ID || parent.Status != models.CommentStatusApproved || parent.Deleted {
+			models.RespondError(w, "Parent comment not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		models.RespondError(w, "Failed to fetch settings", http.StatusInternalServerError)
+		return
+	}
+
+	comment := &models.Comment{
+		VideoID:    video.ID,
+		ParentID:   req.ParentID,
+		AuthorID:   viewerID,
+		AuthorName: authorName,
+		Body:       req.Body,
+		Status:     models.CommentStatusApproved,
+	}
+	if settings.CommentModeration {
+		comment.Status = models.CommentStatusPending
+	}
+
+	if err := h.commentRepo.Create(comment); err != nil {
+		log.Printf("[Comments] ERROR: Failed to create comment on video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to save comment", http.StatusInternalServerError)
+		return
+	}
+
+	if comment.Status == models.CommentStatusPending {
+		models.RespondSuccess(w, "Comment submitted for review", map[string]interface{}{
+			"comment": comment,
+		}, http.StatusAccepted)
+		return
+	}
+	models.RespondSuccess(w, "Comment posted", map[string]interface{}{
+		"comment": comment,
+	}, http.StatusCreated)
+}
+
+// Edit lets the author change their comment. The old text is kept in the edit
+// history and, under pre-moderation, the comment goes back to the queue.
+// PUT /api/videos/{id}/comments/{commentId}
+func (h *CommentHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = middleware.SanitizeString(req.Body)
+	if valid, msg := middleware.ValidateComment(req.Body); !valid {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	comment, ok := h.loadOwnComment(w, r)
+	if !ok {
+		return
+	}
+	if comment.Status == models.CommentStatusRejected {
+		models.RespondError(w, "Rejected comments can't be edited", http.StatusConflict)
+		return
+	}
+	if comment.Body == req.Body {
+		models.RespondSuccess(w, "Comment unchanged", map[string]interface{}{
+			"comment": comment,
+		}, http.StatusOK)
+		return
+	}
+
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		models.RespondError(w, "Failed to fetch settings", http.StatusInternalServerError)
+		return
+	}
+	status := comment.Status
+	if settings.CommentModeration {
+		status = models.CommentStatusPending
+	}
+
+	if err := h.commentRepo.Edit(comment.ID, req.Body, status); err != nil {
+		log.Printf("[Comments] ERROR: Failed to edit comment %d: %v", comment.ID, err)
+		models.RespondError(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondComment(w, comment.ID, "Comment updated")
+}
+
+// DeleteOwn lets the author delete their comment. Replies stay visible.
+// DELETE /api/videos/{id}/comments/{commentId}
+func (h *CommentHandler) DeleteOwn(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadOwnComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.commentRepo.SoftDelete(comment.ID); err != nil {
+		log.Printf("[Comments] ERROR: Failed to delete comment %d: %v", comment.ID, err)
+		models.RespondError(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "Comment deleted", map[string]interface{}{
+		"deletedId": comment.ID,
+	}, http.StatusOK)
+}
+
+// Queue lists comments by moderation status, oldest first (admin)
+// GET /api/comments?status=pending&page=1&limit=20
+func (h *CommentHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.CommentStatusPending
+	}
+	if !validCommentStatuses[status] {
+		models.RespondError(w, "Invalid comment status", http.StatusBadRequest)
+		return
+	}
+
+	pagination := utils.GetPaginationParams(r)
+	comments, total, err := h.commentRepo.ListByStatus(status, pagination.Page, pagination.Limit)
+	if err != nil {
+		log.Printf("[Comments] ERROR: Failed to list %s comments: %v", status, err)
+		models.RespondError(w, "Failed to fetch comments", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"comments":   comments,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total),
+	}, http.StatusOK)
+}
+
+// History returns the previous versions of an edited comment (admin)
+// GET /api/comments/{commentId}/history
+func (h *CommentHandler) History(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	edits, err := h.commentRepo.History(comment.ID)
+	if err != nil {
+		log.Printf("[Comments] ERROR: Failed to fetch history of comment %d: %v", comment.ID, err)
+		models.RespondError(w, "Failed to fetch comment history", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"comment": comment,
+		"edits":   edits,
+	}, http.StatusOK)
+}
+
+// Approve publishes a held comment (admin)
+// POST /api/comments/{commentId}/approve
+func (h *CommentHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, models.CommentStatusApproved, "Comment approved")
+}
+
+// Reject hides a comment without deleting it (admin)
+// POST /api/comments/{commentId}/reject
+func (h *CommentHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, models.CommentStatusRejected, "Comment rejected")
+}
+
+func (h *CommentHandler) moderate(w http.ResponseWriter, r *http.Request, status, message string) {
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if comment.Deleted {
+		models.RespondError(w, "Comment has been deleted", http.StatusConflict)
+		return
+	}
+
+	if err := h.commentRepo.SetStatus(comment.ID, status); err != nil {
+		log.Printf("[Comments] ERROR: Failed to set comment %d to %s: %v", comment.ID, status, err)
+		models.RespondError(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondComment(w, comment.ID, message)
+}
+
+// Pin pins a top-level comment to the top of its video's comments (admin)
+// POST /api/comments/{commentId}/pin
+func (h *CommentHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if comment.ParentID != nil {
+		models.RespondError(w, "Only top-level comments can be pinned", http.StatusBadRequest)
+		return
+	}
+	if comment.Deleted || comment.Status != models.CommentStatusApproved {
+		models.RespondError(w, "Only approved comments can be pinned", http.StatusConflict)
+		return
+	}
+
+	if err := h.commentRepo.SetPinned(comment.ID, true); err != nil {
+		log.Printf("[Comments] ERROR: Failed to pin comment %d: %v", comment.ID, err)
+		models.RespondError(w, "Failed to pin comment", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondComment(w, comment.ID, "Comment pinned")
+}
+
+// Unpin removes a comment's pin (admin)
+// DELETE /api/comments/{commentId}/pin
+func (h *CommentHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.commentRepo.SetPinned(comment.ID, false); err != nil {
+		log.Printf("[Comments] ERROR: Failed to unpin comment %d: %v", comment.ID, err)
+		models.RespondError(w, "Failed to unpin comment", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondComment(w, comment.ID, "Comment unpinned")
+}
+
+// Delete soft-deletes any comment (admin)
+// DELETE /api/comments/{commentId}
+func (h *CommentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.commentRepo.SoftDelete(comment.ID); err != nil {
+		log.Printf("[Comments] ERROR: Failed to delete comment %d: %v", comment.ID, err)
+		models.RespondError(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "Comment deleted", map[string]interface{}{
+		"deletedId": comment.ID,
+	}, http.StatusOK)
+}
+
+// loadVideo resolves the {id} video, writing an error response on failure
+func (h *CommentHandler) loadVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !canWatch(r, video) {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
+
+// loadComment resolves the {commentId} comment, writing an error response on failure
+func (h *CommentHandler) loadComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "commentId"))
+	if err != nil {
+		models.RespondError(w, "Invalid comment ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	comment, err := h.commentRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch comment", http.StatusInternalServerError)
+		return nil, false
+	}
+	if comment == nil {
+		models.RespondError(w, "Comment not found", http.StatusNotFound)
+		return nil, false
+	}
+	return comment, true
+}
+
+// loadVideoComment resolves a visible comment that belongs to the {id} video
+func (h *CommentHandler) loadVideoComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return nil, false
+	}
+	if strconv.Itoa(comment.VideoID) != chi.URLParam(r, "id") || comment.Status != models.CommentStatusApproved {
+		models.RespondError(w, "Comment not found", http.StatusNotFound)
+		return nil, false
+	}
+	return comment, true
+}
+
+// loadOwnComment resolves a live comment on the {id} video written by the current viewer
+func (h *CommentHandler) loadOwnComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	viewerID, ok := middleware.ViewerID(r)
+	if !ok {
+		models.RespondError(w, "Sign in or send an "+middleware.DeviceIDHeader+" header", http.StatusBadRequest)
+		return nil, false
+	}
+
+	comment, ok := h.loadComment(w, r)
+	if !ok {
+		return nil, false
+	}
+	if strconv.Itoa(comment.VideoID) != chi.URLParam(r, "id") || comment.Deleted {
+		models.RespondError(w, "Comment not found", http.StatusNotFound)
+		return nil, false
+	}
+	if comment.AuthorID != viewerID {
+		models.RespondError(w, "You can only change your own comments", http.StatusForbidden)
+		return nil, false
+	}
+	return comment, true
+}
+
+// respondComment reloads a comment after a change and returns it
+func (h *CommentHandler) respondComment(w http.ResponseWriter, id int, message string) {
+	comment, err := h.commentRepo.GetByID(id)
+	if err != nil || comment == nil {
+		models.RespondError(w, "Failed to fetch comment", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, message, map[string]interface{}{
+		"comment": comment,
+	}, http.StatusOK)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// CreatorHandler serves creator profiles and lets admins edit them. Creators
+// are made as videos are uploaded with a new creator name.
+type CreatorHandler struct {
+	creatorRepo *models.CreatorRepository
+	videoRepo   *models.VideoRepository
+	urlSigner   *services.URLSigner
+}
+
+// NewCreatorHandler creates a new creator handler
+func NewCreatorHandler(creatorRepo *models.CreatorRepository, videoRepo *models.VideoRepository, urlSigner *services.URLSigner) *CreatorHandler {
+	return &CreatorHandler{
+		creatorRepo: creatorRepo,
+		videoRepo:   videoRepo,
+		urlSigner:   urlSigner,
+	}
+}
+
+// RegisterPublicRoutes registers the profile routes. The router should run
+// middleware.OptionalAuth so admins see unpublished videos on profiles.
+func (h *CreatorHandler) RegisterPublicRoutes(r chi.Router) {
+	r.Get("/creators", h.List)
+	r.Get("/creators/{slug}", h.Get)
+	r.Get("/creators/{slug}/videos", h.Videos)
+}
+
+// RegisterRoutes registers the admin routes
+func (h *CreatorHandler) RegisterRoutes(r chi.Router) {
+	r.Put("/creators/{slug}", h.Update)
+}
+
+// List returns creators with published videos, most viewed first, with their
+// stats. With q it autocompletes on the slug.
+// GET /api/creators?q=john&page=1&limit=20
+func (h *CreatorHandler) List(w http.ResponseWriter, r *http.Request) {
+	params := utils.GetPaginationParams(r)
+
+	creators, total, err := h.creatorRepo.List(r.URL.Query().Get("q"), params.Limit, params.Offset)
+	if err != nil {
+		log.Printf("[Creator] ERROR: Failed to list creators: %v", err)
+		models.RespondError(w, "Failed to fetch creators", http.StatusInternalServerError)
+		return
+	}
+	for i := range creators {
+		h.signCreator(r, &creators[i])
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"creators":   creators,
+		"pagination": utils.CalculatePaginationMeta(params.Page, params.Limit, total),
+	}, http.StatusOK)
+}
+
+// Get returns a creator's profile with the stats of their published videos
+// GET /api/creators/{slug}
+func (h *CreatorHandler) Get(w http.ResponseWriter, r *http.Request) {
+	creator, ok := h.loadCreator(w, r)
+	if !ok {
+		return
+	}
+	if !h.attachStats(w, creator) {
+		return
+	}
+	h.signCreator(r, creator)
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"creator": creator,
+	}, http.StatusOK)
+}
+
+// Videos lists a creator's videos, with the same filters, sorts and
+// pagination as the video listing
+// GET /api/creators/{slug}/videos?sort=views&limit=20
+func (h *CreatorHandler) Videos(w http.ResponseWriter, r *http.Request) {
+	creator, ok := h.loadCreator(w, r)
+	if !ok {
+		return
+	}
+
+	q, err := parseVideoQuery(r)
+	if err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Creator = creator.Slug
+
+	videos, meta, err := h.videoRepo.GetAll(q)
+	if err != nil {
+		log.Printf("[Creator] ERROR: Failed to list videos of %s: %v", creator.Slug, err)
+		models.RespondError(w, "Failed to fetch videos", http.StatusInternalServerError)
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+	for i := range videos {
+		h.urlSigner.SignVideo(&videos[i], clientIP)
+	}
+	h.signCreator(r, creator)
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"creator":    creator,
+		"videos":     videos,
+		"pagination": meta,
+	}, http.StatusOK)
+}
+
+// Update edits a creator's profile. Omitted fields are kept. A new name
+// changes the slug, and so the profile's URL, and is shown on all of the
+// creator's videos.
+// PUT /api/creators/{slug}
+func (h *CreatorHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     *string               `json:"name"`
+		Avatar   *string               `json:"avatar"`
+		Bio      *string               `json:"bio"`
+		Verified *bool                 `json:"verified"`
+		Links    *[]models.CreatorLink `json:"links"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	creator, ok := h.loadCreator(w, r)
+	if !ok {
+		return
+	}
+	if req.Name != nil {
+		creator.Name = middleware.SanitizeString(*req.Name)
+		if valid, msg := middleware.ValidateCreatorName(creator.Name); !valid {
+			models.RespondError(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Avatar != nil {
+		avatar, ok := storageOrWebURL(*req.Avatar)
+		if !ok {
+			models.RespondError(w, "Avatar must be an http(s) URL or a /storage path", http.StatusBadRequest)
+			return
+		}
+		creator.Avatar = avatar
+	}
+	if req.Bio != nil {
+		creator.Bio = strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(creator.Bio) > models.MaxCreatorBioLength {
+			models.RespondError(w, fmt.Sprintf("Bio must not exceed %d characters", models.MaxCreatorBioLength), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Verified != nil {
+		creator.Verified = *req.Verified
+	}
+	if req.Links != nil {
+		if msg := validateCreatorLinks(*req.Links); msg != "" {
+			models.RespondError(w, msg, http.StatusBadRequest)
+			return
+		}
+		creator.Links = *req.Links
+	}
+
+	if err := h.creatorRepo.Update(creator); err != nil {
+		if errors.Is(err, models.ErrCreatorExists) {
+			models.RespondError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("[Creator] ERROR: Failed to update creator %d: %v", creator.ID, err)
+		models.RespondError(w, "Failed to update creator", http.StatusInternalServerError)
+		return
+	}
+	if !h.attachStats(w, creator) {
+		return
+	}
+	h.signCreator(r, creator)
+
+	models.RespondSuccess(w, "Creator updated successfully", map[string]interface{}{
+		"creator": creator,
+	}, http.StatusOK)
+}
+
+func (h *CreatorHandler) attachStats(w http.ResponseWriter, creator *models.Creator) bool {
+	stats, err := h.creatorRepo.Stats(creator.ID)
+	if err != nil {
+		log.Printf("[Creator] ERROR: Failed to compute stats of creator %d: %v", creator.ID, err)
+		models.RespondError(w, "Failed to fetch creator", http.StatusInternalServerError)
+		return false
+	}
+	creator.Stats = stats
+	return true
+}
+
+func (h *CreatorHandler) signCreator(r *http.Request, creator *models.Creator) {
+	creator.Avatar = h.urlSigner.SignURL(creator.Avatar, utils.ClientIP(r))
+}
+
+// loadCreator looks up the creator in the URL, writing a 404 if there is
+// none. The slug is normalized, so /creators/John%20Doe finds john-doe.
+func (h *CreatorHandler) loadCreator(w http.ResponseWriter, r *http.Request) (*models.Creator, bool) {
+	creator, err := h.creatorRepo.GetBySlug(models.Slugify(chi.URLParam(r, "slug")))
+	if err != nil {
+		log.Printf("[Creator] ERROR: Failed to fetch creator: %v", err)
+		models.RespondError(w, "Failed to fetch creator", http.StatusInternalServerError)
+		return nil, false
+	}
+	if creator == nil {
+		models.RespondError(w, "Creator not found", http.StatusNotFound)
+		return nil, false
+	}
+	return creator, true
+}
+
+// validateCreatorLinks trims profile links and checks them, returning an
+// error message or ""
+func validateCreatorLinks(links []models.CreatorLink) string {
+	if len(links) > models.MaxCreatorLinks {
+		return fmt.Sprintf("A creator can have at most %d links", models.MaxCreatorLinks)
+	}
+	for i := range links {
+		link := &links[i]
+		link.Label = middleware.SanitizeString(link.Label)
+		link.URL = strings.TrimSpace(link.URL)
+		if !middleware.ValidateDisplayName(link.Label) {
+			return "Link labels must be 1-50 characters of plain text"
+		}
+		if !strings.HasPrefix(link.URL, "https://") && !strings.HasPrefix(link.URL, "http://") {
+			return "Links must be http(s) URLs"
+		}
+	}
+	return ""
+}
+
+// storageOrWebURL accepts an http(s) URL or a /storage path, dropping the
+// signature from signed storage links; "" clears the value
+func storageOrWebURL(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return "", true
+	case strings.HasPrefix(value, "/storage/"):
+		return strings.SplitN(value, "?", 2)[0], true
+	case strings.HasPrefix(value, "https://"), strings.HasPrefix(value, "http://"):
+		return value, true
+	}
+	return "", false
+}
@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+const (
+	// Embeds are this wide unless maxwidth or maxheight ask for less, and as
+	// high as the video's aspect ratio makes them
+	defaultPlayerWidth  = 640
+	defaultPlayerHeight = 360 // For videos without probed dimensions
+
+	// oEmbedCacheAge is how long consumers may cache an oEmbed response, in seconds
+	oEmbedCacheAge = 3600
+
+	// maxPreviewDescription caps descriptions in preview tags, which cards cut short anyway
+	maxPreviewDescription = 300
+)
+
+// oEmbedResponse is a video in oEmbed 1.0 terms. Thumbnails are only given
+// with their size, which the spec requires.
+type oEmbedResponse struct {
+	XMLName         xml.Name `json:"-" xml:"oembed"`
+	Type            string   `json:"type" xml:"type"`
+	Version         string   `json:"version" xml:"version"`
+	Title           string   `json:"title" xml:"title"`
+	AuthorName      string   `json:"author_name,omitempty" xml:"author_name,omitempty"`
+	AuthorURL       string   `json:"author_url,omitempty" xml:"author_url,omitempty"`
+	ProviderName    string   `json:"provider_name" xml:"provider_name"`
+	ProviderURL     string   `json:"provider_url" xml:"provider_url"`
+	CacheAge        int      `json:"cache_age" xml:"cache_age"`
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int      `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int      `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+	HTML            string   `json:"html" xml:"html"`
+	Width           int      `json:"width" xml:"width"`
+	Height          int      `json:"height" xml:"height"`
+}
+
+// metaTag is a <meta> tag of a preview page
+type metaTag struct {
+	Name    string
+	Content string
+}
+
+var playerTemplate = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>html,body{margin:0;height:100%;background:#000}video{width:100%;height:100%;display:block}</style>
+</head>
+<body>
+<video src="{{.Src}}"{{if .Poster}} poster="{{.Poster}}"{{end}} controls playsinline preload="metadata"></video>
+</body>
+</html>
+`))
+
+var metaTemplate = template.Must(template.New("meta").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.URL}}">
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedJSON}}" title="{{.Title}}">
+<link rel="alternate" type="text/xml+oembed" href="{{.OEmbedXML}}" title="{{.Title}}">
+{{range .Properties}}<meta property="{{.Name}}" content="{{.Content}}">
+{{end}}{{range .Names}}<meta name="{{.Name}}" content="{{.Content}}">
+{{end}}</head>
+<body>
+<p><a href="{{.URL}}">{{.Title}}</a></p>
+<script>location.replace({{.URL}})</script>
+</body>
+</html>
+`))
+
+// EmbedHandler lets other sites show our videos: an oEmbed provider for
+// embeds and link previews, the iframe player it embeds, and a page of
+// OpenGraph and Twitter card tags for crawlers that only read meta tags
+type EmbedHandler struct {
+	videoRepo      *models.VideoRepository
+	settingsRepo   *models.SettingsRepository
+	storageService *services.StorageService
+	urlSigner      *services.URLSigner
+	links          siteLinks
+}
+
+// NewEmbedHandler creates a new embed handler. publicURL and frontendURL are
+// the bases of links, as for NewFeedHandler.
+func NewEmbedHandler(
+	videoRepo *models.VideoRepository,
+	settingsRepo *models.SettingsRepository,
+	storageService *services.StorageService,
+	urlSigner *services.URLSigner,
+	publicURL, frontendURL string,
+) *EmbedHandler {
+	return &EmbedHandler{
+		videoRepo:      videoRepo,
+		settingsRepo:   settingsRepo,
+		storageService: storageService,
+		urlSigner:      urlSigner,
+		links:          siteLinks{urlSigner: urlSigner, publicURL: publicURL, frontendURL: frontendURL},
+	}
+}
+
+// RegisterRoutes registers the public embed routes, outside /api where
+// consumers and crawlers expect them
+func (h *EmbedHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/oembed", h.OEmbed)
+	r.Get("/embed/{id}", h.Player)
+	r.Get("/meta/videos/{id}", h.Meta)
+}
+
+// OEmbed describes the video of a watch page URL as an oEmbed "video", whose
+// html is an iframe of Player. As the spec asks, unknown URLs get a 404 and
+// formats other than json and xml a 501.
+// GET /oembed?url=https://videos.example.com/watch/12&format=json&maxwidth=480&maxheight=270
+func (h *EmbedHandler) OEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "xml" {
+		models.RespondError(w, "Format must be json or xml", http.StatusNotImplemented)
+		return
+	}
+
+	var maxSize [2]int
+	for i, name := range []string{"maxwidth", "maxheight"} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				models.RespondError(w, name+" must be a positive number", http.StatusBadRequest)
+				return
+			}
+			maxSize[i] = n
+		}
+	}
+
+	id, ok := h.watchVideoID(r, query.Get("url"))
+	if !ok {
+		models.RespondError(w, "URL is not a video on this site", http.StatusNotFound)
+		return
+	}
+	video, ok := h.loadVideo(w, id)
+	if !ok {
+		return
+	}
+	settings, ok := h.settings(w)
+	if !ok {
+		return
+	}
+
+	width, height := playerSize(video, maxSize[0], maxSize[1])
+	resp := oEmbedResponse{
+		Type:         "video",
+		Version:      "1.0",
+		Title:        video.Title,
+		AuthorName:   video.Creator,
+		AuthorURL:    h.links.page(r, "/?search="+url.QueryEscape(video.Creator)),
+		ProviderName: settings.SiteName,
+		ProviderURL:  h.links.page(r, "/"),
+		CacheAge:     oEmbedCacheAge,
+		HTML: fmt.Sprintf(
+			`<iframe src="%s" width="%d" height="%d" title="%s" frameborder="0" allow="autoplay; fullscreen; picture-in-picture" allowfullscreen></iframe>`,
+			html.EscapeString(h.links.api(r, "/embed/"+strconv.Itoa(video.ID))), width, height, html.EscapeString(video.Title),
+		),
+		Width:  width,
+		Height: height,
+	}
+	if tw, th := h.thumbnailSize(video); tw > 0 {
+		resp.ThumbnailURL = h.links.media(r, video, video.Thumbnail, "thumbnail")
+		resp.ThumbnailWidth, resp.ThumbnailHeight = tw, th
+	}
+
+	if format == "xml" {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Player is a bare page playing a video, for iframes on other sites. Its
+// links are signed, so it isn't cached for long.
+// GET /embed/{id}
+func (h *EmbedHandler) Player(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	video, ok := h.loadVideo(w, id)
+	if !ok {
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	if err := playerTemplate.Execute(w, map[string]interface{}{
+		"Title":  video.Title,
+		"Src":    h.urlSigner.SignURL(video.URL, clientIP),
+		"Poster": h.urlSigner.SignURL(video.Thumbnail, clientIP),
+	}); err != nil {
+		log.Printf("[Embed] ERROR: Failed to render player of video %d: %v", id, err)
+	}
+}
+
+// Meta is a page of OpenGraph and Twitter card tags describing a video, for
+// link preview crawlers, which don't run the site's scripts. A reverse proxy
+// sends crawlers of /watch/{id} here; people who land on it are sent on to
+// the watch page.
+// GET /meta/videos/{id}
+func (h *EmbedHandler) Meta(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	video, ok := h.loadVideo(w, id)
+	if !ok {
+		return
+	}
+	settings, ok := h.settings(w)
+	if !ok {
+		return
+	}
+
+	watchURL := h.links.watch(r, video.ID)
+	playerURL := h.links.api(r, "/embed/"+strconv.Itoa(video.ID))
+	description := previewDescription(video.Description)
+	width, height := playerSize(video, 0, 0)
+	size := [2]string{strconv.Itoa(width), strconv.Itoa(height)}
+
+	properties := []metaTag{
+		{"og:site_name", settings.SiteName},
+		{"og:type", "video.other"},
+		{"og:title", video.Title},
+		{"og:description", description},
+		{"og:url", watchURL},
+	}
+	names := []metaTag{
+		{"twitter:card", "player"},
+		{"twitter:title", video.Title},
+		{"twitter:description", description},
+		{"twitter:player", playerURL},
+		{"twitter:player:width", size[0]},
+		{"twitter:player:height", size[1]},
+	}
+
+	if thumbnail := h.links.media(r, video, video.Thumbnail, "thumbnail"); thumbnail != "" {
+		properties = append(properties, metaTag{"og:image", thumbnail})
+		if tw, th := h.thumbnailSize(video); tw > 0 {
+			properties = append(properties,
+				metaTag{"og:image:width", strconv.Itoa(tw)}, metaTag{"og:image:height", strconv.Itoa(th)})
+		}
+		names = append(names, metaTag{"twitter:image", thumbnail})
+	}
+	if video.URL != "" {
+		media := h.links.media(r, video, video.URL, "video")
+		mediaType := enclosureType(video.URL, video.IsAudioOnly())
+		properties = append(properties, metaTag{"og:video", media})
+		if strings.HasPrefix(media, "https://") {
+			properties = append(properties, metaTag{"og:video:secure_url", media})
+		}
+		properties = append(properties,
+			metaTag{"og:video:type", mediaType},
+			metaTag{"og:video:width", size[0]},
+			metaTag{"og:video:height", size[1]},
+		)
+		names = append(names,
+			metaTag{"twitter:player:stream", media}, metaTag{"twitter:player:stream:content_type", mediaType})
+	}
+
+	oEmbed := h.links.api(r, "/oembed?url="+url.QueryEscape(watchURL))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := metaTemplate.Execute(w, map[string]interface{}{
+		"Title":       video.Title,
+		"Description": description,
+		"URL":         watchURL,
+		"OEmbedJSON":  oEmbed + "&format=json",
+		"OEmbedXML":   oEmbed + "&format=xml",
+		"Properties":  properties,
+		"Names":       names,
+	}); err != nil {
+		log.Printf("[Embed] ERROR: Failed to render tags of video %d: %v", id, err)
+	}
+}
+
+// loadVideo fetches a video anyone with a link may watch, writing a 404 for
+// missing and unpublished ones
+func (h *EmbedHandler) loadVideo(w http.ResponseWriter, id int) (*models.Video, bool) {
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		log.Printf("[Embed] ERROR: Failed to fetch video %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return nil, false
+	}
+	if video == nil || !video.IsViewable() {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
+
+func (h *EmbedHandler) settings(w http.ResponseWriter) (*models.Settings, bool) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		log.Printf("[Embed] ERROR: Failed to fetch settings: %v", err)
+		models.RespondError(w, "Failed to fetch settings", http.StatusInternalServerError)
+		return nil, false
+	}
+	return settings, true
+}
+
+// watchVideoID finds the video of a watch page URL on this site, or on the
+// API's host when the site's isn't configured
+func (h *EmbedHandler) watchVideoID(r *http.Request, raw string) (int, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, false
+	}
+	site, err := url.Parse(h.links.page(r, "/"))
+	if err != nil || !strings.EqualFold(u.Host, site.Host) {
+		return 0, false
+	}
+	rest, ok := strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), "/watch/")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(rest)
+	return id, err == nil && id > 0
+}
+
+// thumbnailSize reads the size of a stored thumbnail, returning zeros for
+// external ones and images it can't decode
+func (h *EmbedHandler) thumbnailSize(v *models.Video) (int, int) {
+	if !strings.HasPrefix(v.Thumbnail, "/storage/") {
+		return 0, 0
+	}
+	width, height, err := h.storageService.ImageSize(v.Thumbnail)
+	if err != nil {
+		return 0, 0
+	}
+	return width, height
+}
+
+// playerSize sizes an embed of the video: defaultPlayerWidth wide with the
+// video's aspect ratio, scaled down to fit maxWidth and maxHeight where given
+func playerSize(v *models.Video, maxWidth, maxHeight int) (int, int) {
+	width, height := defaultPlayerWidth, defaultPlayerHeight
+	if v.Width > 0 && v.Height > 0 {
+		height = width * v.Height / v.Width
+	}
+	if maxWidth > 0 && width > maxWidth {
+		height, width = height*maxWidth/width, maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width, height = width*maxHeight/height, maxHeight
+	}
+	return max(width, 1), max(height, 1)
+}
+
+// previewDescription shortens a description to its first
+// maxPreviewDescription characters, on one line
+func previewDescription(description string) string {
+	description = strings.Join(strings.Fields(description), " ")
+	if utf8.RuneCountInString(description) <= maxPreviewDescription {
+		return description
+	}
+	runes := []rune(description)
+	return strings.TrimSpace(string(runes[:maxPreviewDescription-1])) + "…"
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"titan-backend/internal/models"
+)
+
+func TestPlayerSize(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height         int
+		maxWidth, maxHeight   int
+		wantWidth, wantHeight int
+	}{
+		{"unprobed", 0, 0, 0, 0, 640, 360},
+		{"widescreen", 1920, 1080, 0, 0, 640, 360},
+		{"portrait", 1080, 1920, 0, 0, 640, 1137},
+		{"max width", 1920, 1080, 320, 0, 320, 180},
+		{"max height", 1080, 1920, 0, 400, 225, 400},
+		{"larger maxes", 1920, 1080, 1000, 1000, 640, 360},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := playerSize(&models.Video{Width: tt.width, Height: tt.height}, tt.maxWidth, tt.maxHeight)
+			assert.Equal(t, tt.wantWidth, width)
+			assert.Equal(t, tt.wantHeight, height)
+		})
+	}
+}
+
+func TestWatchVideoID(t *testing.T) {
+	h := &EmbedHandler{links: siteLinks{frontendURL: "https://videos.example.com"}}
+	r := httptest.NewRequest("GET", "/oembed", nil)
+
+	for raw, want := range map[string]int{
+		"https://videos.example.com/watch/12":       12,
+		"http://VIDEOS.example.com/watch/12/?t=30":  12,
+		"https://videos.example.com/watch/0":        0,
+		"https://videos.example.com/watch/12/extra": 0,
+		"https://videos.example.com/embed/12":       0,
+		"https://example.com/watch/12":              0,
+		"ftp://videos.example.com/watch/12":         0,
+		"not a url":                                 0,
+	} {
+		id, ok := h.watchVideoID(r, raw)
+		assert.Equal(t, want, id, raw)
+		assert.Equal(t, want != 0, ok, raw)
+	}
+}
+
+func TestPreviewDescription(t *testing.T) {
+	assert.Equal(t, "Two lines", previewDescription("Two\n  lines "))
+
+	long := previewDescription(strings.Repeat("ab ", 200))
+	assert.Equal(t, maxPreviewDescription, len([]rune(long)))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
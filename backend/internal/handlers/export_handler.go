@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/exporter"
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+// ExportHandler streams catalog exports
+type ExportHandler struct {
+	exporter *exporter.Exporter
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(exp *exporter.Exporter) *ExportHandler {
+	return &ExportHandler{exporter: exp}
+}
+
+// RegisterRoutes registers the admin routes
+func (h *ExportHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/export/{entity}", h.Export)
+}
+
+// Export streams videos, categories, ads or settings as a JSON, CSV or
+// NDJSON download. Video exports can be imported as manifests.
+// GET /api/export/{entity}?format=csv&category=music&from=2024-01-01&to=2024-07-01&media=false
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	entity := chi.URLParam(r, "entity")
+	query := r.URL.Query()
+
+	opts := exporter.Options{
+		Format:       query.Get("format"),
+		Category:     query.Get("category"),
+		IncludeMedia: query.Get("media") != "false",
+	}
+	if opts.Format == "" {
+		opts.Format = exporter.FormatJSON
+	}
+	if err := exporter.Valid(entity, opts.Format); err != nil {
+		if errors.Is(err, exporter.ErrUnknownEntity) {
+			models.RespondError(w, "Entity must be videos, categories, ads or settings", http.StatusNotFound)
+			return
+		}
+		models.RespondError(w, "Format must be json, csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &opts.From}, {"to", &opts.To}} {
+		if value := query.Get(param.name); value != "" {
+			t, err := utils.ParseDate(value)
+			if err != nil {
+				models.RespondError(w, "Invalid "+param.name+" date; use YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+				return
+			}
+			*param.dest = &t
+		}
+	}
+
+	// Large catalogs take a while to stream
+	extendDeadlines(w)
+
+	filename := entity + "-" + time.Now().Format("20060102") + "." + opts.Format
+	w.Header().Set("Content-Type", exporter.ContentTypes[opts.Format])
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+
+	out := &trackingWriter{w: w}
+	if err := h.exporter.Export(out, entity, opts); err != nil {
+		log.Printf("[Export] ERROR: Failed to export %s: %v", entity, err)
+		// Once the body has started, the status can't change
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			models.RespondError(w, "Failed to export "+entity, http.StatusInternalServerError)
+		}
+	}
+}
+
+// trackingWriter records whether any of the response body was written
+type trackingWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/feeds"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// feedSize is how many of the newest videos a feed lists
+const feedSize = 50
+
+// feedMediaTypes maps the stored video extensions to enclosure types
+var feedMediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".avi":  "video/x-msvideo",
+}
+
+// FeedHandler serves RSS and Atom feeds of the newest published videos, for
+// the whole site, a category or a creator. Feeds don't carry signed links,
+// which expire: stored media is linked through a redirect that signs it when
+// fetched, so a feed only changes when its videos do and its ETag holds.
+type FeedHandler struct {
+	videoRepo    *models.VideoRepository
+	categoryRepo *models.CategoryRepository
+	creatorRepo  *models.CreatorRepository
+	settingsRepo *models.SettingsRepository
+	urlSigner    *services.URLSigner
+	links        siteLinks
+}
+
+// NewFeedHandler creates a new feed handler. publicURL and frontendURL are
+// the bases of feed and item links; empty ones default to the request's host.
+func NewFeedHandler(
+	videoRepo *models.VideoRepository,
+	categoryRepo *models.CategoryRepository,
+	creatorRepo *models.CreatorRepository,
+	settingsRepo *models.SettingsRepository,
+	urlSigner *services.URLSigner,
+	publicURL, frontendURL string,
+) *FeedHandler {
+	return &FeedHandler{
+		videoRepo:    videoRepo,
+		categoryRepo: categoryRepo,
+		creatorRepo:  creatorRepo,
+		settingsRepo: settingsRepo,
+		urlSigner:    urlSigner,
+		links:        siteLinks{urlSigner: urlSigner, publicURL: publicURL, frontendURL: frontendURL},
+	}
+}
+
+// RegisterRoutes registers the public feed routes, outside /api so feed
+// URLs stay short
+func (h *FeedHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/feeds/{file}", h.Videos)
+	r.Get("/feeds/categories/{file}", h.Category)
+	r.Get("/feeds/creators/{file}", h.Creator)
+	r.Get("/feeds/media/{id}/{file}", h.Media)
+}
+
+// Videos serves the feed of the whole site
+// GET /feeds/videos.rss, /feeds/videos.atom
+func (h *FeedHandler) Videos(w http.ResponseWriter, r *http.Request) {
+	name, format, ok := feedFile(w, r)
+	if !ok {
+		return
+	}
+	if name != "videos" {
+		models.RespondError(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	settings, ok := h.settings(w)
+	if !ok {
+		return
+	}
+	feed := &feeds.Feed{
+		Title:       settings.SiteName,
+		Description: settings.SiteDescription,
+		Link:        h.links.page(r, "/"),
+		Author:      settings.SiteName,
+	}
+	h.serve(w, r, feed, models.VideoFilter{}, format)
+}
+
+// Category serves the feed of a category
+// GET /feeds/categories/{id}.rss, /feeds/categories/{id}.atom
+func (h *FeedHandler) Category(w http.ResponseWriter, r *http.Request) {
+	id, format, ok := feedFile(w, r)
+	if !ok {
+		return
+	}
+	category, err := h.categoryRepo.GetByID(id)
+	if err != nil {
+		log.Printf("[Feed] ERROR: Failed to fetch category %s: %v", id, err)
+		models.RespondError(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+	if category == nil {
+		models.RespondError(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	settings, ok := h.settings(w)
+	if !ok {
+		return
+	}
+	feed := &feeds.Feed{
+		Title:       category.Name + " - " + settings.SiteName,
+		Description: "The newest " + category.Name + " videos on " + settings.SiteName,
+		// The site's category pages filter the home page by name
+		Link:    h.links.page(r, "/?category="+url.QueryEscape(category.Name)),
+		Author:  settings.SiteName,
+		Updated: category.CreatedAt,
+	}
+	h.serve(w, r, feed, models.VideoFilter{Category: category.ID}, format)
+}
+
+// Creator serves the feed of a creator. The name is matched by slug, so
+// /feeds/creators/John%20Doe.rss and /feeds/creators/john-doe.rss are the same.
+// GET /feeds/creators/{name}.rss, /feeds/creators/{name}.atom
+func (h *FeedHandler) Creator(w http.ResponseWriter, r *http.Request) {
+	name, format, ok := feedFile(w, r)
+	if !ok {
+		return
+	}
+	creator, err := h.creatorRepo.GetBySlug(models.Slugify(name))
+	if err != nil {
+		log.Printf("[Feed] ERROR: Failed to fetch creator %s: %v", name, err)
+		models.RespondError(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+	if creator == nil {
+		models.RespondError(w, "Creator not found", http.StatusNotFound)
+		return
+	}
+
+	settings, ok := h.settings(w)
+	if !ok {
+		return
+	}
+	feed := &feeds.Feed{
+		Title:       creator.Name + " - " + settings.SiteName,
+		Description: creator.Bio,
+		Link:        h.links.page(r, "/?search="+url.QueryEscape(creator.Name)),
+		// Aggregators given a name are pointed at the slug
+		Self:    h.links.api(r, "/feeds/creators/"+creator.Slug+"."+format),
+		Author:  creator.Name,
+		Image:   h.links.public(r, creator.Avatar),
+		Updated: creator.UpdatedAt,
+	}
+	if feed.Description == "" {
+		feed.Description = "The newest videos by " + creator.Name + " on " + settings.SiteName
+	}
+	h.serve(w, r, feed, models.VideoFilter{Creator: creator.Slug}, format)
+}
+
+// Media redirects to a freshly signed link to a video's file or thumbnail,
+// for feed enclosures. Only videos anyone with a link may watch are served.
+// GET /feeds/media/{id}/video.mp4, /feeds/media/{id}/thumbnail.jpg
+func (h *FeedHandler) Media(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		log.Printf("[Feed] ERROR: Failed to fetch video %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	if video == nil || !video.IsViewable() {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return
+	}
+
+	var target string
+	switch file := chi.URLParam(r, "file"); strings.TrimSuffix(file, path.Ext(file)) {
+	case "video":
+		target = video.URL
+	case "thumbnail":
+		target = video.Thumbnail
+	}
+	if target == "" {
+		models.RespondError(w, "File not found", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, h.urlSigner.SignURL(target, utils.ClientIP(r)), http.StatusFound)
+}
+
+// serve fills the feed with the newest published videos matching filter and
+// writes it, or a 304 if the client's copy is current. Self defaults to the
+// request's URL.
+func (h *FeedHandler) serve(w http.ResponseWriter, r *http.Request, feed *feeds.Feed, filter models.VideoFilter, format string) {
+	videos, _, err := h.videoRepo.GetAll(models.VideoQuery{
+		VideoFilter:      filter,
+		PaginationParams: utils.PaginationParams{Page: 1, Limit: feedSize},
+		Sort:             "created_at",
+		Order:            "desc",
+	})
+	if err != nil {
+		log.Printf("[Feed] ERROR: Failed to list videos for %s: %v", r.URL.Path, err)
+		models.RespondError(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	if feed.Self == "" {
+		feed.Self = h.links.api(r, r.URL.EscapedPath())
+	}
+	feed.Items = make([]feeds.Item, len(videos))
+	for i := range videos {
+		v := &videos[i]
+		link := h.links.watch(r, v.ID)
+		item := feeds.Item{
+			ID:          link,
+			Title:       v.Title,
+			Link:        link,
+			Description: v.Description,
+			Author:      v.Creator,
+			Category:    v.Category,
+			Published:   v.CreatedAt,
+			Updated:     v.UpdatedAt,
+			Thumbnail:   h.links.media(r, v, v.Thumbnail, "thumbnail"),
+			Duration:    time.Duration(v.DurationSeconds * float64(time.Second)),
+			Audio:       v.IsAudioOnly(),
+		}
+		if v.URL != "" {
+			item.Enclosure = &feeds.Enclosure{
+				URL:    h.links.media(r, v, v.URL, "video"),
+				Type:   enclosureType(v.URL, item.Audio),
+				Length: v.FileSize,
+			}
+		}
+		feed.Items[i] = item
+		if v.UpdatedAt.After(feed.Updated) {
+			feed.Updated = v.UpdatedAt
+		}
+	}
+
+	body, err := feeds.Render(feed, format)
+	if err != nil {
+		log.Printf("[Feed] ERROR: Failed to render %s: %v", r.URL.Path, err)
+		models.RespondError(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	// The body is the same until a listed video, or the feed's own details,
+	// change, so its hash makes a strong validator. Last-Modified can miss
+	// removals, so clients sending an ETag are answered by the ETag alone.
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", feeds.ContentTypes[format])
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if utils.NotModified(w, r, `"`+hex.EncodeToString(sum[:16])+`"`, feed.Updated) {
+		return
+	}
+	w.Write(body)
+}
+
+func (h *FeedHandler) settings(w http.ResponseWriter) (*models.Settings, bool) {
+	settings, err := h.settingsRepo.GetAll()
+	if err != nil {
+		log.Printf("[Feed] ERROR: Failed to fetch settings: %v", err)
+		models.RespondError(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return nil, false
+	}
+	return settings, true
+}
+
+// enclosureType returns the media type of a video file, as audio for files
+// without a picture
+func enclosureType(stored string, audio bool) string {
+	ext := strings.ToLower(path.Ext(strings.SplitN(stored, "?", 2)[0]))
+	mediaType, ok := feedMediaTypes[ext]
+	if !ok {
+		if mediaType = mime.TypeByExtension(ext); mediaType == "" {
+			return "application/octet-stream"
+		}
+	}
+	if audio {
+		mediaType = strings.Replace(mediaType, "video/", "audio/", 1)
+	}
+	return mediaType
+}
+
+// feedFile splits the file name of a feed URL into its name and format,
+// writing a 404 for extensions other than .rss and .atom
+func feedFile(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	file := chi.URLParam(r, "file")
+	i := strings.LastIndexByte(file, '.')
+	if i <= 0 {
+		models.RespondError(w, "Feed not found", http.StatusNotFound)
+		return "", "", false
+	}
+	name, format := file[:i], file[i+1:]
+	if _, ok := feeds.ContentTypes[format]; !ok {
+		models.RespondError(w, "Feeds are .rss or .atom files", http.StatusNotFound)
+		return "", "", false
+	}
+	return name, format, true
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"titan-backend/internal/middleware"
 	"titan-backend/internal/models"
 	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
 )
 
 // FileOperations handles core file management operations
@@ -117,13 +119,95 @@ func (h *FileOperations) List(w http.ResponseWriter, r *http.Request) {
 		totalSize += file.Size
 	}
 
-	models.RespondSuccess(w, "", map[string]interface{}{
+	data := map[string]interface{}{
 		"files":      files,
 		"folders":    folders,
 		"totalFiles": totalFiles,
 		"totalSize":  totalSize,
 		"folderPath": folderPath,
-	}, http.StatusOK)
+	}
+
+	// Paging is opt-in so clients that expect the whole directory keep
+	// getting it; the totals always cover the whole directory
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("page") || query.Has("cursor") {
+		pagination, err := utils.GetKeysetParams(r)
+		if err == nil && pagination.Cursor != nil {
+			if _, ok := pagination.Cursor.Key.(string); !ok {
+				err = utils.ErrInvalidCursor
+			}
+		}
+		if err != nil {
+			models.RespondError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		data["files"], data["folders"], data["pagination"] = pageDirectory(files, folders, pagination)
+	}
+
+	models.RespondSuccess(w, "", data, http.StatusOK)
+}
+
+// directoryEntry is a file or folder in a paged directory listing. Keys sort
+// folders before files, each by name.
+type directoryEntry struct {
+	key    string
+	file   *services.FileEntry
+	folder *services.FolderEntry
+}
+
+// pageDirectory pages a scanned directory. Directories are read whole, but
+// keyset paging still keeps pages stable while files are added or removed.
+func pageDirectory(files []services.FileEntry, folders []services.FolderEntry, p utils.PaginationParams) ([]services.FileEntry, []services.FolderEntry, utils.PaginationMeta) {
+	entries := make([]directoryEntry, 0, len(folders)+len(files))
+	for i := range folders {
+		entries = append(entries, directoryEntry{key: "0" + folders[i].Name, folder: &folders[i]})
+	}
+	for i := range files {
+		entries = append(entries, directoryEntry{key: "1" + files[i].Name, file: &files[i]})
+	}
+	slices.SortFunc(entries, func(a, b directoryEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	// Take up to one entry past the limit in the direction fetched, nearest
+	// first, the way a keyset query would return them
+	var fetched []directoryEntry
+	switch {
+	case p.Cursor == nil:
+		start := min(p.Offset, len(entries))
+		fetched = entries[start:min(start+p.Limit+1, len(entries))]
+	case p.Cursor.Before:
+		key := p.Cursor.Key.(string)
+		end, _ := slices.BinarySearchFunc(entries, key, func(e directoryEntry, key string) int {
+			return strings.Compare(e.key, key)
+		})
+		fetched = slices.Clone(entries[max(0, end-p.Limit-1):end])
+		slices.Reverse(fetched)
+	default:
+		key := p.Cursor.Key.(string)
+		start, found := slices.BinarySearchFunc(entries, key, func(e directoryEntry, key string) int {
+			return strings.Compare(e.key, key)
+		})
+		if found {
+			start++
+		}
+		fetched = entries[start:min(start+p.Limit+1, len(entries))]
+	}
+
+	page, meta := utils.KeysetPage(fetched, p, len(entries), func(e *directoryEntry) utils.Cursor {
+		return utils.Cursor{Sort: "name:asc", Key: e.key}
+	})
+
+	pageFiles := []services.FileEntry{}
+	pageFolders := []services.FolderEntry{}
+	for _, e := range page {
+		if e.folder != nil {
+			pageFolders = append(pageFolders, *e.folder)
+		} else {
+			pageFiles = append(pageFiles, *e.file)
+		}
+	}
+	return pageFiles, pageFolders, meta
 }
 
 // GetByID gets information about a single file
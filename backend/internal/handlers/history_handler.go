@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// HistoryHandler handles playback progress heartbeats, "continue watching"
+// and watch history. Progress is stored on the viewer's view logs.
+type HistoryHandler struct {
+	viewLogRepo *models.ViewLogRepository
+	videoRepo   *models.VideoRepository
+	urlSigner   *services.URLSigner
+}
+
+// NewHistoryHandler creates a new watch history handler
+func NewHistoryHandler(viewLogRepo *models.ViewLogRepository, videoRepo *models.VideoRepository, urlSigner *services.URLSigner) *HistoryHandler {
+	return &HistoryHandler{
+		viewLogRepo: viewLogRepo,
+		videoRepo:   videoRepo,
+		urlSigner:   urlSigner,
+	}
+}
+
+// RegisterRoutes registers the watch history routes. They are public; the
+// router should run middleware.OptionalAuth so signed in users keep their
+// history across devices.
+func (h *HistoryHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/videos/{id}/progress", h.GetProgress)
+	r.Post("/videos/{id}/progress", h.SaveProgress)
+	r.Get("/continue-watching", h.ContinueWatching)
+	r.Get("/history", h.List)
+	r.Delete("/history", h.Clear)
+	r.Delete("/history/{videoId}", h.Remove)
+}
+
+// GetProgress returns where the viewer left off in a video, for resuming
+// GET /api/videos/{id}/progress
+func (h *HistoryHandler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	viewerID, ok := requireViewer(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := h.viewLogRepo.GetProgress(id, viewerID)
+	if err != nil {
+		log.Printf("[History] ERROR: Failed to fetch progress on video %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch progress", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"progress": progress,
+	}, http.StatusOK)
+}
+
+// SaveProgress is the player's heartbeat. The position is stored on the
+// viewer's latest view of the video; without one, it is only saved where a
+// new view would be counted (saved is false otherwise).
+// POST /api/videos/{id}/progress {"position": 93.5, "duration": 612}
+func (h *HistoryHandler) SaveProgress(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Position float64 `json:"position"`
+		Duration float64 `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Position < 0 || req.Duration < 0 {
+		models.RespondError(w, "Position and duration cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	viewerID, ok := requireViewer(w, r)
+	if !ok {
+		return
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	if !canWatch(r, video) {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return
+	}
+
+	// Prefer the probed duration; the player's is only a fallback for
+	// videos uploaded before media probing
+	duration := video.DurationSeconds
+	if duration <= 0 {
+		duration = req.Duration
+	}
+	position := req.Position
+	if duration > 0 && position > duration {
+		position = duration
+	}
+
+	viewLog := &models.ViewLog{
+		VideoID:         id,
+		IPAddress:       utils.ClientIP(r),
+		UserAgent:       r.Header.Get("User-Agent"),
+		ViewerID:        viewerID,
+		PositionSeconds: position,
+		DurationSeconds: duration,
+	}
+	saved, err := h.viewLogRepo.SaveProgress(viewLog)
+	if err != nil {
+		log.Printf("[History] ERROR: Failed to save progress on video %d: %v", id, err)
+		models.RespondError(w, "Failed to save progress", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"videoId":         id,
+		"saved":           saved,
+		"positionSeconds": position,
+		"durationSeconds": duration,
+		"completed":       duration > 0 && position >= duration*models.WatchedFraction,
+	}, http.StatusOK)
+}
+
+// ContinueWatching lists videos the viewer started but didn't finish, most
+// recently watched first
+// GET /api/continue-watching?page=1&limit=20
+func (h *HistoryHandler) ContinueWatching(w http.ResponseWriter, r *http.Request) {
+	h.respondHistory(w, r, true)
+}
+
+// List returns the viewer's watch history, most recently watched first
+// GET /api/history?page=1&limit=20
+func (h *HistoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.respondHistory(w, r, false)
+}
+
+// Clear removes every video from the viewer's history
+// DELETE /api/history
+func (h *HistoryHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	viewerID, ok := requireViewer(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.viewLogRepo.ClearHistory(viewerID); err != nil {
+		log.Printf("[History] ERROR: Failed to clear history: %v", err)
+		models.RespondError(w, "Failed to clear history", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "History cleared", nil, http.StatusOK)
+}
+
+// Remove takes one video out of the viewer's history
+// DELETE /api/history/{videoId}
+func (h *HistoryHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	videoID, err := strconv.Atoi(chi.URLParam(r, "videoId"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+	viewerID, ok := requireViewer(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.viewLogRepo.RemoveFromHistory(viewerID, videoID); err != nil {
+		log.Printf("[History] ERROR: Failed to remove video %d from history: %v", videoID, err)
+		models.RespondError(w, "Failed to update history", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "Removed from history", nil, http.StatusOK)
+}
+
+func (h *HistoryHandler) respondHistory(w http.ResponseWriter, r *http.Request, inProgressOnly bool) {
+	viewerID, ok := requireViewer(w, r)
+	if !ok {
+		return
+	}
+
+	pagination := utils.GetPaginationParams(r)
+	history, total, err := h.viewLogRepo.ListHistory(viewerID, inProgressOnly, pagination.Page, pagination.Limit)
+	if err != nil {
+		log.Printf("[History] ERROR: Failed to list history: %v", err)
+		models.RespondError(w, "Failed to fetch history", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]int, len(history))
+	for i, entry := range history {
+		ids[i] = entry.VideoID
+	}
+	videos, err := h.videoRepo.GetByIDs(ids)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch videos", http.StatusInternalServerError)
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+	for i := range history {
+		if video, ok := videos[history[i].VideoID]; ok && canWatch(r, video) {
+			h.urlSigner.SignVideo(video, clientIP)
+			history[i].Video = video
+		}
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"history":    history,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total),
+	}, http.StatusOK)
+}
+
+// requireViewer resolves the viewer ID, writing a 400 response if there is none
+func requireViewer(w http.ResponseWriter, r *http.Request) (string, bool) {
+	viewerID, ok := middleware.ViewerID(r)
+	if !ok {
+		models.RespondError(w, "Sign in or send an "+middleware.DeviceIDHeader+" header", http.StatusBadRequest)
+	}
+	return viewerID, ok
+}
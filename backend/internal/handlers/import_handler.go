@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/importer"
+	"titan-backend/internal/models"
+)
+
+// maxManifestSize caps uploaded manifests; a full-size one with long
+// descriptions is a few MB
+const maxManifestSize = 10 << 20
+
+// ImportHandler creates videos in bulk from an uploaded manifest. Local
+// files it names are read from the import directory, where they are put
+// beforehand, e.g. with rsync or as a mounted volume.
+type ImportHandler struct {
+	importer  *importer.Importer
+	importDir string
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(imp *importer.Importer, importDir string) *ImportHandler {
+	os.MkdirAll(importDir, 0755)
+
+	return &ImportHandler{
+		importer:  imp,
+		importDir: importDir,
+	}
+}
+
+// RegisterRoutes registers the admin routes
+func (h *ImportHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/videos/import", h.Import)
+}
+
+// Import validates a CSV, JSON or NDJSON manifest and, unless dryRun is set, creates
+// its videos in one go. The report lists what became of every row. If any
+// row is invalid nothing is created and the report comes with a 422.
+// POST /api/videos/import?dryRun=true (multipart: manifest)
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	// Copying the files of a large catalog takes a while
+	extendDeadlines(w)
+
+	if err := r.ParseMultipartForm(maxManifestSize); err != nil {
+		models.RespondError(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("manifest")
+	if err != nil {
+		models.RespondError(w, "Manifest file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxManifestSize {
+		models.RespondError(w, "Manifest is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	format, ok := importer.FormatFromName(header.Filename)
+	if !ok {
+		models.RespondError(w, "Manifests must be .csv, .json or .ndjson files", http.StatusBadRequest)
+		return
+	}
+	rows, err := importer.ParseManifest(file, format)
+	if err != nil {
+		if errors.Is(err, importer.ErrInvalidManifest) {
+			models.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		models.RespondError(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := h.importer.Import(rows, importer.Options{
+		DryRun:  r.FormValue("dryRun") == "true",
+		Dir:     h.importDir,
+		Confine: true,
+	})
+	if err != nil {
+		log.Printf("[Import] ERROR: Failed to import %s: %v", header.Filename, err)
+		models.RespondError(w, "Import failed, nothing was imported", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"report": report,
+	}
+	switch {
+	case report.Invalid > 0:
+		models.RespondJSON(w, http.StatusUnprocessableEntity, models.APIResponse{
+			Success: false,
+			Error:   "Manifest has invalid rows, nothing was imported",
+			Data:    data,
+		})
+	case report.DryRun:
+		models.RespondSuccess(w, "Manifest is valid", data, http.StatusOK)
+	case report.Created == 0:
+		models.RespondSuccess(w, "All videos were imported before", data, http.StatusOK)
+	default:
+		log.Printf("[Import] Imported %d videos from %s", report.Created, header.Filename)
+		models.RespondSuccess(w, "Videos imported successfully", data, http.StatusCreated)
+	}
+}
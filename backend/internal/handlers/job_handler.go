@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// JobHandler exposes the background job queue to admins
+type JobHandler struct {
+	jobRepo  *models.JobRepository
+	jobQueue *services.JobQueue
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(jobRepo *models.JobRepository, jobQueue *services.JobQueue) *JobHandler {
+	return &JobHandler{
+		jobRepo:  jobRepo,
+		jobQueue: jobQueue,
+	}
+}
+
+var validJobStatuses = map[string]bool{
+	models.JobStatusPending:   true,
+	models.JobStatusRunning:   true,
+	models.JobStatusSucceeded: true,
+	models.JobStatusDead:      true,
+	models.JobStatusCancelled: true,
+}
+
+// List returns jobs newest first
+// GET /api/jobs?status=dead&type=storage.delete_files&page=1&limit=20
+func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
+	pagination := utils.GetPaginationParams(r)
+	status := r.URL.Query().Get("status")
+	jobType := r.URL.Query().Get("type")
+
+	if status != "" && !validJobStatuses[status] {
+		models.RespondError(w, "Invalid job status", http.StatusBadRequest)
+		return
+	}
+
+	jobs, total, err := h.jobRepo.List(status, jobType, pagination.Page, pagination.Limit)
+	if err != nil {
+		log.Printf("[Jobs] ERROR: Failed to list jobs: %v", err)
+		models.RespondError(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.jobRepo.CountByStatus()
+	if err != nil {
+		log.Printf("[Jobs] ERROR: Failed to count jobs: %v", err)
+		models.RespondError(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"jobs":       jobs,
+		"counts":     counts,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total),
+	}, http.StatusOK)
+}
+
+// GetByID returns a single job
+// GET /api/jobs/{id}
+func (h *JobHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"job": job,
+	}, http.StatusOK)
+}
+
+// Retry re-queues a dead or cancelled job
+// POST /api/jobs/{id}/retry
+func (h *JobHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.jobQueue.Retry(job.ID); err != nil {
+		h.respondQueueError(w, err, "retry")
+		return
+	}
+
+	log.Printf("[Jobs] Job %d (%s) re-queued by admin", job.ID, job.Type)
+	h.respondWithJob(w, job.ID, "Job queued for retry")
+}
+
+// Cancel stops a pending or running job
+// POST /api/jobs/{id}/cancel
+func (h *JobHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.jobQueue.Cancel(job.ID); err != nil {
+		h.respondQueueError(w, err, "cancel")
+		return
+	}
+
+	log.Printf("[Jobs] Job %d (%s) cancelled by admin", job.ID, job.Type)
+	h.respondWithJob(w, job.ID, "Job cancelled")
+}
+
+// loadJob parses the {id} URL param and fetches the job.
+// It returns false if it already wrote an error response.
+func (h *JobHandler) loadJob(w http.ResponseWriter, r *http.Request) (*models.Job, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		models.RespondError(w, "Invalid job ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	job, err := h.jobRepo.GetByID(id)
+	if err != nil {
+		log.Printf("[Jobs] ERROR: Failed to fetch job %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch job", http.StatusInternalServerError)
+		return nil, false
+	}
+	if job == nil {
+		models.RespondError(w, "Job not found", http.StatusNotFound)
+		return nil, false
+	}
+	return job, true
+}
+
+func (h *JobHandler) respondQueueError(w http.ResponseWriter, err error, action string) {
+	if errors.Is(err, services.ErrJobNotRetryable) || errors.Is(err, services.ErrJobNotCancellable) {
+		models.RespondError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("[Jobs] ERROR: Failed to %s job: %v", action, err)
+	models.RespondError(w, "Failed to "+action+" job", http.StatusInternalServerError)
+}
+
+func (h *JobHandler) respondWithJob(w http.ResponseWriter, id int64, message string) {
+	job, err := h.jobRepo.GetByID(id)
+	if err != nil || job == nil {
+		models.RespondSuccess(w, message, map[string]interface{}{"id": id}, http.StatusOK)
+		return
+	}
+	models.RespondSuccess(w, message, map[string]interface{}{"job": job}, http.StatusOK)
+}
+
+// RegisterRoutes registers the job admin routes (protected)
+func (h *JobHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/jobs", h.List)
+	r.Get("/jobs/{id}", h.GetByID)
+	r.Post("/jobs/{id}/retry", h.Retry)
+	r.Post("/jobs/{id}/cancel", h.Cancel)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// siteLinks builds absolute links for documents read away from the site,
+// such as feeds and link previews. Those are cached and fetched later, so
+// they can't carry signed /storage links, which expire.
+type siteLinks struct {
+	urlSigner   *services.URLSigner
+	publicURL   string // Base of API links; empty for the request's host
+	frontendURL string // Base of page links; empty for publicURL
+}
+
+// api returns an absolute link to a path of this server
+func (l *siteLinks) api(r *http.Request, p string) string {
+	return utils.BaseURL(r, l.publicURL) + p
+}
+
+// page returns an absolute link to a page of the site
+func (l *siteLinks) page(r *http.Request, p string) string {
+	base := l.frontendURL
+	if base == "" {
+		base = l.publicURL
+	}
+	return utils.BaseURL(r, base) + p
+}
+
+// watch returns the link to a video's watch page
+func (l *siteLinks) watch(r *http.Request, id int) string {
+	return l.page(r, "/watch/"+strconv.Itoa(id))
+}
+
+// media returns a link to a video's file or thumbnail that doesn't expire:
+// external URLs and public files as they are, other stored files through
+// FeedHandler.Media, which signs them when fetched
+func (l *siteLinks) media(r *http.Request, v *models.Video, stored, name string) string {
+	if link := l.public(r, stored); link != "" || stored == "" {
+		return link
+	}
+	return l.api(r, fmt.Sprintf("/feeds/media/%d/%s%s", v.ID, name, path.Ext(stored)))
+}
+
+// public returns an absolute link to a file anyone can fetch without a
+// signature, or "" for a stored file that needs one
+func (l *siteLinks) public(r *http.Request, stored string) string {
+	switch {
+	case stored == "", !strings.HasPrefix(stored, "/storage/"):
+		return stored
+	case l.urlSigner.IsPublic(stored):
+		return l.api(r, stored)
+	}
+	return ""
+}
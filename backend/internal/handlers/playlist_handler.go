@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// maxPlaylistItems caps how many videos a single playlist can hold
+const maxPlaylistItems = 5000
+
+// PlaylistHandler handles viewer- and admin-curated playlists
+type PlaylistHandler struct {
+	playlistRepo *models.PlaylistRepository
+	videoRepo    *models.VideoRepository
+	urlSigner    *services.URLSigner
+}
+
+// NewPlaylistHandler creates a new playlist handler
+func NewPlaylistHandler(playlistRepo *models.PlaylistRepository, videoRepo *models.VideoRepository, urlSigner *services.URLSigner) *PlaylistHandler {
+	return &PlaylistHandler{
+		playlistRepo: playlistRepo,
+		videoRepo:    videoRepo,
+		urlSigner:    urlSigner,
+	}
+}
+
+// RegisterRoutes registers the playlist routes. They are public; the router
+// should run middleware.OptionalAuth so signed in users own playlists by
+// account and admins can manage everyone's.
+func (h *PlaylistHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/playlists", h.List)
+	r.Post("/playlists", h.Create)
+	r.Get("/playlists/{id}", h.GetByID)
+	r.Put("/playlists/{id}", h.Update)
+	r.Delete("/playlists/{id}", h.Delete)
+	r.Post("/playlists/{id}/items", h.AddItem)
+	r.Put("/playlists/{id}/items/{videoId}", h.MoveItem)
+	r.Delete("/playlists/{id}/items/{videoId}", h.RemoveItem)
+}
+
+// List returns a page of public playlists, or with mine=true the viewer's own
+// GET /api/playlists?mine=true&page=1&limit=20
+func (h *PlaylistHandler) List(w http.ResponseWriter, r *http.Request) {
+	pagination := utils.GetPaginationParams(r)
+
+	var playlists []models.Playlist
+	var total int
+	var err error
+	viewerID, hasViewer := middleware.ViewerID(r)
+	if r.URL.Query().Get("mine") == "true" {
+		if !hasViewer {
+			models.RespondError(w, "Sign in or send an "+middleware.DeviceIDHeader+" header", http.StatusBadRequest)
+			return
+		}
+		playlists, total, err = h.playlistRepo.ListByOwner(viewerID, pagination.Page, pagination.Limit)
+	} else {
+		playlists, total, err = h.playlistRepo.ListPublic(pagination.Page, pagination.Limit)
+	}
+	if err != nil {
+		log.Printf("[Playlists] ERROR: Failed to list playlists: %v", err)
+		models.RespondError(w, "Failed to fetch playlists", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range playlists {
+		playlists[i].IsOwner = hasViewer && playlists[i].OwnerID == viewerID
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"playlists":  playlists,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total),
+	}, http.StatusOK)
+}
+
+// GetByID returns a playlist with a page of its videos in order
+// GET /api/playlists/{id}?page=1&limit=20
+func (h *PlaylistHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := h.loadPlaylist(w, r, false)
+	if !ok {
+		return
+	}
+
+	pagination := utils.GetPaginationParams(r)
+	items, err := h.playlistRepo.Items(playlist.ID, pagination.Page, pagination.Limit)
+	if err != nil {
+		log.Printf("[Playlists] ERROR: Failed to list items of playlist %s: %v", playlist.ID, err)
+		models.RespondError(w, "Failed to fetch playlist items", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.VideoID
+	}
+	videos, err := h.videoRepo.GetByIDs(ids)
+	if err != nil {
+		log.Printf("[Playlists] ERROR: Failed to load videos of playlist %s: %v", playlist.ID, err)
+		models.RespondError(w, "Failed to fetch playlist items", http.StatusInternalServerError)
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+	for i := range items {
+		if video, ok := videos[items[i].VideoID]; ok && canWatch(r, video) {
+			h.urlSigner.SignVideo(video, clientIP)
+			items[i].Video = video
+		}
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"playlist":   playlist,
+		"items":      items,
+		"pagination": utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, playlist.ItemCount),
+	}, http.StatusOK)
+}
+
+// Create makes a new playlist owned by the viewer. Visibility defaults to private.
+// POST /api/playlists
+func (h *PlaylistHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	viewerID, ok := middleware.ViewerID(r)
+	if !ok {
+		models.RespondError(w, "Sign in or send an "+middleware.DeviceIDHeader+" header", http.StatusBadRequest)
+		return
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = models.PlaylistPrivate
+	}
+	playlist := &models.Playlist{
+		ID:         uuid.New().String(),
+		OwnerID:    viewerID,
+		Visibility: req.Visibility,
+		IsOwner:    true,
+	}
+	if !applyPlaylistFields(w, playlist, &req.Title, &req.Description, &req.Visibility) {
+		return
+	}
+
+	if err := h.playlistRepo.Create(playlist); err != nil {
+		log.Printf("[Playlists] ERROR: Failed to create playlist: %v", err)
+		models.RespondError(w, "Failed to create playlist", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "Playlist created", map[string]interface{}{
+		"playlist": playlist,
+	}, http.StatusCreated)
+}
+
+// Update changes a playlist's title, description or visibility. Omitted
+// fields are left as they are.
+// PUT /api/playlists/{id}
+func (h *PlaylistHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.loadPlaylist(w, r, true)
+	if !ok {
+		return
+	}
+	if !applyPlaylistFields(w, playlist, req.Title, req.Description, req.Visibility) {
+		return
+	}
+
+	if err := h.playlistRepo.Update(playlist); err != nil {
+		log.Printf("[Playlists] ERROR: Failed to update playlist %s: %v", playlist.ID, err)
+		models.RespondError(w, "Failed to update playlist", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "Playlist updated", map[string]interface{}{
+		"playlist": playlist,
+	}, http.StatusOK)
+}
+
+// Delete removes a playlist. The videos in it are not affected.
+// DELETE /api/playlists/{id}
+func (h *PlaylistHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	playlist, ok := h.loadPlaylist(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.playlistRepo.Delete(playlist.ID); err != nil {
+		log.Printf("[Playlists] ERROR: Failed to delete playlist %s: %v", playlist.ID, err)
+		models.RespondError(w, "Failed to delete playlist", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "Playlist deleted", nil, http.StatusOK)
+}
+
+// AddItem adds a video to a playlist, at the end unless a position is given
+// POST /api/playlists/{id}/items {"videoId": 12, "position": 0}
+func (h *PlaylistHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		VideoID  int  `json:"videoId"`
+		Position *int `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.loadPlaylist(w, r, true)
+	if !ok {
+		return
+	}
+	if playlist.ItemCount >= maxPlaylistItems {
+		models.RespondError(w, "Playlist cannot hold more than "+strconv.Itoa(maxPlaylistItems)+" videos", http.StatusBadRequest)
+		return
+	}
+
+	video, err := h.videoRepo.GetByID(req.VideoID)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	if !canWatch(r, video) {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return
+	}
+
+	position, err := h.playlistRepo.AddItem(playlist.ID, video.ID, req.Position)
+	if !h.checkItemError(w, playlist.ID, err) {
+		return
+	}
+
+	models.RespondSuccess(w, "Video added to playlist", map[string]interface{}{
+		"videoId":  video.ID,
+		"position": position,
+	}, http.StatusCreated)
+}
+
+// MoveItem moves a video to a new index in the playlist, shifting the videos
+// in between. Out of range indexes are clamped.
+// PUT /api/playlists/{id}/items/{videoId} {"position": 0}
+func (h *PlaylistHandler) MoveItem(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Position *int `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Position == nil {
+		models.RespondError(w, "Position is required", http.StatusBadRequest)
+		return
+	}
+
+	videoID, err := strconv.Atoi(chi.URLParam(r, "videoId"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.loadPlaylist(w, r, true)
+	if !ok {
+		return
+	}
+
+	position, err := h.playlistRepo.MoveItem(playlist.ID, videoID, *req.Position)
+	if !h.checkItemError(w, playlist.ID, err) {
+		return
+	}
+
+	models.RespondSuccess(w, "Video moved", map[string]interface{}{
+		"videoId":  videoID,
+		"position": position,
+	}, http.StatusOK)
+}
+
+// RemoveItem takes a video out of a playlist
+// DELETE /api/playlists/{id}/items/{videoId}
+func (h *PlaylistHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	videoID, err := strconv.Atoi(chi.URLParam(r, "videoId"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.loadPlaylist(w, r, true)
+	if !ok {
+		return
+	}
+
+	if !h.checkItemError(w, playlist.ID, h.playlistRepo.RemoveItem(playlist.ID, videoID)) {
+		return
+	}
+
+	models.RespondSuccess(w, "Video removed from playlist", nil, http.StatusOK)
+}
+
+// loadPlaylist resolves the playlist in the URL and checks the viewer may see
+// it or, with edit, change it. Private playlists look missing to other viewers.
+func (h *PlaylistHandler) loadPlaylist(w http.ResponseWriter, r *http.Request, edit bool) (*models.Playlist, bool) {
+	playlist, err := h.playlistRepo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Failed to fetch playlist", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	viewerID, _ := middleware.ViewerID(r)
+	admin := middleware.IsAdmin(r)
+	if playlist == nil || !playlist.CanView(viewerID, admin) {
+		models.RespondError(w, "Playlist not found", http.StatusNotFound)
+		return nil, false
+	}
+	if edit && !playlist.CanEdit(viewerID, admin) {
+		models.RespondError(w, "You can only change your own playlists", http.StatusForbidden)
+		return nil, false
+	}
+
+	playlist.IsOwner = viewerID != "" && playlist.OwnerID == viewerID
+	return playlist, true
+}
+
+// checkItemError writes the response for a failed item change
+func (h *PlaylistHandler) checkItemError(w http.ResponseWriter, playlistID string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrPlaylistNotFound):
+		models.RespondError(w, "Playlist not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrNotInPlaylist):
+		models.RespondError(w, "Video is not in this playlist", http.StatusNotFound)
+	case errors.Is(err, models.ErrAlreadyInPlaylist):
+		models.RespondError(w, "Video is already in this playlist", http.StatusConflict)
+	default:
+		log.Printf("[Playlists] ERROR: Failed to update items of playlist %s: %v", playlistID, err)
+		models.RespondError(w, "Failed to update playlist", http.StatusInternalServerError)
+	}
+	return false
+}
+
+// applyPlaylistFields validates and copies the given fields onto the
+// playlist, writing a 400 response if any is invalid
+func applyPlaylistFields(w http.ResponseWriter, p *models.Playlist, title, description, visibility *string) bool {
+	if title != nil {
+		t := middleware.SanitizeString(*title)
+		if valid, msg := middleware.ValidateVideoTitle(t); !valid {
+			models.RespondError(w, msg, http.StatusBadRequest)
+			return false
+		}
+		p.Title = t
+	}
+	if description != nil {
+		d := middleware.SanitizeString(*description)
+		if valid, msg := middleware.ValidateDescription(d); !valid {
+			models.RespondError(w, msg, http.StatusBadRequest)
+			return false
+		}
+		p.Description = d
+	}
+	if visibility != nil {
+		if !models.ValidPlaylistVisibilities[*visibility] {
+			models.RespondError(w, "Visibility must be 'public', 'unlisted' or 'private'", http.StatusBadRequest)
+			return false
+		}
+		p.Visibility = *visibility
+	}
+	return true
+}
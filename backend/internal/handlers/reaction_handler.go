@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+)
+
+// ReactionHandler handles like/dislike reactions on videos
+type ReactionHandler struct {
+	reactionRepo *models.ReactionRepository
+	videoRepo    *models.VideoRepository
+}
+
+// NewReactionHandler creates a new reaction handler
+func NewReactionHandler(reactionRepo *models.ReactionRepository, videoRepo *models.VideoRepository) *ReactionHandler {
+	return &ReactionHandler{
+		reactionRepo: reactionRepo,
+		videoRepo:    videoRepo,
+	}
+}
+
+// RegisterRoutes registers the reaction routes. They are public; the router
+// should run middleware.OptionalAuth so signed in users are keyed by account.
+func (h *ReactionHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/videos/{id}/reaction", h.Get)
+	r.Post("/videos/{id}/reaction", h.Set)
+	r.Delete("/videos/{id}/reaction", h.Remove)
+}
+
+// Get returns the viewer's current reaction and the video's totals
+// GET /api/videos/{id}/reaction
+func (h *ReactionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	video, viewerID, ok := h.loadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	reaction, err := h.reactionRepo.Get(video.ID, viewerID)
+	if err != nil {
+		log.Printf("[Reactions] ERROR: Failed to fetch reaction for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to fetch reaction", http.StatusInternalServerError)
+		return
+	}
+
+	respondReaction(w, "", video.ID, reaction, &models.ReactionCounts{Likes: video.Likes, Dislikes: video.Dislikes})
+}
+
+// Set likes or dislikes a video, replacing any earlier reaction by the same viewer
+// POST /api/videos/{id}/reaction {"reaction": "like"}
+func (h *ReactionHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reaction string `json:"reaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidReactions[req.Reaction] {
+		models.RespondError(w, "Reaction must be 'like' or 'dislike'", http.StatusBadRequest)
+		return
+	}
+
+	video, viewerID, ok := h.loadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	counts, err := h.reactionRepo.Set(video.ID, viewerID, req.Reaction)
+	if err != nil {
+		log.Printf("[Reactions] ERROR: Failed to save reaction for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to save reaction", http.StatusInternalServerError)
+		return
+	}
+
+	respondReaction(w, "Reaction saved", video.ID, req.Reaction, counts)
+}
+
+// Remove clears the viewer's reaction
+// DELETE /api/videos/{id}/reaction
+func (h *ReactionHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	video, viewerID, ok := h.loadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	counts, err := h.reactionRepo.Remove(video.ID, viewerID)
+	if err != nil {
+		log.Printf("[Reactions] ERROR: Failed to remove reaction for video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to remove reaction", http.StatusInternalServerError)
+		return
+	}
+
+	respondReaction(w, "Reaction removed", video.ID, "", counts)
+}
+
+// loadRequest resolves the video and viewer, writing an error response on failure
+func (h *ReactionHandler) loadRequest(w http.ResponseWriter, r *http.Request) (*models.Video, string, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	viewerID, ok := middleware.ViewerID(r)
+	if !ok {
+		models.RespondError(w, "Sign in or send an "+middleware.DeviceIDHeader+" header", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return nil, "", false
+	}
+	if !canWatch(r, video) {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return nil, "", false
+	}
+
+	return video, viewerID, true
+}
+
+func respondReaction(w http.ResponseWriter, message string, videoID int, reaction string, counts *models.ReactionCounts) {
+	var current interface{}
+	if reaction != "" {
+		current = reaction
+	}
+	models.RespondSuccess(w, message, map[string]interface{}{
+		"videoId":  videoID,
+		"reaction": current,
+		"likes":    counts.Likes,
+		"dislikes": counts.Dislikes,
+	}, http.StatusOK)
+}
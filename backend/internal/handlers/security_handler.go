@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
 )
 
 // SecurityHandler handles security-related HTTP requests
@@ -30,41 +31,6 @@ type VPNCheckResponse struct {
 	ISP     string `json:"isp"`
 }
 
-// getClientIP extracts the real client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (common for proxies/load balancers)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			if ip != "" {
-				return ip
-			}
-		}
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Check CF-Connecting-IP (Cloudflare)
-	cfIP := r.Header.Get("CF-Connecting-IP")
-	if cfIP != "" {
-		return cfIP
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
-
 // isPrivateIP checks if an IP is private/local
 func isPrivateIP(ip string) bool {
 	parsedIP := net.ParseIP(ip)
@@ -102,7 +68,7 @@ func isPrivateIP(ip string) bool {
 // CheckVPN checks if the client is using a VPN or proxy
 // GET /api/check-vpn
 func (h *SecurityHandler) CheckVPN(w http.ResponseWriter, r *http.Request) {
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	// If it's a private/local IP, we can't check for VPN
 	if isPrivateIP(clientIP) {
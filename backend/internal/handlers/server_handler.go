@@ -12,6 +12,7 @@ import (
 
 	"titan-backend/internal/models"
 	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
 )
 
 var upgrader = websocket.Upgrader{
@@ -57,14 +58,24 @@ func (h *ServerHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var cursor *utils.Cursor
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		var err error
+		if cursor, err = utils.DecodeCursor(token); err != nil {
+			models.RespondError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
 	level := r.URL.Query().Get("level")
 	var logs []models.ServerLog
+	var meta utils.PaginationMeta
 	var err error
 
 	if level != "" {
-		logs, err = h.logRepo.GetByLevel(level, limit)
+		logs, meta, err = h.logRepo.GetByLevel(level, limit, cursor)
 	} else {
-		logs, err = h.logRepo.GetRecent(limit)
+		logs, meta, err = h.logRepo.GetRecent(limit, cursor)
 	}
 
 	if err != nil {
@@ -73,8 +84,9 @@ func (h *ServerHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	models.RespondSuccess(w, "", map[string]interface{}{
-		"logs":  logs,
-		"count": len(logs),
+		"logs":       logs,
+		"count":      len(logs),
+		"pagination": meta,
 	}, http.StatusOK)
 }
 
@@ -162,7 +174,7 @@ func (h *ServerHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
 	defer h.serverService.Unsubscribe(logChan)
 
 	// Send initial logs
-	initialLogs, _ := h.logRepo.GetRecent(50)
+	initialLogs, _, _ := h.logRepo.GetRecent(50, nil)
 	for i := len(initialLogs) - 1; i >= 0; i-- {
 		if err := conn.WriteJSON(initialLogs[i]); err != nil {
 			return
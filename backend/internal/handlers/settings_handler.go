@@ -3,17 +3,22 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"path"
+	"strings"
 
 	"titan-backend/internal/models"
+	"titan-backend/internal/services"
 )
 
 type SettingsHandler struct {
 	settingsRepo *models.SettingsRepository
+	urlSigner    *services.URLSigner
 }
 
-func NewSettingsHandler(settingsRepo *models.SettingsRepository) *SettingsHandler {
+func NewSettingsHandler(settingsRepo *models.SettingsRepository, urlSigner *services.URLSigner) *SettingsHandler {
 	return &SettingsHandler{
 		settingsRepo: settingsRepo,
+		urlSigner:    urlSigner,
 	}
 }
 
@@ -54,13 +59,43 @@ func (h *SettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	current.MaintenanceMode = req.MaintenanceMode
 	current.AllowNewUploads = req.AllowNewUploads
 	current.FeaturedVideoID = req.FeaturedVideoID
+	current.CommentModeration = req.CommentModeration
+
+	// A nil list means the field was omitted; an empty one makes everything private
+	if req.PublicStoragePaths != nil {
+		dirs, ok := normalizeStorageDirs(req.PublicStoragePaths)
+		if !ok {
+			models.RespondError(w, "Invalid public storage path", http.StatusBadRequest)
+			return
+		}
+		current.PublicStoragePaths = dirs
+	}
 
 	if err := h.settingsRepo.Update(current); err != nil {
 		models.RespondError(w, "Failed to update settings", http.StatusInternalServerError)
 		return
 	}
+	h.urlSigner.InvalidatePublicPaths()
 
 	models.RespondSuccess(w, "Settings updated successfully", map[string]interface{}{
 		"settings": current,
 	}, http.StatusOK)
 }
+
+// normalizeStorageDirs cleans directory names relative to /storage
+// ("/storage/thumbnails/" -> "thumbnails") and rejects paths that escape it
+func normalizeStorageDirs(dirs []string) ([]string, bool) {
+	cleaned := []string{}
+	for _, dir := range dirs {
+		if strings.Contains(dir, "..") || strings.Contains(dir, ",") {
+			return nil, false
+		}
+		dir = path.Clean("/" + strings.TrimSpace(dir))
+		dir = strings.TrimPrefix(strings.TrimPrefix(dir, "/storage/"), "/")
+		if dir == "" || dir == "storage" {
+			return nil, false
+		}
+		cleaned = append(cleaned, dir)
+	}
+	return cleaned, true
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/cache"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/sitemap"
+	"titan-backend/internal/utils"
+)
+
+// Kinds of child sitemaps, named {kind}-{page}.xml
+const (
+	sitemapVideos     = "videos"
+	sitemapCategories = "categories"
+	sitemapCreators   = "creators"
+)
+
+// errNoSitemap is returned by sitemap builders for pages past the last one
+var errNoSitemap = errors.New("no such sitemap")
+
+// sitemapFile is a rendered sitemap, as cached
+type sitemapFile struct {
+	body     []byte
+	etag     string
+	modified time.Time
+}
+
+// SitemapHandler serves /sitemap.xml, an index of paged sitemaps of the
+// published videos' watch pages (with Google video entries), the categories
+// and the creators. Sitemaps are built from the database and cached under the
+// catalog's PublishedSummary, so publishing, editing or removing a video
+// rebuilds them on the next request; other changes, such as renamed
+// categories, show once the TTL has passed.
+type SitemapHandler struct {
+	videoRepo    *models.VideoRepository
+	categoryRepo *models.CategoryRepository
+	creatorRepo  *models.CreatorRepository
+	links        siteLinks
+	cache        *cache.Cache
+}
+
+// NewSitemapHandler creates a new sitemap handler. publicURL and frontendURL
+// are the bases of links, as for feeds; built sitemaps are kept for ttl.
+func NewSitemapHandler(
+	videoRepo *models.VideoRepository,
+	categoryRepo *models.CategoryRepository,
+	creatorRepo *models.CreatorRepository,
+	urlSigner *services.URLSigner,
+	publicURL, frontendURL string,
+	ttl time.Duration,
+) *SitemapHandler {
+	return &SitemapHandler{
+		videoRepo:    videoRepo,
+		categoryRepo: categoryRepo,
+		creatorRepo:  creatorRepo,
+		links:        siteLinks{urlSigner: urlSigner, publicURL: publicURL, frontendURL: frontendURL},
+		cache:        cache.NewCache(ttl),
+	}
+}
+
+// RegisterRoutes registers the public sitemap routes. Crawlers only accept
+// sitemaps for URLs on their own host, so a site on FRONTEND_URL should serve
+// these through its reverse proxy or list the index in its robots.txt.
+func (h *SitemapHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/sitemap.xml", h.Index)
+	r.Get("/sitemaps/{file}", h.Sitemap)
+}
+
+// Index serves the sitemap index
+// GET /sitemap.xml
+func (h *SitemapHandler) Index(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, func(summary *models.PublishedSummary) ([]byte, time.Time, error) {
+		categories, err := h.categories()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		entries := []sitemap.Entry{}
+		add := func(kind string, count, perPage int) {
+			for page := 1; (page-1)*perPage < count; page++ {
+				entries = append(entries, sitemap.Entry{
+					Loc: h.links.api(r, fmt.Sprintf("/sitemaps/%s-%d.xml", kind, page)),
+				})
+			}
+		}
+		add(sitemapVideos, summary.VideoCount, sitemap.MaxVideoURLs)
+		add(sitemapCategories, len(categories), sitemap.MaxURLs)
+		add(sitemapCreators, summary.CreatorCount, sitemap.MaxURLs)
+
+		body, err := sitemap.RenderIndex(entries)
+		return body, summary.LastUpdated, err
+	})
+}
+
+// Sitemap serves a page of one of the sitemaps listed in the index
+// GET /sitemaps/videos-{page}.xml, /sitemaps/categories-{page}.xml, /sitemaps/creators-{page}.xml
+func (h *SitemapHandler) Sitemap(w http.ResponseWriter, r *http.Request) {
+	kind, page, ok := sitemapName(chi.URLParam(r, "file"))
+	if !ok {
+		models.RespondError(w, "Sitemap not found", http.StatusNotFound)
+		return
+	}
+
+	switch kind {
+	case sitemapVideos:
+		h.serve(w, r, func(*models.PublishedSummary) ([]byte, time.Time, error) {
+			return h.videos(r, page)
+		})
+	case sitemapCategories:
+		h.serve(w, r, func(*models.PublishedSummary) ([]byte, time.Time, error) {
+			return h.categoryPages(r, page)
+		})
+	case sitemapCreators:
+		h.serve(w, r, func(*models.PublishedSummary) ([]byte, time.Time, error) {
+			return h.creators(r, page)
+		})
+	default:
+		models.RespondError(w, "Sitemap not found", http.StatusNotFound)
+	}
+}
+
+// videos renders a page of the published videos' watch pages, oldest first
+// so that new videos only change the last page
+func (h *SitemapHandler) videos(r *http.Request, page int) ([]byte, time.Time, error) {
+	videos, _, err := h.videoRepo.GetAll(models.VideoQuery{
+		PaginationParams: utils.PaginationParams{
+			Page:   page,
+			Limit:  sitemap.MaxVideoURLs,
+			Offset: (page - 1) * sitemap.MaxVideoURLs,
+		},
+		Sort:  "created_at",
+		Order: "asc",
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(videos) == 0 {
+		return nil, time.Time{}, errNoSitemap
+	}
+
+	var modified time.Time
+	urls := make([]sitemap.URL, len(videos))
+	for i := range videos {
+		v := &videos[i]
+		entry := &sitemap.Video{
+			ThumbnailLoc:    h.links.media(r, v, v.Thumbnail, "thumbnail"),
+			Title:           v.Title,
+			Description:     v.Description,
+			PlayerLoc:       h.links.api(r, "/embed/"+strconv.Itoa(v.ID)),
+			Duration:        time.Duration(v.DurationSeconds * float64(time.Second)),
+			ViewCount:       v.Views,
+			PublicationDate: v.CreatedAt,
+			Uploader:        v.Creator,
+		}
+		if v.URL != "" {
+			entry.ContentLoc = h.links.media(r, v, v.URL, "video")
+		}
+		urls[i] = sitemap.URL{Loc: h.links.watch(r, v.ID), LastMod: v.UpdatedAt, Video: entry}
+		if v.UpdatedAt.After(modified) {
+			modified = v.UpdatedAt
+		}
+	}
+
+	body, err := sitemap.Render(urls)
+	return body, modified, err
+}
+
+// categoryPages renders a page of the categories with published videos. The
+// site's category pages filter the home page by name.
+func (h *SitemapHandler) categoryPages(r *http.Request, page int) ([]byte, time.Time, error) {
+	categories, err := h.categories()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	start := (page - 1) * sitemap.MaxURLs
+	if start >= len(categories) {
+		return nil, time.Time{}, errNoSitemap
+	}
+	categories = categories[start:]
+	if len(categories) > sitemap.MaxURLs {
+		categories = categories[:sitemap.MaxURLs]
+	}
+
+	urls := make([]sitemap.URL, len(categories))
+	for i, category := range categories {
+		urls[i] = sitemap.URL{Loc: h.links.page(r, "/?category="+url.QueryEscape(category.Name))}
+	}
+	body, err := sitemap.Render(urls)
+	return body, time.Time{}, err
+}
+
+// creators renders a page of the creators with published videos, whose
+// pages on the site are searches for their name. Pages are cached until the
+// catalog changes, so they follow creator IDs rather than views, which
+// change in between.
+func (h *SitemapHandler) creators(r *http.Request, page int) ([]byte, time.Time, error) {
+	creators, err := h.creatorRepo.ListByID(sitemap.MaxURLs, (page-1)*sitemap.MaxURLs)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(creators) == 0 {
+		return nil, time.Time{}, errNoSitemap
+	}
+
+	urls := make([]sitemap.URL, len(creators))
+	for i, creator := range creators {
+		urls[i] = sitemap.URL{Loc: h.links.page(r, "/?search="+url.QueryEscape(creator.Name))}
+	}
+	body, err := sitemap.Render(urls)
+	return body, time.Time{}, err
+}
+
+// categories returns the categories with published videos
+func (h *SitemapHandler) categories() ([]models.Category, error) {
+	all, err := h.categoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	categories := all[:0]
+	for _, category := range all {
+		if category.VideoCount > 0 {
+			categories = append(categories, category)
+		}
+	}
+	return categories, nil
+}
+
+// serve writes the sitemap built by build, or a 304 if the client's copy is
+// current. Built sitemaps are cached per catalog version and host, so only
+// the summary is read while the catalog is unchanged.
+func (h *SitemapHandler) serve(w http.ResponseWriter, r *http.Request, build func(*models.PublishedSummary) ([]byte, time.Time, error)) {
+	summary, err := h.videoRepo.PublishedSummary()
+	if err != nil {
+		log.Printf("[Sitemap] ERROR: Failed to summarize the catalog: %v", err)
+		models.RespondError(w, "Failed to fetch sitemap", http.StatusInternalServerError)
+		return
+	}
+
+	key := fmt.Sprintf("%s %s %s %d %d %d", h.links.api(r, ""), h.links.page(r, ""), r.URL.Path,
+		summary.VideoCount, summary.CreatorCount, summary.LastUpdated.UnixNano())
+	var file *sitemapFile
+	if cached, found := h.cache.Get(key); found {
+		file = cached.(*sitemapFile)
+	} else {
+		body, modified, err := build(summary)
+		if err == errNoSitemap {
+			models.RespondError(w, "Sitemap not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Sitemap] ERROR: Failed to build %s: %v", r.URL.Path, err)
+			models.RespondError(w, "Failed to fetch sitemap", http.StatusInternalServerError)
+			return
+		}
+		sum := sha256.Sum256(body)
+		file = &sitemapFile{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`, modified: modified}
+		h.cache.Set(key, file)
+	}
+
+	w.Header().Set("Content-Type", sitemap.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if utils.NotModified(w, r, file.etag, file.modified) {
+		return
+	}
+	w.Write(file.body)
+}
+
+// sitemapName splits a child sitemap's file name, such as videos-2.xml, into
+// its kind and page
+func sitemapName(file string) (string, int, bool) {
+	if !strings.HasSuffix(file, ".xml") {
+		return "", 0, false
+	}
+	file = strings.TrimSuffix(file, ".xml")
+	i := strings.LastIndexByte(file, '-')
+	if i <= 0 {
+		return "", 0, false
+	}
+	page, err := strconv.Atoi(file[i+1:])
+	if err != nil || page < 1 {
+		return "", 0, false
+	}
+	return file[:i], page, true
+}
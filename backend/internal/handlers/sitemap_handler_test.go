@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapName(t *testing.T) {
+	tests := []struct {
+		file string
+		kind string
+		page int
+		ok   bool
+	}{
+		{"videos-1.xml", "videos", 1, true},
+		{"categories-12.xml", "categories", 12, true},
+		{"videos-0.xml", "", 0, false},
+		{"videos-x.xml", "", 0, false},
+		{"videos-1.rss", "", 0, false},
+		{"-1.xml", "", 0, false},
+		{"videos.xml", "", 0, false},
+	}
+	for _, tt := range tests {
+		kind, page, ok := sitemapName(tt.file)
+		assert.Equal(t, tt.kind, kind, tt.file)
+		assert.Equal(t, tt.page, page, tt.file)
+		assert.Equal(t, tt.ok, ok, tt.file)
+	}
+}
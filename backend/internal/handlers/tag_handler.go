@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// maxTagSuggestions caps tag autocomplete results
+const maxTagSuggestions = 10
+
+// TagHandler serves tag listings, autocomplete and tag pages. Tags are added
+// and removed through VideoHandler.Update.
+type TagHandler struct {
+	tagRepo   *models.TagRepository
+	videoRepo *models.VideoRepository
+	urlSigner *services.URLSigner
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(tagRepo *models.TagRepository, videoRepo *models.VideoRepository, urlSigner *services.URLSigner) *TagHandler {
+	return &TagHandler{
+		tagRepo:   tagRepo,
+		videoRepo: videoRepo,
+		urlSigner: urlSigner,
+	}
+}
+
+// RegisterRoutes registers the public tag routes
+func (h *TagHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/tags", h.List)
+	r.Get("/tags/{slug}", h.Get)
+	r.Get("/tags/{slug}/videos", h.Videos)
+}
+
+// List returns the most used tags with their video counts. With q it
+// autocompletes: tags whose slug starts with q, most used first.
+// GET /api/tags?q=moun&limit=10
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("q")
+	limit := utils.GetPaginationParams(r).Limit
+	if prefix != "" && r.URL.Query().Get("limit") == "" {
+		limit = maxTagSuggestions
+	}
+
+	tags, err := h.tagRepo.List(prefix, limit)
+	if err != nil {
+		log.Printf("[Tag] ERROR: Failed to list tags: %v", err)
+		models.RespondError(w, "Failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"tags": tags,
+	}, http.StatusOK)
+}
+
+// Get returns a tag with its video count
+// GET /api/tags/{slug}
+func (h *TagHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tag, ok := h.loadTag(w, r)
+	if !ok {
+		return
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"tag": tag,
+	}, http.StatusOK)
+}
+
+// Videos lists a tag's videos, with the same filters, sorts and pagination
+// as the video listing
+// GET /api/tags/{slug}/videos?sort=views&limit=20
+func (h *TagHandler) Videos(w http.ResponseWriter, r *http.Request) {
+	tag, ok := h.loadTag(w, r)
+	if !ok {
+		return
+	}
+
+	q, err := parseVideoQuery(r)
+	if err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Tag = tag.Slug
+
+	videos, meta, err := h.videoRepo.GetAll(q)
+	if err != nil {
+		log.Printf("[Tag] ERROR: Failed to list videos tagged %s: %v", tag.Slug, err)
+		models.RespondError(w, "Failed to fetch videos", http.StatusInternalServerError)
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+	for i := range videos {
+		h.urlSigner.SignVideo(&videos[i], clientIP)
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"tag":        tag,
+		"videos":     videos,
+		"pagination": meta,
+	}, http.StatusOK)
+}
+
+// loadTag looks up the tag in the URL, writing a 404 if there is none. The
+// slug is normalized, so /tags/Machine%20Learning finds machine-learning.
+func (h *TagHandler) loadTag(w http.ResponseWriter, r *http.Request) (*models.Tag, bool) {
+	tag, err := h.tagRepo.GetBySlug(models.Slugify(chi.URLParam(r, "slug")))
+	if err != nil {
+		log.Printf("[Tag] ERROR: Failed to fetch tag: %v", err)
+		models.RespondError(w, "Failed to fetch tag", http.StatusInternalServerError)
+		return nil, false
+	}
+	if tag == nil {
+		models.RespondError(w, "Tag not found", http.StatusNotFound)
+		return nil, false
+	}
+	return tag, true
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,creation-with-upload,termination,expiration"
+
+	// uploadChunkTimeout replaces the server-wide read/write timeouts for a
+	// single PATCH so large chunks on slow links are not cut off after 15s
+	uploadChunkTimeout = 30 * time.Minute
+)
+
+// UploadHandler implements the tus 1.0 resumable upload protocol for videos.
+// Finished uploads are handed to VideoHandler to create the video record.
+type UploadHandler struct {
+	uploadService  *services.UploadService
+	storageService *services.StorageService
+	videoHandler   *VideoHandler
+}
+
+// NewUploadHandler creates a new upload handler
+func NewUploadHandler(
+	uploadService *services.UploadService,
+	storageService *services.StorageService,
+	videoHandler *VideoHandler,
+) *UploadHandler {
+	return &UploadHandler{
+		uploadService:  uploadService,
+		storageService: storageService,
+		videoHandler:   videoHandler,
+	}
+}
+
+// Options advertises the server's tus capabilities
+// OPTIONS /api/uploads
+func (h *UploadHandler) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.uploadService.MaxSize(), 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Create starts a new upload
+// POST /api/uploads
+// Headers: Upload-Length, Upload-Metadata (filename, title, creator, category, duration, description, thumbnail, tags, status, publishAt)
+func (h *UploadHandler) Create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		models.RespondError(w, "Upload-Length header is required", http.StatusBadRequest)
+		return
+	}
+	if length > h.uploadService.MaxSize() {
+		models.RespondError(w, "Upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		models.RespondError(w, "Invalid Upload-Metadata header", http.StatusBadRequest)
+		return
+	}
+
+	// Validate up front so the client doesn't send gigabytes that we'd reject
+	if metadata["title"] == "" || metadata["creator"] == "" {
+		models.RespondError(w, "Title and creator are required", http.StatusBadRequest)
+		return
+	}
+	if valid, msg := middleware.ValidateCreatorName(metadata["creator"]); !valid {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+	if msg := validateTags(splitTags(metadata["tags"])); msg != "" {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+	if _, _, err := models.ParseVideoStatus(metadata["status"], metadata["publishAt"], models.VideoStatusPublished); err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.storageService.IsAllowedVideo(metadata["filename"]) {
+		models.RespondError(w, "Invalid or missing filename", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := h.uploadService.Create(length, metadata)
+	if err != nil {
+		log.Printf("[Upload] ERROR: Failed to create upload: %v", err)
+		models.RespondError(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Upload] Created: id=%s, length=%d, filename=%s", upload.ID, upload.Length, metadata["filename"])
+
+	w.Header().Set("Location", "/api/uploads/"+upload.ID)
+
+	// creation-with-upload: the request body may already carry the first chunk
+	if r.Header.Get("Content-Type") == "application/offset+octet-stream" {
+		extendDeadlines(w)
+		written, err := h.uploadService.WriteChunk(upload.ID, 0, r.Body)
+		if err != nil {
+			log.Printf("[Upload] ERROR: Failed to write initial chunk for %s: %v", upload.ID, err)
+		}
+		if written != nil {
+			upload = written
+		}
+		if !h.finishIfComplete(w, upload) {
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	}
+
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Head returns the current offset so the client can resume
+// HEAD /api/uploads/{id}
+func (h *UploadHandler) Head(w http.ResponseWriter, r *http.Request) {
+	upload, err := h.uploadService.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(uploadErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if !upload.IsComplete() {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	if upload.VideoID != 0 {
+		w.Header().Set("Upload-Video-Id", strconv.Itoa(upload.VideoID))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Patch appends a chunk at Upload-Offset
+// PATCH /api/uploads/{id}
+func (h *UploadHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		models.RespondError(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		models.RespondError(w, "Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+
+	extendDeadlines(w)
+
+	id := chi.URLParam(r, "id")
+	upload, err := h.uploadService.WriteChunk(id, offset, r.Body)
+	if err != nil {
+		// An interrupted body still advanced the offset; anything else is a hard failure
+		if upload == nil || err == services.ErrUploadOffsetMismatch {
+			log.Printf("[Upload] PATCH rejected: id=%s, offset=%d: %v", id, offset, err)
+			models.RespondError(w, err.Error(), uploadErrorStatus(err))
+			return
+		}
+	}
+
+	if !h.finishIfComplete(w, upload) {
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete terminates an upload and discards its data
+// DELETE /api/uploads/{id}
+func (h *UploadHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.uploadService.Terminate(id); err != nil {
+		models.RespondError(w, err.Error(), uploadErrorStatus(err))
+		return
+	}
+
+	log.Printf("[Upload] Terminated: id=%s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errUploadDataLost is returned when a finished upload's data could neither
+// become a video nor be put back for a retry
+var errUploadDataLost = errors.New("upload data lost")
+
+// finishIfComplete creates the video once every byte has arrived.
+// It returns false if it already wrote an error response.
+func (h *UploadHandler) finishIfComplete(w http.ResponseWriter, upload *services.Upload) bool {
+	if upload.IsComplete() && upload.VideoID == 0 {
+		finished, err := h.uploadService.Finish(upload.ID, h.createUploadedVideo)
+		switch {
+		case errors.Is(err, services.ErrInvalidMedia), errors.Is(err, errUploadDataLost):
+			// Retrying can't fix the bytes, or bring them back, so drop the upload
+			h.uploadService.Terminate(upload.ID)
+			log.Printf("[Upload] Rejected upload %s: %v", upload.ID, err)
+			if errors.Is(err, services.ErrInvalidMedia) {
+				models.RespondError(w, err.Error(), http.StatusUnprocessableEntity)
+			} else {
+				models.RespondError(w, "Failed to create video record; please upload the file again", http.StatusInternalServerError)
+			}
+			return false
+		case err != nil:
+			// Another request finishing the upload holds its lock; the client
+			// finds the video with HEAD once it is done
+			status := uploadErrorStatus(err)
+			if status != http.StatusInternalServerError {
+				models.RespondError(w, err.Error(), status)
+				return false
+			}
+			log.Printf("[Upload] ERROR: Failed to finish upload %s: %v", upload.ID, err)
+			models.RespondError(w, "Failed to save video", status)
+			return false
+		}
+		*upload = *finished
+	}
+
+	if upload.VideoID != 0 {
+		w.Header().Set("Upload-Video-Id", strconv.Itoa(upload.VideoID))
+	}
+	return true
+}
+
+// createUploadedVideo moves a finished upload's data into storage and
+// creates its video. Run by UploadService.Finish under the upload's lock.
+func (h *UploadHandler) createUploadedVideo(upload *services.Upload) (int, error) {
+	dataPath := h.uploadService.DataPath(upload.ID)
+	videoURL, mediaInfo, err := h.storageService.ImportVideo(dataPath, upload.Metadata["filename"])
+	if err != nil {
+		return 0, err
+	}
+
+	// The status was checked when the upload was created, so this only fails
+	// if the publish time passed during the upload; the video is then
+	// published right away
+	status, publishAt, _ := models.ParseVideoStatus(upload.Metadata["status"], upload.Metadata["publishAt"], models.VideoStatusPublished)
+
+	video, err := h.videoHandler.createVideo(videoInput{
+		Title:       upload.Metadata["title"],
+		Creator:     upload.Metadata["creator"],
+		URL:         videoURL,
+		Thumbnail:   upload.Metadata["thumbnail"],
+		Category:    upload.Metadata["category"],
+		Duration:    upload.Metadata["duration"],
+		Description: upload.Metadata["description"],
+		Tags:        splitTags(upload.Metadata["tags"]),
+		Status:      status,
+		PublishAt:   publishAt,
+		Media:       mediaInfo,
+	})
+	if err != nil {
+		// Put the data back so a retried PATCH can finish the upload
+		if renameErr := os.Rename(strings.TrimPrefix(videoURL, "/"), dataPath); renameErr != nil {
+			log.Printf("[Upload] ERROR: Failed to restore data of upload %s: %v", upload.ID, renameErr)
+			h.storageService.DeleteFile(videoURL)
+			return 0, fmt.Errorf("%w: %v", errUploadDataLost, err)
+		}
+		return 0, err
+	}
+
+	log.Printf("[Upload] Completed: id=%s, videoId=%d", upload.ID, video.ID)
+	return video.ID, nil
+}
+
+// RegisterRoutes registers the tus upload routes
+func (h *UploadHandler) RegisterRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(tusResumable)
+		r.Options("/uploads", h.Options)
+		r.Head("/uploads/{id}", h.Head)
+		r.Patch("/uploads/{id}", h.Patch)
+		r.Delete("/uploads/{id}", h.Delete)
+	})
+}
+
+// RegisterCreateRoute registers upload creation separately so it can sit
+// behind the upload rate limiter without throttling every chunk
+func (h *UploadHandler) RegisterCreateRoute(r chi.Router) {
+	r.With(tusResumable).Post("/uploads", h.Create)
+}
+
+// tusResumable enforces the Tus-Resumable header required by the protocol
+func tusResumable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			models.RespondError(w, "Unsupported tus version", http.StatusPreconditionFailed)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseUploadMetadata decodes "key base64value,key2 base64value2"
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = strings.TrimSpace(string(value))
+	}
+
+	return metadata, nil
+}
+
+// extendDeadlines lifts the server-wide timeouts for long chunk transfers
+func extendDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(uploadChunkTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		log.Printf("[Upload] WARNING: Could not extend read deadline: %v", err)
+	}
+	rc.SetWriteDeadline(deadline)
+}
+
+func uploadErrorStatus(err error) int {
+	switch err {
+	case services.ErrUploadNotFound:
+		return http.StatusNotFound
+	case services.ErrUploadExpired:
+		return http.StatusGone
+	case services.ErrUploadOffsetMismatch:
+		return http.StatusConflict
+	case services.ErrUploadLocked:
+		return http.StatusLocked
+	case services.ErrUploadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
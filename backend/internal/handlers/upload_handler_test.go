@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+)
+
+// testMP4 builds a minimal MP4 that mediaprobe accepts: one 320x240 video
+// track of two seconds
+func testMP4() []byte {
+	box := func(typ string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+		return append(append(out, typ...), body...)
+	}
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	zeros := func(n int) []byte { return make([]byte, n) }
+
+	tkhd := box("tkhd", zeros(76), u32(320<<16), u32(240<<16))
+	entry := box("avc1", zeros(6), u16(1), zeros(16), u16(320), u16(240), zeros(50))
+	trak := box("trak", tkhd, box("mdia",
+		box("mdhd", zeros(12), u32(1000), u32(2000), zeros(4)),
+		box("hdlr", zeros(8), []byte("vide"), zeros(12)),
+		box("minf", box("stbl", box("stsd", zeros(4), u32(1), entry))),
+	))
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(0), []byte("isom")),
+		box("mdat", zeros(100)),
+		box("moov", box("mvhd", zeros(12), u32(1000), u32(2000), zeros(80)), trak),
+	}, nil)
+}
+
+func newTestUploadHandler(t *testing.T) (http.Handler, *services.UploadService, *sql.DB) {
+	t.Helper()
+
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "uploads.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.RunMigrations(db))
+
+	storage := services.NewStorageService(
+		filepath.Join(dir, "storage/videos"), filepath.Join(dir, "storage/thumbnails"),
+		filepath.Join(dir, "storage/ads"), filepath.Join(dir, "storage/captions"),
+	)
+	uploads := services.NewUploadService(filepath.Join(dir, "uploads"), 1<<20, time.Hour)
+	videoHandler := NewVideoHandler(
+		models.NewVideoRepository(db), models.NewViewLogRepository(db), storage,
+		services.NewJobQueue(models.NewJobRepository(db, "sqlite"), 1, 1), nil,
+		models.NewPlaylistRepository(db), models.NewTagRepository(db), models.NewCaptionRepository(db),
+		models.NewChapterRepository(db), models.NewCreatorRepository(db), time.Hour,
+	)
+
+	h := NewUploadHandler(uploads, storage, videoHandler)
+	r := chi.NewRouter()
+	h.RegisterRoutes(r)
+	h.RegisterCreateRoute(r)
+	return r, uploads, db
+}
+
+func tusRequest(method, target string, body []byte, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func createTestUpload(t *testing.T, h http.Handler, length int) string {
+	t.Helper()
+
+	meta := func(k, v string) string { return k + " " + base64.StdEncoding.EncodeToString([]byte(v)) }
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodPost, "/uploads", nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": meta("filename", "clip.mp4") + "," + meta("title", "Clip") + "," + meta("creator", "Ann"),
+	}))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	return filepath.Base(w.Header().Get("Location"))
+}
+
+func patchUpload(h http.Handler, id string, offset int, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodPatch, "/uploads/"+id, body, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	}))
+	return w
+}
+
+func countVideos(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM videos").Scan(&n))
+	return n
+}
+
+func TestUploadHandler_RetriedFinalPatch(t *testing.T) {
+	h, _, db := newTestUploadHandler(t)
+	data := testMP4()
+	id := createTestUpload(t, h, len(data))
+
+	w := patchUpload(h, id, 0, data[:10])
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, http.StatusConflict, patchUpload(h, id, 5, data[5:]).Code)
+
+	w = patchUpload(h, id, 10, data[10:])
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	videoID := w.Header().Get("Upload-Video-Id")
+	require.NotEmpty(t, videoID)
+
+	// The client didn't see the response and sends the last PATCH again
+	w = patchUpload(h, id, len(data), nil)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, videoID, w.Header().Get("Upload-Video-Id"))
+	assert.Equal(t, 1, countVideos(t, db))
+}
+
+func TestUploadHandler_ConcurrentFinalPatches(t *testing.T) {
+	h, uploads, db := newTestUploadHandler(t)
+	data := testMP4()
+	id := createTestUpload(t, h, len(data))
+
+	// Every byte has arrived, but no request has finished the upload yet
+	_, err := uploads.WriteChunk(id, 0, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := patchUpload(h, id, len(data), nil)
+			assert.Contains(t, []int{http.StatusNoContent, http.StatusLocked}, w.Code, w.Body.String())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, countVideos(t, db))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodHead, "/uploads/"+id, nil, nil))
+	assert.NotEmpty(t, w.Header().Get("Upload-Video-Id"))
+}
+
+func TestUploadHandler_InvalidMediaDropsUpload(t *testing.T) {
+	h, _, db := newTestUploadHandler(t)
+	data := []byte("not a video at all")
+	id := createTestUpload(t, h, len(data))
+
+	w := patchUpload(h, id, 0, data)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 0, countVideos(t, db))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, tusRequest(http.MethodHead, "/uploads/"+id, nil, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
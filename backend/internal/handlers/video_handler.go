@@ -2,11 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"titan-backend/internal/mediaprobe"
+	"titan-backend/internal/middleware"
 	"titan-backend/internal/models"
 	"titan-backend/internal/services"
 	"titan-backend/internal/utils"
@@ -16,40 +24,78 @@ type VideoHandler struct {
 	videoRepo      *models.VideoRepository
 	viewLogRepo    *models.ViewLogRepository
 	storageService *services.StorageService
+	jobQueue       *services.JobQueue
+	urlSigner      *services.URLSigner
+	playlistRepo   *models.PlaylistRepository
+	tagRepo        *models.TagRepository
+	captionRepo    *models.CaptionRepository
+	chapterRepo    *models.ChapterRepository
+	creatorRepo    *models.CreatorRepository
+	versionGrace   time.Duration // How long replaced files are kept
 }
 
 func NewVideoHandler(
 	videoRepo *models.VideoRepository,
 	viewLogRepo *models.ViewLogRepository,
 	storageService *services.StorageService,
+	jobQueue *services.JobQueue,
+	urlSigner *services.URLSigner,
+	playlistRepo *models.PlaylistRepository,
+	tagRepo *models.TagRepository,
+	captionRepo *models.CaptionRepository,
+	chapterRepo *models.ChapterRepository,
+	creatorRepo *models.CreatorRepository,
+	versionGrace time.Duration,
 ) *VideoHandler {
 	return &VideoHandler{
 		videoRepo:      videoRepo,
 		viewLogRepo:    viewLogRepo,
 		storageService: storageService,
+		jobQueue:       jobQueue,
+		urlSigner:      urlSigner,
+		playlistRepo:   playlistRepo,
+		tagRepo:        tagRepo,
+		captionRepo:    captionRepo,
+		chapterRepo:    chapterRepo,
+		creatorRepo:    creatorRepo,
+		versionGrace:   versionGrace,
 	}
 }
 
-func (h *VideoHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	pagination := utils.GetPaginationParams(r)
-	sort := r.URL.Query().Get("sort")
-	order := r.URL.Query().Get("order")
-	category := r.URL.Query().Get("category")
+// signVideo replaces stored /storage paths with signed links for a response
+func (h *VideoHandler) signVideo(r *http.Request, v *models.Video) {
+	h.urlSigner.SignVideo(v, utils.ClientIP(r))
+}
 
-	if sort == "" {
-		sort = "created_at"
+func (h *VideoHandler) signVideos(r *http.Request, videos []models.Video) {
+	for i := range videos {
+		h.signVideo(r, &videos[i])
 	}
-	if order == "" {
-		order = "desc"
+}
+
+// canWatch reports whether the request may see the video: anyone for
+// published and unlisted videos, only admins for the rest
+func canWatch(r *http.Request, v *models.Video) bool {
+	return v != nil && (v.IsViewable() || middleware.IsAdmin(r))
+}
+
+// GetAll lists videos, filtered and sorted
+// GET /api/videos?category=&creator=&verified=true&duration=short&uploaded=week&from=&to=&sort=views&order=desc
+func (h *VideoHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	q, err := parseVideoQuery(r)
+	if err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	videos, total, err := h.videoRepo.GetAll(pagination.Page, pagination.Limit, sort, order, category)
+	videos, meta, err := h.videoRepo.GetAll(q)
 	if err != nil {
+		log.Printf("[Video] ERROR: Failed to list videos: %v", err)
 		models.RespondError(w, "Failed to fetch videos", http.StatusInternalServerError)
 		return
 	}
 
-	meta := utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total)
+	h.signVideos(r, videos)
 
 	models.RespondSuccess(w, "", map[string]interface{}{
 		"videos":     videos,
@@ -57,6 +103,86 @@ func (h *VideoHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK)
 }
 
+// parseVideoQuery reads the filter, sort and pagination parameters shared by
+// listing and search. Unknown sorts fall back to the default, but malformed
+// filters and cursors are rejected rather than silently ignored.
+func parseVideoQuery(r *http.Request) (models.VideoQuery, error) {
+	params := r.URL.Query()
+	pagination, err := utils.GetKeysetParams(r)
+	if err != nil {
+		return models.VideoQuery{}, err
+	}
+	q := models.VideoQuery{
+		VideoFilter: models.VideoFilter{
+			Category: params.Get("category"),
+			Creator:  params.Get("creator"),
+			Tag:      models.Slugify(params.Get("tag")),
+		},
+		PaginationParams: pagination,
+		Sort:             params.Get("sort"),
+		Order:            params.Get("order"),
+	}
+
+	if verified := params.Get("verified"); verified != "" {
+		v, err := strconv.ParseBool(verified)
+		if err != nil {
+			return q, errors.New("verified must be true or false")
+		}
+		q.Verified = v
+	}
+
+	if duration := params.Get("duration"); duration != "" {
+		names := make([]string, len(models.DurationBuckets))
+		for i, bucket := range models.DurationBuckets {
+			names[i] = bucket.Name
+		}
+		if !slices.Contains(names, duration) {
+			return q, fmt.Errorf("duration must be one of: %s", strings.Join(names, ", "))
+		}
+		q.Duration = duration
+	}
+
+	if uploaded := params.Get("uploaded"); uploaded != "" {
+		if _, ok := models.UploadedWithin[uploaded]; !ok {
+			return q, errors.New("uploaded must be one of: day, week, month, year")
+		}
+		q.Uploaded = uploaded
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		value := params.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := utils.ParseDate(value)
+		if err != nil {
+			return q, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 time", bound.name)
+		}
+		*bound.dest = &t
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return q, errors.New("from must be before to")
+	}
+
+	// Admins see every video and can filter by status; everyone else only
+	// sees published ones
+	q.AllStatuses = middleware.IsAdmin(r)
+	if status := params.Get("status"); status != "" {
+		if !q.AllStatuses {
+			return q, errors.New("only admins can filter by status")
+		}
+		if !slices.Contains(models.VideoStatuses, status) {
+			return q, fmt.Errorf("status must be one of: %s", strings.Join(models.VideoStatuses, ", "))
+		}
+		q.Status = status
+	}
+
+	return q, nil
+}
+
 func (h *VideoHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
@@ -71,18 +197,79 @@ func (h *VideoHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if video == nil {
+	// Unlisted videos are reachable by their link; drafts, scheduled and
+	// private videos only by admins
+	if !canWatch(r, video) {
 		models.RespondError(w, "Video not found", http.StatusNotFound)
 		return
 	}
 
+	data := map[string]interface{}{}
+
+	// Watching from a playlist adds the neighbours for auto-advance
+	if playlistID := r.URL.Query().Get("playlist"); playlistID != "" {
+		playlist, err := h.playlistRepo.GetByID(playlistID)
+		if err != nil {
+			models.RespondError(w, "Failed to fetch playlist", http.StatusInternalServerError)
+			return
+		}
+		viewerID, _ := middleware.ViewerID(r)
+		if playlist == nil || !playlist.CanView(viewerID, middleware.IsAdmin(r)) {
+			models.RespondError(w, "Playlist not found", http.StatusNotFound)
+			return
+		}
+
+		position, err := h.playlistRepo.Position(playlist.ID, video.ID, middleware.IsAdmin(r))
+		if err != nil {
+			log.Printf("[Video] ERROR: Failed to find video %d in playlist %s: %v", video.ID, playlist.ID, err)
+			models.RespondError(w, "Failed to fetch playlist", http.StatusInternalServerError)
+			return
+		}
+		if position == nil {
+			models.RespondError(w, "Video is not in this playlist", http.StatusNotFound)
+			return
+		}
+
+		data["playlist"] = map[string]interface{}{
+			"id":              playlist.ID,
+			"title":           playlist.Title,
+			"position":        position.Position,
+			"total":           position.Total,
+			"previousVideoId": position.PreviousVideoID,
+			"nextVideoId":     position.NextVideoID,
+		}
+	}
+
+	tags, err := h.tagRepo.ForVideo(id)
+	if err != nil {
+		log.Printf("[Video] ERROR: Failed to fetch tags for video %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	video.Tags = tags
+
+	captionList, err := h.captionRepo.ListForVideo(id)
+	if err != nil {
+		log.Printf("[Video] ERROR: Failed to fetch captions for video %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	video.Captions = captionList
+
+	if video.Chapters, _, err = h.chapterRepo.ForVideo(video); err != nil {
+		log.Printf("[Video] ERROR: Failed to fetch chapters for video %d: %v", id, err)
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+
 	// Get related videos
 	relatedVideos, _ := h.videoRepo.GetRelated(id, video.Category, 6)
+	h.signVideo(r, video)
+	h.signVideos(r, relatedVideos)
 
-	models.RespondSuccess(w, "", map[string]interface{}{
-		"video":         video,
-		"relatedVideos": relatedVideos,
-	}, http.StatusOK)
+	data["video"] = video
+	data["relatedVideos"] = relatedVideos
+	models.RespondSuccess(w, "", data, http.StatusOK)
 }
 
 func (h *VideoHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -104,13 +291,25 @@ func (h *VideoHandler) Create(w http.ResponseWriter, r *http.Request) {
 		models.RespondError(w, "Title and creator are required", http.StatusBadRequest)
 		return
 	}
+	if valid, msg := middleware.ValidateCreatorName(creator); !valid {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
 
-	// Set default category if not provided
-	if category == "" {
-		category = "other"
+	tags := splitTags(r.FormValue("tags"))
+	if msg := validateTags(tags); msg != "" {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	status, publishAt, err := models.ParseVideoStatus(r.FormValue("status"), r.FormValue("publishAt"), models.VideoStatusPublished)
+	if err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	var videoURL string
+	var mediaInfo *mediaprobe.Info
 
 	// First, check if a URL was provided (for external video links)
 	urlValue := r.FormValue("url")
@@ -127,7 +326,11 @@ func (h *VideoHandler) Create(w http.ResponseWriter, r *http.Request) {
 		defer videoFile.Close()
 
 		// Save video file
-		videoURL, err = h.storageService.SaveVideo(videoFile, videoHeader)
+		videoURL, mediaInfo, err = h.storageService.SaveVideo(videoFile, videoHeader)
+		if errors.Is(err, services.ErrInvalidMedia) {
+			models.RespondError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		if err != nil {
 			models.RespondError(w, "Failed to save video: "+err.Error(), http.StatusBadRequest)
 			return
@@ -153,12 +356,7 @@ func (h *VideoHandler) Create(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Normalize URLs to relative paths for portability
-	videoURL = utils.NormalizeStorageURL(videoURL)
-	thumbnailURL = utils.NormalizeStorageURL(thumbnailURL)
-
-	// Create video record
-	video := &models.Video{
+	video, err := h.createVideo(videoInput{
 		Title:       title,
 		Creator:     creator,
 		URL:         videoURL,
@@ -166,9 +364,12 @@ func (h *VideoHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Category:    category,
 		Duration:    duration,
 		Description: description,
-	}
-
-	if err := h.videoRepo.Create(video); err != nil {
+		Tags:        tags,
+		Status:      status,
+		PublishAt:   publishAt,
+		Media:       mediaInfo,
+	})
+	if err != nil {
 		// Clean up uploaded files only if we uploaded them (not external URLs)
 		if urlValue == "" {
 			h.storageService.DeleteFile(videoURL)
@@ -180,11 +381,73 @@ func (h *VideoHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.signVideo(r, video)
 	models.RespondSuccess(w, "Video created successfully", map[string]interface{}{
 		"video": video,
 	}, http.StatusCreated)
 }
 
+// videoInput holds the fields shared by every video creation flow
+// (multipart form upload and resumable upload)
+type videoInput struct {
+	Title       string
+	Creator     string
+	URL         string
+	Thumbnail   string
+	Category    string
+	Duration    string
+	Description string
+	Tags        []string
+	Status      string // Published when empty
+	PublishAt   *time.Time
+	Media       *mediaprobe.Info // probed container metadata, nil for external URLs
+}
+
+// createVideo normalizes the input and inserts the video record.
+// Callers are responsible for cleaning up stored files on error.
+func (h *VideoHandler) createVideo(in videoInput) (*models.Video, error) {
+	// Set default category if not provided
+	if in.Category == "" {
+		in.Category = "other"
+	}
+
+	// Create video record, normalizing URLs to relative paths for portability
+	video := &models.Video{
+		Title:       in.Title,
+		Creator:     in.Creator,
+		URL:         utils.NormalizeStorageURL(in.URL),
+		Thumbnail:   utils.NormalizeStorageURL(in.Thumbnail),
+		Category:    in.Category,
+		Description: in.Description,
+		Status:      in.Status,
+		PublishAt:   in.PublishAt,
+	}
+	video.SetDuration(in.Duration)
+	video.ApplyMediaInfo(in.Media)
+
+	if err := h.videoRepo.Create(video); err != nil {
+		return nil, err
+	}
+
+	if len(in.Tags) > 0 {
+		tags, err := h.tagRepo.ApplyToVideo(video.ID, models.TagChange{Add: in.Tags})
+		if err != nil {
+			// The video itself is saved; tags can be fixed with an update
+			log.Printf("[Video] ERROR: Failed to tag video %d: %v", video.ID, err)
+		}
+		video.Tags = tags
+	}
+
+	// Remux to HLS in the background; the progressive file keeps working meanwhile
+	if services.CanPackageHLS(in.Media) {
+		if _, err := h.jobQueue.Enqueue(services.JobPackageHLS, services.PackageHLSPayload{VideoID: video.ID}); err != nil {
+			log.Printf("[Video] ERROR: Failed to queue HLS packaging for video %d: %v", video.ID, err)
+		}
+	}
+
+	return video, nil
+}
+
 func (h *VideoHandler) Update(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
@@ -212,12 +475,38 @@ func (h *VideoHandler) Update(w http.ResponseWriter, r *http.Request) {
 		Duration    string `json:"duration"`
 		Description string `json:"description"`
 		Verified    *bool  `json:"verified"`
+		Status      string `json:"status"`
+		PublishAt   string `json:"publishAt"` // RFC 3339; schedules the video
+
+		// Tags replaces every tag (an empty list clears them); addTags and
+		// removeTags edit them in bulk
+		Tags       []string `json:"tags"`
+		AddTags    []string `json:"addTags"`
+		RemoveTags []string `json:"removeTags"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
 		models.RespondError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if msg := validateTags(append(updateData.Tags, updateData.AddTags...)); msg != "" {
+		models.RespondError(w, msg, http.StatusBadRequest)
+		return
+	}
+	if updateData.Creator != "" {
+		if valid, msg := middleware.ValidateCreatorName(updateData.Creator); !valid {
+			models.RespondError(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	status, publishAt := existingVideo.Status, existingVideo.PublishAt
+	if updateData.Status != "" || updateData.PublishAt != "" {
+		status, publishAt, err = models.ParseVideoStatus(updateData.Status, updateData.PublishAt, existingVideo.Status)
+		if err != nil {
+			models.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Update fields
 	if updateData.Title != "" {
@@ -230,20 +519,38 @@ func (h *VideoHandler) Update(w http.ResponseWriter, r *http.Request) {
 		existingVideo.Category = updateData.Category
 	}
 	if updateData.Duration != "" {
-		existingVideo.Duration = updateData.Duration
+		existingVideo.SetDuration(updateData.Duration)
 	}
 	if updateData.Description != "" {
 		existingVideo.Description = updateData.Description
 	}
-	if updateData.Verified != nil {
-		existingVideo.Verified = *updateData.Verified
-	}
+	existingVideo.Status, existingVideo.PublishAt = status, publishAt
 
-	if err := h.videoRepo.Update(existingVideo); err != nil {
+	// Saved together with the details, so a tag change that would leave too
+	// many tags saves nothing
+	change := models.TagChange{Set: updateData.Tags, Add: updateData.AddTags, Remove: updateData.RemoveTags}
+	err = h.videoRepo.UpdateWithTags(existingVideo, change)
+	if errors.Is(err, models.ErrTooManyTags) {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[Video] ERROR: Failed to update video %d: %v", id, err)
 		models.RespondError(w, "Failed to update video", http.StatusInternalServerError)
 		return
 	}
 
+	// Verified belongs to the creator, so this verifies all of their videos
+	if updateData.Verified != nil && *updateData.Verified != existingVideo.Verified {
+		if err := h.creatorRepo.SetVerified(existingVideo.CreatorID, *updateData.Verified); err != nil {
+			log.Printf("[Video] ERROR: Failed to set verified on creator %d: %v", existingVideo.CreatorID, err)
+			models.RespondError(w, "Failed to update video", http.StatusInternalServerError)
+			return
+		}
+		existingVideo.Verified = *updateData.Verified
+	}
+
+	h.signVideo(r, existingVideo)
 	models.RespondSuccess(w, "Video updated successfully", map[string]interface{}{
 		"video": existingVideo,
 	}, http.StatusOK)
@@ -268,23 +575,252 @@ func (h *VideoHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	captionList, err := h.captionRepo.ListForVideo(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	versions, err := h.videoRepo.Versions(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+
 	// Delete from database
 	if err := h.videoRepo.Delete(id); err != nil {
 		models.RespondError(w, "Failed to delete video", http.StatusInternalServerError)
 		return
 	}
 
-	// Delete files
-	h.storageService.DeleteFile(video.URL)
-	if video.Thumbnail != "" {
-		h.storageService.DeleteFile(video.Thumbnail)
+	// Delete files in the background
+	files := []string{video.URL, video.Thumbnail, video.HLSURL}
+	for _, c := range captionList {
+		files = append(files, c.URL)
+	}
+	for _, v := range versions {
+		if strings.HasPrefix(v.URL, "/storage/") {
+			files = append(files, v.URL, v.HLSURL)
+		}
 	}
+	services.DeleteFilesInBackground(h.jobQueue, h.storageService, files...)
 
 	models.RespondSuccess(w, "Video deleted successfully", map[string]interface{}{
 		"deletedId": id,
 	}, http.StatusOK)
 }
 
+// PackageHLS queues (re)packaging of a stored video into HLS
+// POST /api/videos/{id}/hls
+func (h *VideoHandler) PackageHLS(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return
+	}
+	if video == nil {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return
+	}
+
+	job, err := h.jobQueue.Enqueue(services.JobPackageHLS, services.PackageHLSPayload{VideoID: id})
+	if err != nil {
+		log.Printf("[Video] ERROR: Failed to queue HLS packaging for video %d: %v", id, err)
+		models.RespondError(w, "Failed to queue packaging", http.StatusInternalServerError)
+		return
+	}
+
+	models.RespondSuccess(w, "HLS packaging queued", map[string]interface{}{
+		"job": job,
+	}, http.StatusAccepted)
+}
+
+// ReplaceMedia swaps a video's media file for a new upload, keeping its ID,
+// views, comments and everything else. The previous file is kept as a
+// version for the grace period, then deleted.
+// PUT /api/videos/{id}/media (multipart: video)
+func (h *VideoHandler) ReplaceMedia(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadManagedVideo(w, r)
+	if !ok {
+		return
+	}
+
+	// The new file may be up to 2 GB, more than the server timeouts allow for
+	extendDeadlines(w)
+
+	if err := r.ParseMultipartForm(2 << 30); err != nil {
+		models.RespondError(w, "Failed to parse form or file too large", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("video")
+	if err != nil {
+		models.RespondError(w, "Video file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	videoURL, mediaInfo, err := h.storageService.SaveVideo(file, header)
+	if errors.Is(err, services.ErrInvalidMedia) {
+		models.RespondError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		models.RespondError(w, "Failed to save video: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := h.videoRepo.ReplaceMedia(video, videoURL, mediaInfo, time.Now().Add(h.versionGrace))
+	if err != nil {
+		h.storageService.DeleteFile(videoURL)
+		h.respondReplaceError(w, video.ID, err)
+		return
+	}
+	services.PurgeVersionLater(h.jobQueue, version)
+
+	if services.CanPackageHLS(mediaInfo) {
+		if _, err := h.jobQueue.Enqueue(services.JobPackageHLS, services.PackageHLSPayload{VideoID: video.ID}); err != nil {
+			log.Printf("[Video] ERROR: Failed to queue HLS packaging for video %d: %v", video.ID, err)
+		}
+	}
+
+	h.signVideo(r, video)
+	models.RespondSuccess(w, "Video media replaced successfully", map[string]interface{}{
+		"video":           video,
+		"previousVersion": version,
+	}, http.StatusOK)
+}
+
+// ReplaceThumbnail swaps a video's thumbnail for a new upload, keeping the
+// previous one as a version for the grace period
+// PUT /api/videos/{id}/thumbnail (multipart: thumbnail)
+func (h *VideoHandler) ReplaceThumbnail(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadManagedVideo(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		models.RespondError(w, "Failed to parse form or file too large", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("thumbnail")
+	if err != nil {
+		models.RespondError(w, "Thumbnail file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	thumbnailURL, err := h.storageService.SaveThumbnail(file, header)
+	if err != nil {
+		models.RespondError(w, "Failed to save thumbnail: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := h.videoRepo.ReplaceThumbnail(video, thumbnailURL, time.Now().Add(h.versionGrace))
+	if err != nil {
+		h.storageService.DeleteFile(thumbnailURL)
+		h.respondReplaceError(w, video.ID, err)
+		return
+	}
+	if version != nil {
+		services.PurgeVersionLater(h.jobQueue, version)
+	}
+
+	h.signVideo(r, video)
+	models.RespondSuccess(w, "Thumbnail replaced successfully", map[string]interface{}{
+		"video":           video,
+		"previousVersion": version,
+	}, http.StatusOK)
+}
+
+// Versions lists the replaced media files and thumbnails still kept for a
+// video, newest first
+// GET /api/videos/{id}/versions
+func (h *VideoHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	video, ok := h.loadManagedVideo(w, r)
+	if !ok {
+		return
+	}
+
+	versions, err := h.videoRepo.Versions(video.ID)
+	if err != nil {
+		log.Printf("[Video] ERROR: Failed to list versions of video %d: %v", video.ID, err)
+		models.RespondError(w, "Failed to fetch versions", http.StatusInternalServerError)
+		return
+	}
+	ip := utils.ClientIP(r)
+	for i := range versions {
+		versions[i].URL = h.urlSigner.SignURL(versions[i].URL, ip)
+		versions[i].HLSURL = h.urlSigner.SignHLSURL(versions[i].HLSURL, ip)
+	}
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"versions": versions,
+	}, http.StatusOK)
+}
+
+func (h *VideoHandler) respondReplaceError(w http.ResponseWriter, videoID int, err error) {
+	if errors.Is(err, models.ErrVideoChanged) {
+		models.RespondError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("[Video] ERROR: Failed to replace file of video %d: %v", videoID, err)
+	models.RespondError(w, "Failed to update video", http.StatusInternalServerError)
+}
+
+// loadManagedVideo resolves the {id} video for an admin route, writing an
+// error response on failure
+func (h *VideoHandler) loadManagedVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		models.RespondError(w, "Invalid video ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(id)
+	if err != nil {
+		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
+		return nil, false
+	}
+	if video == nil {
+		models.RespondError(w, "Video not found", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
+
+// splitTags reads a comma-separated tag list, as sent by upload forms
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// validateTags checks tag names being added, returning an error message or ""
+func validateTags(tags []string) string {
+	if len(tags) > models.MaxVideoTags {
+		return models.ErrTooManyTags.Error()
+	}
+	for _, tag := range tags {
+		if valid, msg := middleware.ValidateTag(tag); !valid {
+			return msg
+		}
+	}
+	return ""
+}
+
+// Search runs a full-text search with the same filters and sorts as GetAll,
+// plus relevance (the default). Facets count the matches per filter value.
+// GET /api/videos/search?q=mountain&duration=short&sort=relevance
 func (h *VideoHandler) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
@@ -292,26 +828,91 @@ func (h *VideoHandler) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	category := r.URL.Query().Get("category")
-	pagination := utils.GetPaginationParams(r)
+	q, err := parseVideoQuery(r)
+	if err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	videos, total, err := h.videoRepo.Search(query, category, pagination.Page, pagination.Limit)
+	results, meta, err := h.videoRepo.Search(query, q)
 	if err != nil {
+		log.Printf("[Video] ERROR: Search for %q failed: %v", query, err)
 		models.RespondError(w, "Search failed", http.StatusInternalServerError)
 		return
 	}
 
-	meta := utils.CalculatePaginationMeta(pagination.Page, pagination.Limit, total)
+	facets, err := h.videoRepo.SearchFacets(query, q.VideoFilter)
+	if err != nil {
+		log.Printf("[Video] ERROR: Facet counts for %q failed: %v", query, err)
+		models.RespondError(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
 
-	filters := map[string]interface{}{}
-	if category != "" {
-		filters["category"] = category
+	for i := range results {
+		h.signVideo(r, &results[i].Video)
 	}
 
 	models.RespondSuccess(w, "", map[string]interface{}{
-		"results":    videos,
+		"results":    results,
 		"query":      query,
-		"filters":    filters,
+		"filters":    q.VideoFilter,
+		"facets":     facets,
+		"pagination": meta,
+	}, http.StatusOK)
+}
+
+// maxTrendingPerCategory caps perCategory on the trending endpoint
+const maxTrendingPerCategory = 20
+
+// Trending lists videos with recent views, hottest first. It takes the same
+// filters as GetAll. With perCategory it returns the top videos of each
+// category instead of one page.
+// GET /api/videos/trending?category=music&limit=20
+// GET /api/videos/trending?perCategory=5
+func (h *VideoHandler) Trending(w http.ResponseWriter, r *http.Request) {
+	q, err := parseVideoQuery(r)
+	if err != nil {
+		models.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Trending = true
+
+	if value := r.URL.Query().Get("perCategory"); value != "" {
+		perCategory, err := strconv.Atoi(value)
+		if err != nil || perCategory < 1 || perCategory > maxTrendingPerCategory {
+			models.RespondError(w, fmt.Sprintf("perCategory must be between 1 and %d", maxTrendingPerCategory), http.StatusBadRequest)
+			return
+		}
+
+		categories, err := h.videoRepo.TrendingByCategory(q.VideoFilter, perCategory)
+		if err != nil {
+			log.Printf("[Video] ERROR: Failed to list trending videos by category: %v", err)
+			models.RespondError(w, "Failed to fetch trending videos", http.StatusInternalServerError)
+			return
+		}
+		for i := range categories {
+			h.signVideos(r, categories[i].Videos)
+		}
+
+		models.RespondSuccess(w, "", map[string]interface{}{
+			"categories": categories,
+		}, http.StatusOK)
+		return
+	}
+
+	// The cursor carries the sort, so this only matters for the first page
+	q.Sort, q.Order = "trending", "desc"
+	videos, meta, err := h.videoRepo.GetAll(q)
+	if err != nil {
+		log.Printf("[Video] ERROR: Failed to list trending videos: %v", err)
+		models.RespondError(w, "Failed to fetch trending videos", http.StatusInternalServerError)
+		return
+	}
+
+	h.signVideos(r, videos)
+
+	models.RespondSuccess(w, "", map[string]interface{}{
+		"videos":     videos,
 		"pagination": meta,
 	}, http.StatusOK)
 }
@@ -330,21 +931,18 @@ func (h *VideoHandler) IncrementView(w http.ResponseWriter, r *http.Request) {
 		models.RespondError(w, "Failed to fetch video", http.StatusInternalServerError)
 		return
 	}
-	if video == nil {
+	if !canWatch(r, video) {
 		models.RespondError(w, "Video not found", http.StatusNotFound)
 		return
 	}
 
 	// Get IP address
-	ipAddress := r.Header.Get("X-Forwarded-For")
-	if ipAddress == "" {
-		ipAddress = r.RemoteAddr
-	}
+	ipAddress := utils.ClientIP(r)
 
 	userAgent := r.Header.Get("User-Agent")
 
 	// Check for recent view (throttle: 1 view per IP per video per 24 hours)
-	hasRecentView, err := h.viewLogRepo.HasRecentView(id, ipAddress, 24)
+	hasRecentView, err := h.viewLogRepo.HasRecentView(id, ipAddress, models.ViewThrottleHours)
 	if err != nil {
 		models.RespondError(w, "Failed to check view history", http.StatusInternalServerError)
 		return
@@ -358,11 +956,11 @@ func (h *VideoHandler) IncrementView(w http.ResponseWriter, r *http.Request) {
 			IPAddress: ipAddress,
 			UserAgent: userAgent,
 		}
+		// Tie the view to the viewer so it shows up in their watch history
+		viewLog.ViewerID, _ = middleware.ViewerID(r)
+		// Logging the view also increments the view count
 		if err := h.viewLogRepo.Create(viewLog); err == nil {
-			// Increment view count
-			if err := h.videoRepo.IncrementViews(id); err == nil {
-				viewCounted = true
-			}
+			viewCounted = true
 		}
 	}
 
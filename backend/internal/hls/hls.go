@@ -0,0 +1,214 @@
+// Package hls remuxes progressive H.264/AAC MP4 files into CMAF (fragmented
+// MP4) segments and an HLS media playlist. Nothing is re-encoded: segments
+// are cut at video keyframes, so their length depends on the source GOP.
+package hls
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrInvalidMP4 is returned when the source isn't a well-formed progressive MP4
+	ErrInvalidMP4 = errors.New("hls: invalid or truncated mp4")
+	// ErrUnsupported is returned for valid files the segmenter can't package (codecs, layout)
+	ErrUnsupported = errors.New("hls: unsupported source")
+)
+
+// File names written into the output directory
+const (
+	InitSegmentName = "init.mp4"
+	segmentPattern  = "seg_%05d.m4s"
+)
+
+// DefaultSegmentDuration is the target segment length recommended by Apple's HLS authoring spec
+const DefaultSegmentDuration = 6 * time.Second
+
+// Options configures Package
+type Options struct {
+	// SegmentDuration is the minimum segment length; segments end at the first keyframe after it
+	SegmentDuration time.Duration
+}
+
+// Segment is one media segment listed in the playlist
+type Segment struct {
+	Name     string
+	Duration float64 // seconds
+}
+
+// Playlist describes the packaged output
+type Playlist struct {
+	InitName       string
+	Segments       []Segment
+	TargetDuration int // seconds, the rounded-up longest segment
+}
+
+// Duration returns the total playback time in seconds
+func (p *Playlist) Duration() float64 {
+	var total float64
+	for _, s := range p.Segments {
+		total += s.Duration
+	}
+	return total
+}
+
+// Encode writes a VOD media playlist. uriPrefix is prepended to segment
+// names (e.g. "video_hls/") so the playlist can live outside the segment directory.
+func (p *Playlist) Encode(w io.Writer, uriPrefix string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	fmt.Fprintln(bw, "#EXT-X-VERSION:7")
+	fmt.Fprintf(bw, "#EXT-X-TARGETDURATION:%d\n", p.TargetDuration)
+	fmt.Fprintln(bw, "#EXT-X-MEDIA-SEQUENCE:0")
+	fmt.Fprintln(bw, "#EXT-X-PLAYLIST-TYPE:VOD")
+	fmt.Fprintln(bw, "#EXT-X-INDEPENDENT-SEGMENTS")
+	fmt.Fprintf(bw, "#EXT-X-MAP:URI=\"%s%s\"\n", uriPrefix, p.InitName)
+	for _, s := range p.Segments {
+		fmt.Fprintf(bw, "#EXTINF:%.3f,\n%s%s\n", s.Duration, uriPrefix, s.Name)
+	}
+	fmt.Fprintln(bw, "#EXT-X-ENDLIST")
+	return bw.Flush()
+}
+
+// Package remuxes the MP4 at srcPath into dir/init.mp4 and dir/seg_NNNNN.m4s.
+// dir is created if needed. The source must contain an H.264 video track and
+// at most one AAC audio track; other tracks (subtitles, timecode) are dropped.
+func Package(ctx context.Context, srcPath, dir string, opts Options) (*Playlist, error) {
+	if opts.SegmentDuration <= 0 {
+		opts.SegmentDuration = DefaultSegmentDuration
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tracks, err := readMovie(src, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	video, audio, err := selectTracks(tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	output := []*track{video}
+	if audio != nil {
+		output = append(output, audio)
+	}
+	if err := os.WriteFile(filepath.Join(dir, InitSegmentName), initSegment(output), 0644); err != nil {
+		return nil, err
+	}
+
+	cuts := keyframeCuts(video, opts.SegmentDuration)
+	playlist := &Playlist{InitName: InitSegmentName}
+	audioPos := 0
+	var longest float64
+
+	for i, start := range cuts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		end := len(video.samples)
+		if i+1 < len(cuts) {
+			end = cuts[i+1]
+		}
+		frags := []fragment{{trackID: 1, samples: video.samples[start:end]}}
+
+		// Audio goes with the video segment whose time range contains its decode time
+		if audio != nil {
+			audioEnd := len(audio.samples)
+			if end < len(video.samples) {
+				audioEnd = audioPos
+				boundary := video.samples[end].dts
+				for audioEnd < len(audio.samples) &&
+					audio.samples[audioEnd].dts*uint64(video.timescale) < boundary*uint64(audio.timescale) {
+					audioEnd++
+				}
+			}
+			if audioEnd > audioPos {
+				frags = append(frags, fragment{trackID: 2, samples: audio.samples[audioPos:audioEnd]})
+			}
+			audioPos = audioEnd
+		}
+
+		name := fmt.Sprintf(segmentPattern, i)
+		if err := writeSegment(filepath.Join(dir, name), src, uint32(i+1), frags); err != nil {
+			return nil, err
+		}
+
+		duration := segmentDuration(video, start, end)
+		longest = math.Max(longest, duration)
+		playlist.Segments = append(playlist.Segments, Segment{Name: name, Duration: duration})
+	}
+
+	playlist.TargetDuration = int(math.Ceil(longest))
+	return playlist, nil
+}
+
+// selectTracks picks the first video and audio track and checks their codecs
+func selectTracks(tracks []*track) (video, audio *track, err error) {
+	for _, t := range tracks {
+		switch {
+		case t.handler == "vide" && video == nil:
+			video = t
+		case t.handler == "soun" && audio == nil:
+			audio = t
+		}
+	}
+
+	if video == nil {
+		return nil, nil, fmt.Errorf("%w: no video track", ErrUnsupported)
+	}
+	if video.codec != "avc1" && video.codec != "avc3" {
+		return nil, nil, fmt.Errorf("%w: video codec %q is not H.264", ErrUnsupported, video.codec)
+	}
+	if !video.samples[0].sync {
+		return nil, nil, fmt.Errorf("%w: video does not start with a keyframe", ErrUnsupported)
+	}
+	if audio != nil && audio.codec != "mp4a" {
+		return nil, nil, fmt.Errorf("%w: audio codec %q is not AAC", ErrUnsupported, audio.codec)
+	}
+	return video, audio, nil
+}
+
+// keyframeCuts returns the index of the first sample of each segment. A new
+// segment starts at the first keyframe at least target after the previous cut.
+func keyframeCuts(video *track, target time.Duration) []int {
+	minTicks := uint64(target.Seconds() * float64(video.timescale))
+
+	cuts := []int{0}
+	segmentStart := video.samples[0].dts
+	for i := 1; i < len(video.samples); i++ {
+		s := video.samples[i]
+		if s.sync && s.dts-segmentStart >= minTicks {
+			cuts = append(cuts, i)
+			segmentStart = s.dts
+		}
+	}
+	return cuts
+}
+
+func segmentDuration(video *track, start, end int) float64 {
+	last := video.samples[end-1]
+	ticks := last.dts + uint64(last.duration) - video.samples[start].dts
+	return float64(ticks) / float64(video.timescale)
+}
@@ -0,0 +1,245 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testVideoSamples = 40 // 200ms each, keyframe every 10 samples (2s)
+	testAudioSamples = 375
+	testAudioChunk   = 200
+)
+
+func videoSampleData(i int) []byte { return bytes.Repeat([]byte{byte(i)}, 50+i) }
+func audioSampleData(i int) []byte { return bytes.Repeat([]byte{byte(0x80 | i%64)}, 10) }
+
+// buildProgressiveMP4 lays out chunks as V0 A0 V1 V2 V3 A1 so chunk offsets and
+// multi-run stsc tables are exercised. Video has a 400 tick B-frame delay
+// expressed through ctts plus an edit list.
+func buildProgressiveMP4(t *testing.T, videoCodec string) []byte {
+	t.Helper()
+
+	ftyp := box("ftyp", []byte("isom"), u32(0x200), []byte("isomavc1"))
+
+	var mdat []byte
+	chunk := func(data ...[]byte) uint32 {
+		offset := uint32(len(ftyp) + 8 + len(mdat))
+		for _, d := range data {
+			mdat = append(mdat, d...)
+		}
+		return offset
+	}
+	videoChunk := func(c int) uint32 {
+		var data [][]byte
+		for i := c * 10; i < (c+1)*10; i++ {
+			data = append(data, videoSampleData(i))
+		}
+		return chunk(data...)
+	}
+	audioChunk := func(from, to int) uint32 {
+		var data [][]byte
+		for i := from; i < to; i++ {
+			data = append(data, audioSampleData(i))
+		}
+		return chunk(data...)
+	}
+
+	v0 := videoChunk(0)
+	a0 := audioChunk(0, testAudioChunk)
+	v1, v2, v3 := videoChunk(1), videoChunk(2), videoChunk(3)
+	a1 := audioChunk(testAudioChunk, testAudioSamples)
+
+	var videoSizes, keyframes []byte
+	for i := 0; i < testVideoSamples; i++ {
+		videoSizes = append(videoSizes, u32(uint32(len(videoSampleData(i))))...)
+		if i%10 == 0 {
+			keyframes = append(keyframes, u32(uint32(i+1))...)
+		}
+	}
+
+	videoStbl := box("stbl",
+		fullBox("stsd", 0, 0, u32(1), box(videoCodec, make([]byte, 78))),
+		fullBox("stts", 0, 0, u32(1), u32(testVideoSamples), u32(200)),
+		fullBox("ctts", 0, 0, u32(1), u32(testVideoSamples), u32(400)),
+		fullBox("stss", 0, 0, u32(testVideoSamples/10), keyframes),
+		fullBox("stsc", 0, 0, u32(1), u32(1), u32(10), u32(1)),
+		fullBox("stsz", 0, 0, u32(0), u32(testVideoSamples), videoSizes),
+		fullBox("stco", 0, 0, u32(4), u32(v0), u32(v1), u32(v2), u32(v3)),
+	)
+	audioStbl := box("stbl",
+		fullBox("stsd", 0, 0, u32(1), box("mp4a", make([]byte, 28))),
+		fullBox("stts", 0, 0, u32(1), u32(testAudioSamples), u32(1024)),
+		fullBox("stsc", 0, 0, u32(2), u32(1), u32(testAudioChunk), u32(1), u32(2), u32(testAudioSamples-testAudioChunk), u32(1)),
+		fullBox("stsz", 0, 0, u32(10), u32(testAudioSamples)),
+		fullBox("stco", 0, 0, u32(2), u32(a0), u32(a1)),
+	)
+
+	trak := func(id uint32, handler string, timescale uint32, stbl []byte, extra ...[]byte) []byte {
+		tkhd := fullBox("tkhd", 0, 3, u32(0), u32(0), u32(id), make([]byte, 60), u32(1280<<16), u32(720<<16))
+		mdia := box("mdia",
+			fullBox("mdhd", 0, 0, u32(0), u32(0), u32(timescale), u32(0), u16(0x55C4), u16(0)),
+			fullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 13)),
+			box("minf", stbl),
+		)
+		return box("trak", append(append([][]byte{tkhd}, extra...), mdia)...)
+	}
+	edts := box("edts", fullBox("elst", 0, 0, u32(1), u32(8000), u32(400), u32(0x00010000)))
+
+	moov := box("moov",
+		fullBox("mvhd", 0, 0, make([]byte, 96)),
+		trak(1, "vide", 1000, videoStbl, edts),
+		trak(2, "soun", 48000, audioStbl),
+	)
+
+	return bytes.Join([][]byte{ftyp, box("mdat", mdat), moov}, nil)
+}
+
+func writeFixture(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "source.mp4")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+// parseTrun returns the data offset, per-sample sizes and composition offsets of a trun payload
+func parseTrun(p []byte) (int32, []uint32, []int32) {
+	count := int(binary.BigEndian.Uint32(p[4:8]))
+	offset := int32(binary.BigEndian.Uint32(p[8:12]))
+	var sizes []uint32
+	var ctos []int32
+	for i := 0; i < count; i++ {
+		e := p[12+16*i:]
+		sizes = append(sizes, binary.BigEndian.Uint32(e[4:8]))
+		ctos = append(ctos, int32(binary.BigEndian.Uint32(e[12:16])))
+	}
+	return offset, sizes, ctos
+}
+
+func TestPackage(t *testing.T) {
+	src := writeFixture(t, buildProgressiveMP4(t, "avc1"))
+	dir := filepath.Join(t.TempDir(), "out")
+
+	playlist, err := Package(context.Background(), src, dir, Options{SegmentDuration: 3 * time.Second})
+	require.NoError(t, err)
+
+	// Keyframes every 2s with a 3s minimum: cuts at 0s and 4s
+	require.Len(t, playlist.Segments, 2)
+	assert.InDelta(t, 4.0, playlist.Segments[0].Duration, 0.001)
+	assert.InDelta(t, 4.0, playlist.Segments[1].Duration, 0.001)
+	assert.Equal(t, 4, playlist.TargetDuration)
+	assert.InDelta(t, 8.0, playlist.Duration(), 0.001)
+
+	initData, err := os.ReadFile(filepath.Join(dir, InitSegmentName))
+	require.NoError(t, err)
+	moov := findBox(initData, "moov")
+	require.NotNil(t, moov)
+	assert.NotNil(t, findBox(moov, "mvex"))
+	traks := 0
+	eachBox(moov, func(typ string, _ []byte) error {
+		if typ == "trak" {
+			traks++
+		}
+		return nil
+	})
+	assert.Equal(t, 2, traks)
+
+	// Audio samples are split at the 4s video boundary: 1024*188 ticks >= 4s at 48kHz
+	expectedAudio := [][2]int{{0, 188}, {188, testAudioSamples}}
+	for seg, s := range playlist.Segments {
+		data, err := os.ReadFile(filepath.Join(dir, s.Name))
+		require.NoError(t, err)
+
+		var mdat []byte
+		var runs [][]byte
+		eachBox(data, func(typ string, payload []byte) error {
+			switch typ {
+			case "moof":
+				eachBox(payload, func(typ string, traf []byte) error {
+					if typ == "traf" {
+						runs = append(runs, findBox(traf, "trun"))
+					}
+					return nil
+				})
+			case "mdat":
+				mdat = payload
+			}
+			return nil
+		})
+		moofStart := bytes.Index(data, []byte("moof")) - 4
+		require.Len(t, runs, 2)
+
+		// Video: 20 samples, the B-frame delay removed by the edit list
+		offset, sizes, ctos := parseTrun(runs[0])
+		require.Len(t, sizes, 20)
+		assert.Equal(t, int32(0), ctos[0])
+		var want []byte
+		for i := seg * 20; i < (seg+1)*20; i++ {
+			want = append(want, videoSampleData(i)...)
+		}
+		assert.Equal(t, want, data[moofStart+int(offset):moofStart+int(offset)+len(want)])
+
+		// Audio follows video in the same mdat
+		audioOffset, audioSizes, _ := parseTrun(runs[1])
+		from, to := expectedAudio[seg][0], expectedAudio[seg][1]
+		require.Len(t, audioSizes, to-from)
+		var wantAudio []byte
+		for i := from; i < to; i++ {
+			wantAudio = append(wantAudio, audioSampleData(i)...)
+		}
+		assert.Equal(t, int(offset)+len(want), int(audioOffset))
+		assert.Equal(t, append(want, wantAudio...), mdat)
+	}
+
+	var m3u8 strings.Builder
+	require.NoError(t, playlist.Encode(&m3u8, "source_hls/"))
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="source_hls/init.mp4"
+#EXTINF:4.000,
+source_hls/seg_00000.m4s
+#EXTINF:4.000,
+source_hls/seg_00001.m4s
+#EXT-X-ENDLIST
+`, m3u8.String())
+}
+
+func TestPackage_Rejects(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Package(context.Background(), writeFixture(t, buildProgressiveMP4(t, "hvc1")), dir, Options{})
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	data := buildProgressiveMP4(t, "avc1")
+	_, err = Package(context.Background(), writeFixture(t, data[:len(data)-40]), dir, Options{})
+	assert.ErrorIs(t, err, ErrInvalidMP4)
+
+	// A uniform sample size with a sample count the file can't hold
+	audioStsz := append([]byte("stsz"), fullBox("stsz", 0, 0, u32(10), u32(testAudioSamples))[8:]...)
+	at := bytes.Index(data, audioStsz)
+	require.NotEqual(t, -1, at)
+	for _, count := range []uint32{0xFFFFFFFF, uint32(len(data) / 2)} {
+		forged := bytes.Clone(data)
+		binary.BigEndian.PutUint32(forged[at+len(audioStsz)-4:], count)
+		_, err = Package(context.Background(), writeFixture(t, forged), dir, Options{})
+		assert.ErrorIs(t, err, ErrInvalidMP4, "sample count %d", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Package(ctx, writeFixture(t, data), dir, Options{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
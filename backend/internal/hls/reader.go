@@ -0,0 +1,451 @@
+package hls
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxMoovSize caps how much of the movie header we load into memory
+const maxMoovSize = 256 << 20
+
+// sample is one access unit of a track, located in the source file
+type sample struct {
+	offset   int64
+	size     uint32
+	dts      uint64 // decode time in track timescale
+	duration uint32
+	cto      int32 // composition time offset (pts - dts)
+	sync     bool
+}
+
+// track holds what the segmenter needs from a progressive MP4 trak
+type track struct {
+	id        uint32
+	handler   string // "vide" or "soun"
+	timescale uint32
+	language  uint16
+	width     uint32 // 16.16 fixed point, copied verbatim into the init segment
+	height    uint32
+	codec     string // fourcc of the first sample entry
+	stsd      []byte // stsd payload, copied verbatim into the init segment
+	samples   []sample
+}
+
+// sample table boxes of one trak, decoded lazily into track.samples
+type sampleTable struct {
+	stts, ctts, stss, stsz, stsc, stco []byte
+	co64                               bool
+}
+
+// readMovie loads the moov box of a progressive MP4 and decodes its tracks
+func readMovie(r io.ReadSeeker, size int64) ([]*track, error) {
+	moov, err := loadMoov(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []*track
+	err = eachBox(moov, func(typ string, payload []byte) error {
+		if typ == "mvex" {
+			return fmt.Errorf("%w: file is already fragmented", ErrUnsupported)
+		}
+		if typ != "trak" {
+			return nil
+		}
+		t, err := parseTrak(payload, size)
+		if err != nil {
+			return err
+		}
+		if t != nil {
+			tracks = append(tracks, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+func loadMoov(r io.ReadSeeker, size int64) ([]byte, error) {
+	hdr := make([]byte, 16)
+	for offset := int64(0); offset < size; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+			return nil, ErrInvalidMP4
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(hdr[0:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+				return nil, ErrInvalidMP4
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > size {
+			return nil, ErrInvalidMP4
+		}
+
+		if string(hdr[4:8]) == "moov" {
+			if boxSize-headerSize > maxMoovSize {
+				return nil, ErrInvalidMP4
+			}
+			moov := make([]byte, boxSize-headerSize)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, ErrInvalidMP4
+			}
+			return moov, nil
+		}
+		offset += boxSize
+	}
+	return nil, ErrInvalidMP4
+}
+
+// eachBox calls fn for every child box in data
+func eachBox(data []byte, fn func(typ string, payload []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return ErrInvalidMP4
+		}
+		boxSize := uint64(binary.BigEndian.Uint32(data[0:4]))
+		headerSize := uint64(8)
+		switch boxSize {
+		case 0:
+			boxSize = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return ErrInvalidMP4
+			}
+			boxSize = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if boxSize < headerSize || boxSize > uint64(len(data)) {
+			return ErrInvalidMP4
+		}
+		if err := fn(string(data[4:8]), data[headerSize:boxSize]); err != nil {
+			return err
+		}
+		data = data[boxSize:]
+	}
+	return nil
+}
+
+// findBox returns the payload of the first child box of the given type
+func findBox(data []byte, typ string) []byte {
+	var found []byte
+	eachBox(data, func(t string, payload []byte) error {
+		if found == nil && t == typ {
+			found = payload
+		}
+		return nil
+	})
+	return found
+}
+
+// parseTrak returns nil for tracks the segmenter ignores (subtitles, timecode, hint)
+func parseTrak(trak []byte, fileSize int64) (*track, error) {
+	t := &track{}
+	var st sampleTable
+	var mediaTime int64
+
+	tkhd := findBox(trak, "tkhd")
+	mdia := findBox(trak, "mdia")
+	if tkhd == nil || mdia == nil {
+		return nil, ErrInvalidMP4
+	}
+
+	// tkhd: version(1) flags(3) creation/modification then track_id; width/height are the last two fields
+	if tkhd[0] == 1 {
+		if len(tkhd) < 96 {
+			return nil, ErrInvalidMP4
+		}
+		t.id = binary.BigEndian.Uint32(tkhd[20:24])
+	} else {
+		if len(tkhd) < 84 {
+			return nil, ErrInvalidMP4
+		}
+		t.id = binary.BigEndian.Uint32(tkhd[12:16])
+	}
+	t.width = binary.BigEndian.Uint32(tkhd[len(tkhd)-8:])
+	t.height = binary.BigEndian.Uint32(tkhd[len(tkhd)-4:])
+
+	if edts := findBox(trak, "edts"); edts != nil {
+		mediaTime = firstMediaTime(findBox(edts, "elst"))
+	}
+
+	if hdlr := findBox(mdia, "hdlr"); len(hdlr) >= 12 {
+		t.handler = string(hdlr[8:12])
+	}
+	if t.handler != "vide" && t.handler != "soun" {
+		return nil, nil
+	}
+
+	mdhd := findBox(mdia, "mdhd")
+	if len(mdhd) < 24 {
+		return nil, ErrInvalidMP4
+	}
+	if mdhd[0] == 1 {
+		if len(mdhd) < 36 {
+			return nil, ErrInvalidMP4
+		}
+		t.timescale = binary.BigEndian.Uint32(mdhd[20:24])
+		t.language = binary.BigEndian.Uint16(mdhd[32:34])
+	} else {
+		t.timescale = binary.BigEndian.Uint32(mdhd[12:16])
+		t.language = binary.BigEndian.Uint16(mdhd[20:22])
+	}
+	if t.timescale == 0 {
+		return nil, ErrInvalidMP4
+	}
+
+	stbl := findBox(findBox(mdia, "minf"), "stbl")
+	if stbl == nil {
+		return nil, ErrInvalidMP4
+	}
+	err := eachBox(stbl, func(typ string, payload []byte) error {
+		switch typ {
+		case "stsd":
+			t.stsd = payload
+		case "stts":
+			st.stts = payload
+		case "ctts":
+			st.ctts = payload
+		case "stss":
+			st.stss = payload
+		case "stsz":
+			st.stsz = payload
+		case "stz2":
+			return fmt.Errorf("%w: compact sample sizes", ErrUnsupported)
+		case "stsc":
+			st.stsc = payload
+		case "stco":
+			st.stco = payload
+		case "co64":
+			st.stco, st.co64 = payload, true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// stsd: version/flags(4) entry_count(4) then entries: size(4) format(4)
+	if len(t.stsd) < 16 {
+		return nil, ErrInvalidMP4
+	}
+	if binary.BigEndian.Uint32(t.stsd[4:8]) != 1 {
+		return nil, fmt.Errorf("%w: multiple sample descriptions", ErrUnsupported)
+	}
+	t.codec = string(t.stsd[12:16])
+
+	if t.samples, err = st.build(fileSize); err != nil {
+		return nil, err
+	}
+
+	// Apply the edit list offset to composition times so presentation starts at zero
+	// (this is how B-frame reordering delay is expressed in progressive files)
+	if mediaTime > 0 && t.handler == "vide" {
+		for i := range t.samples {
+			t.samples[i].cto -= int32(mediaTime)
+		}
+	}
+
+	return t, nil
+}
+
+// firstMediaTime returns the media_time of the first non-empty edit, or 0
+func firstMediaTime(elst []byte) int64 {
+	if len(elst) < 8 {
+		return 0
+	}
+	version := elst[0]
+	count := int(binary.BigEndian.Uint32(elst[4:8]))
+	p := elst[8:]
+	for i := 0; i < count; i++ {
+		var mediaTime int64
+		if version == 1 {
+			if len(p) < 20 {
+				return 0
+			}
+			mediaTime = int64(binary.BigEndian.Uint64(p[8:16]))
+			p = p[20:]
+		} else {
+			if len(p) < 12 {
+				return 0
+			}
+			mediaTime = int64(int32(binary.BigEndian.Uint32(p[4:8])))
+			p = p[12:]
+		}
+		if mediaTime >= 0 {
+			return mediaTime
+		}
+	}
+	return 0
+}
+
+// build expands the run-length coded sample tables into one entry per sample
+func (st *sampleTable) build(fileSize int64) ([]sample, error) {
+	if len(st.stsz) < 12 || len(st.stts) < 8 || len(st.stsc) < 8 || len(st.stco) < 8 {
+		return nil, ErrInvalidMP4
+	}
+
+	// stsz: version/flags(4) sample_size(4) sample_count(4) [entry_size(4)...]
+	uniformSize := binary.BigEndian.Uint32(st.stsz[4:8])
+	count := int(binary.BigEndian.Uint32(st.stsz[8:12]))
+	if count == 0 {
+		return nil, ErrInvalidMP4
+	}
+	// The count comes straight from the file, so bound it by the bytes the
+	// samples would take up before allocating a table for it
+	if int64(count) > fileSize {
+		return nil, ErrInvalidMP4
+	}
+	if uniformSize != 0 && int64(count) > fileSize/int64(uniformSize) {
+		return nil, ErrInvalidMP4
+	}
+	if uniformSize == 0 && len(st.stsz) < 12+4*count {
+		return nil, ErrInvalidMP4
+	}
+
+	samples := make([]sample, count)
+	for i := range samples {
+		if uniformSize != 0 {
+			samples[i].size = uniformSize
+		} else {
+			samples[i].size = binary.BigEndian.Uint32(st.stsz[12+4*i:])
+		}
+		samples[i].sync = st.stss == nil // no stss means every sample is a sync sample
+	}
+
+	// stts: (sample_count, sample_delta) runs
+	n := 0
+	var dts uint64
+	if err := eachEntry(st.stts, 8, func(e []byte) bool {
+		runLen := int(binary.BigEndian.Uint32(e[0:4]))
+		delta := binary.BigEndian.Uint32(e[4:8])
+		for j := 0; j < runLen && n < count; j++ {
+			samples[n].dts = dts
+			samples[n].duration = delta
+			dts += uint64(delta)
+			n++
+		}
+		return n < count
+	}); err != nil {
+		return nil, err
+	}
+	if n < count {
+		return nil, ErrInvalidMP4
+	}
+
+	// ctts: (sample_count, sample_offset) runs; treat v0 offsets as signed too,
+	// real files never exceed 2^31
+	if st.ctts != nil {
+		n = 0
+		if err := eachEntry(st.ctts, 8, func(e []byte) bool {
+			runLen := int(binary.BigEndian.Uint32(e[0:4]))
+			offset := int32(binary.BigEndian.Uint32(e[4:8]))
+			for j := 0; j < runLen && n < count; j++ {
+				samples[n].cto = offset
+				n++
+			}
+			return n < count
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// stss: 1-based sample numbers of sync samples
+	if st.stss != nil {
+		if err := eachEntry(st.stss, 4, func(e []byte) bool {
+			if i := int(binary.BigEndian.Uint32(e)) - 1; i >= 0 && i < count {
+				samples[i].sync = true
+			}
+			return true
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Chunk offsets
+	var chunkOffsets []int64
+	entrySize := 4
+	if st.co64 {
+		entrySize = 8
+	}
+	if err := eachEntry(st.stco, entrySize, func(e []byte) bool {
+		if st.co64 {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint64(e)))
+		} else {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint32(e)))
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	// stsc: (first_chunk, samples_per_chunk, sample_description_index) runs
+	type chunkRun struct{ firstChunk, samplesPerChunk int }
+	var runs []chunkRun
+	if err := eachEntry(st.stsc, 12, func(e []byte) bool {
+		runs = append(runs, chunkRun{
+			firstChunk:      int(binary.BigEndian.Uint32(e[0:4])),
+			samplesPerChunk: int(binary.BigEndian.Uint32(e[4:8])),
+		})
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, ErrInvalidMP4
+	}
+
+	n = 0
+	run := 0
+	for c := range chunkOffsets {
+		chunk := c + 1
+		for run+1 < len(runs) && runs[run+1].firstChunk <= chunk {
+			run++
+		}
+		offset := chunkOffsets[c]
+		for j := 0; j < runs[run].samplesPerChunk && n < count; j++ {
+			samples[n].offset = offset
+			offset += int64(samples[n].size)
+			if offset > fileSize {
+				return nil, ErrInvalidMP4
+			}
+			n++
+		}
+	}
+	if n < count {
+		return nil, ErrInvalidMP4
+	}
+
+	return samples, nil
+}
+
+// eachEntry walks a full box table: version/flags(4) entry_count(4) entries...
+// fn returns false to stop early.
+func eachEntry(payload []byte, entrySize int, fn func(entry []byte) bool) error {
+	if len(payload) < 8 {
+		return ErrInvalidMP4
+	}
+	count := int(binary.BigEndian.Uint32(payload[4:8]))
+	entries := payload[8:]
+	if count < 0 || count > len(entries)/entrySize {
+		return ErrInvalidMP4
+	}
+	for i := 0; i < count; i++ {
+		if !fn(entries[i*entrySize : (i+1)*entrySize]) {
+			break
+		}
+	}
+	return nil
+}
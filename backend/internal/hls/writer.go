@@ -0,0 +1,217 @@
+package hls
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// trun sample flags
+const (
+	syncSampleFlags    = 0x02000000 // sample_depends_on=2: decodable on its own
+	nonSyncSampleFlags = 0x01010000 // sample_depends_on=1, sample_is_non_sync_sample=1
+)
+
+// identity transformation matrix used by mvhd and tkhd
+var unityMatrix = []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	out := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(out, uint32(size))
+	copy(out[4:], typ)
+	for _, p := range payload {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func fullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	vf := u32(flags)
+	vf[0] = version
+	return box(typ, append([][]byte{vf}, payload...)...)
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func matrix() []byte {
+	out := make([]byte, 0, 36)
+	for _, v := range unityMatrix {
+		out = append(out, u32(v)...)
+	}
+	return out
+}
+
+// initSegment builds the CMAF header: ftyp plus a moov with empty sample tables and mvex
+func initSegment(tracks []*track) []byte {
+	ftyp := box("ftyp", []byte("iso6"), u32(0), []byte("iso6cmfcisommp41"))
+
+	mvhd := fullBox("mvhd", 0, 0,
+		u32(0), u32(0), u32(1000), u32(0), // creation, modification, timescale, duration
+		u32(0x00010000), u16(0x0100), make([]byte, 10), // rate, volume, reserved
+		matrix(), make([]byte, 24), // matrix, pre_defined
+		u32(uint32(len(tracks)+1)), // next_track_ID
+	)
+
+	moov := [][]byte{mvhd}
+	var trexes [][]byte
+	for i, t := range tracks {
+		id := uint32(i + 1)
+		moov = append(moov, initTrak(t, id))
+		trexes = append(trexes, fullBox("trex", 0, 0, u32(id), u32(1), u32(0), u32(0), u32(0)))
+	}
+	moov = append(moov, box("mvex", trexes...))
+
+	return append(ftyp, box("moov", moov...)...)
+}
+
+func initTrak(t *track, id uint32) []byte {
+	var volume uint16
+	if t.handler == "soun" {
+		volume = 0x0100
+	}
+	tkhd := fullBox("tkhd", 0, 0x3, // track enabled, in movie
+		u32(0), u32(0), u32(id), u32(0), u32(0), // creation, modification, track_ID, reserved, duration
+		make([]byte, 8), u16(0), u16(0), u16(volume), u16(0), // reserved, layer, alternate_group, volume, reserved
+		matrix(), u32(t.width), u32(t.height),
+	)
+
+	mdhd := fullBox("mdhd", 0, 0, u32(0), u32(0), u32(t.timescale), u32(0), u16(t.language), u16(0))
+
+	var hdlr, mediaHeader []byte
+	if t.handler == "vide" {
+		hdlr = fullBox("hdlr", 0, 0, u32(0), []byte("vide"), make([]byte, 12), []byte("VideoHandler\x00"))
+		mediaHeader = fullBox("vmhd", 0, 1, make([]byte, 8))
+	} else {
+		hdlr = fullBox("hdlr", 0, 0, u32(0), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00"))
+		mediaHeader = fullBox("smhd", 0, 0, make([]byte, 4))
+	}
+
+	dinf := box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1)))
+	stbl := box("stbl",
+		box("stsd", t.stsd),
+		fullBox("stts", 0, 0, u32(0)),
+		fullBox("stsc", 0, 0, u32(0)),
+		fullBox("stsz", 0, 0, u32(0), u32(0)),
+		fullBox("stco", 0, 0, u32(0)),
+	)
+
+	return box("trak", tkhd, box("mdia", mdhd, hdlr, box("minf", mediaHeader, dinf, stbl)))
+}
+
+// fragment is one track's run of samples inside a media segment
+type fragment struct {
+	trackID uint32
+	samples []sample
+}
+
+// moof builds the movie fragment header. dataOffsets are relative to the
+// start of the moof (default-base-is-moof) and point into the following mdat.
+func moof(sequence uint32, frags []fragment, dataOffsets []int32) []byte {
+	trafs := [][]byte{fullBox("mfhd", 0, 0, u32(sequence))}
+	for i, f := range frags {
+		tfhd := fullBox("tfhd", 0, 0x020000, u32(f.trackID)) // default-base-is-moof
+		tfdt := fullBox("tfdt", 1, 0, u64(f.samples[0].dts))
+
+		// data-offset, sample duration, size, flags and composition offset present
+		entries := make([]byte, 0, 8+16*len(f.samples))
+		entries = append(entries, u32(uint32(len(f.samples)))...)
+		entries = append(entries, u32(uint32(dataOffsets[i]))...)
+		for _, s := range f.samples {
+			flags := uint32(nonSyncSampleFlags)
+			if s.sync {
+				flags = syncSampleFlags
+			}
+			entries = append(entries, u32(s.duration)...)
+			entries = append(entries, u32(s.size)...)
+			entries = append(entries, u32(flags)...)
+			entries = append(entries, u32(uint32(s.cto))...)
+		}
+		trun := fullBox("trun", 1, 0x000F01, entries)
+
+		trafs = append(trafs, box("traf", tfhd, tfdt, trun))
+	}
+	return box("moof", trafs...)
+}
+
+// writeSegment writes one CMAF media segment (styp, moof, mdat) to path,
+// copying sample data from src.
+func writeSegment(path string, src io.ReaderAt, sequence uint32, frags []fragment) error {
+	// moof's size doesn't depend on the offsets it contains, so build it once to measure
+	offsets := make([]int32, len(frags))
+	moofSize := len(moof(sequence, frags, offsets))
+
+	var mdatSize int64
+	for i, f := range frags {
+		offsets[i] = int32(int64(moofSize) + 8 + mdatSize)
+		for _, s := range f.samples {
+			mdatSize += int64(s.size)
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriterSize(out, 1<<20)
+
+	w.Write(box("styp", []byte("cmfs"), u32(0), []byte("cmfsmsdhmsix")))
+	w.Write(moof(sequence, frags, offsets))
+	w.Write(u32(uint32(8 + mdatSize)))
+	w.Write([]byte("mdat"))
+
+	for _, f := range frags {
+		if err := copySamples(w, src, f.samples); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// copySamples copies sample data, merging samples that are contiguous in the source
+func copySamples(w io.Writer, src io.ReaderAt, samples []sample) error {
+	for i := 0; i < len(samples); {
+		start := samples[i].offset
+		end := start + int64(samples[i].size)
+		j := i + 1
+		for j < len(samples) && samples[j].offset == end {
+			end += int64(samples[j].size)
+			j++
+		}
+
+		n, err := io.Copy(w, io.NewSectionReader(src, start, end-start))
+		if err != nil {
+			return err
+		}
+		if n != end-start {
+			return ErrInvalidMP4
+		}
+		i = j
+	}
+	return nil
+}
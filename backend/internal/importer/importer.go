@@ -0,0 +1,384 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"titan-backend/internal/mediaprobe"
+	"titan-backend/internal/middleware"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+)
+
+// Row statuses in a Report
+const (
+	StatusCreated = "created"
+	StatusReady   = "ready"  // Valid; dry runs, and runs with invalid rows, stop here
+	StatusExists  = "exists" // Imported before, skipped
+	StatusInvalid = "invalid"
+)
+
+// maxExternalIDLength caps external IDs, which are usually a number or a UUID
+const maxExternalIDLength = 200
+
+// Result is what became of one row of the manifest
+type Result struct {
+	Line       int      `json:"line"`
+	ExternalID string   `json:"externalId"`
+	Status     string   `json:"status"`
+	VideoID    int      `json:"videoId,omitempty"` // The created video, or the one imported before
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// Report lists the result of every row, in manifest order, with counts
+type Report struct {
+	DryRun   bool     `json:"dryRun"`
+	Created  int      `json:"created"`
+	Ready    int      `json:"ready"`
+	Existing int      `json:"existing"`
+	Invalid  int      `json:"invalid"`
+	Rows     []Result `json:"rows"`
+}
+
+func (r *Report) count() {
+	r.Created, r.Ready, r.Existing, r.Invalid = 0, 0, 0, 0
+	for _, row := range r.Rows {
+		switch row.Status {
+		case StatusCreated:
+			r.Created++
+		case StatusReady:
+			r.Ready++
+		case StatusExists:
+			r.Existing++
+		case StatusInvalid:
+			r.Invalid++
+		}
+	}
+}
+
+// Options controls an import
+type Options struct {
+	DryRun bool
+	// Dir is where relative file and thumbnail paths are resolved
+	Dir string
+	// Confine rejects absolute paths and paths leading out of Dir, for
+	// manifests that didn't come from someone with access to the server
+	Confine bool
+}
+
+// Importer validates manifest rows and creates their videos
+type Importer struct {
+	videoRepo      *models.VideoRepository
+	categoryRepo   *models.CategoryRepository
+	storageService *services.StorageService
+	jobQueue       *services.JobQueue
+}
+
+// NewImporter creates an importer. Jobs it queues are run by the server's
+// workers, so it can be used from commands that don't start the queue.
+func NewImporter(
+	videoRepo *models.VideoRepository,
+	categoryRepo *models.CategoryRepository,
+	storageService *services.StorageService,
+	jobQueue *services.JobQueue,
+) *Importer {
+	return &Importer{
+		videoRepo:      videoRepo,
+		categoryRepo:   categoryRepo,
+		storageService: storageService,
+		jobQueue:       jobQueue,
+	}
+}
+
+// pending is a valid row waiting to be created
+type pending struct {
+	result    *Result
+	video     *models.Video
+	file      string // Local video to copy into storage
+	thumbnail string // Local thumbnail to copy into storage
+	media     *mediaprobe.Info
+}
+
+// Import validates every row and, unless it's a dry run, creates the videos
+// of the valid ones in one transaction. Rows whose external ID was imported
+// before are skipped. If any row is invalid nothing is created, so a fixed
+// manifest can simply be run again.
+//
+// Local files are copied into storage, leaving the originals. The returned
+// error is for failures of the import itself, such as the database; the
+// report is nil then and nothing was created.
+func (imp *Importer) Import(rows []Row, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Rows: make([]Result, len(rows))}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if id := strings.TrimSpace(row.ExternalID); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	existing, err := imp.videoRepo.ExternalIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make(map[string]bool)
+	firstLine := make(map[string]int)
+	var valid []*pending
+	for i, row := range rows {
+		result := &report.Rows[i]
+		result.Line = row.Line
+		result.ExternalID = strings.TrimSpace(row.ExternalID)
+
+		if line, ok := firstLine[result.ExternalID]; ok {
+			result.Status = StatusInvalid
+			result.Errors = []string{fmt.Sprintf("External ID is also used on line %d", line)}
+			continue
+		}
+		if result.ExternalID != "" {
+			firstLine[result.ExternalID] = row.Line
+		}
+		if id, ok := existing[result.ExternalID]; ok {
+			result.Status = StatusExists
+			result.VideoID = id
+			continue
+		}
+
+		p, errs, err := imp.prepare(row, opts, categories)
+		if err != nil {
+			return nil, err
+		}
+		if len(errs) > 0 {
+			result.Status = StatusInvalid
+			result.Errors = errs
+			continue
+		}
+		result.Status = StatusReady
+		p.result = result
+		valid = append(valid, p)
+	}
+
+	report.count()
+	if opts.DryRun || report.Invalid > 0 || len(valid) == 0 {
+		return report, nil
+	}
+
+	if err := imp.create(valid); err != nil {
+		return nil, err
+	}
+	for _, p := range valid {
+		p.result.Status = StatusCreated
+		p.result.VideoID = p.video.ID
+
+		// Remux to HLS in the background, like uploads
+		if services.CanPackageHLS(p.media) {
+			if _, err := imp.jobQueue.Enqueue(services.JobPackageHLS, services.PackageHLSPayload{VideoID: p.video.ID}); err != nil {
+				log.Printf("[Import] ERROR: Failed to queue HLS packaging for video %d: %v", p.video.ID, err)
+			}
+		}
+	}
+	report.count()
+	return report, nil
+}
+
+// prepare validates a row and builds its video. Row problems are returned as
+// messages; the error is for failed lookups.
+func (imp *Importer) prepare(row Row, opts Options, categories map[string]bool) (*pending, []string, error) {
+	var errs []string
+	p := &pending{video: &models.Video{
+		ExternalID:  strings.TrimSpace(row.ExternalID),
+		Title:       middleware.SanitizeString(row.Title),
+		Creator:     middleware.SanitizeString(row.Creator),
+		Category:    middleware.SanitizeString(row.Category),
+		Description: middleware.SanitizeString(row.Description),
+	}}
+	video := p.video
+
+	switch {
+	case video.ExternalID == "":
+		errs = append(errs, "External ID is required")
+	case utf8.RuneCountInString(video.ExternalID) > maxExternalIDLength:
+		errs = append(errs, fmt.Sprintf("External ID must not exceed %d characters", maxExternalIDLength))
+	case strings.IndexFunc(video.ExternalID, unicode.IsControl) >= 0:
+		errs = append(errs, "External ID cannot contain control characters")
+	}
+
+	switch {
+	case video.Title == "":
+		errs = append(errs, "Title is required")
+	case utf8.RuneCountInString(video.Title) > 200:
+		errs = append(errs, "Title must not exceed 200 characters")
+	case strings.IndexFunc(video.Title, unicode.IsControl) >= 0:
+		errs = append(errs, "Title cannot contain control characters")
+	}
+
+	if video.Creator == "" {
+		errs = append(errs, "Creator is required")
+	} else if valid, msg := middleware.ValidateCreatorName(video.Creator); !valid {
+		errs = append(errs, msg)
+	}
+
+	// Like uploads, videos without a category go under "other"
+	if video.Category == "" {
+		video.Category = "other"
+	} else {
+		exists, ok := categories[video.Category]
+		if !ok {
+			category, err := imp.categoryRepo.GetByID(video.Category)
+			if err != nil {
+				return nil, nil, err
+			}
+			exists = category != nil
+			categories[video.Category] = exists
+		}
+		if !exists {
+			errs = append(errs, fmt.Sprintf("Category %q does not exist", video.Category))
+		}
+	}
+
+	if valid, msg := middleware.ValidateDescription(video.Description); !valid {
+		errs = append(errs, msg)
+	}
+
+	file, videoURL := strings.TrimSpace(row.File), strings.TrimSpace(row.URL)
+	switch {
+	case file != "" && videoURL != "":
+		errs = append(errs, "Set either a file or a URL, not both")
+	case file == "" && videoURL == "":
+		errs = append(errs, "A file or a URL is required")
+	case videoURL != "":
+		if !isWebURL(videoURL) {
+			errs = append(errs, "URL must be an http(s) URL")
+		}
+		video.URL = videoURL
+	default:
+		path, msg := resolvePath(file, opts)
+		if msg != "" {
+			errs = append(errs, msg)
+			break
+		}
+		info, err := imp.storageService.CheckVideo(path)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", file, err))
+			break
+		}
+		p.file, p.media = path, info
+	}
+
+	if thumbnail := strings.TrimSpace(row.Thumbnail); isWebURL(thumbnail) {
+		video.Thumbnail = thumbnail
+	} else if thumbnail != "" {
+		path, msg := resolvePath(thumbnail, opts)
+		switch {
+		case msg != "":
+			errs = append(errs, msg)
+		case !imp.storageService.IsAllowedImage(path):
+			errs = append(errs, "Thumbnail must be an http(s) URL or a .jpg, .jpeg, .png, .gif or .webp file")
+		default:
+			p.thumbnail = path
+		}
+	}
+
+	// Validated like the status of an upload, so drafts and private videos
+	// of an exported catalog stay hidden
+	status, publishAt, err := models.ParseVideoStatus(
+		strings.TrimSpace(row.Status), strings.TrimSpace(row.PublishAt), models.VideoStatusPublished)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	video.Status, video.PublishAt = status, publishAt
+
+	duration := strings.TrimSpace(row.Duration)
+	if _, ok := mediaprobe.ParseDuration(duration); duration != "" && !ok {
+		errs = append(errs, "Duration must be written as SS, M:SS or H:MM:SS")
+	}
+	video.SetDuration(duration)
+	video.ApplyMediaInfo(p.media)
+
+	return p, errs, nil
+}
+
+// create copies the local files into storage and inserts the videos,
+// removing the copies again if anything fails
+func (imp *Importer) create(valid []*pending) error {
+	var stored []string
+	cleanup := func() {
+		for _, path := range stored {
+			imp.storageService.DeleteFile(path)
+		}
+	}
+
+	videos := make([]*models.Video, len(valid))
+	for i, p := range valid {
+		if p.file != "" {
+			url, err := imp.storageService.CopyVideo(p.file)
+			if err != nil {
+				cleanup()
+				return fmt.Errorf("copy %s: %w", p.file, err)
+			}
+			stored = append(stored, url)
+			p.video.URL = url
+		}
+		if p.thumbnail != "" {
+			url, err := imp.storageService.CopyThumbnail(p.thumbnail)
+			if err != nil {
+				cleanup()
+				return fmt.Errorf("copy %s: %w", p.thumbnail, err)
+			}
+			stored = append(stored, url)
+			p.video.Thumbnail = url
+		}
+		videos[i] = p.video
+	}
+
+	if err := imp.videoRepo.CreateBatch(videos); err != nil {
+		cleanup()
+		return err
+	}
+	return nil
+}
+
+// resolvePath finds a file named in the manifest, returning its path or a
+// message saying why it can't be used. Messages name the file as written in
+// the manifest, not where it was looked for.
+func resolvePath(name string, opts Options) (string, string) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(opts.Dir, path)
+	} else if opts.Confine {
+		return "", fmt.Sprintf("%s: paths must be relative to the import directory", name)
+	}
+
+	if opts.Confine {
+		// Compare real paths so symlinks can't point out of the directory
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", fmt.Sprintf("%s: file not found", name)
+		}
+		root, err := filepath.EvalSymlinks(opts.Dir)
+		if err != nil {
+			return "", fmt.Sprintf("%s: file not found", name)
+		}
+		rel, err := filepath.Rel(root, real)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Sprintf("%s: paths must stay inside the import directory", name)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Sprintf("%s: file not found", name)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Sprintf("%s: not a file", name)
+	}
+	return path, ""
+}
+
+func isWebURL(value string) bool {
+	return strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://")
+}
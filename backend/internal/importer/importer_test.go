@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+)
+
+func newTestImporter(t *testing.T) (*Importer, *models.VideoRepository, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "import.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.RunMigrations(db))
+
+	storage := services.NewStorageService(
+		filepath.Join(dir, "storage/videos"), filepath.Join(dir, "storage/thumbnails"),
+		filepath.Join(dir, "storage/ads"), filepath.Join(dir, "storage/captions"),
+	)
+	videoRepo := models.NewVideoRepository(db)
+	queue := services.NewJobQueue(models.NewJobRepository(db, "sqlite"), 1, 1)
+	services.RegisterVideoJobs(queue, videoRepo, storage)
+
+	imports := filepath.Join(dir, "imports")
+	require.NoError(t, os.MkdirAll(imports, 0755))
+	return NewImporter(videoRepo, models.NewCategoryRepository(db), storage, queue), videoRepo, imports
+}
+
+func TestParseManifest_CSV(t *testing.T) {
+	rows, err := ParseManifest(strings.NewReader(
+		"\uFEFFExternal ID,Title,creator,url\n"+
+			"a1,First,Ann,https://cdn.example.com/1.mp4\n"+
+			"\n"+
+			"a2,\"Second, with comma\",Bob,https://cdn.example.com/2.mp4\n",
+	), FormatCSV)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, Row{Line: 2, ExternalID: "a1", Title: "First", Creator: "Ann", URL: "https://cdn.example.com/1.mp4"}, rows[0])
+	assert.Equal(t, 4, rows[1].Line)
+	assert.Equal(t, "Second, with comma", rows[1].Title)
+
+	for name, manifest := range map[string]string{
+		"unknown column": "external_id,title,creator,thumbnial\n1,a,b,c\n",
+		"missing column": "external_id,title\n1,a\n",
+		"duplicate":      "external_id,title,creator,Title\n1,a,b,c\n",
+		"no rows":        "external_id,title,creator\n",
+		"ragged row":     "external_id,title,creator\n1,a\n",
+		"empty":          "",
+		"unknown format": "",
+		"json unknown":   `[{"externalId": "1", "name": "x"}]`,
+		"json not array": `{"externalId": "1"}`,
+	} {
+		format := FormatCSV
+		switch {
+		case name == "unknown format":
+			format = "xml"
+		case strings.HasPrefix(name, "json"):
+			format = FormatJSON
+		}
+		_, err := ParseManifest(strings.NewReader(manifest), format)
+		assert.ErrorIs(t, err, ErrInvalidManifest, name)
+	}
+}
+
+func TestParseManifest_JSON(t *testing.T) {
+	rows, err := ParseManifest(strings.NewReader(
+		`[{"externalId": "a1", "title": "First", "creator": "Ann", "file": "clips/1.mp4"},
+		  {"externalId": "a2", "title": "Second", "creator": "Bob", "url": "https://cdn.example.com/2.mp4"}]`,
+	), FormatJSON)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, Row{Line: 1, ExternalID: "a1", Title: "First", Creator: "Ann", File: "clips/1.mp4"}, rows[0])
+	assert.Equal(t, 2, rows[1].Line)
+
+	// Columns added by exports are skipped, null is left out
+	rows, err = ParseManifest(strings.NewReader(
+		`{"id": 7, "external_id": "a1", "title": "First", "creator": "Ann", "url": "https://cdn.example.com/1.mp4", "views": 12, "thumbnail": null}`+"\n\n"+
+			`{"id": 8, "externalId": "a2", "title": "Second", "creator": "Bob", "url": "https://cdn.example.com/2.mp4"}`+"\n",
+	), FormatNDJSON)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, Row{Line: 1, ExternalID: "a1", Title: "First", Creator: "Ann", URL: "https://cdn.example.com/1.mp4"}, rows[0])
+	assert.Equal(t, 3, rows[1].Line)
+
+	_, err = ParseManifest(strings.NewReader(`{"externalId": 1}`), FormatNDJSON)
+	assert.ErrorIs(t, err, ErrInvalidManifest)
+}
+
+func TestImport(t *testing.T) {
+	imp, videoRepo, dir := newTestImporter(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cover.png"), []byte("png"), 0644))
+
+	rows := []Row{
+		{Line: 2, ExternalID: "v1", Title: "Volcanoes", Creator: "Ann", URL: "https://cdn.example.com/1.mp4",
+			Thumbnail: "cover.png", Duration: "1:30"},
+		{Line: 3, ExternalID: "v2", Title: "Glaciers", Creator: "ann", URL: "https://cdn.example.com/2.mp4",
+			Thumbnail: "https://cdn.example.com/2.jpg", Status: "draft"},
+	}
+
+	report, err := imp.Import(rows, Options{DryRun: true, Dir: dir, Confine: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Ready)
+	assert.Equal(t, StatusReady, report.Rows[0].Status)
+	existing, err := videoRepo.ExternalIDs([]string{"v1", "v2"})
+	require.NoError(t, err)
+	assert.Empty(t, existing, "dry runs create nothing")
+
+	report, err = imp.Import(rows, Options{Dir: dir, Confine: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	require.NotZero(t, report.Rows[0].VideoID)
+
+	video, err := videoRepo.GetByID(report.Rows[0].VideoID)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", video.ExternalID)
+	assert.Equal(t, "other", video.Category)
+	assert.Equal(t, models.VideoStatusPublished, video.Status)
+	assert.Equal(t, float64(90), video.DurationSeconds)
+	assert.True(t, strings.HasSuffix(video.Thumbnail, ".png"))
+	assert.NotContains(t, video.Thumbnail, "imports", "thumbnails are copied into storage")
+	assert.FileExists(t, filepath.Join(dir, "cover.png"), "originals are left in place")
+
+	second, err := videoRepo.GetByID(report.Rows[1].VideoID)
+	require.NoError(t, err)
+	assert.Equal(t, video.CreatorID, second.CreatorID, "creators are matched by slug")
+	assert.Equal(t, models.VideoStatusDraft, second.Status)
+
+	// Running the manifest again, with a new row, only adds the new row
+	rows = append(rows, Row{Line: 4, ExternalID: "v3", Title: "Deserts", Creator: "Cy", URL: "https://cdn.example.com/3.mp4"})
+	report, err = imp.Import(rows, Options{Dir: dir, Confine: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Existing)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, StatusExists, report.Rows[0].Status)
+	assert.Equal(t, video.ID, report.Rows[0].VideoID)
+}
+
+func TestImport_InvalidRowsCreateNothing(t *testing.T) {
+	imp, videoRepo, dir := newTestImporter(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.mp4"), []byte("not a video"), 0644))
+	outside := filepath.Join(filepath.Dir(dir), "secret.mp4")
+	require.NoError(t, os.WriteFile(outside, []byte("x"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link.mp4")))
+
+	rows := []Row{
+		{Line: 2, ExternalID: "ok", Title: "Fine", Creator: "Ann", URL: "https://cdn.example.com/1.mp4"},
+		{Line: 3, ExternalID: "ok", Title: "Same ID", Creator: "Ann", URL: "https://cdn.example.com/2.mp4"},
+		{Line: 4, ExternalID: "", Title: "", Creator: "!!!", URL: "ftp://example.com/3.mp4"},
+		{Line: 5, ExternalID: "both", Title: "Both", Creator: "Ann", URL: "https://cdn.example.com/4.mp4", File: "broken.mp4"},
+		{Line: 6, ExternalID: "broken", Title: "Broken", Creator: "Ann", File: "broken.mp4"},
+		{Line: 7, ExternalID: "escape", Title: "Escape", Creator: "Ann", File: "../secret.mp4"},
+		{Line: 8, ExternalID: "link", Title: "Link", Creator: "Ann", File: "link.mp4"},
+		{Line: 9, ExternalID: "abs", Title: "Absolute", Creator: "Ann", File: outside},
+		{Line: 10, ExternalID: "cat", Title: "Category", Creator: "Ann", Category: "nope", URL: "https://cdn.example.com/5.mp4",
+			Thumbnail: "cover.svg", Duration: "soon"},
+		{Line: 11, ExternalID: "status", Title: "Status", Creator: "Ann", URL: "https://cdn.example.com/6.mp4", Status: "hidden"},
+		{Line: 12, ExternalID: "past", Title: "Past", Creator: "Ann", URL: "https://cdn.example.com/7.mp4",
+			Status: "scheduled", PublishAt: "2020-01-01T00:00:00Z"},
+	}
+
+	report, err := imp.Import(rows, Options{Dir: dir, Confine: true})
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 1, report.Ready)
+	assert.Equal(t, 10, report.Invalid)
+
+	byLine := make(map[int]Result)
+	for _, r := range report.Rows {
+		byLine[r.Line] = r
+	}
+	assert.Equal(t, []string{"External ID is also used on line 2"}, byLine[3].Errors)
+	assert.Len(t, byLine[4].Errors, 4)
+	assert.Equal(t, []string{"Set either a file or a URL, not both"}, byLine[5].Errors)
+	assert.Contains(t, byLine[6].Errors[0], "invalid video file")
+	assert.Equal(t, []string{"../secret.mp4: paths must stay inside the import directory"}, byLine[7].Errors)
+	assert.Equal(t, []string{"link.mp4: paths must stay inside the import directory"}, byLine[8].Errors)
+	assert.Equal(t, []string{outside + ": paths must be relative to the import directory"}, byLine[9].Errors)
+	assert.Len(t, byLine[10].Errors, 3)
+	assert.Contains(t, byLine[11].Errors[0], "status must be one of")
+	assert.Equal(t, []string{"publishAt must be in the future"}, byLine[12].Errors)
+
+	existing, err := videoRepo.ExternalIDs([]string{"ok"})
+	require.NoError(t, err)
+	assert.Empty(t, existing)
+}
@@ -0,0 +1,270 @@
+// Package importer creates videos in bulk from a catalog manifest, a CSV,
+// JSON or NDJSON file listing one video per row. Rows carry an external ID, the video's
+// ID in the catalog it comes from, which makes imports idempotent: rows whose
+// ID was imported before are skipped, so a manifest can be run again after
+// fixing the rows that failed.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Manifest formats accepted by ParseManifest
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson" // One JSON object per line
+)
+
+// MaxRows caps the rows of one manifest; larger catalogs are imported in parts
+const MaxRows = 5000
+
+// maxNDJSONLine caps a line of an NDJSON manifest; descriptions are at most 5000 characters
+const maxNDJSONLine = 1 << 20
+
+// ErrInvalidManifest is returned, wrapped with the reason, for manifests that
+// can't be read at all. Problems with single rows are reported per row by
+// Importer.Import instead.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// Row is one video of a manifest. Exactly one of File, a path to a local
+// video file, and URL, an external video link, is set.
+type Row struct {
+	Line        int // Line of a CSV or NDJSON row, or 1-based index of a JSON one
+	ExternalID  string
+	Title       string
+	Creator     string
+	Category    string
+	Description string
+	File        string
+	URL         string
+	Thumbnail   string // An http(s) URL or a path to a local image
+	Duration    string // Only needed for URLs; files are probed
+	Status      string // Published when empty
+	PublishAt   string // RFC 3339, for scheduled videos
+}
+
+// columns maps normalized column names to the Row field they fill
+var columns = map[string]func(*Row) *string{
+	"externalid":  func(r *Row) *string { return &r.ExternalID },
+	"title":       func(r *Row) *string { return &r.Title },
+	"creator":     func(r *Row) *string { return &r.Creator },
+	"category":    func(r *Row) *string { return &r.Category },
+	"description": func(r *Row) *string { return &r.Description },
+	"file":        func(r *Row) *string { return &r.File },
+	"url":         func(r *Row) *string { return &r.URL },
+	"thumbnail":   func(r *Row) *string { return &r.Thumbnail },
+	"duration":    func(r *Row) *string { return &r.Duration },
+	"status":      func(r *Row) *string { return &r.Status },
+	"publishat":   func(r *Row) *string { return &r.PublishAt },
+}
+
+// exportedColumns are written by catalog exports next to the columns above.
+// They describe the video on the site it was exported from and are skipped,
+// so exports can be imported as they are.
+var exportedColumns = map[string]bool{
+	"id":        true,
+	"views":     true,
+	"likes":     true,
+	"dislikes":  true,
+	"createdat": true,
+}
+
+// requiredColumns must be in every CSV header
+var requiredColumns = []string{"externalid", "title", "creator"}
+
+// FormatFromName returns the manifest format for a file name, by extension
+func FormatFromName(name string) (string, bool) {
+	switch {
+	case strings.HasSuffix(strings.ToLower(name), ".csv"):
+		return FormatCSV, true
+	case strings.HasSuffix(strings.ToLower(name), ".json"):
+		return FormatJSON, true
+	case strings.HasSuffix(strings.ToLower(name), ".ndjson"), strings.HasSuffix(strings.ToLower(name), ".jsonl"):
+		return FormatNDJSON, true
+	}
+	return "", false
+}
+
+// ParseManifest reads the rows of a manifest. CSV manifests start with a
+// header naming the columns, in any order and case, with external_id,
+// external-id and "External ID" all accepted. JSON manifests are an array of
+// objects with the same names, usually in camelCase (externalId), and NDJSON
+// ones have an object per line. Columns that catalog exports add are skipped.
+func ParseManifest(r io.Reader, format string) ([]Row, error) {
+	var rows []Row
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = parseCSV(r)
+	case FormatJSON:
+		rows, err = parseJSON(r)
+	case FormatNDJSON:
+		rows, err = parseNDJSON(r)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidManifest, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rows", ErrInvalidManifest)
+	}
+	if len(rows) > MaxRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidManifest, MaxRows)
+	}
+	return rows, nil
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidManifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	// Spreadsheet programs start UTF-8 exports with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+
+	fields := make([]func(*Row) *string, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		key := normalizeColumn(name)
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidManifest, name)
+		}
+		seen[key] = true
+		if exportedColumns[key] {
+			continue
+		}
+		field, ok := columns[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidManifest, name)
+		}
+		fields[i] = field
+	}
+	for _, key := range requiredColumns {
+		if !seen[key] {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidManifest, key)
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidManifest, MaxRows)
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line}
+		for i, value := range record {
+			if fields[i] != nil {
+				*fields[i](&row) = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// normalizeColumn lowercases a column name and drops the separators people
+// put between words
+func normalizeColumn(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+func parseJSON(r io.Reader) ([]Row, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	rows := make([]Row, len(objects))
+	for i, object := range objects {
+		row, err := objectRow(object, i+1)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// parseNDJSON reads one JSON object per line, skipping blank lines
+func parseNDJSON(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxNDJSONLine)
+
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if line == 1 {
+			text = bytes.TrimPrefix(text, []byte("\uFEFF"))
+		}
+		if len(text) == 0 {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidManifest, MaxRows)
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(text, &object); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidManifest, line, err)
+		}
+		row, err := objectRow(object, line)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	return rows, nil
+}
+
+// objectRow fills a row from a JSON object
+func objectRow(object map[string]json.RawMessage, line int) (Row, error) {
+	row := Row{Line: line}
+	for name, value := range object {
+		key := normalizeColumn(name)
+		if exportedColumns[key] {
+			continue
+		}
+		field, ok := columns[key]
+		if !ok {
+			return row, fmt.Errorf("%w: row %d: unknown field %q", ErrInvalidManifest, line, name)
+		}
+		// null is the same as leaving the field out
+		if err := json.Unmarshal(value, field(&row)); err != nil {
+			return row, fmt.Errorf("%w: row %d: %s must be a string", ErrInvalidManifest, line, name)
+		}
+	}
+	return row, nil
+}
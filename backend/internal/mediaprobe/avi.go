@@ -0,0 +1,134 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// maxHdrlSize caps the AVI header list we load into memory
+const maxHdrlSize = 1 << 20
+
+func probeAVI(r io.ReadSeeker, size int64) (*Info, error) {
+	// RIFF(4) size(4) "AVI "(4) then the first chunk must be LIST hdrl
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, ErrInvalidContainer
+	}
+	if string(hdr[0:4]) != "LIST" || string(hdr[8:12]) != "hdrl" {
+		return nil, ErrInvalidContainer
+	}
+
+	listSize := int64(binary.LittleEndian.Uint32(hdr[4:8])) - 4
+	if listSize < 56 || listSize > maxHdrlSize || 24+listSize > size {
+		return nil, ErrInvalidContainer
+	}
+	hdrl := make([]byte, listSize)
+	if _, err := io.ReadFull(r, hdrl); err != nil {
+		return nil, ErrInvalidContainer
+	}
+
+	info := &Info{Container: ContainerAVI}
+	foundMain := false
+	var streamType string
+
+	eachChunk(hdrl, func(id string, data []byte) {
+		switch id {
+		case "avih":
+			// dwMicroSecPerFrame(4) ... dwTotalFrames(4) at 16 ... dwWidth(4) at 32, dwHeight(4) at 36
+			if len(data) < 40 {
+				return
+			}
+			foundMain = true
+			usPerFrame := binary.LittleEndian.Uint32(data[0:4])
+			frames := binary.LittleEndian.Uint32(data[16:20])
+			info.Duration = float64(usPerFrame) * float64(frames) / 1e6
+			info.Width = int(binary.LittleEndian.Uint32(data[32:36]))
+			info.Height = int(binary.LittleEndian.Uint32(data[36:40]))
+		case "strl":
+			streamType = ""
+			eachChunk(data, func(id string, data []byte) {
+				switch id {
+				case "strh":
+					// fccType(4) fccHandler(4)
+					if len(data) < 8 {
+						return
+					}
+					streamType = string(data[0:4])
+					if streamType == "vids" && info.VideoCodec == "" {
+						info.VideoCodec = aviVideoCodec(string(data[4:8]))
+					}
+				case "strf":
+					// WAVEFORMATEX starts with wFormatTag
+					if streamType == "auds" && info.AudioCodec == "" && len(data) >= 2 {
+						info.AudioCodec = aviAudioCodec(binary.LittleEndian.Uint16(data[0:2]))
+					}
+				}
+			})
+		}
+	})
+
+	if !foundMain {
+		return nil, ErrInvalidContainer
+	}
+	return info, nil
+}
+
+// eachChunk walks RIFF chunks; LIST chunks are reported under their list type
+func eachChunk(data []byte, fn func(id string, payload []byte)) {
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		n := int(binary.LittleEndian.Uint32(data[4:8]))
+		if n < 0 || n > len(data)-8 {
+			return
+		}
+		payload := data[8 : 8+n]
+
+		if id == "LIST" && len(payload) >= 4 {
+			fn(string(payload[0:4]), payload[4:])
+		} else {
+			fn(id, payload)
+		}
+
+		// Chunks are padded to an even size
+		next := 8 + n + n%2
+		if next > len(data) {
+			return
+		}
+		data = data[next:]
+	}
+}
+
+func aviVideoCodec(handler string) string {
+	switch strings.ToLower(strings.TrimRight(handler, "\x00 ")) {
+	case "h264", "x264", "avc1":
+		return "h264"
+	case "xvid", "divx", "dx50", "fmp4", "mp4v":
+		return "mpeg4"
+	case "mjpg":
+		return "mjpeg"
+	case "":
+		return ""
+	default:
+		return strings.ToLower(strings.TrimSpace(handler))
+	}
+}
+
+func aviAudioCodec(formatTag uint16) string {
+	switch formatTag {
+	case 0x0001:
+		return "pcm"
+	case 0x0055:
+		return "mp3"
+	case 0x00FF, 0x1610:
+		return "aac"
+	case 0x2000:
+		return "ac3"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,315 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// maxMoovSize caps how much of the movie header we load into memory
+const maxMoovSize = 128 << 20
+
+// Top-level box types that may legitimately start an MP4/MOV file
+var leadingBoxTypes = map[string]bool{
+	"ftyp": true, "moov": true, "mdat": true, "free": true,
+	"skip": true, "wide": true, "pnot": true, "styp": true,
+}
+
+func isBoxType(b []byte) bool {
+	return leadingBoxTypes[string(b)]
+}
+
+type isoTrack struct {
+	handler    string
+	width      int
+	height     int
+	codec      string
+	timescale  uint32
+	duration   uint64
+	sampleSize [2]int // width/height from the sample description
+}
+
+func probeISOBMFF(r io.ReadSeeker, size int64) (*Info, error) {
+	info := &Info{Container: ContainerMP4}
+	var moov []byte
+
+	// Walk top-level boxes by seeking so a multi-gigabyte mdat is never read
+	offset := int64(0)
+	for offset < size {
+		typ, headerSize, boxSize, err := readBoxHeader(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case "ftyp":
+			brand := make([]byte, 4)
+			if _, err := r.Seek(offset+headerSize, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if _, err := io.ReadFull(r, brand); err != nil {
+				return nil, ErrInvalidContainer
+			}
+			if string(brand) == "qt  " {
+				info.Container = ContainerMOV
+			}
+		case "moov":
+			payloadSize := boxSize - headerSize
+			if payloadSize > maxMoovSize {
+				return nil, ErrInvalidContainer
+			}
+			moov = make([]byte, payloadSize)
+			if _, err := r.Seek(offset+headerSize, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, ErrInvalidContainer
+			}
+		}
+
+		offset += boxSize
+	}
+
+	if moov == nil {
+		return nil, ErrInvalidContainer
+	}
+	if err := parseMoov(moov, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// readBoxHeader returns the type, header length and total length of the box at offset
+func readBoxHeader(r io.ReadSeeker, offset, fileSize int64) (string, int64, int64, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return "", 0, 0, err
+	}
+
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+		return "", 0, 0, ErrInvalidContainer
+	}
+
+	boxSize := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	typ := string(hdr[4:8])
+	headerSize := int64(8)
+
+	switch boxSize {
+	case 0: // box extends to end of file
+		boxSize = fileSize - offset
+	case 1: // 64-bit largesize follows the type
+		if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+			return "", 0, 0, ErrInvalidContainer
+		}
+		boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		headerSize = 16
+	}
+
+	if boxSize < headerSize || offset+boxSize > fileSize {
+		return "", 0, 0, ErrInvalidContainer
+	}
+	return typ, headerSize, boxSize, nil
+}
+
+// eachBox calls fn for every child box in data. Malformed sizes stop the walk with an error.
+func eachBox(data []byte, fn func(typ string, payload []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return ErrInvalidContainer
+		}
+		boxSize := uint64(binary.BigEndian.Uint32(data[0:4]))
+		typ := string(data[4:8])
+		headerSize := uint64(8)
+
+		switch boxSize {
+		case 0:
+			boxSize = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return ErrInvalidContainer
+			}
+			boxSize = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+
+		if boxSize < headerSize || boxSize > uint64(len(data)) {
+			return ErrInvalidContainer
+		}
+		if err := fn(typ, data[headerSize:boxSize]); err != nil {
+			return err
+		}
+		data = data[boxSize:]
+	}
+	return nil
+}
+
+func parseMoov(moov []byte, info *Info) error {
+	var timescale uint32
+	var duration uint64
+	var tracks []*isoTrack
+
+	err := eachBox(moov, func(typ string, payload []byte) error {
+		switch typ {
+		case "mvhd":
+			var ok bool
+			timescale, duration, ok = parseTimeHeader(payload)
+			if !ok {
+				return ErrInvalidContainer
+			}
+		case "trak":
+			track := &isoTrack{}
+			if err := parseTrak(payload, track); err != nil {
+				return err
+			}
+			tracks = append(tracks, track)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if timescale == 0 {
+		return ErrInvalidContainer
+	}
+
+	info.Duration = float64(duration) / float64(timescale)
+
+	for _, t := range tracks {
+		// Fall back to the longest track when mvhd carries no duration (fragmented files)
+		if info.Duration == 0 && t.timescale > 0 {
+			if d := float64(t.duration) / float64(t.timescale); d > info.Duration {
+				info.Duration = d
+			}
+		}
+
+		switch t.handler {
+		case "vide":
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = codecName(t.codec)
+			info.Width, info.Height = t.width, t.height
+			if info.Width == 0 || info.Height == 0 {
+				info.Width, info.Height = t.sampleSize[0], t.sampleSize[1]
+			}
+		case "soun":
+			if info.AudioCodec == "" {
+				info.AudioCodec = codecName(t.codec)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseTimeHeader reads timescale and duration from an mvhd or mdhd payload
+func parseTimeHeader(p []byte) (uint32, uint64, bool) {
+	if len(p) < 4 {
+		return 0, 0, false
+	}
+	if p[0] == 1 {
+		// version(1) flags(3) creation(8) modification(8) timescale(4) duration(8)
+		if len(p) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(p[20:24]), binary.BigEndian.Uint64(p[24:32]), true
+	}
+	// version(1) flags(3) creation(4) modification(4) timescale(4) duration(4)
+	if len(p) < 20 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(p[12:16]), uint64(binary.BigEndian.Uint32(p[16:20])), true
+}
+
+func parseTrak(trak []byte, track *isoTrack) error {
+	return eachBox(trak, func(typ string, payload []byte) error {
+		switch typ {
+		case "tkhd":
+			// Width and height are the last two 16.16 fixed-point fields
+			if len(payload) >= 84 {
+				n := len(payload)
+				track.width = int(binary.BigEndian.Uint32(payload[n-8:n-4]) >> 16)
+				track.height = int(binary.BigEndian.Uint32(payload[n-4:n]) >> 16)
+			}
+		case "mdia":
+			return parseMdia(payload, track)
+		}
+		return nil
+	})
+}
+
+func parseMdia(mdia []byte, track *isoTrack) error {
+	return eachBox(mdia, func(typ string, payload []byte) error {
+		switch typ {
+		case "mdhd":
+			track.timescale, track.duration, _ = parseTimeHeader(payload)
+		case "hdlr":
+			// version/flags(4) pre_defined(4) handler_type(4)
+			if len(payload) >= 12 {
+				track.handler = string(payload[8:12])
+			}
+		case "minf":
+			return eachBox(payload, func(typ string, payload []byte) error {
+				if typ != "stbl" {
+					return nil
+				}
+				return eachBox(payload, func(typ string, payload []byte) error {
+					if typ == "stsd" {
+						parseStsd(payload, track)
+					}
+					return nil
+				})
+			})
+		}
+		return nil
+	})
+}
+
+// parseStsd reads the codec fourcc and coded size from the first sample entry
+func parseStsd(p []byte, track *isoTrack) {
+	// version/flags(4) entry_count(4) then sample entries: size(4) format(4) ...
+	if len(p) < 16 {
+		return
+	}
+	entry := p[8:]
+	track.codec = string(entry[4:8])
+
+	// Visual sample entry: reserved(6) data_ref_index(2) pre_defined/reserved(16) width(2) height(2)
+	if len(entry) >= 36 {
+		track.sampleSize[0] = int(binary.BigEndian.Uint16(entry[32:34]))
+		track.sampleSize[1] = int(binary.BigEndian.Uint16(entry[34:36]))
+	}
+}
+
+// codecName maps sample entry fourccs to the short names stored in the videos table
+func codecName(fourcc string) string {
+	switch fourcc {
+	case "avc1", "avc3":
+		return "h264"
+	case "hvc1", "hev1":
+		return "hevc"
+	case "av01":
+		return "av1"
+	case "vp08":
+		return "vp8"
+	case "vp09":
+		return "vp9"
+	case "mp4v":
+		return "mpeg4"
+	case "mp4a":
+		return "aac"
+	case "Opus":
+		return "opus"
+	case "ac-3":
+		return "ac3"
+	case "ec-3":
+		return "eac3"
+	case "fLaC":
+		return "flac"
+	case ".mp3":
+		return "mp3"
+	case "":
+		return ""
+	default:
+		return fourcc
+	}
+}
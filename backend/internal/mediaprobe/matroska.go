@@ -0,0 +1,324 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+)
+
+// EBML element IDs used by the probe (marker bits included, as in the spec)
+const (
+	ebmlHeaderID    = 0x1A45DFA3
+	ebmlDocTypeID   = 0x4282
+	segmentID       = 0x18538067
+	infoID          = 0x1549A966
+	timecodeScaleID = 0x2AD7B1
+	durationID      = 0x4489
+	tracksID        = 0x1654AE6B
+	trackEntryID    = 0xAE
+	trackTypeID     = 0x83
+	codecIDID       = 0x86
+	videoID         = 0xE0
+	pixelWidthID    = 0xB0
+	pixelHeightID   = 0xBA
+	clusterID       = 0x1F43B675
+)
+
+// maxElementSize caps the header elements (Info, Tracks) we load into memory
+const maxElementSize = 16 << 20
+
+// unknownSize marks an element whose size field is all ones (live streams)
+const unknownSize = -1
+
+func probeMatroska(r io.ReadSeeker, size int64) (*Info, error) {
+	br := &ebmlReader{r: r}
+
+	// EBML header
+	id, headerLen, err := br.readElement()
+	if err != nil || id != ebmlHeaderID || headerLen == unknownSize {
+		return nil, ErrInvalidContainer
+	}
+	header, err := br.readPayload(headerLen)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Container: ContainerMatroska}
+	eachElement(header, func(id uint64, payload []byte) {
+		if id == ebmlDocTypeID && string(payload) == "webm" {
+			info.Container = ContainerWebM
+		}
+	})
+
+	// Segment
+	id, segmentLen, err := br.readElement()
+	if err != nil || id != segmentID {
+		return nil, ErrInvalidContainer
+	}
+	segmentEnd := size
+	if segmentLen != unknownSize && br.pos+segmentLen < size {
+		segmentEnd = br.pos + segmentLen
+	}
+
+	timecodeScale := uint64(1000000) // default: 1ms per tick
+	var rawDuration float64
+	foundInfo, foundTracks := false, false
+
+	for br.pos < segmentEnd && !(foundInfo && foundTracks) {
+		id, elemLen, err := br.readElement()
+		if err != nil {
+			break
+		}
+		// Clusters hold the media; header elements always come before the first one
+		if id == clusterID || elemLen == unknownSize {
+			break
+		}
+
+		switch id {
+		case infoID, tracksID:
+			payload, err := br.readPayload(elemLen)
+			if err != nil {
+				return nil, err
+			}
+			if id == infoID {
+				foundInfo = true
+				eachElement(payload, func(id uint64, p []byte) {
+					switch id {
+					case timecodeScaleID:
+						timecodeScale = readUint(p)
+					case durationID:
+						rawDuration = readFloat(p)
+					}
+				})
+			} else {
+				foundTracks = true
+				parseTracks(payload, info)
+			}
+		default:
+			if _, err := br.skip(elemLen); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !foundTracks {
+		return nil, ErrInvalidContainer
+	}
+
+	// Duration is expressed in timecode ticks of timecodeScale nanoseconds
+	info.Duration = rawDuration * float64(timecodeScale) / 1e9
+	return info, nil
+}
+
+func parseTracks(tracks []byte, info *Info) {
+	eachElement(tracks, func(id uint64, entry []byte) {
+		if id != trackEntryID {
+			return
+		}
+
+		var trackType uint64
+		var codec string
+		var width, height int
+		eachElement(entry, func(id uint64, p []byte) {
+			switch id {
+			case trackTypeID:
+				trackType = readUint(p)
+			case codecIDID:
+				codec = strings.TrimRight(string(p), "\x00")
+			case videoID:
+				eachElement(p, func(id uint64, p []byte) {
+					switch id {
+					case pixelWidthID:
+						width = int(readUint(p))
+					case pixelHeightID:
+						height = int(readUint(p))
+					}
+				})
+			}
+		})
+
+		switch trackType {
+		case 1: // video
+			if info.VideoCodec == "" {
+				info.VideoCodec = matroskaCodecName(codec)
+				info.Width, info.Height = width, height
+			}
+		case 2: // audio
+			if info.AudioCodec == "" {
+				info.AudioCodec = matroskaCodecName(codec)
+			}
+		}
+	})
+}
+
+func matroskaCodecName(codecID string) string {
+	switch codecID {
+	case "V_VP8":
+		return "vp8"
+	case "V_VP9":
+		return "vp9"
+	case "V_AV1":
+		return "av1"
+	case "V_MPEG4/ISO/AVC":
+		return "h264"
+	case "V_MPEGH/ISO/HEVC":
+		return "hevc"
+	case "A_OPUS":
+		return "opus"
+	case "A_VORBIS":
+		return "vorbis"
+	case "A_FLAC":
+		return "flac"
+	case "A_AC3":
+		return "ac3"
+	case "A_MPEG/L3":
+		return "mp3"
+	}
+	if strings.HasPrefix(codecID, "A_AAC") {
+		return "aac"
+	}
+	return strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(codecID, "V_"), "A_"))
+}
+
+// ebmlReader reads EBML element headers from a seekable stream
+type ebmlReader struct {
+	r   io.ReadSeeker
+	pos int64
+}
+
+// readElement returns the next element ID and payload size (unknownSize if unset)
+func (br *ebmlReader) readElement() (uint64, int64, error) {
+	id, _, err := br.readVint(true)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, allOnes, err := br.readVint(false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if allOnes {
+		return id, unknownSize, nil
+	}
+	return id, int64(size), nil
+}
+
+func (br *ebmlReader) readPayload(n int64) ([]byte, error) {
+	if n < 0 || n > maxElementSize {
+		return nil, ErrInvalidContainer
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(br.r, buf); err != nil {
+		return nil, ErrInvalidContainer
+	}
+	br.pos += n
+	return buf, nil
+}
+
+func (br *ebmlReader) skip(n int64) (int64, error) {
+	pos, err := br.r.Seek(n, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	br.pos = pos
+	return pos, nil
+}
+
+// readVint reads an EBML variable-length integer. IDs keep their length
+// marker bit; sizes have it stripped. allOnes reports the reserved "unknown" value.
+func (br *ebmlReader) readVint(keepMarker bool) (uint64, bool, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(br.r, first); err != nil {
+		return 0, false, ErrInvalidContainer
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, false, ErrInvalidContainer
+	}
+
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(br.r, rest); err != nil {
+		return 0, false, ErrInvalidContainer
+	}
+	br.pos += int64(length)
+
+	value, allOnes := decodeVint(first[0], rest, length, keepMarker)
+	return value, allOnes, nil
+}
+
+func decodeVint(first byte, rest []byte, length int, keepMarker bool) (uint64, bool) {
+	marker := byte(0x80) >> (length - 1)
+	bits := first & (marker - 1)
+
+	value := uint64(bits)
+	if keepMarker {
+		value = uint64(first)
+	}
+
+	allOnes := bits == marker-1
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+		if b != 0xFF {
+			allOnes = false
+		}
+	}
+	return value, allOnes
+}
+
+// eachElement walks the child elements of an in-memory master element
+func eachElement(data []byte, fn func(id uint64, payload []byte)) {
+	for len(data) > 0 {
+		id, n := bufVint(data, true)
+		if n == 0 {
+			return
+		}
+		data = data[n:]
+
+		size, m := bufVint(data, false)
+		if m == 0 || size > uint64(len(data)-m) {
+			return
+		}
+		data = data[m:]
+
+		fn(id, data[:size])
+		data = data[size:]
+	}
+}
+
+// bufVint decodes a vint at the start of data and returns it with its length (0 on error)
+func bufVint(data []byte, keepMarker bool) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || len(data) < length {
+		return 0, 0
+	}
+	value, _ := decodeVint(data[0], data[1:length], length, keepMarker)
+	return value, length
+}
+
+func readUint(p []byte) uint64 {
+	var v uint64
+	for _, b := range p {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func readFloat(p []byte) float64 {
+	switch len(p) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(p)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(p))
+	}
+	return 0
+}
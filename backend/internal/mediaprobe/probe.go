@@ -0,0 +1,132 @@
+// Package mediaprobe reads container headers of uploaded videos without
+// shelling out to ffprobe. It understands ISO-BMFF (MP4/MOV), Matroska/WebM
+// and RIFF AVI well enough to extract duration, dimensions and codecs.
+package mediaprobe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Container names reported in Info.Container
+const (
+	ContainerMP4      = "mp4"
+	ContainerMOV      = "mov"
+	ContainerWebM     = "webm"
+	ContainerMatroska = "matroska"
+	ContainerAVI      = "avi"
+)
+
+var (
+	// ErrUnknownFormat is returned when the data doesn't start with a known container signature
+	ErrUnknownFormat = errors.New("mediaprobe: unrecognized container format")
+	// ErrInvalidContainer is returned when the signature matches but the structure is broken
+	ErrInvalidContainer = errors.New("mediaprobe: invalid or truncated container")
+)
+
+// Info holds the metadata extracted from a media container
+type Info struct {
+	Container  string  `json:"container"`
+	Duration   float64 `json:"duration"` // seconds
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	VideoCodec string  `json:"videoCodec,omitempty"`
+	AudioCodec string  `json:"audioCodec,omitempty"`
+	Bitrate    int64   `json:"bitrate,omitempty"` // bits per second, averaged over the file
+	Size       int64   `json:"size"`
+}
+
+// HasVideo reports whether a video track was found
+func (i *Info) HasVideo() bool {
+	return i.VideoCodec != "" || i.Width > 0
+}
+
+// ProbeFile opens path and probes it
+func ProbeFile(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Probe(f)
+}
+
+// Probe detects the container type from the leading bytes and parses its headers
+func Probe(r io.ReadSeeker) (*Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return nil, ErrUnknownFormat
+		}
+		return nil, err
+	}
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var info *Info
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		info, err = probeMatroska(r, size)
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")):
+		info, err = probeAVI(r, size)
+	case len(header) >= 8 && isBoxType(header[4:8]):
+		info, err = probeISOBMFF(r, size)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info.Size = size
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(size*8) / info.Duration)
+	}
+	return info, nil
+}
+
+// FormatDuration renders seconds the way creators write it: M:SS or H:MM:SS
+func FormatDuration(seconds float64) string {
+	total := int(seconds + 0.5)
+	h, m, s := total/3600, (total%3600)/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// ParseDuration reads a duration written as SS, M:SS or H:MM:SS back into
+// seconds. It reports false for anything else.
+func ParseDuration(text string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(text), ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+
+	total := 0
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && (len(part) != 2 || n > 59)) {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	return float64(total), total > 0
+}
@@ -0,0 +1,189 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// box builds an ISO-BMFF box
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out, uint32(8+len(body)))
+	copy(out[4:], typ)
+	return append(out, body...)
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func zeros(n int) []byte { return make([]byte, n) }
+
+func buildMP4(brand string, timescale, duration uint32, width, height uint16) []byte {
+	mvhd := box("mvhd", zeros(4), u32(0), u32(0), u32(timescale), u32(duration), zeros(80))
+	tkhd := box("tkhd", zeros(4), zeros(72), u32(uint32(width)<<16), u32(uint32(height)<<16))
+
+	videoEntry := box("avc1", zeros(6), u16(1), zeros(16), u16(width), u16(height), zeros(50))
+	videoTrak := box("trak", tkhd, box("mdia",
+		box("mdhd", zeros(4), u32(0), u32(0), u32(timescale), u32(duration), zeros(4)),
+		box("hdlr", zeros(4), zeros(4), []byte("vide"), zeros(12)),
+		box("minf", box("stbl", box("stsd", zeros(4), u32(1), videoEntry))),
+	))
+
+	audioEntry := box("mp4a", zeros(6), u16(1), zeros(20))
+	audioTrak := box("trak", box("mdia",
+		box("hdlr", zeros(4), zeros(4), []byte("soun"), zeros(12)),
+		box("minf", box("stbl", box("stsd", zeros(4), u32(1), audioEntry))),
+	))
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte(brand), u32(0), []byte("isom")),
+		box("mdat", zeros(1000)),
+		box("moov", mvhd, videoTrak, audioTrak),
+	}, nil)
+}
+
+// ebml builds an EBML element with a fixed 8-byte size field
+func ebml(id uint64, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(out, size...), body...)
+}
+
+func buildWebM(durationMs float64, width, height byte) []byte {
+	dur := make([]byte, 8)
+	binary.BigEndian.PutUint64(dur, math.Float64bits(durationMs))
+
+	return bytes.Join([][]byte{
+		ebml(ebmlHeaderID, ebml(ebmlDocTypeID, []byte("webm"))),
+		ebml(segmentID,
+			ebml(infoID, ebml(timecodeScaleID, []byte{0x0F, 0x42, 0x40}), ebml(durationID, dur)),
+			ebml(tracksID,
+				ebml(trackEntryID, ebml(trackTypeID, []byte{1}), ebml(codecIDID, []byte("V_VP9")),
+					ebml(videoID, ebml(pixelWidthID, []byte{width}), ebml(pixelHeightID, []byte{height}))),
+				ebml(trackEntryID, ebml(trackTypeID, []byte{2}), ebml(codecIDID, []byte("A_OPUS"))),
+			),
+			ebml(clusterID, zeros(100)),
+		),
+	}, nil)
+}
+
+func TestProbe_MP4(t *testing.T) {
+	data := buildMP4("isom", 1000, 90500, 1920, 1080)
+
+	info, err := Probe(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, ContainerMP4, info.Container)
+	assert.InDelta(t, 90.5, info.Duration, 0.001)
+	assert.Equal(t, 1920, info.Width)
+	assert.Equal(t, 1080, info.Height)
+	assert.Equal(t, "h264", info.VideoCodec)
+	assert.Equal(t, "aac", info.AudioCodec)
+	assert.Equal(t, int64(len(data)), info.Size)
+	assert.Greater(t, info.Bitrate, int64(0))
+}
+
+func TestProbe_MOVBrand(t *testing.T) {
+	info, err := Probe(bytes.NewReader(buildMP4("qt  ", 600, 1200, 640, 480)))
+	require.NoError(t, err)
+	assert.Equal(t, ContainerMOV, info.Container)
+	assert.InDelta(t, 2.0, info.Duration, 0.001)
+}
+
+func TestProbe_WebM(t *testing.T) {
+	info, err := Probe(bytes.NewReader(buildWebM(12345, 200, 100)))
+	require.NoError(t, err)
+
+	assert.Equal(t, ContainerWebM, info.Container)
+	assert.InDelta(t, 12.345, info.Duration, 0.001)
+	assert.Equal(t, 200, info.Width)
+	assert.Equal(t, 100, info.Height)
+	assert.Equal(t, "vp9", info.VideoCodec)
+	assert.Equal(t, "opus", info.AudioCodec)
+}
+
+func TestProbe_AVI(t *testing.T) {
+	le := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, v)
+		return b
+	}
+	chunk := func(id string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		return append(append([]byte(id), le(uint32(len(body)))...), body...)
+	}
+
+	avih := chunk("avih", le(40000), zeros(12), le(250), zeros(12), le(320), le(240), zeros(16))
+	strl := chunk("LIST", []byte("strl"), chunk("strh", []byte("vidsXVID"), zeros(48)))
+	hdrl := chunk("LIST", []byte("hdrl"), avih, strl)
+	data := chunk("RIFF", []byte("AVI "), hdrl)
+
+	info, err := Probe(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, ContainerAVI, info.Container)
+	assert.InDelta(t, 10.0, info.Duration, 0.001)
+	assert.Equal(t, 320, info.Width)
+	assert.Equal(t, "mpeg4", info.VideoCodec)
+}
+
+func TestProbe_RejectsInvalidData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrUnknownFormat},
+		{"text renamed to mp4", []byte("this is definitely not a video file"), ErrUnknownFormat},
+		{"mp4 without moov", box("ftyp", []byte("isom"), u32(0)), ErrInvalidContainer},
+		{"truncated mp4", buildMP4("isom", 1000, 1000, 16, 16)[:60], ErrInvalidContainer},
+		{"ebml header only", ebml(ebmlHeaderID, ebml(ebmlDocTypeID, []byte("webm"))), ErrInvalidContainer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Probe(bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "0:59", FormatDuration(59.4))
+	assert.Equal(t, "1:30", FormatDuration(90.4))
+	assert.Equal(t, "1:02:03", FormatDuration(3723))
+}
+
+func TestParseDuration(t *testing.T) {
+	for text, want := range map[string]float64{"45": 45, "1:30": 90, "1:02:03": 3723, " 12:00 ": 720} {
+		got, ok := ParseDuration(text)
+		assert.True(t, ok, text)
+		assert.Equal(t, want, got, text)
+	}
+	for _, text := range []string{"", "0:00", "1:5", "1:60", "a:10", "1:2:3:4", "-1:00", "1h30m"} {
+		_, ok := ParseDuration(text)
+		assert.False(t, ok, text)
+	}
+}
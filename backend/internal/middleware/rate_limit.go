@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
 )
 
 // RateLimiter implements a simple token bucket rate limiter
@@ -106,7 +107,7 @@ func (rl *RateLimiter) Allow(ip string) bool {
 func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getIPAddress(r)
+			ip := utils.ClientIP(r)
 
 			if !limiter.Allow(ip) {
 				log.Printf("[RateLimit] SECURITY: Rate limit exceeded for IP: %s on %s %s", ip, r.Method, r.URL.Path)
@@ -119,25 +120,6 @@ func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 	}
 }
 
-// getIPAddress extracts the real IP address from the request
-func getIPAddress(r *http.Request) string {
-	// Check X-Forwarded-For header (if behind proxy)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		return forwarded
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
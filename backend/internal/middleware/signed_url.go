@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/services"
+	"titan-backend/internal/utils"
+)
+
+// playlistURIAttr matches URI attributes in HLS tags such as EXT-X-MAP
+var playlistURIAttr = regexp.MustCompile(`URI="([^"?]*)"`)
+
+// SignedStorage rejects /storage requests without a valid signature unless the
+// file is in a public directory. HLS playlists are rewritten so their segment
+// URIs carry the playlist's own signature.
+func SignedStorage(signer *services.URLSigner) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := path.Clean(r.URL.Path)
+			if signer.IsPublic(p) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := signer.Verify(p, r.URL.Query(), utils.ClientIP(r)); err != nil {
+				if errors.Is(err, services.ErrURLExpired) {
+					models.RespondError(w, "Link has expired", http.StatusForbidden)
+				} else {
+					models.RespondError(w, "Invalid or missing signature", http.StatusForbidden)
+				}
+				return
+			}
+
+			if strings.HasSuffix(p, ".m3u8") {
+				servePlaylist(w, r, next)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// servePlaylist buffers a playlist response and appends the request's query
+// string to every URI in it
+func servePlaylist(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	next.ServeHTTP(buf, r)
+
+	body := buf.body.Bytes()
+	if buf.status == http.StatusOK {
+		body = signPlaylist(body, r.URL.RawQuery)
+		buf.header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	for k, v := range buf.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(buf.status)
+	w.Write(body)
+}
+
+func signPlaylist(playlist []byte, query string) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(playlist), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "#"):
+			line = playlistURIAttr.ReplaceAllStringFunc(line, func(attr string) string {
+				return strings.TrimSuffix(attr, `"`) + "?" + query + `"`
+			})
+		case !strings.Contains(trimmed, "?"):
+			line = trimmed + "?" + query
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n"))
+}
+
+// bufferedResponse captures a handler's response so it can be modified
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
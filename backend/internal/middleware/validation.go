@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
 )
 
 var (
@@ -41,7 +44,7 @@ func SecurityValidationMiddleware() func(http.Handler) http.Handler {
 				for _, value := range values {
 					if isSuspicious(value) {
 						log.Printf("[Validation] SECURITY: Suspicious input detected in query param '%s': %s from IP: %s",
-							key, value, getIPAddress(r))
+							key, value, utils.ClientIP(r))
 						models.RespondError(w, "Invalid input detected", http.StatusBadRequest)
 						return
 					}
@@ -51,7 +54,7 @@ func SecurityValidationMiddleware() func(http.Handler) http.Handler {
 			// Check URL path for traversal attempts
 			if containsPathTraversal(r.URL.Path) {
 				log.Printf("[Validation] SECURITY: Path traversal attempt detected: %s from IP: %s",
-					r.URL.Path, getIPAddress(r))
+					r.URL.Path, utils.ClientIP(r))
 				models.RespondError(w, "Invalid path", http.StatusBadRequest)
 				return
 			}
@@ -179,6 +182,71 @@ func ValidateVideoTitle(title string) (bool, string) {
 	return true, ""
 }
 
+// ValidateComment checks a comment body. Only the XSS patterns apply: the SQL
+// patterns would reject ordinary prose ("select", ";") and comments are
+// always stored through bound parameters.
+func ValidateComment(body string) (bool, string) {
+	body = SanitizeString(body)
+
+	if len(body) < 1 {
+		return false, "Comment cannot be empty"
+	}
+
+	if utf8.RuneCountInString(body) > 2000 {
+		return false, "Comment must not exceed 2000 characters"
+	}
+
+	for _, pattern := range xssPatterns {
+		if pattern.MatchString(body) {
+			return false, "Comment contains invalid content"
+		}
+	}
+
+	return true, ""
+}
+
+// ValidateDescription checks optional free text such as a playlist
+// description, with the same rules as comments
+func ValidateDescription(description string) (bool, string) {
+	description = SanitizeString(description)
+
+	if utf8.RuneCountInString(description) > 5000 {
+		return false, "Description must not exceed 5000 characters"
+	}
+
+	for _, pattern := range xssPatterns {
+		if pattern.MatchString(description) {
+			return false, "Description contains invalid content"
+		}
+	}
+
+	return true, ""
+}
+
+// ValidateDisplayName checks a name shown next to user content, such as an
+// anonymous commenter's name
+func ValidateDisplayName(name string) bool {
+	name = SanitizeString(name)
+
+	if len(name) < 1 || utf8.RuneCountInString(name) > 50 {
+		return false
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+
+	for _, pattern := range xssPatterns {
+		if pattern.MatchString(name) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ValidateCategory checks if category name is valid
 func ValidateCategory(category string) bool {
 	category = SanitizeString(category)
@@ -191,3 +259,63 @@ func ValidateCategory(category string) bool {
 	matched, _ := regexp.MatchString(`^[a-zA-Z0-9 -]+$`, category)
 	return matched
 }
+
+// ValidateTag checks a video tag name. Tags are matched by their slug, so
+// the name only needs to be short, printable and contain a letter or digit.
+func ValidateTag(name string) (bool, string) {
+	name = SanitizeString(name)
+
+	if utf8.RuneCountInString(name) > 50 {
+		return false, "Tags must not exceed 50 characters"
+	}
+
+	hasWord := false
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return false, "Tags cannot contain control characters"
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			hasWord = true
+		}
+	}
+	if !hasWord {
+		return false, "Tags must contain a letter or digit"
+	}
+
+	for _, pattern := range xssPatterns {
+		if pattern.MatchString(name) {
+			return false, "Tag contains invalid content"
+		}
+	}
+
+	return true, ""
+}
+
+// ValidateCreatorName checks the creator name given with a video. Like tags,
+// creators are matched by slug, so the name must have one.
+func ValidateCreatorName(name string) (bool, string) {
+	name = SanitizeString(name)
+
+	if models.Slugify(name) == "" {
+		return false, "Creator names must contain a letter or digit"
+	}
+
+	if utf8.RuneCountInString(name) > 100 {
+		return false, "Creator names must not exceed 100 characters"
+	}
+
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return false, "Creator names cannot contain control characters"
+	}
+
+	return true, ""
+}
+
+// languageTagRegex matches BCP 47 language tags as used in practice: a 2-3
+// letter language optionally followed by script, region or variant subtags
+var languageTagRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// ValidateLanguageTag checks a language tag such as "en", "pt-BR" or "zh-Hant"
+func ValidateLanguageTag(tag string) bool {
+	return len(tag) <= 35 && languageTagRegex.MatchString(tag)
+}
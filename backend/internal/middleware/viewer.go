@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"titan-backend/internal/services"
+)
+
+// DeviceIDHeader carries the anonymous device ID the frontend generates and
+// keeps in local storage
+const DeviceIDHeader = "X-Device-ID"
+
+var validDeviceID = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// OptionalAuth attaches the JWT claims to the request when a valid bearer
+// token is present, but lets anonymous requests through
+func OptionalAuth(authService *services.AuthService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if tokenString != "" {
+				if claims, err := authService.ValidateToken(tokenString); err == nil {
+					r = r.WithContext(context.WithValue(r.Context(), UserContextKey, claims))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ViewerID identifies who is making a public request: "user:<id>" for signed
+// in users, otherwise "device:<id>" from the X-Device-ID header. Returns false
+// when neither is available.
+func ViewerID(r *http.Request) (string, bool) {
+	if claims := GetUserFromContext(r); claims != nil {
+		return "user:" + strconv.Itoa(claims.UserID), true
+	}
+	if deviceID := r.Header.Get(DeviceIDHeader); validDeviceID.MatchString(deviceID) {
+		return "device:" + deviceID, true
+	}
+	return "", false
+}
+
+// IsAdmin reports whether the request carries an admin's JWT claims
+func IsAdmin(r *http.Request) bool {
+	claims := GetUserFromContext(r)
+	return claims != nil && claims.Role == "admin"
+}
@@ -0,0 +1,384 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"titan-backend/internal/captions"
+)
+
+// Caption kinds, as in the kind attribute of an HTML <track> element
+const (
+	CaptionKindSubtitles    = "subtitles"    // The dialogue, usually translated
+	CaptionKindCaptions     = "captions"     // Dialogue and sound effects, for viewers who can't hear them
+	CaptionKindDescriptions = "descriptions" // What happens on screen, for viewers who can't see it
+)
+
+// CaptionKinds lists the valid kinds
+var CaptionKinds = []string{CaptionKindSubtitles, CaptionKindCaptions, CaptionKindDescriptions}
+
+// ErrCaptionExists is returned when a video already has a track with the same language and kind
+var ErrCaptionExists = errors.New("the video already has a caption track for this language and kind")
+
+const (
+	// captionSearchLimit caps the cues returned when searching inside a video
+	captionSearchLimit = 50
+	// maxSearchCaptionsLength caps the caption text copied onto a video for
+	// full-text search, in bytes; a feature film's subtitles are well under it
+	maxSearchCaptionsLength = 256 * 1024
+)
+
+// Caption is a subtitle or caption track of a video, stored as WebVTT
+type Caption struct {
+	ID        int       `json:"id"`
+	VideoID   int       `json:"videoId"`
+	Language  string    `json:"language"` // BCP 47 tag such as "en" or "pt-BR"
+	Label     string    `json:"label"`    // Shown in the player's track menu
+	Kind      string    `json:"kind"`
+	IsDefault bool      `json:"default"`
+	URL       string    `json:"url"`
+	CueCount  int       `json:"cueCount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CaptionMatch is a cue matching a search, for jumping to that point of the video
+type CaptionMatch struct {
+	CaptionID int     `json:"captionId"`
+	Language  string  `json:"language"`
+	Start     float64 `json:"start"` // seconds
+	End       float64 `json:"end"`
+	Text      string  `json:"text"` // HTML-escaped, matches wrapped in <mark>
+}
+
+const captionColumns = "id, video_id, language, label, kind, is_default, url, cue_count, created_at"
+
+func scanCaption(row rowScanner, c *Caption) error {
+	var isDefault int
+	if err := row.Scan(&c.ID, &c.VideoID, &c.Language, &c.Label, &c.Kind, &isDefault, &c.URL, &c.CueCount, &c.CreatedAt); err != nil {
+		return err
+	}
+	c.IsDefault = isDefault == 1
+	return nil
+}
+
+type CaptionRepository struct {
+	db *sql.DB
+}
+
+func NewCaptionRepository(db *sql.DB) *CaptionRepository {
+	return &CaptionRepository{db: db}
+}
+
+// ListForVideo returns a video's tracks, the default one first
+func (r *CaptionRepository) ListForVideo(videoID int) ([]Caption, error) {
+	rows, err := r.db.Query(
+		"SELECT "+captionColumns+" FROM video_captions WHERE video_id = ? ORDER BY is_default DESC, language ASC, kind ASC",
+		videoID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Caption{}
+	for rows.Next() {
+		var c Caption
+		if err := scanCaption(rows, &c); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (r *CaptionRepository) GetByID(id int) (*Caption, error) {
+	c := &Caption{}
+	err := scanCaption(r.db.QueryRow("SELECT "+captionColumns+" FROM video_captions WHERE id = ?", id), c)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Create stores a track with its cues and makes its text searchable. A new
+// default track replaces the video's previous default.
+func (r *CaptionRepository) Create(c *Caption, cues []captions.Cue) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCaptionUnique(tx, c); err != nil {
+		return err
+	}
+	if err := clearDefaultCaption(tx, c); err != nil {
+		return err
+	}
+
+	isDefault := 0
+	if c.IsDefault {
+		isDefault = 1
+	}
+	c.CueCount = len(cues)
+	result, err := tx.Exec(
+		`INSERT INTO video_captions (video_id, language, label, kind, is_default, url, cue_count)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.VideoID, c.Language, c.Label, c.Kind, isDefault, c.URL, c.CueCount,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	c.CreatedAt = time.Now()
+
+	stmt, err := tx.Prepare("INSERT INTO video_caption_cues (caption_id, video_id, start_ms, end_ms, text) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, cue := range cues {
+		text := captions.PlainText(cue.Text)
+		if text == "" {
+			continue
+		}
+		if _, err := stmt.Exec(c.ID, c.VideoID, cue.Start.Milliseconds(), cue.End.Milliseconds(), text); err != nil {
+			return err
+		}
+	}
+
+	if err := refreshSearchCaptions(tx, c.VideoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update saves a track's language, label, kind and default flag
+func (r *CaptionRepository) Update(c *Caption) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCaptionUnique(tx, c); err != nil {
+		return err
+	}
+	if err := clearDefaultCaption(tx, c); err != nil {
+		return err
+	}
+	isDefault := 0
+	if c.IsDefault {
+		isDefault = 1
+	}
+	if _, err := tx.Exec(
+		"UPDATE video_captions SET language = ?, label = ?, kind = ?, is_default = ? WHERE id = ?",
+		c.Language, c.Label, c.Kind, isDefault, c.ID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a track and its cues from the video's searchable text. The
+// WebVTT file is left for the caller to delete.
+func (r *CaptionRepository) Delete(c *Caption) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM video_caption_cues WHERE caption_id = ?", c.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM video_captions WHERE id = ?", c.ID); err != nil {
+		return err
+	}
+	if err := refreshSearchCaptions(tx, c.VideoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SearchCues returns the cues of a video containing any word of query, in
+// playback order
+func (r *CaptionRepository) SearchCues(videoID int, query string) ([]CaptionMatch, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []CaptionMatch{}, nil
+	}
+
+	cues, err := matchingCues(r.db, []int{videoID}, terms)
+	if err != nil {
+		return nil, err
+	}
+	matches := []CaptionMatch{}
+	for _, cue := range cues {
+		if len(matches) == captionSearchLimit {
+			break
+		}
+		matches = append(matches, cue.match(terms))
+	}
+	return matches, nil
+}
+
+func checkCaptionUnique(tx *sql.Tx, c *Caption) error {
+	var count int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM video_captions WHERE video_id = ? AND language = ? AND kind = ? AND id != ?",
+		c.VideoID, c.Language, c.Kind, c.ID,
+	).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCaptionExists
+	}
+	return nil
+}
+
+// clearDefaultCaption unsets the video's other default track when c becomes the default
+func clearDefaultCaption(tx *sql.Tx, c *Caption) error {
+	if !c.IsDefault {
+		return nil
+	}
+	_, err := tx.Exec("UPDATE video_captions SET is_default = 0 WHERE video_id = ? AND id != ?", c.VideoID, c.ID)
+	return err
+}
+
+// refreshSearchCaptions copies the video's cue text onto videos.search_captions,
+// which the full-text index covers
+func refreshSearchCaptions(tx *sql.Tx, videoID int) error {
+	rows, err := tx.Query("SELECT text FROM video_caption_cues WHERE video_id = ? ORDER BY caption_id, start_ms", videoID)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			rows.Close()
+			return err
+		}
+		if b.Len()+len(text) >= maxSearchCaptionsLength {
+			break
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE videos SET search_captions = ? WHERE id = ?", b.String(), videoID)
+	return err
+}
+
+// cueRow is a stored cue with the language of its track
+type cueRow struct {
+	videoID   int
+	captionID int
+	language  string
+	startMs   int64
+	endMs     int64
+	text      string
+}
+
+func (c cueRow) match(terms []string) CaptionMatch {
+	return CaptionMatch{
+		CaptionID: c.captionID,
+		Language:  c.language,
+		Start:     float64(c.startMs) / 1000,
+		End:       float64(c.endMs) / 1000,
+		Text:      renderHighlight(markTerms(c.text, terms)),
+	}
+}
+
+// matchingCues loads the cues of the videos containing any of the terms, in
+// playback order, default tracks first at equal times
+func matchingCues(db *sql.DB, videoIDs []int, terms []string) ([]cueRow, error) {
+	args := make([]interface{}, 0, len(videoIDs)+len(terms))
+	for _, id := range videoIDs {
+		args = append(args, id)
+	}
+	conds := make([]string, len(terms))
+	for i, term := range terms {
+		conds[i] = "LOWER(c.text) LIKE ?"
+		args = append(args, "%"+term+"%")
+	}
+
+	rows, err := db.Query(
+		`SELECT c.video_id, c.caption_id, vc.language, c.start_ms, c.end_ms, c.text
+		 FROM video_caption_cues c JOIN video_captions vc ON vc.id = c.caption_id
+		 WHERE c.video_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(videoIDs)), ",")+`)
+		 AND (`+strings.Join(conds, " OR ")+`)
+		 ORDER BY c.video_id, c.start_ms, vc.is_default DESC, c.caption_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cues []cueRow
+	for rows.Next() {
+		var c cueRow
+		if err := rows.Scan(&c.videoID, &c.captionID, &c.language, &c.startMs, &c.endMs, &c.text); err != nil {
+			return nil, err
+		}
+		cues = append(cues, c)
+	}
+	return cues, rows.Err()
+}
+
+// attachCaptionMatches sets each result's CaptionMatch to the cue containing
+// the most search terms, the earliest on ties
+func attachCaptionMatches(db *sql.DB, results []SearchResult, terms []string) error {
+	if len(results) == 0 {
+		return nil
+	}
+	ids := make([]int, len(results))
+	for i, res := range results {
+		ids[i] = res.ID
+	}
+	cues, err := matchingCues(db, ids, terms)
+	if err != nil {
+		return err
+	}
+
+	best := make(map[int]cueRow)
+	bestTerms := make(map[int]int)
+	for _, c := range cues {
+		lower := strings.ToLower(c.text)
+		matched := 0
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				matched++
+			}
+		}
+		if matched > bestTerms[c.videoID] {
+			best[c.videoID] = c
+			bestTerms[c.videoID] = matched
+		}
+	}
+
+	for i := range results {
+		if c, ok := best[results[i].ID]; ok {
+			match := c.match(terms)
+			results[i].CaptionMatch = &match
+		}
+	}
+	return nil
+}
@@ -25,10 +25,10 @@ func (r *CategoryRepository) GetAll() ([]Category, error) {
 	rows, err := r.db.Query(`
 		SELECT c.id, c.name, c.icon, c.created_at, COUNT(v.id) as video_count
 		FROM categories c
-		LEFT JOIN videos v ON c.id = v.category
+		LEFT JOIN videos v ON c.id = v.category AND v.status = ?
 		GROUP BY c.id
 		ORDER BY c.name ASC
-	`)
+	`, VideoStatusPublished)
 	if err != nil {
 		return nil, err
 	}
@@ -52,10 +52,10 @@ func (r *CategoryRepository) GetByID(id string) (*Category, error) {
 	err := r.db.QueryRow(
 		`SELECT c.id, c.name, c.icon, c.created_at, COUNT(v.id) as video_count
 		 FROM categories c
-		 LEFT JOIN videos v ON c.id = v.category
+		 LEFT JOIN videos v ON c.id = v.category AND v.status = ?
 		 WHERE c.id = ?
 		 GROUP BY c.id`,
-		id,
+		VideoStatusPublished, id,
 	).Scan(&c.ID, &c.Name, &c.Icon, &c.CreatedAt, &c.VideoCount)
 
 	if err == sql.ErrNoRows {
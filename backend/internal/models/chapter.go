@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Where a video's chapters come from. Chapters set through the API take
+// precedence; without them they are parsed from the description.
+const (
+	ChapterSourceManual      = "manual"
+	ChapterSourceDescription = "description"
+)
+
+const (
+	// MaxChapters caps the chapters of a video
+	MaxChapters = 100
+	// MaxChapterTitleLength caps chapter titles, in characters
+	MaxChapterTitleLength = 100
+	// minDescriptionChapters is how many timestamp lines a description needs
+	// before they are read as chapters, so a single "at 2:30 you can see"
+	// doesn't split the video
+	minDescriptionChapters = 3
+)
+
+// Chapter is a named section of a video
+type Chapter struct {
+	Start     float64 `json:"start"`         // seconds
+	End       float64 `json:"end,omitempty"` // The next chapter's start, or the video's end if known
+	Title     string  `json:"title"`
+	Thumbnail string  `json:"thumbnail,omitempty"`
+}
+
+// chapterLine matches a description line starting with a timestamp, such as
+// "0:00 Intro", "01:02:03 - Outro" or "[12:30] Q&A"
+var chapterLine = regexp.MustCompile(`^[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?\s*(?:[-–—:|]\s*)?(\S.*)$`)
+
+// ParseChapters reads chapters from "MM:SS Title" lines in a description,
+// following the rules viewers know from other platforms: at least three
+// timestamps, the first at 0:00, each later than the one before. Anything
+// else means the timestamps aren't chapters and nil is returned.
+func ParseChapters(description string) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		m := chapterLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		start, ok := parseChapterTime(m[1])
+		if !ok {
+			return nil
+		}
+		if len(chapters) == 0 && start != 0 {
+			return nil
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].Start {
+			return nil
+		}
+		chapters = append(chapters, Chapter{Start: start, Title: truncateRunes(m[2], MaxChapterTitleLength)})
+		if len(chapters) == MaxChapters {
+			break
+		}
+	}
+
+	if len(chapters) < minDescriptionChapters {
+		return nil
+	}
+	return chapters
+}
+
+// parseChapterTime reads M:SS, MM:SS or H:MM:SS into seconds
+func parseChapterTime(text string) (float64, bool) {
+	total := 0
+	for i, part := range strings.Split(text, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || (i > 0 && n > 59) {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	return float64(total), true
+}
+
+func truncateRunes(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > max {
+		return strings.TrimSpace(string(r[:max]))
+	}
+	return s
+}
+
+// setChapterEnds sets each chapter's end to the next chapter's start, and the
+// last one's to the video's duration when it is known
+func setChapterEnds(chapters []Chapter, duration float64) {
+	for i := range chapters {
+		switch {
+		case i+1 < len(chapters):
+			chapters[i].End = chapters[i+1].Start
+		case duration > chapters[i].Start:
+			chapters[i].End = duration
+		default:
+			chapters[i].End = 0
+		}
+	}
+}
+
+type ChapterRepository struct {
+	db *sql.DB
+}
+
+func NewChapterRepository(db *sql.DB) *ChapterRepository {
+	return &ChapterRepository{db: db}
+}
+
+// ForVideo returns the video's chapters set through the API, or else those
+// in its description, and which of the two they are. A video without
+// chapters gets an empty list and no source.
+func (r *ChapterRepository) ForVideo(v *Video) ([]Chapter, string, error) {
+	rows, err := r.db.Query(
+		"SELECT start_seconds, title, thumbnail FROM video_chapters WHERE video_id = ? ORDER BY start_seconds ASC",
+		v.ID,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	chapters := []Chapter{}
+	for rows.Next() {
+		var c Chapter
+		if err := rows.Scan(&c.Start, &c.Title, &c.Thumbnail); err != nil {
+			return nil, "", err
+		}
+		chapters = append(chapters, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	source := ""
+	if len(chapters) > 0 {
+		source = ChapterSourceManual
+	} else if parsed := ParseChapters(v.Description); parsed != nil {
+		chapters, source = parsed, ChapterSourceDescription
+	}
+	setChapterEnds(chapters, v.DurationSeconds)
+	return chapters, source, nil
+}
+
+// Set replaces the chapters of a video. An empty list removes them, so the
+// description's chapters, if any, apply again.
+func (r *ChapterRepository) Set(videoID int, chapters []Chapter) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM video_chapters WHERE video_id = ?", videoID); err != nil {
+		return err
+	}
+	for _, c := range chapters {
+		if _, err := tx.Exec(
+			"INSERT INTO video_chapters (video_id, start_seconds, title, thumbnail) VALUES (?, ?, ?, ?)",
+			videoID, c.Start, c.Title, c.Thumbnail,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChapters(t *testing.T) {
+	description := "My trip to the mountains!\n\n" +
+		"Chapters:\n" +
+		"0:00 Intro\n" +
+		"  [01:30] - Packing & prep\n" +
+		"12:05: The climb\n" +
+		"1:02:03 | Summit\n" +
+		"Thanks for watching"
+
+	assert.Equal(t, []Chapter{
+		{Start: 0, Title: "Intro"},
+		{Start: 90, Title: "Packing & prep"},
+		{Start: 725, Title: "The climb"},
+		{Start: 3723, Title: "Summit"},
+	}, ParseChapters(description))
+
+	tests := map[string]string{
+		"too few":         "0:00 Intro\n1:00 End",
+		"no zero start":   "0:10 Intro\n1:00 Middle\n2:00 End",
+		"out of order":    "0:00 Intro\n2:00 Middle\n1:00 End",
+		"invalid seconds": "0:00 Intro\n1:75 Middle\n2:00 End",
+		"no titles":       "0:00\n1:00\n2:00",
+	}
+	for name, text := range tests {
+		assert.Nil(t, ParseChapters(text), name)
+	}
+}
+
+func TestSetChapterEnds(t *testing.T) {
+	chapters := []Chapter{{Start: 0}, {Start: 60}, {Start: 120}}
+	setChapterEnds(chapters, 300)
+	assert.Equal(t, []float64{60, 120, 300}, []float64{chapters[0].End, chapters[1].End, chapters[2].End})
+
+	// Unknown duration leaves the last chapter open
+	setChapterEnds(chapters, 0)
+	assert.Equal(t, 0.0, chapters[2].End)
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Comment moderation statuses
+const (
+	CommentStatusPending  = "pending" // Held for review while pre-moderation is on
+	CommentStatusApproved = "approved"
+	CommentStatusRejected = "rejected"
+)
+
+// Comment is a viewer comment on a video. Replies point at their parent via
+// ParentID; top-level comments have none.
+type Comment struct {
+	ID         int        `json:"id"`
+	VideoID    int        `json:"videoId"`
+	ParentID   *int       `json:"parentId,omitempty"`
+	AuthorID   string     `json:"-"` // Viewer ID (user:<id> or device:<id>), never exposed
+	AuthorName string     `json:"authorName"`
+	Body       string     `json:"body"`
+	Status     string     `json:"status"`
+	Pinned     bool       `json:"pinned"`
+	Deleted    bool       `json:"deleted"`
+	ReplyCount int        `json:"replyCount"`
+	EditedAt   *time.Time `json:"editedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// CommentEdit is a previous version of an edited comment. CreatedAt is when
+// that version was written, not when it was replaced.
+type CommentEdit struct {
+	ID        int       `json:"id"`
+	CommentID int       `json:"commentId"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CommentRepository struct {
+	db *sql.DB
+}
+
+func NewCommentRepository(db *sql.DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+// Approved replies, counted for every listed comment
+const commentReplyCount = `(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND r.status = 'approved')`
+
+const commentColumns = `c.id, c.video_id, c.parent_id, c.author_id, c.author_name, c.body, c.status,
+	c.pinned, c.deleted_at, c.edited_at, c.created_at, c.updated_at, ` + commentReplyCount
+
+func scanComment(row rowScanner, c *Comment) error {
+	var parentID sql.NullInt64
+	var pinned int
+	var deletedAt, editedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.VideoID, &parentID, &c.AuthorID, &c.AuthorName, &c.Body, &c.Status,
+		&pinned, &deletedAt, &editedAt, &c.CreatedAt, &c.UpdatedAt, &c.ReplyCount)
+	if err != nil {
+		return err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	c.Pinned = pinned == 1
+	if editedAt.Valid {
+		c.EditedAt = &editedAt.Time
+	}
+
+	// Soft-deleted comments stay in threads as placeholders without content
+	if deletedAt.Valid {
+		c.Deleted = true
+		c.Body = ""
+		c.AuthorName = ""
+		c.EditedAt = nil
+	}
+	return nil
+}
+
+func (r *CommentRepository) queryComments(query string, args ...interface{}) ([]Comment, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := scanComment(rows, &c); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+func (r *CommentRepository) GetByID(id int) (*Comment, error) {
+	c := &Comment{}
+	err := scanComment(r.db.QueryRow("SELECT "+commentColumns+" FROM comments c WHERE c.id = ?", id), c)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *CommentRepository) Create(c *Comment) error {
+	result, err := r.db.Exec(
+		`INSERT INTO comments (video_id, parent_id, author_id, author_name, body, status)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		c.VideoID, c.ParentID, c.AuthorID, c.AuthorName, c.Body, c.Status,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+	return nil
+}
+
+// visibleComment selects approved comments, hiding deleted ones unless they
+// still have live replies to hold together
+const visibleComment = ` c.status = 'approved' AND (c.deleted_at IS NULL OR EXISTS (
+	SELECT 1 FROM comments r WHERE r.parent_id = c.id AND r.status = 'approved' AND r.deleted_at IS NULL))`
+
+// ListByVideo returns a page of a video's visible top-level comments, pinned first
+func (r *CommentRepository) ListByVideo(videoID int, oldestFirst bool, page, limit int) ([]Comment, int, error) {
+	offset := (page - 1) * limit
+	where := " WHERE c.video_id = ? AND c.parent_id IS NULL AND" + visibleComment
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM comments c"+where, videoID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := "c.pinned DESC, c.created_at DESC, c.id DESC"
+	if oldestFirst {
+		order = "c.pinned DESC, c.created_at ASC, c.id ASC"
+	}
+	comments, err := r.queryComments(
+		"SELECT "+commentColumns+" FROM comments c"+where+" ORDER BY "+order+" LIMIT ? OFFSET ?",
+		videoID, limit, offset,
+	)
+	return comments, total, err
+}
+
+// ListReplies returns a page of visible replies to a comment, oldest first
+func (r *CommentRepository) ListReplies(parentID, page, limit int) ([]Comment, int, error) {
+	offset := (page - 1) * limit
+	where := " WHERE c.parent_id = ? AND" + visibleComment
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM comments c"+where, parentID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	comments, err := r.queryComments(
+		"SELECT "+commentColumns+" FROM comments c"+where+" ORDER BY c.created_at ASC, c.id ASC LIMIT ? OFFSET ?",
+		parentID, limit, offset,
+	)
+	return comments, total, err
+}
+
+// ListByStatus returns comments in a moderation state across all videos, oldest first
+func (r *CommentRepository) ListByStatus(status string, page, limit int) ([]Comment, int, error) {
+	offset := (page - 1) * limit
+	where := " WHERE c.status = ? AND c.deleted_at IS NULL"
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM comments c"+where, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	comments, err := r.queryComments(
+		"SELECT "+commentColumns+" FROM comments c"+where+" ORDER BY c.created_at ASC, c.id ASC LIMIT ? OFFSET ?",
+		status, limit, offset,
+	)
+	return comments, total, err
+}
+
+// Edit replaces a comment's body, keeping the previous text in comment_edits.
+// status is the moderation state the edited comment ends up in.
+func (r *CommentRepository) Edit(id int, body, status string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO comment_edits (comment_id, body, created_at)
+		 SELECT id, body, COALESCE(edited_at, created_at) FROM comments WHERE id = ?`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE comments SET body = ?, status = ?, edited_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		body, status, id,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History returns a comment's previous versions, oldest first
+func (r *CommentRepository) History(id int) ([]CommentEdit, error) {
+	rows, err := r.db.Query(
+		"SELECT id, comment_id, body, created_at FROM comment_edits WHERE comment_id = ? ORDER BY id ASC",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edits := []CommentEdit{}
+	for rows.Next() {
+		var e CommentEdit
+		if err := rows.Scan(&e.ID, &e.CommentID, &e.Body, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, e)
+	}
+	return edits, rows.Err()
+}
+
+// SetStatus moves a comment through moderation
+func (r *CommentRepository) SetStatus(id int, status string) error {
+	_, err := r.db.Exec(
+		"UPDATE comments SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		status, id,
+	)
+	return err
+}
+
+func (r *CommentRepository) SetPinned(id int, pinned bool) error {
+	pinnedInt := 0
+	if pinned {
+		pinnedInt = 1
+	}
+	_, err := r.db.Exec(
+		"UPDATE comments SET pinned = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		pinnedInt, id,
+	)
+	return err
+}
+
+// SoftDelete hides a comment's content but keeps the row so replies stay threaded
+func (r *CommentRepository) SoftDelete(id int) error {
+	_, err := r.db.Exec(
+		`UPDATE comments SET deleted_at = CURRENT_TIMESTAMP, pinned = 0, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND deleted_at IS NULL`,
+		id,
+	)
+	return err
+}
@@ -0,0 +1,169 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func newTestComments(t *testing.T) (*models.CommentRepository, int) {
+	t.Helper()
+
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db), &models.Video{Title: "Clip", Creator: "Ann"})
+	return models.NewCommentRepository(db), ids[0]
+}
+
+func addComment(t *testing.T, repo *models.CommentRepository, videoID int, parent *models.Comment, body, status string) *models.Comment {
+	t.Helper()
+
+	c := &models.Comment{VideoID: videoID, AuthorID: "device:a", AuthorName: "Ann", Body: body, Status: status}
+	if parent != nil {
+		c.ParentID = &parent.ID
+	}
+	require.NoError(t, repo.Create(c))
+	return c
+}
+
+func commentIDs(comments []models.Comment) []int {
+	ids := []int{}
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestComment_Threads(t *testing.T) {
+	repo, videoID := newTestComments(t)
+	top := addComment(t, repo, videoID, nil, "Top", models.CommentStatusApproved)
+	reply := addComment(t, repo, videoID, top, "Reply", models.CommentStatusApproved)
+	nested := addComment(t, repo, videoID, reply, "Nested", models.CommentStatusApproved)
+	deepest := addComment(t, repo, videoID, nested, "Deepest", models.CommentStatusApproved)
+	held := addComment(t, repo, videoID, top, "Held", models.CommentStatusPending)
+
+	// Each level lists only its direct, approved replies
+	comments, total, err := repo.ListByVideo(videoID, false, 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []int{top.ID}, commentIDs(comments))
+	assert.Equal(t, 1, comments[0].ReplyCount)
+
+	for _, level := range []struct {
+		parent *models.Comment
+		want   []int
+	}{
+		{top, []int{reply.ID}},
+		{reply, []int{nested.ID}},
+		{nested, []int{deepest.ID}},
+		{deepest, []int{}},
+	} {
+		replies, total, err := repo.ListReplies(level.parent.ID, 1, 20)
+		require.NoError(t, err)
+		assert.Equal(t, len(level.want), total, level.parent.Body)
+		assert.Equal(t, level.want, commentIDs(replies), level.parent.Body)
+	}
+
+	loaded, err := repo.GetByID(held.ID)
+	require.NoError(t, err)
+	require.NotNil(t, loaded.ParentID)
+	assert.Equal(t, top.ID, *loaded.ParentID)
+
+	// A deleted comment stays as a placeholder while it has live replies
+	require.NoError(t, repo.SoftDelete(nested.ID))
+	replies, _, err := repo.ListReplies(reply.ID, 1, 20)
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+	assert.True(t, replies[0].Deleted)
+	assert.Empty(t, replies[0].Body)
+	assert.Empty(t, replies[0].AuthorName)
+
+	require.NoError(t, repo.SoftDelete(deepest.ID))
+	replies, total, err = repo.ListReplies(reply.ID, 1, 20)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, replies)
+}
+
+func TestComment_EditHistory(t *testing.T) {
+	repo, videoID := newTestComments(t)
+	c := addComment(t, repo, videoID, nil, "First", models.CommentStatusApproved)
+
+	history, err := repo.History(c.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	require.NoError(t, repo.Edit(c.ID, "Second", models.CommentStatusApproved))
+	require.NoError(t, repo.Edit(c.ID, "Third", models.CommentStatusApproved))
+
+	edited, err := repo.GetByID(c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Third", edited.Body)
+	assert.NotNil(t, edited.EditedAt)
+
+	// Previous versions, oldest first
+	history, err = repo.History(c.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "First", history[0].Body)
+	assert.Equal(t, "Second", history[1].Body)
+	assert.Equal(t, c.ID, history[1].CommentID)
+
+	// Under pre-moderation an edit sends the comment back to the queue
+	require.NoError(t, repo.Edit(c.ID, "Fourth", models.CommentStatusPending))
+	comments, _, err := repo.ListByVideo(videoID, false, 1, 20)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+	queue, _, err := repo.ListByStatus(models.CommentStatusPending, 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []int{c.ID}, commentIDs(queue))
+}
+
+func TestComment_Moderation(t *testing.T) {
+	repo, videoID := newTestComments(t)
+	first := addComment(t, repo, videoID, nil, "First", models.CommentStatusPending)
+	second := addComment(t, repo, videoID, nil, "Second", models.CommentStatusPending)
+
+	queue := func(status string) []int {
+		t.Helper()
+		comments, total, err := repo.ListByStatus(status, 1, 20)
+		require.NoError(t, err)
+		assert.Equal(t, len(comments), total)
+		return commentIDs(comments)
+	}
+	listed := func() []int {
+		t.Helper()
+		comments, _, err := repo.ListByVideo(videoID, true, 1, 20)
+		require.NoError(t, err)
+		return commentIDs(comments)
+	}
+
+	// Held comments wait in the queue, oldest first, until approved
+	assert.Equal(t, []int{first.ID, second.ID}, queue(models.CommentStatusPending))
+	assert.Empty(t, listed())
+
+	require.NoError(t, repo.SetStatus(first.ID, models.CommentStatusApproved))
+	require.NoError(t, repo.SetStatus(second.ID, models.CommentStatusApproved))
+	assert.Empty(t, queue(models.CommentStatusPending))
+	assert.Equal(t, []int{first.ID, second.ID}, listed())
+
+	// Pinned comments come first
+	require.NoError(t, repo.SetPinned(second.ID, true))
+	assert.Equal(t, []int{second.ID, first.ID}, listed())
+
+	// Rejecting hides a comment without deleting it
+	require.NoError(t, repo.SetStatus(first.ID, models.CommentStatusRejected))
+	assert.Equal(t, []int{second.ID}, listed())
+	assert.Equal(t, []int{first.ID}, queue(models.CommentStatusRejected))
+
+	// Deleting unpins and drops the comment from every queue
+	require.NoError(t, repo.SoftDelete(second.ID))
+	deleted, err := repo.GetByID(second.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.Deleted)
+	assert.False(t, deleted.Pinned)
+	assert.Empty(t, listed())
+	assert.Empty(t, queue(models.CommentStatusApproved))
+}
@@ -0,0 +1,290 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Limits on creator profiles
+const (
+	MaxCreatorLinks     = 10
+	MaxCreatorBioLength = 2000
+)
+
+var (
+	// ErrCreatorExists is returned when renaming a creator to a name another creator's slug already has
+	ErrCreatorExists = errors.New("another creator already has this name")
+	// ErrInvalidCreator is returned for creator names without a letter or digit, which have no slug
+	ErrInvalidCreator = errors.New("creator names must contain a letter or digit")
+)
+
+// Creator is who made a video. Like tags, creators are identified by the slug
+// of their name, so "John Doe" and "john doe" are the same creator; the name
+// is kept as first written. The name is also copied onto each of their
+// videos, where the search index and creator facet read it.
+type Creator struct {
+	ID        int           `json:"id"`
+	Slug      string        `json:"slug"`
+	Name      string        `json:"name"`
+	Avatar    string        `json:"avatar,omitempty"`
+	Bio       string        `json:"bio,omitempty"`
+	Verified  bool          `json:"verified"`
+	Links     []CreatorLink `json:"links"`
+	Stats     *CreatorStats `json:"stats,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// CreatorLink is a link shown on a creator's profile, such as their website
+type CreatorLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// CreatorStats sums up a creator's published videos
+type CreatorStats struct {
+	VideoCount      int        `json:"videoCount"`
+	TotalViews      int        `json:"totalViews"`
+	TotalLikes      int        `json:"totalLikes"`
+	DurationSeconds float64    `json:"durationSeconds"` // Combined length of the videos
+	LatestUploadAt  *time.Time `json:"latestUploadAt,omitempty"`
+}
+
+const creatorColumns = "c.id, c.slug, c.name, COALESCE(c.avatar, ''), COALESCE(c.bio, ''), c.verified, COALESCE(c.links, ''), c.created_at, c.updated_at"
+
+func creatorDest(c *Creator, verified *int, links *string) []interface{} {
+	return []interface{}{&c.ID, &c.Slug, &c.Name, &c.Avatar, &c.Bio, verified, links, &c.CreatedAt, &c.UpdatedAt}
+}
+
+// apply converts the scanned verified flag and JSON links onto c
+func (c *Creator) apply(verified int, links string) {
+	c.Verified = verified == 1
+	c.Links = []CreatorLink{}
+	if links != "" {
+		// Links are only written by Update, so a decoding error means an
+		// edited database; show no links rather than fail the profile
+		json.Unmarshal([]byte(links), &c.Links)
+	}
+}
+
+type CreatorRepository struct {
+	db *sql.DB
+}
+
+func NewCreatorRepository(db *sql.DB) *CreatorRepository {
+	return &CreatorRepository{db: db}
+}
+
+// List returns a page of creators with published videos, most viewed first,
+// with their stats. A prefix narrows it to creators whose slug starts with
+// it, for autocomplete.
+func (r *CreatorRepository) List(prefix string, limit, offset int) ([]Creator, int, error) {
+	where := ""
+	args := []interface{}{VideoStatusPublished}
+	if slug := Slugify(prefix); slug != "" {
+		where = " WHERE c.slug LIKE ?"
+		args = append(args, slug+"%")
+	}
+
+	var total int
+	if err := r.db.QueryRow(
+		`SELECT COUNT(DISTINCT c.id) FROM creators c
+		 JOIN videos v ON v.creator_id = c.id AND v.status = ?`+where,
+		args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		`SELECT `+creatorColumns+`, COUNT(*), COALESCE(SUM(v.views), 0), COALESCE(SUM(v.likes), 0),
+		        COALESCE(SUM(v.duration_seconds), 0)
+		 FROM creators c JOIN videos v ON v.creator_id = c.id AND v.status = ?`+where+`
+		 GROUP BY c.id, c.slug, c.name, c.avatar, c.bio, c.verified, c.links, c.created_at, c.updated_at
+		 ORDER BY COALESCE(SUM(v.views), 0) DESC, c.slug ASC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	creators := []Creator{}
+	for rows.Next() {
+		var c Creator
+		var verified int
+		var links string
+		stats := &CreatorStats{}
+		dest := append(creatorDest(&c, &verified, &links),
+			&stats.VideoCount, &stats.TotalViews, &stats.TotalLikes, &stats.DurationSeconds)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, err
+		}
+		c.apply(verified, links)
+		c.Stats = stats
+		creators = append(creators, c)
+	}
+	return creators, total, rows.Err()
+}
+
+// ListByID returns a page of creators with published videos, oldest first.
+// Unlike List's order, this one doesn't shift as videos are watched, and new
+// creators only change the last page, so listings paged over every creator,
+// such as sitemaps, stay consistent between pages.
+func (r *CreatorRepository) ListByID(limit, offset int) ([]Creator, error) {
+	rows, err := r.db.Query(
+		`SELECT `+creatorColumns+` FROM creators c
+		 WHERE EXISTS (SELECT 1 FROM videos v WHERE v.creator_id = c.id AND v.status = ?)
+		 ORDER BY c.id ASC LIMIT ? OFFSET ?`,
+		VideoStatusPublished, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creators := []Creator{}
+	for rows.Next() {
+		var c Creator
+		var verified int
+		var links string
+		if err := rows.Scan(creatorDest(&c, &verified, &links)...); err != nil {
+			return nil, err
+		}
+		c.apply(verified, links)
+		creators = append(creators, c)
+	}
+	return creators, rows.Err()
+}
+
+// GetBySlug returns a creator, or nil if there is none with the slug
+func (r *CreatorRepository) GetBySlug(slug string) (*Creator, error) {
+	c := &Creator{}
+	var verified int
+	var links string
+	err := r.db.QueryRow("SELECT "+creatorColumns+" FROM creators c WHERE c.slug = ?", slug).
+		Scan(creatorDest(c, &verified, &links)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.apply(verified, links)
+	return c, nil
+}
+
+// Stats sums up the creator's published videos
+func (r *CreatorRepository) Stats(creatorID int) (*CreatorStats, error) {
+	stats := &CreatorStats{}
+	if err := r.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(views), 0), COALESCE(SUM(likes), 0), COALESCE(SUM(duration_seconds), 0)
+		 FROM videos WHERE creator_id = ? AND status = ?`,
+		creatorID, VideoStatusPublished,
+	).Scan(&stats.VideoCount, &stats.TotalViews, &stats.TotalLikes, &stats.DurationSeconds); err != nil {
+		return nil, err
+	}
+
+	// Read separately so the driver parses the column as a time, which it
+	// doesn't for MAX(created_at)
+	var latest time.Time
+	err := r.db.QueryRow(
+		"SELECT created_at FROM videos WHERE creator_id = ? AND status = ? ORDER BY created_at DESC LIMIT 1",
+		creatorID, VideoStatusPublished,
+	).Scan(&latest)
+	if err == nil {
+		stats.LatestUploadAt = &latest
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Update saves a creator's profile. A new name changes the slug and is copied
+// onto the creator's videos.
+func (r *CreatorRepository) Update(c *Creator) error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Slug = Slugify(c.Name)
+	if c.Slug == "" {
+		return ErrInvalidCreator
+	}
+	if c.Links == nil {
+		c.Links = []CreatorLink{}
+	}
+	links, err := json.Marshal(c.Links)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM creators WHERE slug = ? AND id != ?", c.Slug, c.ID).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCreatorExists
+	}
+
+	verified := 0
+	if c.Verified {
+		verified = 1
+	}
+	if _, err := tx.Exec(
+		`UPDATE creators SET slug = ?, name = ?, avatar = ?, bio = ?, verified = ?, links = ?,
+		 updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		c.Slug, c.Name, c.Avatar, c.Bio, verified, string(links), c.ID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE videos SET creator = ? WHERE creator_id = ? AND creator != ?", c.Name, c.ID, c.Name); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetVerified marks a creator, and so all of their videos, as verified or not
+func (r *CreatorRepository) SetVerified(id int, verified bool) error {
+	value := 0
+	if verified {
+		value = 1
+	}
+	_, err := r.db.Exec("UPDATE creators SET verified = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", value, id)
+	return err
+}
+
+// ensureCreator returns the creator named name, matched by slug, creating it
+// if needed, and points v at it: v gets the creator's ID, slug, verified flag
+// and name as first written
+func ensureCreator(tx *sql.Tx, v *Video) error {
+	name := strings.TrimSpace(v.Creator)
+	slug := Slugify(name)
+	if slug == "" {
+		return ErrInvalidCreator
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO creators (slug, name) VALUES (?, ?) ON CONFLICT (slug) DO NOTHING",
+		slug, name,
+	); err != nil {
+		return err
+	}
+	var verified int
+	if err := tx.QueryRow("SELECT id, name, verified FROM creators WHERE slug = ?", slug).
+		Scan(&v.CreatorID, &v.Creator, &verified); err != nil {
+		return err
+	}
+	v.CreatorSlug = slug
+	v.Verified = verified == 1
+	return nil
+}
@@ -0,0 +1,202 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+)
+
+func creatorSlugs(creators []models.Creator) []string {
+	slugs := []string{}
+	for _, c := range creators {
+		slugs = append(slugs, c.Slug)
+	}
+	return slugs
+}
+
+func TestCreatorBackfill_MovesLegacyNames(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	creators := models.NewCreatorRepository(db)
+
+	// A creator added since, who a legacy video turns out to share
+	createVideos(t, videos, &models.Video{Title: "New", Creator: "Ann Lee"})
+
+	// Videos from before creators: a free-text name and a per-video flag
+	_, err := db.Exec("ALTER TABLE videos ADD COLUMN verified INTEGER DEFAULT 0")
+	require.NoError(t, err)
+	legacy := func(creator string, verified int) int {
+		t.Helper()
+		result, err := db.Exec(
+			`INSERT INTO videos (title, creator, url, thumbnail, duration, description, verified)
+			 VALUES (?, ?, ?, '', '', '', ?)`,
+			"Old", creator, "https://cdn.example.com/video.mp4", verified,
+		)
+		require.NoError(t, err)
+		id, err := result.LastInsertId()
+		require.NoError(t, err)
+		return int(id)
+	}
+	johns := []int{legacy("john doe", 0), legacy("John Doe", 0), legacy(" John Doe ", 1)}
+	ann := legacy("ann lee", 1)
+	solo := legacy("Solo", 0)
+
+	require.NoError(t, database.RunMigrations(db))
+
+	var columns int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('videos') WHERE name = 'verified'").Scan(&columns))
+	assert.Zero(t, columns)
+
+	check := func() {
+		t.Helper()
+
+		// Spellings of one slug become one creator, named by the most used
+		// spelling and verified if any video was
+		john, err := creators.GetBySlug("john-doe")
+		require.NoError(t, err)
+		require.NotNil(t, john)
+		assert.Equal(t, "John Doe", john.Name)
+		assert.True(t, john.Verified)
+		for _, id := range johns {
+			v, err := videos.GetByID(id)
+			require.NoError(t, err)
+			assert.Equal(t, john.ID, v.CreatorID)
+			assert.Equal(t, "John Doe", v.Creator)
+			assert.True(t, v.Verified)
+		}
+
+		// An existing creator keeps their name and flag
+		v, err := videos.GetByID(ann)
+		require.NoError(t, err)
+		assert.Equal(t, "Ann Lee", v.Creator)
+		assert.Equal(t, "ann-lee", v.CreatorSlug)
+		assert.False(t, v.Verified)
+
+		v, err = videos.GetByID(solo)
+		require.NoError(t, err)
+		assert.Equal(t, "solo", v.CreatorSlug)
+		assert.False(t, v.Verified)
+
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM creators").Scan(&count))
+		assert.Equal(t, 3, count)
+	}
+	check()
+
+	// Running the migrations again finds nothing left to move
+	require.NoError(t, database.RunMigrations(db))
+	check()
+}
+
+func TestCreator_ListRenameAndVerify(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	repo := models.NewCreatorRepository(db)
+	ids := createVideos(t, videos,
+		&models.Video{Title: "One", Creator: "Ann"},
+		&models.Video{Title: "Two", Creator: "Ann"},
+		&models.Video{Title: "Three", Creator: "Bob"},
+		&models.Video{Title: "Four", Creator: "Bea"},
+		&models.Video{Title: "Draft", Creator: "Cat", Status: models.VideoStatusDraft},
+	)
+	for i, views := range []int{5, 5, 30, 1} {
+		_, err := db.Exec("UPDATE videos SET views = ? WHERE id = ?", views, ids[i])
+		require.NoError(t, err)
+	}
+
+	// Creators with published videos, most viewed first
+	list, total, err := repo.List("", 20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"bob", "ann", "bea"}, creatorSlugs(list))
+	require.NotNil(t, list[1].Stats)
+	assert.Equal(t, 2, list[1].Stats.VideoCount)
+	assert.Equal(t, 10, list[1].Stats.TotalViews)
+
+	list, total, err = repo.List("B", 20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []string{"bob", "bea"}, creatorSlugs(list))
+
+	list, _, err = repo.List("", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ann"}, creatorSlugs(list))
+
+	// Renaming changes the slug and the name on every video
+	ann, err := repo.GetBySlug("ann")
+	require.NoError(t, err)
+	require.NotNil(t, ann)
+	ann.Name = "  Ann Lee "
+	ann.Bio = "Films things"
+	require.NoError(t, repo.Update(ann))
+	assert.Equal(t, "ann-lee", ann.Slug)
+
+	gone, err := repo.GetBySlug("ann")
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+	renamed, err := repo.GetBySlug("ann-lee")
+	require.NoError(t, err)
+	require.NotNil(t, renamed)
+	assert.Equal(t, "Ann Lee", renamed.Name)
+	assert.Equal(t, "Films things", renamed.Bio)
+	for _, id := range ids[:2] {
+		v, err := videos.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, "Ann Lee", v.Creator)
+		assert.Equal(t, "ann-lee", v.CreatorSlug)
+	}
+
+	renamed.Name = "BOB"
+	assert.ErrorIs(t, repo.Update(renamed), models.ErrCreatorExists)
+	renamed.Name = "!!!"
+	assert.ErrorIs(t, repo.Update(renamed), models.ErrInvalidCreator)
+
+	// Verifying a creator verifies their videos
+	require.NoError(t, repo.SetVerified(renamed.ID, true))
+	verified, err := repo.GetBySlug("ann-lee")
+	require.NoError(t, err)
+	assert.True(t, verified.Verified)
+	v, err := videos.GetByID(ids[0])
+	require.NoError(t, err)
+	assert.True(t, v.Verified)
+
+	require.NoError(t, repo.SetVerified(renamed.ID, false))
+	v, err = videos.GetByID(ids[1])
+	require.NoError(t, err)
+	assert.False(t, v.Verified)
+}
+
+func TestCreator_ListByIDIgnoresViews(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	repo := models.NewCreatorRepository(db)
+	ids := createVideos(t, videos,
+		&models.Video{Title: "One", Creator: "Zed"},
+		&models.Video{Title: "Two", Creator: "Amy"},
+		&models.Video{Title: "Draft", Creator: "Cat", Status: models.VideoStatusDraft},
+		&models.Video{Title: "Three", Creator: "Bob"},
+	)
+
+	pages := func() [][]string {
+		t.Helper()
+		var slugs [][]string
+		for offset := 0; offset < 3; offset += 2 {
+			page, err := repo.ListByID(2, offset)
+			require.NoError(t, err)
+			slugs = append(slugs, creatorSlugs(page))
+		}
+		return slugs
+	}
+
+	// Oldest first, leaving out creators without published videos
+	want := [][]string{{"zed", "amy"}, {"bob"}}
+	assert.Equal(t, want, pages())
+
+	_, err := db.Exec("UPDATE videos SET views = 100 WHERE id = ?", ids[3])
+	require.NoError(t, err)
+	assert.Equal(t, want, pages())
+}
@@ -0,0 +1,47 @@
+package models_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+)
+
+// Repository tests live in models_test, as the migrations they run import
+// the models package
+
+// newTestDB opens a migrated SQLite database with foreign keys enforced, as
+// database.InitDB does
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "models.db")+"?_busy_timeout=5000&_foreign_keys=on")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.RunMigrations(db))
+	return db
+}
+
+// createVideos inserts videos in the "other" category, published unless
+// they have a status, and returns their IDs in order
+func createVideos(t *testing.T, repo *models.VideoRepository, videos ...*models.Video) []int {
+	t.Helper()
+
+	ids := make([]int, len(videos))
+	for i, v := range videos {
+		if v.Category == "" {
+			v.Category = "other"
+		}
+		if v.URL == "" {
+			v.URL = "https://cdn.example.com/video.mp4"
+		}
+		require.NoError(t, repo.Create(v))
+		ids[i] = v.ID
+	}
+	return ids
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Job statuses
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead" // Out of attempts, kept for inspection and manual retry
+	JobStatusCancelled = "cancelled"
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	LastError   string          `json:"lastError,omitempty"`
+	RunAt       time.Time       `json:"runAt"`
+	LockedAt    *time.Time      `json:"lockedAt,omitempty"`
+	FinishedAt  *time.Time      `json:"finishedAt,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+type JobRepository struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// NewJobRepository creates a job repository for the database's driver, as
+// returned by database.GetDBDriver: "postgres" or "sqlite"
+func NewJobRepository(db *sql.DB, driver string) *JobRepository {
+	return &JobRepository{db: db, postgres: driver == "postgres"}
+}
+
+// bind rewrites ? placeholders for the repository's database
+func (r *JobRepository) bind(query string) string {
+	if r.postgres {
+		return numberPlaceholders(query)
+	}
+	return query
+}
+
+const jobColumns = `id, type, payload, status, attempts, max_attempts, COALESCE(last_error, ''),
+	run_at, locked_at, finished_at, created_at, updated_at`
+
+func scanJob(row rowScanner, j *Job) error {
+	var payload string
+	var lockedAt, finishedAt sql.NullTime
+	err := row.Scan(&j.ID, &j.Type, &payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.LastError,
+		&j.RunAt, &lockedAt, &finishedAt, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	j.Payload = json.RawMessage(payload)
+	if lockedAt.Valid {
+		j.LockedAt = &lockedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return nil
+}
+
+// Create inserts a pending job. RunAt defaults to now.
+func (r *JobRepository) Create(j *Job) error {
+	now := time.Now().UTC()
+	if j.RunAt.IsZero() {
+		j.RunAt = now
+	}
+	if len(j.Payload) == 0 {
+		j.Payload = json.RawMessage("{}")
+	}
+	j.Status = JobStatusPending
+	j.CreatedAt = now
+	j.UpdatedAt = now
+
+	// RETURNING rather than LastInsertId, which pgx doesn't support
+	return r.db.QueryRow(r.bind(
+		`INSERT INTO jobs (type, payload, status, max_attempts, run_at, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		j.Type, string(j.Payload), j.Status, j.MaxAttempts, j.RunAt.UTC(), now, now,
+	).Scan(&j.ID)
+}
+
+func (r *JobRepository) GetByID(id int64) (*Job, error) {
+	j := &Job{}
+	err := scanJob(r.db.QueryRow(r.bind("SELECT "+jobColumns+" FROM jobs WHERE id = ?"), id), j)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Claim atomically moves the oldest due pending job of one of the given
+// types to running and returns it. It returns nil when nothing is due.
+// The status check in the outer WHERE makes a lost race update zero rows;
+// on PostgreSQL, workers also skip rows another worker is claiming.
+func (r *JobRepository) Claim(types []string) (*Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	args := []interface{}{now, now}
+	placeholders := ""
+	for i, t := range types {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, t)
+	}
+	args = append(args, now)
+
+	lock := ""
+	if r.postgres {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+
+	var id int64
+	err := r.db.QueryRow(r.bind(
+		`UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = ?, updated_at = ?
+		 WHERE status = 'pending' AND id = (
+			SELECT id FROM jobs
+			WHERE status = 'pending' AND type IN (`+placeholders+`) AND run_at <= ?
+			ORDER BY run_at, id LIMIT 1`+lock+`
+		 )
+		 RETURNING id`),
+		args...,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// MarkSucceeded finishes a running job
+func (r *JobRepository) MarkSucceeded(id int64) error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'succeeded', last_error = NULL, locked_at = NULL, finished_at = ?, updated_at = ?
+		 WHERE id = ? AND status = 'running'`),
+		now, now, id,
+	)
+	return err
+}
+
+// MarkRetry records a failed attempt and schedules the job to run again at runAt
+func (r *JobRepository) MarkRetry(id int64, errMsg string, runAt time.Time) error {
+	_, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'pending', last_error = ?, locked_at = NULL, run_at = ?, updated_at = ?
+		 WHERE id = ? AND status = 'running'`),
+		errMsg, runAt.UTC(), time.Now().UTC(), id,
+	)
+	return err
+}
+
+// MarkDead records the final failure of a job that will not be retried automatically
+func (r *JobRepository) MarkDead(id int64, errMsg string) error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'dead', last_error = ?, locked_at = NULL, finished_at = ?, updated_at = ?
+		 WHERE id = ? AND status = 'running'`),
+		errMsg, now, now, id,
+	)
+	return err
+}
+
+// Release returns a running job to the queue without counting the attempt.
+// Used when a worker is interrupted by shutdown.
+func (r *JobRepository) Release(id int64) error {
+	_, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'pending', attempts = attempts - 1, locked_at = NULL, updated_at = ?
+		 WHERE id = ? AND status = 'running'`),
+		time.Now().UTC(), id,
+	)
+	return err
+}
+
+// Retry puts a dead or cancelled job back in the queue with a fresh attempt budget
+func (r *JobRepository) Retry(id int64) (bool, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'pending', attempts = 0, run_at = ?, finished_at = NULL, updated_at = ?
+		 WHERE id = ? AND status IN ('dead', 'cancelled')`),
+		now, now, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Cancel stops a pending or running job from being (re)run
+func (r *JobRepository) Cancel(id int64) (bool, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'cancelled', locked_at = NULL, finished_at = ?, updated_at = ?
+		 WHERE id = ? AND status IN ('pending', 'running')`),
+		now, now, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// HasActive reports whether a pending or running job of the given type exists
+func (r *JobRepository) HasActive(jobType string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(r.bind(
+		`SELECT COUNT(*) FROM jobs WHERE type = ? AND status IN ('pending', 'running')`), jobType,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// RequeueStale releases running jobs whose lock is older than cutoff.
+// This recovers jobs left behind by a crashed worker.
+func (r *JobRepository) RequeueStale(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(r.bind(
+		`UPDATE jobs SET status = 'pending', locked_at = NULL, last_error = 'worker lost', updated_at = ?
+		 WHERE status = 'running' AND locked_at < ?`),
+		time.Now().UTC(), cutoff.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteFinished removes succeeded and cancelled jobs finished before cutoff.
+// Dead jobs are kept until an admin retries or cancels them.
+func (r *JobRepository) DeleteFinished(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(r.bind(
+		`DELETE FROM jobs WHERE status IN ('succeeded', 'cancelled') AND finished_at < ?`), cutoff.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// List returns jobs newest first, optionally filtered by status and type
+func (r *JobRepository) List(status, jobType string, page, limit int) ([]Job, int, error) {
+	offset := (page - 1) * limit
+
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+	if jobType != "" {
+		where += " AND type = ?"
+		args = append(args, jobType)
+	}
+
+	var total int
+	if err := r.db.QueryRow(r.bind("SELECT COUNT(*) FROM jobs"+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		r.bind("SELECT "+jobColumns+" FROM jobs"+where+" ORDER BY id DESC LIMIT ? OFFSET ?"),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		var j Job
+		if err := scanJob(rows, &j); err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, total, nil
+}
+
+// CountByStatus returns the number of jobs in each status
+func (r *JobRepository) CountByStatus() (map[string]int, error) {
+	rows, err := r.db.Query(`SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, nil
+}
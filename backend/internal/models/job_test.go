@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedQuery is one statement the job repository sent to the database
+type recordedQuery struct {
+	query string
+	args  int
+}
+
+// recordingConnector is a database/sql connector that records every statement
+// instead of running it. Counts and RETURNING id read back a single 1; every
+// other query returns no rows.
+type recordingConnector struct {
+	queries []recordedQuery
+}
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver { return recordingDriver{} }
+
+type recordingDriver struct{}
+
+func (recordingDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("use the connector")
+}
+
+type recordingConn struct{ c *recordingConnector }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.c.queries = append(c.c.queries, recordedQuery{query, len(args)})
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recordingConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.c.queries = append(c.c.queries, recordedQuery{query, len(args)})
+	if strings.Contains(query, "RETURNING id") || strings.Contains(query, "COUNT(*)") {
+		return &recordingRows{values: []driver.Value{int64(1)}}, nil
+	}
+	return &recordingRows{}, nil
+}
+
+type recordingRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *recordingRows) Columns() []string {
+	return make([]string, len(r.values))
+}
+
+func (r *recordingRows) Close() error { return nil }
+
+func (r *recordingRows) Next(dest []driver.Value) error {
+	if r.done || r.values == nil {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+// runJobQueries calls every JobRepository method that takes arguments
+func runJobQueries(t *testing.T, driver string) []recordedQuery {
+	t.Helper()
+
+	c := &recordingConnector{}
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	repo := NewJobRepository(db, driver)
+
+	job := &Job{Type: "thumbnail", MaxAttempts: 3}
+	require.NoError(t, repo.Create(job))
+	assert.Equal(t, int64(1), job.ID)
+
+	repo.GetByID(1)
+	repo.Claim([]string{"thumbnail", "transcode"})
+	repo.MarkSucceeded(1)
+	repo.MarkRetry(1, "boom", time.Now())
+	repo.MarkDead(1, "boom")
+	repo.Release(1)
+	repo.Retry(1)
+	repo.Cancel(1)
+	repo.HasActive("thumbnail")
+	repo.RequeueStale(time.Now())
+	repo.DeleteFinished(time.Now())
+	repo.List(JobStatusDead, "thumbnail", 2, 20)
+
+	return c.queries
+}
+
+func TestJobRepository_PostgresPlaceholders(t *testing.T) {
+	numbered := regexp.MustCompile(`\$(\d+)`)
+
+	queries := runJobQueries(t, "postgres")
+	require.NotEmpty(t, queries)
+	for _, q := range queries {
+		assert.NotContains(t, q.query, "?")
+
+		// $1..$n, one per argument
+		highest := 0
+		for _, m := range numbered.FindAllStringSubmatch(q.query, -1) {
+			n, _ := strconv.Atoi(m[1])
+			highest = max(highest, n)
+		}
+		assert.Equal(t, q.args, highest, q.query)
+	}
+	assert.Contains(t, queries[2].query, "FOR UPDATE SKIP LOCKED")
+}
+
+func TestJobRepository_SQLitePlaceholders(t *testing.T) {
+	queries := runJobQueries(t, "sqlite")
+	require.NotEmpty(t, queries)
+	for _, q := range queries {
+		assert.NotContains(t, q.query, "$1")
+		assert.Equal(t, q.args, strings.Count(q.query, "?"), q.query)
+	}
+	assert.NotContains(t, queries[2].query, "FOR UPDATE")
+}
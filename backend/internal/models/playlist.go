@@ -0,0 +1,450 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Playlist visibilities
+const (
+	PlaylistPublic   = "public"   // Listed and viewable by anyone
+	PlaylistUnlisted = "unlisted" // Viewable by anyone with the link, never listed
+	PlaylistPrivate  = "private"  // Owner and admins only
+)
+
+// ValidPlaylistVisibilities is a map of valid playlist visibilities
+var ValidPlaylistVisibilities = map[string]bool{
+	PlaylistPublic:   true,
+	PlaylistUnlisted: true,
+	PlaylistPrivate:  true,
+}
+
+// Errors returned by the playlist item operations
+var (
+	ErrPlaylistNotFound  = errors.New("playlist not found")
+	ErrAlreadyInPlaylist = errors.New("video is already in this playlist")
+	ErrNotInPlaylist     = errors.New("video is not in this playlist")
+)
+
+// Playlist is an ordered list of videos curated by a viewer or an admin
+type Playlist struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"-"` // Viewer ID (user:<id> or device:<id>), never exposed
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"`
+	ItemCount   int       `json:"itemCount"`
+	IsOwner     bool      `json:"isOwner"` // Set per request by the handler
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// PlaylistItem is a video's place in a playlist. Video is filled in by the
+// caller; the repository only knows the ID.
+type PlaylistItem struct {
+	VideoID  int       `json:"videoId"`
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"addedAt"`
+	Video    *Video    `json:"video,omitempty"`
+}
+
+// PlaylistPosition is where a video sits in a playlist, with its neighbours
+// for auto-advance. Previous/next are nil at either end.
+type PlaylistPosition struct {
+	Position        int  `json:"position"`
+	Total           int  `json:"total"`
+	PreviousVideoID *int `json:"previousVideoId"`
+	NextVideoID     *int `json:"nextVideoId"`
+}
+
+// CanEdit reports whether the viewer may change the playlist: its owner or an admin
+func (p *Playlist) CanEdit(viewerID string, admin bool) bool {
+	return admin || (viewerID != "" && viewerID == p.OwnerID)
+}
+
+// CanView reports whether the viewer may open the playlist. Unlisted
+// playlists are open to anyone who has the ID.
+func (p *Playlist) CanView(viewerID string, admin bool) bool {
+	return p.Visibility != PlaylistPrivate || p.CanEdit(viewerID, admin)
+}
+
+type PlaylistRepository struct {
+	db *sql.DB
+}
+
+func NewPlaylistRepository(db *sql.DB) *PlaylistRepository {
+	return &PlaylistRepository{db: db}
+}
+
+const playlistColumns = `p.id, p.owner_id, p.title, p.description, p.visibility, p.created_at, p.updated_at,
+	(SELECT COUNT(*) FROM playlist_items pi WHERE pi.playlist_id = p.id)`
+
+func scanPlaylist(row rowScanner, p *Playlist) error {
+	return row.Scan(&p.ID, &p.OwnerID, &p.Title, &p.Description, &p.Visibility,
+		&p.CreatedAt, &p.UpdatedAt, &p.ItemCount)
+}
+
+func (r *PlaylistRepository) queryPlaylists(query string, args ...interface{}) ([]Playlist, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+	for rows.Next() {
+		var p Playlist
+		if err := scanPlaylist(rows, &p); err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+func (r *PlaylistRepository) GetByID(id string) (*Playlist, error) {
+	p := &Playlist{}
+	err := scanPlaylist(r.db.QueryRow("SELECT "+playlistColumns+" FROM playlists p WHERE p.id = ?", id), p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ListPublic returns a page of public playlists, most recently updated first
+func (r *PlaylistRepository) ListPublic(page, limit int) ([]Playlist, int, error) {
+	return r.list(" WHERE p.visibility = ?", PlaylistPublic, page, limit)
+}
+
+// ListByOwner returns a page of a viewer's own playlists of any visibility
+func (r *PlaylistRepository) ListByOwner(ownerID string, page, limit int) ([]Playlist, int, error) {
+	return r.list(" WHERE p.owner_id = ?", ownerID, page, limit)
+}
+
+func (r *PlaylistRepository) list(where string, arg interface{}, page, limit int) ([]Playlist, int, error) {
+	offset := (page - 1) * limit
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM playlists p"+where, arg).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	playlists, err := r.queryPlaylists(
+		"SELECT "+playlistColumns+" FROM playlists p"+where+" ORDER BY p.updated_at DESC, p.id LIMIT ? OFFSET ?",
+		arg, limit, offset,
+	)
+	return playlists, total, err
+}
+
+func (r *PlaylistRepository) Create(p *Playlist) error {
+	_, err := r.db.Exec(
+		"INSERT INTO playlists (id, owner_id, title, description, visibility) VALUES (?, ?, ?, ?, ?)",
+		p.ID, p.OwnerID, p.Title, p.Description, p.Visibility,
+	)
+	if err != nil {
+		return err
+	}
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	return nil
+}
+
+func (r *PlaylistRepository) Update(p *Playlist) error {
+	_, err := r.db.Exec(
+		`UPDATE playlists SET title = ?, description = ?, visibility = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		p.Title, p.Description, p.Visibility, p.ID,
+	)
+	return err
+}
+
+func (r *PlaylistRepository) Delete(id string) error {
+	_, err := r.db.Exec("DELETE FROM playlists WHERE id = ?", id)
+	return err
+}
+
+// Items returns a page of a playlist's items in order
+func (r *PlaylistRepository) Items(playlistID string, page, limit int) ([]PlaylistItem, error) {
+	offset := (page - 1) * limit
+	rows, err := r.db.Query(
+		`SELECT video_id, position, added_at FROM playlist_items
+		 WHERE playlist_id = ? ORDER BY position ASC LIMIT ? OFFSET ?`,
+		playlistID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []PlaylistItem{}
+	for rows.Next() {
+		var item PlaylistItem
+		if err := rows.Scan(&item.VideoID, &item.Position, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		// Report the index rather than the stored position, which can have gaps
+		item.Position = offset + len(items)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// beginItemChange starts a transaction for reordering a playlist. It writes
+// first so concurrent edits to the same playlist serialize, then renumbers
+// positions to 0..n-1 to close gaps left by deleted videos. Returns the item count.
+func (r *PlaylistRepository) beginItemChange(playlistID string) (*sql.Tx, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := tx.Exec("UPDATE playlists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", playlistID)
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, err
+	}
+	if found, _ := result.RowsAffected(); found == 0 {
+		tx.Rollback()
+		return nil, 0, ErrPlaylistNotFound
+	}
+
+	count, err := compactPositions(tx, playlistID)
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, err
+	}
+	return tx, count, nil
+}
+
+// compactPositions renumbers a playlist's items to 0..n-1 in their current
+// order and returns n
+func compactPositions(tx *sql.Tx, playlistID string) (int, error) {
+	rows, err := tx.Query(
+		"SELECT video_id, position FROM playlist_items WHERE playlist_id = ? ORDER BY position ASC",
+		playlistID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	type item struct{ videoID, position int }
+	var items []item
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.videoID, &it.position); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, it := range items {
+		if it.position == i {
+			continue
+		}
+		if _, err := tx.Exec(
+			"UPDATE playlist_items SET position = ? WHERE playlist_id = ? AND video_id = ?",
+			i, playlistID, it.videoID,
+		); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// AddItem inserts a video at position, shifting later items down. A nil or
+// out of range position appends. Returns the position the video ended up at.
+func (r *PlaylistRepository) AddItem(playlistID string, videoID int, position *int) (int, error) {
+	tx, count, err := r.beginItemChange(playlistID)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(
+		"SELECT 1 FROM playlist_items WHERE playlist_id = ? AND video_id = ?",
+		playlistID, videoID,
+	).Scan(&exists)
+	if err == nil {
+		return 0, ErrAlreadyInPlaylist
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	to := count
+	if position != nil && *position >= 0 && *position < count {
+		to = *position
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE playlist_items SET position = position + 1 WHERE playlist_id = ? AND position >= ?",
+		playlistID, to,
+	); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO playlist_items (playlist_id, video_id, position) VALUES (?, ?, ?)",
+		playlistID, videoID, to,
+	); err != nil {
+		return 0, err
+	}
+	return to, tx.Commit()
+}
+
+// MoveItem moves a video to index position, shifting the items in between.
+// The index is clamped to the playlist. Returns the final position.
+func (r *PlaylistRepository) MoveItem(playlistID string, videoID, position int) (int, error) {
+	tx, count, err := r.beginItemChange(playlistID)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var from int
+	err = tx.QueryRow(
+		"SELECT position FROM playlist_items WHERE playlist_id = ? AND video_id = ?",
+		playlistID, videoID,
+	).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotInPlaylist
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	to := position
+	if to < 0 {
+		to = 0
+	}
+	if to > count-1 {
+		to = count - 1
+	}
+
+	switch {
+	case to < from:
+		_, err = tx.Exec(
+			"UPDATE playlist_items SET position = position + 1 WHERE playlist_id = ? AND position >= ? AND position < ?",
+			playlistID, to, from,
+		)
+	case to > from:
+		_, err = tx.Exec(
+			"UPDATE playlist_items SET position = position - 1 WHERE playlist_id = ? AND position > ? AND position <= ?",
+			playlistID, from, to,
+		)
+	default:
+		return to, tx.Commit()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE playlist_items SET position = ? WHERE playlist_id = ? AND video_id = ?",
+		to, playlistID, videoID,
+	); err != nil {
+		return 0, err
+	}
+	return to, tx.Commit()
+}
+
+// RemoveItem takes a video out of a playlist and closes the gap
+func (r *PlaylistRepository) RemoveItem(playlistID string, videoID int) error {
+	tx, _, err := r.beginItemChange(playlistID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var from int
+	err = tx.QueryRow(
+		"DELETE FROM playlist_items WHERE playlist_id = ? AND video_id = ? RETURNING position",
+		playlistID, videoID,
+	).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotInPlaylist
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE playlist_items SET position = position - 1 WHERE playlist_id = ? AND position > ?",
+		playlistID, from,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Position returns where a video sits in a playlist and its neighbours, or
+// nil if the video isn't in it. Unless allStatuses is set, for admins, only
+// videos anyone with a link may watch are counted or offered as neighbours,
+// matching the item list viewers see.
+func (r *PlaylistRepository) Position(playlistID string, videoID int, allStatuses bool) (*PlaylistPosition, error) {
+	var stored int
+	err := r.db.QueryRow(
+		"SELECT position FROM playlist_items WHERE playlist_id = ? AND video_id = ?",
+		playlistID, videoID,
+	).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from := "playlist_items i"
+	var statuses []interface{}
+	if !allStatuses {
+		from += " JOIN videos v ON v.id = i.video_id AND v.status IN (?, ?)"
+		statuses = []interface{}{VideoStatusPublished, VideoStatusUnlisted}
+	}
+
+	// Stored positions can have gaps after videos are deleted, so the index
+	// is counted rather than read
+	pos := &PlaylistPosition{}
+	args := append(append(append([]interface{}{}, statuses...), playlistID, stored), statuses...)
+	err = r.db.QueryRow(
+		`SELECT
+			(SELECT COUNT(*) FROM `+from+` WHERE i.playlist_id = ? AND i.position < ?),
+			(SELECT COUNT(*) FROM `+from+` WHERE i.playlist_id = ?)`,
+		append(args, playlistID)...,
+	).Scan(&pos.Position, &pos.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if pos.PreviousVideoID, err = r.neighbour(from, statuses, playlistID, "i.position < ? ORDER BY i.position DESC", stored); err != nil {
+		return nil, err
+	}
+	if pos.NextVideoID, err = r.neighbour(from, statuses, playlistID, "i.position > ? ORDER BY i.position ASC", stored); err != nil {
+		return nil, err
+	}
+	return pos, nil
+}
+
+func (r *PlaylistRepository) neighbour(from string, statuses []interface{}, playlistID, condition string, position int) (*int, error) {
+	var id int
+	args := append(append([]interface{}{}, statuses...), playlistID, position)
+	err := r.db.QueryRow(
+		"SELECT i.video_id FROM "+from+" WHERE i.playlist_id = ? AND "+condition+" LIMIT 1",
+		args...,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
@@ -0,0 +1,217 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func newTestPlaylist(t *testing.T, repo *models.PlaylistRepository, id, owner, visibility string, videoIDs ...int) {
+	t.Helper()
+
+	require.NoError(t, repo.Create(&models.Playlist{ID: id, OwnerID: owner, Title: id, Visibility: visibility}))
+	for _, videoID := range videoIDs {
+		_, err := repo.AddItem(id, videoID, nil)
+		require.NoError(t, err)
+	}
+}
+
+func playlistOrder(t *testing.T, repo *models.PlaylistRepository, id string) []int {
+	t.Helper()
+
+	items, err := repo.Items(id, 1, 50)
+	require.NoError(t, err)
+	ids := []int{}
+	for i, item := range items {
+		assert.Equal(t, i, item.Position)
+		ids = append(ids, item.VideoID)
+	}
+	return ids
+}
+
+func TestPlaylist_AddMoveAndRemoveItems(t *testing.T) {
+	db := newTestDB(t)
+	v := createVideos(t, models.NewVideoRepository(db),
+		&models.Video{Title: "A", Creator: "Ann"}, &models.Video{Title: "B", Creator: "Ann"},
+		&models.Video{Title: "C", Creator: "Ann"}, &models.Video{Title: "D", Creator: "Ann"},
+		&models.Video{Title: "E", Creator: "Ann"},
+	)
+	repo := models.NewPlaylistRepository(db)
+	newTestPlaylist(t, repo, "pl", "device:a", models.PlaylistPrivate, v[0], v[1], v[2])
+
+	// Inserting shifts later items down; out of range positions append
+	at := func(i int) *int { return &i }
+	pos, err := repo.AddItem("pl", v[3], at(1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, pos)
+	pos, err = repo.AddItem("pl", v[4], at(99))
+	require.NoError(t, err)
+	assert.Equal(t, 4, pos)
+	assert.Equal(t, []int{v[0], v[3], v[1], v[2], v[4]}, playlistOrder(t, repo, "pl"))
+
+	_, err = repo.AddItem("pl", v[0], nil)
+	assert.ErrorIs(t, err, models.ErrAlreadyInPlaylist)
+	_, err = repo.AddItem("missing", v[0], nil)
+	assert.ErrorIs(t, err, models.ErrPlaylistNotFound)
+
+	// Moves shift the items in between, with the index clamped to the playlist
+	pos, err = repo.MoveItem("pl", v[2], 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pos)
+	assert.Equal(t, []int{v[2], v[0], v[3], v[1], v[4]}, playlistOrder(t, repo, "pl"))
+
+	pos, err = repo.MoveItem("pl", v[0], 99)
+	require.NoError(t, err)
+	assert.Equal(t, 4, pos)
+	assert.Equal(t, []int{v[2], v[3], v[1], v[4], v[0]}, playlistOrder(t, repo, "pl"))
+
+	pos, err = repo.MoveItem("pl", v[4], -3)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pos)
+	assert.Equal(t, []int{v[4], v[2], v[3], v[1], v[0]}, playlistOrder(t, repo, "pl"))
+
+	// Removing closes the gap
+	require.NoError(t, repo.RemoveItem("pl", v[2]))
+	assert.Equal(t, []int{v[4], v[3], v[1], v[0]}, playlistOrder(t, repo, "pl"))
+	assert.ErrorIs(t, repo.RemoveItem("pl", v[2]), models.ErrNotInPlaylist)
+	_, err = repo.MoveItem("pl", v[2], 0)
+	assert.ErrorIs(t, err, models.ErrNotInPlaylist)
+
+	playlist, err := repo.GetByID("pl")
+	require.NoError(t, err)
+	assert.Equal(t, 4, playlist.ItemCount)
+}
+
+func TestPlaylist_GapsFromDeletedVideos(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	v := createVideos(t, videos,
+		&models.Video{Title: "A", Creator: "Ann"}, &models.Video{Title: "B", Creator: "Ann"},
+		&models.Video{Title: "C", Creator: "Ann"}, &models.Video{Title: "D", Creator: "Ann"},
+	)
+	repo := models.NewPlaylistRepository(db)
+	newTestPlaylist(t, repo, "pl", "device:a", models.PlaylistPublic, v...)
+
+	storedPositions := func() []int {
+		rows, err := db.Query("SELECT position FROM playlist_items WHERE playlist_id = 'pl' ORDER BY position")
+		require.NoError(t, err)
+		defer rows.Close()
+		positions := []int{}
+		for rows.Next() {
+			var p int
+			require.NoError(t, rows.Scan(&p))
+			positions = append(positions, p)
+		}
+		return positions
+	}
+
+	// Deleting a video drops its item and leaves a gap
+	require.NoError(t, videos.Delete(v[1]))
+	assert.Equal(t, []int{0, 2, 3}, storedPositions())
+
+	// Reads count the index rather than trusting stored positions
+	assert.Equal(t, []int{v[0], v[2], v[3]}, playlistOrder(t, repo, "pl"))
+	pos, err := repo.Position("pl", v[3], false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pos.Position)
+	assert.Equal(t, 3, pos.Total)
+
+	// The next change compacts them, and moves by index
+	at, err := repo.MoveItem("pl", v[3], 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, at)
+	assert.Equal(t, []int{0, 1, 2}, storedPositions())
+	assert.Equal(t, []int{v[0], v[3], v[2]}, playlistOrder(t, repo, "pl"))
+}
+
+func TestPlaylist_PositionSkipsHiddenVideos(t *testing.T) {
+	db := newTestDB(t)
+	v := createVideos(t, models.NewVideoRepository(db),
+		&models.Video{Title: "A", Creator: "Ann"},
+		&models.Video{Title: "B", Creator: "Ann", Status: models.VideoStatusDraft},
+		&models.Video{Title: "C", Creator: "Ann", Status: models.VideoStatusUnlisted},
+		&models.Video{Title: "D", Creator: "Ann", Status: models.VideoStatusPrivate},
+		&models.Video{Title: "E", Creator: "Ann"},
+	)
+	repo := models.NewPlaylistRepository(db)
+	newTestPlaylist(t, repo, "pl", "device:a", models.PlaylistPublic, v...)
+
+	id := func(i int) *int { return &v[i] }
+
+	// Viewers skip over the draft and private videos
+	pos, err := repo.Position("pl", v[2], false)
+	require.NoError(t, err)
+	assert.Equal(t, &models.PlaylistPosition{Position: 1, Total: 3, PreviousVideoID: id(0), NextVideoID: id(4)}, pos)
+
+	pos, err = repo.Position("pl", v[0], false)
+	require.NoError(t, err)
+	assert.Equal(t, &models.PlaylistPosition{Position: 0, Total: 3, NextVideoID: id(2)}, pos)
+
+	pos, err = repo.Position("pl", v[4], false)
+	require.NoError(t, err)
+	assert.Equal(t, &models.PlaylistPosition{Position: 2, Total: 3, PreviousVideoID: id(2)}, pos)
+
+	// Admins see every item
+	pos, err = repo.Position("pl", v[2], true)
+	require.NoError(t, err)
+	assert.Equal(t, &models.PlaylistPosition{Position: 2, Total: 5, PreviousVideoID: id(1), NextVideoID: id(3)}, pos)
+
+	pos, err = repo.Position("other", v[2], false)
+	require.NoError(t, err)
+	assert.Nil(t, pos)
+}
+
+func TestPlaylist_Visibility(t *testing.T) {
+	repo := models.NewPlaylistRepository(newTestDB(t))
+	newTestPlaylist(t, repo, "public", "device:a", models.PlaylistPublic)
+	newTestPlaylist(t, repo, "unlisted", "device:a", models.PlaylistUnlisted)
+	newTestPlaylist(t, repo, "private", "device:a", models.PlaylistPrivate)
+	newTestPlaylist(t, repo, "other", "device:b", models.PlaylistPublic)
+
+	ids := func(playlists []models.Playlist) []string {
+		out := []string{}
+		for _, p := range playlists {
+			out = append(out, p.ID)
+		}
+		return out
+	}
+
+	// Only public playlists are listed; owners see all of theirs
+	public, total, err := repo.ListPublic(1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.ElementsMatch(t, []string{"public", "other"}, ids(public))
+
+	owned, total, err := repo.ListByOwner("device:a", 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.ElementsMatch(t, []string{"public", "unlisted", "private"}, ids(owned))
+
+	for _, tc := range []struct {
+		id               string
+		viewer           string
+		admin            bool
+		canView, canEdit bool
+	}{
+		{"public", "device:b", false, true, false},
+		{"unlisted", "device:b", false, true, false},
+		{"unlisted", "", false, true, false},
+		{"private", "device:b", false, false, false},
+		{"private", "", false, false, false},
+		{"private", "device:a", false, true, true},
+		{"private", "device:b", true, true, true},
+	} {
+		p, err := repo.GetByID(tc.id)
+		require.NoError(t, err)
+		require.NotNil(t, p)
+		assert.Equal(t, tc.canView, p.CanView(tc.viewer, tc.admin), "%s viewed by %q", tc.id, tc.viewer)
+		assert.Equal(t, tc.canEdit, p.CanEdit(tc.viewer, tc.admin), "%s edited by %q", tc.id, tc.viewer)
+	}
+
+	missing, err := repo.GetByID("missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
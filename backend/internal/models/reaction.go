@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Reaction values stored in video_reactions
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+// ValidReactions is a map of valid reaction values
+var ValidReactions = map[string]bool{
+	ReactionLike:    true,
+	ReactionDislike: true,
+}
+
+// reactionCounters maps a reaction to the videos column that counts it
+var reactionCounters = map[string]string{
+	ReactionLike:    "likes",
+	ReactionDislike: "dislikes",
+}
+
+// ReactionCounts is a video's like/dislike totals
+type ReactionCounts struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+type ReactionRepository struct {
+	db *sql.DB
+}
+
+func NewReactionRepository(db *sql.DB) *ReactionRepository {
+	return &ReactionRepository{db: db}
+}
+
+// Get returns the viewer's reaction to a video, or "" if there is none
+func (r *ReactionRepository) Get(videoID int, viewerID string) (string, error) {
+	var reaction string
+	err := r.db.QueryRow(
+		"SELECT reaction FROM video_reactions WHERE video_id = ? AND viewer_id = ?",
+		videoID, viewerID,
+	).Scan(&reaction)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return reaction, err
+}
+
+// Set records the viewer's reaction and updates the video's counters in one
+// transaction. Every statement writes before anything is read, so concurrent
+// requests from the same viewer serialize on the row instead of double counting.
+func (r *ReactionRepository) Set(videoID int, viewerID, reaction string) (*ReactionCounts, error) {
+	column, ok := reactionCounters[reaction]
+	if !ok {
+		return nil, errors.New("invalid reaction")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Switching sides moves one count from the other column
+	result, err := tx.Exec(
+		`UPDATE video_reactions SET reaction = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE video_id = ? AND viewer_id = ? AND reaction <> ?`,
+		reaction, videoID, viewerID, reaction,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if switched, _ := result.RowsAffected(); switched > 0 {
+		other := reactionCounters[ReactionDislike]
+		if reaction == ReactionDislike {
+			other = reactionCounters[ReactionLike]
+		}
+		if _, err := tx.Exec(
+			"UPDATE videos SET "+column+" = "+column+" + 1, "+other+" = CASE WHEN "+other+" > 0 THEN "+other+" - 1 ELSE 0 END WHERE id = ?",
+			videoID,
+		); err != nil {
+			return nil, err
+		}
+		return r.commitCounts(tx, videoID)
+	}
+
+	// New reaction; a repeat of the current one is a no-op
+	result, err = tx.Exec(
+		`INSERT INTO video_reactions (video_id, viewer_id, reaction) VALUES (?, ?, ?)
+		 ON CONFLICT (video_id, viewer_id) DO NOTHING`,
+		videoID, viewerID, reaction,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted > 0 {
+		if _, err := tx.Exec("UPDATE videos SET "+column+" = "+column+" + 1 WHERE id = ?", videoID); err != nil {
+			return nil, err
+		}
+	}
+	return r.commitCounts(tx, videoID)
+}
+
+// Remove deletes the viewer's reaction, if any, and decrements its counter
+func (r *ReactionRepository) Remove(videoID int, viewerID string) (*ReactionCounts, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reaction string
+	err = tx.QueryRow(
+		"DELETE FROM video_reactions WHERE video_id = ? AND viewer_id = ? RETURNING reaction",
+		videoID, viewerID,
+	).Scan(&reaction)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if column, ok := reactionCounters[reaction]; ok {
+		if _, err := tx.Exec(
+			"UPDATE videos SET "+column+" = "+column+" - 1 WHERE id = ? AND "+column+" > 0",
+			videoID,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return r.commitCounts(tx, videoID)
+}
+
+func (r *ReactionRepository) commitCounts(tx *sql.Tx, videoID int) (*ReactionCounts, error) {
+	counts := &ReactionCounts{}
+	err := tx.QueryRow(
+		"SELECT COALESCE(likes, 0), COALESCE(dislikes, 0) FROM videos WHERE id = ?",
+		videoID,
+	).Scan(&counts.Likes, &counts.Dislikes)
+	if err != nil {
+		return nil, err
+	}
+	return counts, tx.Commit()
+}
+
+// Reconcile recomputes every video's like/dislike counters from
+// video_reactions and returns how many videos were corrected
+func (r *ReactionRepository) Reconcile() (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE videos SET
+			likes = (SELECT COUNT(*) FROM video_reactions vr WHERE vr.video_id = videos.id AND vr.reaction = 'like'),
+			dislikes = (SELECT COUNT(*) FROM video_reactions vr WHERE vr.video_id = videos.id AND vr.reaction = 'dislike')
+		WHERE COALESCE(likes, 0) <> (SELECT COUNT(*) FROM video_reactions vr WHERE vr.video_id = videos.id AND vr.reaction = 'like')
+		   OR COALESCE(dislikes, 0) <> (SELECT COUNT(*) FROM video_reactions vr WHERE vr.video_id = videos.id AND vr.reaction = 'dislike')
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func TestReaction_SetSwitchAndRemove(t *testing.T) {
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db), &models.Video{Title: "Clip", Creator: "Ann"})
+	repo := models.NewReactionRepository(db)
+
+	set := func(viewer, reaction string, likes, dislikes int) {
+		t.Helper()
+		counts, err := repo.Set(ids[0], viewer, reaction)
+		require.NoError(t, err)
+		assert.Equal(t, &models.ReactionCounts{Likes: likes, Dislikes: dislikes}, counts)
+	}
+	remove := func(viewer string, likes, dislikes int) {
+		t.Helper()
+		counts, err := repo.Remove(ids[0], viewer)
+		require.NoError(t, err)
+		assert.Equal(t, &models.ReactionCounts{Likes: likes, Dislikes: dislikes}, counts)
+	}
+
+	set("device:a", models.ReactionLike, 1, 0)
+	set("device:b", models.ReactionLike, 2, 0)
+
+	// Repeating a reaction doesn't count it again
+	set("device:a", models.ReactionLike, 2, 0)
+
+	// Switching moves the viewer's count across
+	set("device:a", models.ReactionDislike, 1, 1)
+	reaction, err := repo.Get(ids[0], "device:a")
+	require.NoError(t, err)
+	assert.Equal(t, models.ReactionDislike, reaction)
+
+	remove("device:a", 1, 0)
+	remove("device:a", 1, 0)
+	reaction, err = repo.Get(ids[0], "device:a")
+	require.NoError(t, err)
+	assert.Empty(t, reaction)
+
+	_, err = repo.Set(ids[0], "device:a", "love")
+	assert.Error(t, err)
+}
+
+func TestReaction_ConcurrentRequestsFromOneViewer(t *testing.T) {
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db), &models.Video{Title: "Clip", Creator: "Ann"})
+	repo := models.NewReactionRepository(db)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Set(ids[0], "device:a", models.ReactionLike)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	counts, err := repo.Set(ids[0], "device:b", models.ReactionDislike)
+	require.NoError(t, err)
+	assert.Equal(t, &models.ReactionCounts{Likes: 1, Dislikes: 1}, counts)
+}
+
+func TestReaction_ReconcileFixesDriftedCounters(t *testing.T) {
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db),
+		&models.Video{Title: "Drifted", Creator: "Ann"}, &models.Video{Title: "Fine", Creator: "Ann"},
+	)
+	repo := models.NewReactionRepository(db)
+	for _, viewer := range []string{"device:a", "device:b"} {
+		for _, id := range ids {
+			_, err := repo.Set(id, viewer, models.ReactionLike)
+			require.NoError(t, err)
+		}
+	}
+
+	_, err := db.Exec("UPDATE videos SET likes = 7, dislikes = 3 WHERE id = ?", ids[0])
+	require.NoError(t, err)
+
+	fixed, err := repo.Reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), fixed)
+
+	counts, err := repo.Remove(ids[0], "device:a")
+	require.NoError(t, err)
+	assert.Equal(t, &models.ReactionCounts{Likes: 1, Dislikes: 0}, counts)
+}
@@ -0,0 +1,264 @@
+package models
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// Co-view tuning. Two videos are co-viewed when the same viewer (device or
+// signed in user, else IP address) watched both within CoViewWindow.
+const (
+	CoViewWindow   = 1 * time.Hour
+	CoViewLookback = 90 * 24 * time.Hour // Older views don't count
+
+	// minCoViewers drops pairs watched together by fewer viewers as noise
+	minCoViewers = 2
+	// maxSimilarVideos caps the similar videos stored per video
+	maxSimilarVideos = 30
+	// maxSessionViews caps the views compared per session, so a shared IP
+	// watching hundreds of videos doesn't pair all of them
+	maxSessionViews = 50
+)
+
+// Related video blend. Co-viewing dominates once a video has enough views;
+// shared tags, category and recency order the rest and stand in for it on
+// new or rarely watched videos.
+const (
+	relatedCoViewWeight    = 0.6
+	relatedTagWeight       = 0.2
+	relatedCategoryWeight  = 0.15
+	relatedRecencyWeight   = 0.05
+	relatedRecencyHalfLife = 30 * 24 * time.Hour
+
+	// relatedPoolSize is how many candidates per related video are scored
+	relatedPoolSize = 4
+)
+
+// SimilarVideo is a video often watched together with another one
+type SimilarVideo struct {
+	VideoID   int     `json:"videoId"`
+	Score     float64 `json:"score"`     // Cosine similarity of their viewers, 0-1
+	CoViewers int     `json:"coViewers"` // Viewers who watched both
+}
+
+type SimilarityRepository struct {
+	db *sql.DB
+}
+
+func NewSimilarityRepository(db *sql.DB) *SimilarityRepository {
+	return &SimilarityRepository{db: db}
+}
+
+// Rebuild recomputes video_similarity from the view logs since since and
+// returns how many similar video rows were stored
+func (r *SimilarityRepository) Rebuild(since time.Time) (int, error) {
+	rows, err := r.db.Query(
+		`SELECT COALESCE(viewer_id, ip_address), video_id, viewed_at FROM view_logs
+		 WHERE viewed_at >= ?
+		 ORDER BY COALESCE(viewer_id, ip_address), viewed_at, id`,
+		sqlTime(since),
+	)
+	if err != nil {
+		return 0, err
+	}
+	counter := newCoViewCounter(CoViewWindow)
+	for rows.Next() {
+		var viewer string
+		var videoID int
+		var viewedAt time.Time
+		if err := rows.Scan(&viewer, &videoID, &viewedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		counter.add(viewer, videoID, viewedAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	similar := counter.similar(minCoViewers, maxSimilarVideos)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM video_similarity"); err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare(
+		`INSERT INTO video_similarity (video_id, similar_video_id, score, co_viewers)
+		 SELECT ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM videos WHERE id = ?) AND EXISTS (SELECT 1 FROM videos WHERE id = ?)`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	stored := 0
+	for videoID, list := range similar {
+		for _, s := range list {
+			// Videos deleted since the views were logged are skipped
+			if _, err := stmt.Exec(videoID, s.VideoID, s.Score, s.CoViewers, videoID, s.VideoID); err != nil {
+				return 0, err
+			}
+			stored++
+		}
+	}
+
+	return stored, tx.Commit()
+}
+
+type videoPair struct {
+	a, b int // a < b
+}
+
+type timedView struct {
+	videoID int
+	at      time.Time
+}
+
+// coViewCounter counts, for every pair of videos, how many viewers watched
+// both within the window. Views must be added grouped by viewer and in time
+// order within each viewer.
+type coViewCounter struct {
+	window  time.Duration
+	viewers map[int]int       // Distinct viewers per video
+	pairs   map[videoPair]int // Distinct viewers per pair
+
+	// The viewer being added
+	viewer     string
+	recent     []timedView
+	seenVideos map[int]bool
+	seenPairs  map[videoPair]bool
+}
+
+func newCoViewCounter(window time.Duration) *coViewCounter {
+	return &coViewCounter{
+		window:  window,
+		viewers: make(map[int]int),
+		pairs:   make(map[videoPair]int),
+	}
+}
+
+func (c *coViewCounter) add(viewer string, videoID int, at time.Time) {
+	if viewer != c.viewer || c.seenVideos == nil {
+		c.viewer = viewer
+		c.recent = c.recent[:0]
+		c.seenVideos = make(map[int]bool)
+		c.seenPairs = make(map[videoPair]bool)
+	}
+
+	// Forget views that fell out of the window, and the oldest ones past the cap
+	keep := 0
+	for keep < len(c.recent) && (at.Sub(c.recent[keep].at) > c.window || len(c.recent)-keep >= maxSessionViews) {
+		keep++
+	}
+	c.recent = c.recent[keep:]
+
+	for _, v := range c.recent {
+		if v.videoID == videoID {
+			continue
+		}
+		p := videoPair{a: v.videoID, b: videoID}
+		if p.a > p.b {
+			p.a, p.b = p.b, p.a
+		}
+		if !c.seenPairs[p] {
+			c.seenPairs[p] = true
+			c.pairs[p]++
+		}
+	}
+
+	if !c.seenVideos[videoID] {
+		c.seenVideos[videoID] = true
+		c.viewers[videoID]++
+	}
+	c.recent = append(c.recent, timedView{videoID: videoID, at: at})
+}
+
+// similar returns each video's most similar videos, best first. Pairs watched
+// together by fewer than minViewers viewers are left out.
+func (c *coViewCounter) similar(minViewers, perVideo int) map[int][]SimilarVideo {
+	similar := make(map[int][]SimilarVideo)
+	for p, together := range c.pairs {
+		if together < minViewers {
+			continue
+		}
+		score := float64(together) / math.Sqrt(float64(c.viewers[p.a]*c.viewers[p.b]))
+		similar[p.a] = append(similar[p.a], SimilarVideo{VideoID: p.b, Score: score, CoViewers: together})
+		similar[p.b] = append(similar[p.b], SimilarVideo{VideoID: p.a, Score: score, CoViewers: together})
+	}
+
+	for id, list := range similar {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			if list[i].CoViewers != list[j].CoViewers {
+				return list[i].CoViewers > list[j].CoViewers
+			}
+			return list[i].VideoID < list[j].VideoID
+		})
+		if len(list) > perVideo {
+			similar[id] = list[:perVideo]
+		}
+	}
+	return similar
+}
+
+// relatedCandidate is a video that may be shown as related to another, with
+// the signals blended into its score
+type relatedCandidate struct {
+	video        Video
+	coView       float64 // video_similarity score, 0 without co-view data
+	sharedTags   int
+	sourceTags   int // Tags on the video being watched
+	sameCategory bool
+}
+
+func (c relatedCandidate) score(now time.Time) float64 {
+	score := relatedCoViewWeight * c.coView
+	if c.sourceTags > 0 {
+		score += relatedTagWeight * float64(c.sharedTags) / float64(c.sourceTags)
+	}
+	if c.sameCategory {
+		score += relatedCategoryWeight
+	}
+	if age := now.Sub(c.video.CreatedAt); age > 0 {
+		score += relatedRecencyWeight * math.Pow(0.5, float64(age)/float64(relatedRecencyHalfLife))
+	} else {
+		score += relatedRecencyWeight
+	}
+	return score
+}
+
+// rankRelated orders candidates by blended score, most viewed first on ties,
+// and returns the top limit videos
+func rankRelated(candidates []relatedCandidate, limit int, now time.Time) []Video {
+	scores := make([]float64, len(candidates))
+	order := make([]int, len(candidates))
+	for i, c := range candidates {
+		scores[i] = c.score(now)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return candidates[a].video.Views > candidates[b].video.Views
+	})
+
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	videos := make([]Video, len(order))
+	for i, idx := range order {
+		videos[i] = candidates[idx].video
+	}
+	return videos
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoViewCounter_PairsViewsWithinWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newCoViewCounter(time.Hour)
+
+	// Two viewers watch 1 then 2; one of them rewatches 1, which counts once
+	c.add("a", 1, start)
+	c.add("a", 2, start.Add(10*time.Minute))
+	c.add("a", 1, start.Add(20*time.Minute))
+	c.add("b", 1, start)
+	c.add("b", 2, start.Add(30*time.Minute))
+	// Too far apart to be one session
+	c.add("b", 3, start.Add(3*time.Hour))
+	c.add("c", 3, start)
+
+	similar := c.similar(2, 10)
+	require.Len(t, similar[1], 1)
+	assert.Equal(t, 2, similar[1][0].VideoID)
+	assert.Equal(t, 2, similar[1][0].CoViewers)
+	assert.InDelta(t, 1.0, similar[1][0].Score, 1e-9)
+	assert.Equal(t, 1, similar[2][0].VideoID)
+	assert.Empty(t, similar[3])
+}
+
+func TestCoViewCounter_NormalizesByPopularity(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newCoViewCounter(time.Hour)
+
+	// Video 9 is watched by everyone, video 2 only by those who watched 1
+	for _, viewer := range []string{"a", "b", "c", "d"} {
+		c.add(viewer, 9, start)
+		if viewer == "a" || viewer == "b" {
+			c.add(viewer, 1, start.Add(time.Minute))
+			c.add(viewer, 2, start.Add(2*time.Minute))
+		}
+	}
+
+	similar := c.similar(2, 10)
+	require.Len(t, similar[1], 2)
+	assert.Equal(t, 2, similar[1][0].VideoID)
+	assert.Equal(t, 9, similar[1][1].VideoID)
+	assert.Less(t, similar[1][1].Score, similar[1][0].Score)
+}
+
+func TestRankRelated_FallsBackToTagsAndCategory(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(-1, 0, 0)
+	candidates := []relatedCandidate{
+		{video: Video{ID: 1, Views: 500, CreatedAt: old}, sameCategory: true, sourceTags: 2},
+		{video: Video{ID: 2, Views: 10, CreatedAt: old}, sharedTags: 2, sourceTags: 2},
+		{video: Video{ID: 3, Views: 1, CreatedAt: old}, coView: 0.8, sourceTags: 2},
+		{video: Video{ID: 4, Views: 5, CreatedAt: now.AddDate(0, 0, -30)}, sameCategory: true, sourceTags: 2},
+	}
+
+	ids := func(videos []Video) []int {
+		out := make([]int, len(videos))
+		for i, v := range videos {
+			out[i] = v.ID
+		}
+		return out
+	}
+	assert.Equal(t, []int{3, 2, 4}, ids(rankRelated(candidates, 3, now)))
+
+	// Without co-view data, shared tags beat category, and newer videos win ties
+	withoutCoView := []relatedCandidate{candidates[0], candidates[1], candidates[3]}
+	assert.Equal(t, []int{2, 4, 1}, ids(rankRelated(withoutCoView, 6, now)))
+}
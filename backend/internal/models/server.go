@@ -2,7 +2,10 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 	"time"
+
+	"titan-backend/internal/utils"
 )
 
 type ServerStatus string
@@ -83,37 +86,48 @@ func (r *ServerLogRepository) Create(log *ServerLog) error {
 	return nil
 }
 
-func (r *ServerLogRepository) GetRecent(limit int) ([]ServerLog, error) {
-	rows, err := r.db.Query(
-		`SELECT id, level, message, source, timestamp FROM server_logs
-		 ORDER BY timestamp DESC LIMIT ?`,
-		limit,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// GetRecent returns the newest logs first. A cursor from a previous call's
+// metadata continues from there instead of starting at the newest.
+func (r *ServerLogRepository) GetRecent(limit int, cursor *utils.Cursor) ([]ServerLog, utils.PaginationMeta, error) {
+	return r.page("", limit, cursor)
+}
 
-	logs := []ServerLog{}
-	for rows.Next() {
-		var log ServerLog
-		if err := rows.Scan(&log.ID, &log.Level, &log.Message, &log.Source, &log.Timestamp); err != nil {
-			return nil, err
+// GetByLevel is GetRecent limited to one level
+func (r *ServerLogRepository) GetByLevel(level string, limit int, cursor *utils.Cursor) ([]ServerLog, utils.PaginationMeta, error) {
+	return r.page(level, limit, cursor)
+}
+
+// page fetches a keyset page of logs. Logs are only ever appended, so the ID
+// follows the timestamp and serves as the sort key.
+func (r *ServerLogRepository) page(level string, limit int, cursor *utils.Cursor) ([]ServerLog, utils.PaginationMeta, error) {
+	var conds []string
+	var args []interface{}
+	if level != "" {
+		conds = append(conds, "level = ?")
+		args = append(args, level)
+	}
+	order := "DESC"
+	if cursor != nil {
+		if cursor.Before {
+			conds = append(conds, "id > ?")
+			order = "ASC"
+		} else {
+			conds = append(conds, "id < ?")
 		}
-		logs = append(logs, log)
+		args = append(args, cursor.ID)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
 	}
 
-	return logs, nil
-}
-
-func (r *ServerLogRepository) GetByLevel(level string, limit int) ([]ServerLog, error) {
 	rows, err := r.db.Query(
-		`SELECT id, level, message, source, timestamp FROM server_logs
-		 WHERE level = ? ORDER BY timestamp DESC LIMIT ?`,
-		level, limit,
+		`SELECT id, level, message, source, timestamp FROM server_logs`+where+
+			` ORDER BY id `+order+` LIMIT ?`,
+		append(args, limit+1)...,
 	)
 	if err != nil {
-		return nil, err
+		return nil, utils.PaginationMeta{}, err
 	}
 	defer rows.Close()
 
@@ -121,12 +135,18 @@ func (r *ServerLogRepository) GetByLevel(level string, limit int) ([]ServerLog,
 	for rows.Next() {
 		var log ServerLog
 		if err := rows.Scan(&log.ID, &log.Level, &log.Message, &log.Source, &log.Timestamp); err != nil {
-			return nil, err
+			return nil, utils.PaginationMeta{}, err
 		}
 		logs = append(logs, log)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PaginationMeta{}, err
+	}
 
-	return logs, nil
+	logs, meta := utils.KeysetPage(logs, utils.PaginationParams{Page: 1, Limit: limit, Cursor: cursor}, -1, func(log *ServerLog) utils.Cursor {
+		return utils.Cursor{Sort: "id:desc", ID: log.ID}
+	})
+	return logs, meta, nil
 }
 
 func (r *ServerLogRepository) ClearOld(daysToKeep int) error {
@@ -2,14 +2,25 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 )
 
+// DefaultPublicStoragePaths are the /storage directories served without a
+// signed URL until an admin changes the setting
+var DefaultPublicStoragePaths = []string{"thumbnails"}
+
 type Settings struct {
 	SiteName        string `json:"siteName"`
 	SiteDescription string `json:"siteDescription"`
 	MaintenanceMode bool   `json:"maintenanceMode"`
 	AllowNewUploads bool   `json:"allowNewUploads"`
 	FeaturedVideoID string `json:"featuredVideoId"`
+
+	// New comments wait in the moderation queue until an admin approves them
+	CommentModeration bool `json:"commentModeration"`
+
+	// Directories under /storage (e.g. "thumbnails") that don't need signed URLs
+	PublicStoragePaths []string `json:"publicStoragePaths"`
 }
 
 type SettingsRepository struct {
@@ -27,7 +38,7 @@ func (r *SettingsRepository) GetAll() (*Settings, error) {
 	}
 	defer rows.Close()
 
-	settings := &Settings{}
+	settings := &Settings{PublicStoragePaths: DefaultPublicStoragePaths}
 	for rows.Next() {
 		var key, value string
 		if err := rows.Scan(&key, &value); err != nil {
@@ -45,6 +56,10 @@ func (r *SettingsRepository) GetAll() (*Settings, error) {
 			settings.AllowNewUploads = value == "true"
 		case "featured_video_id":
 			settings.FeaturedVideoID = value
+		case "comment_moderation":
+			settings.CommentModeration = value == "true"
+		case "public_storage_paths":
+			settings.PublicStoragePaths = splitList(value)
 		}
 	}
 
@@ -58,6 +73,8 @@ func (r *SettingsRepository) Update(settings *Settings) error {
 		"maintenance_mode": boolToString(settings.MaintenanceMode),
 		"allow_new_uploads": boolToString(settings.AllowNewUploads),
 		"featured_video_id": settings.FeaturedVideoID,
+		"comment_moderation": boolToString(settings.CommentModeration),
+		"public_storage_paths": strings.Join(settings.PublicStoragePaths, ","),
 	}
 
 	for key, value := range updates {
@@ -79,3 +96,14 @@ func boolToString(b bool) string {
 	}
 	return "false"
 }
+
+// splitList parses a comma-separated setting, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxVideoTags caps how many tags a video can have
+const MaxVideoTags = 20
+
+// ErrTooManyTags is returned when a tag change would exceed MaxVideoTags
+var ErrTooManyTags = fmt.Errorf("a video can have at most %d tags", MaxVideoTags)
+
+// Tag is a free-form label on videos. Tags are identified by their slug, so
+// "Machine Learning" and "machine-learning" are the same tag; the name is
+// kept as first written.
+type Tag struct {
+	ID         int       `json:"id"`
+	Slug       string    `json:"slug"`
+	Name       string    `json:"name"`
+	VideoCount int       `json:"videoCount,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// TagChange edits a video's tags. A non-nil Set replaces every tag; Add and
+// Remove apply after it. Names are matched by slug.
+type TagChange struct {
+	Set    []string
+	Add    []string
+	Remove []string
+}
+
+// Slugify normalizes a tag name to lowercase letters and digits, with runs of
+// anything else collapsed into single hyphens
+func Slugify(name string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+	return b.String()
+}
+
+const tagColumns = "t.id, t.slug, t.name, t.created_at"
+
+type TagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// List returns tags by how many published videos use them, most used first.
+// A prefix narrows it to tags whose slug starts with it, for autocomplete.
+// Unused tags are left out.
+func (r *TagRepository) List(prefix string, limit int) ([]Tag, error) {
+	where := ""
+	args := []interface{}{VideoStatusPublished}
+	if slug := Slugify(prefix); slug != "" {
+		where = " WHERE t.slug LIKE ?"
+		args = append(args, slug+"%")
+	}
+
+	rows, err := r.db.Query(
+		`SELECT `+tagColumns+`, COUNT(*) AS video_count
+		 FROM tags t JOIN video_tags vt ON vt.tag_id = t.id
+		 JOIN videos v ON v.id = vt.video_id AND v.status = ?`+where+`
+		 GROUP BY t.id, t.slug, t.name, t.created_at
+		 ORDER BY video_count DESC, t.slug ASC LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows, true)
+}
+
+// GetBySlug returns a tag with its published video count, or nil if it
+// doesn't exist
+func (r *TagRepository) GetBySlug(slug string) (*Tag, error) {
+	t := &Tag{}
+	err := r.db.QueryRow(
+		`SELECT `+tagColumns+`, COUNT(v.id)
+		 FROM tags t LEFT JOIN video_tags vt ON vt.tag_id = t.id
+		 LEFT JOIN videos v ON v.id = vt.video_id AND v.status = ?
+		 WHERE t.slug = ?
+		 GROUP BY t.id, t.slug, t.name, t.created_at`,
+		VideoStatusPublished, slug,
+	).Scan(&t.ID, &t.Slug, &t.Name, &t.CreatedAt, &t.VideoCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ForVideo returns a video's tags in name order
+func (r *TagRepository) ForVideo(videoID int) ([]Tag, error) {
+	return videoTags(r.db, videoID)
+}
+
+// ApplyToVideo makes a tag change, creating tags as needed, and returns the
+// video's tags afterwards. The tag names are copied onto the video so search
+// matches them.
+func (r *TagRepository) ApplyToVideo(videoID int, change TagChange) ([]Tag, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tags, err := applyTags(tx, videoID, change)
+	if err != nil {
+		return nil, err
+	}
+	return tags, tx.Commit()
+}
+
+// applyTags makes a tag change within tx, as ApplyToVideo does
+func applyTags(tx *sql.Tx, videoID int, change TagChange) ([]Tag, error) {
+	add := change.Add
+	if change.Set != nil {
+		if _, err := tx.Exec("DELETE FROM video_tags WHERE video_id = ?", videoID); err != nil {
+			return nil, err
+		}
+		add = append(append([]string{}, change.Set...), change.Add...)
+	}
+
+	for _, name := range add {
+		slug := Slugify(name)
+		if slug == "" {
+			continue
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO tags (slug, name) VALUES (?, ?) ON CONFLICT (slug) DO NOTHING",
+			slug, strings.TrimSpace(name),
+		); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO video_tags (video_id, tag_id) SELECT ?, id FROM tags WHERE slug = ?
+			 ON CONFLICT (video_id, tag_id) DO NOTHING`,
+			videoID, slug,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range change.Remove {
+		if _, err := tx.Exec(
+			"DELETE FROM video_tags WHERE video_id = ? AND tag_id IN (SELECT id FROM tags WHERE slug = ?)",
+			videoID, Slugify(name),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	tags, err := videoTags(tx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > MaxVideoTags {
+		return nil, ErrTooManyTags
+	}
+
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	if _, err := tx.Exec("UPDATE videos SET search_tags = ? WHERE id = ?", strings.Join(names, " "), videoID); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// tagQueryer is satisfied by both *sql.DB and *sql.Tx
+type tagQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func videoTags(q tagQueryer, videoID int) ([]Tag, error) {
+	rows, err := q.Query(
+		`SELECT `+tagColumns+` FROM tags t JOIN video_tags vt ON vt.tag_id = t.id
+		 WHERE vt.video_id = ? ORDER BY t.name ASC`,
+		videoID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows, false)
+}
+
+// scanTags reads tagColumns, followed by the video count if withCount is set,
+// and closes rows
+func scanTags(rows *sql.Rows, withCount bool) ([]Tag, error) {
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		dest := []interface{}{&t.ID, &t.Slug, &t.Name, &t.CreatedAt}
+		if withCount {
+			dest = append(dest, &t.VideoCount)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,213 @@
+package models_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "machine-learning", models.Slugify("  Machine Learning "))
+	assert.Equal(t, "c-tips", models.Slugify("C++ -- tips!"))
+	assert.Equal(t, "café-2024", models.Slugify("Café_2024"))
+	assert.Equal(t, "", models.Slugify("#!?"))
+}
+
+func tagSlugs(tags []models.Tag) []string {
+	slugs := []string{}
+	for _, t := range tags {
+		slugs = append(slugs, t.Slug)
+	}
+	return slugs
+}
+
+func TestTag_UpdateWithTags(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	tags := models.NewTagRepository(db)
+	ids := createVideos(t, videos, &models.Video{Title: "Clip", Creator: "Ann"})
+
+	video, err := videos.GetByID(ids[0])
+	require.NoError(t, err)
+	video.Title = "Renamed"
+	require.NoError(t, videos.UpdateWithTags(video, models.TagChange{Set: []string{"Travel"}}))
+	assert.Equal(t, []string{"travel"}, tagSlugs(video.Tags))
+
+	// A change leaving too many tags saves neither the tags nor the details
+	tooMany := []string{}
+	for i := 0; i < models.MaxVideoTags; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("tag %d", i))
+	}
+	video.Title = "Lost"
+	err = videos.UpdateWithTags(video, models.TagChange{Add: tooMany})
+	assert.ErrorIs(t, err, models.ErrTooManyTags)
+
+	saved, err := videos.GetByID(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", saved.Title)
+	current, err := tags.ForVideo(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"travel"}, tagSlugs(current))
+
+	// Without a change the tags are left as they are
+	video.Title = "Kept"
+	require.NoError(t, videos.UpdateWithTags(video, models.TagChange{}))
+	assert.Equal(t, []string{"travel"}, tagSlugs(video.Tags))
+}
+
+func TestTag_ApplyToVideo(t *testing.T) {
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db), &models.Video{Title: "Clip", Creator: "Ann"})
+	repo := models.NewTagRepository(db)
+
+	apply := func(change models.TagChange, want ...string) {
+		t.Helper()
+		want = append([]string{}, want...)
+		tags, err := repo.ApplyToVideo(ids[0], change)
+		require.NoError(t, err)
+		assert.Equal(t, want, tagSlugs(tags))
+		stored, err := repo.ForVideo(ids[0])
+		require.NoError(t, err)
+		assert.Equal(t, want, tagSlugs(stored))
+	}
+
+	// Spellings of one slug are one tag, named as first written
+	apply(models.TagChange{Set: []string{"Road Trip", "road-trip", "Food", "!!"}}, "food", "road-trip")
+	tag, err := repo.GetBySlug("road-trip")
+	require.NoError(t, err)
+	require.NotNil(t, tag)
+	assert.Equal(t, "Road Trip", tag.Name)
+
+	apply(models.TagChange{Add: []string{"FOOD", "Hiking"}}, "food", "hiking", "road-trip")
+	apply(models.TagChange{Remove: []string{"road trip", "unknown"}}, "food", "hiking")
+
+	// Set replaces everything, then Add and Remove apply
+	apply(models.TagChange{Set: []string{"Beach"}, Add: []string{"Sunset"}, Remove: []string{"beach"}}, "sunset")
+	apply(models.TagChange{Set: []string{}})
+
+	// A change that would leave too many tags is rejected whole
+	apply(models.TagChange{Set: []string{"Kept"}}, "kept")
+	tooMany := []string{}
+	for i := 0; i < models.MaxVideoTags; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("tag %d", i))
+	}
+	_, err = repo.ApplyToVideo(ids[0], models.TagChange{Add: tooMany})
+	assert.ErrorIs(t, err, models.ErrTooManyTags)
+	stored, err := repo.ForVideo(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kept"}, tagSlugs(stored))
+
+	// Up to the limit is fine
+	apply(models.TagChange{Set: tooMany[1:]}, tagSlugsSorted(tooMany[1:])...)
+}
+
+// tagSlugsSorted slugifies names in the name order ForVideo lists them in
+func tagSlugsSorted(names []string) []string {
+	sorted := append([]string{}, names...)
+	slices.Sort(sorted)
+	slugs := make([]string, len(sorted))
+	for i, name := range sorted {
+		slugs[i] = models.Slugify(name)
+	}
+	return slugs
+}
+
+func TestTag_ListAndCounts(t *testing.T) {
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db),
+		&models.Video{Title: "One", Creator: "Ann"},
+		&models.Video{Title: "Two", Creator: "Ann"},
+		&models.Video{Title: "Three", Creator: "Ann"},
+		&models.Video{Title: "Draft", Creator: "Ann", Status: models.VideoStatusDraft},
+	)
+	repo := models.NewTagRepository(db)
+	for i, names := range [][]string{
+		{"Hiking", "Food"},
+		{"Hiking", "History"},
+		{"Hiking", "Hats"},
+		{"Food", "Food", "Food history", "Unused"},
+	} {
+		_, err := repo.ApplyToVideo(ids[i], models.TagChange{Set: names})
+		require.NoError(t, err)
+	}
+
+	counts := func(tags []models.Tag) map[string]int {
+		m := map[string]int{}
+		for _, t := range tags {
+			m[t.Slug] = t.VideoCount
+		}
+		return m
+	}
+
+	// Most used first, then by slug; tags only on unpublished videos are left out
+	tags, err := repo.List("", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hiking", "food", "hats", "history"}, tagSlugs(tags))
+	assert.Equal(t, map[string]int{"hiking": 3, "food": 1, "hats": 1, "history": 1}, counts(tags))
+
+	tags, err = repo.List("", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hiking", "food"}, tagSlugs(tags))
+
+	// Autocomplete matches the slug prefix of what was typed
+	tags, err = repo.List("H", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hiking", "hats", "history"}, tagSlugs(tags))
+	tags, err = repo.List("Hi", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hiking", "history"}, tagSlugs(tags))
+	tags, err = repo.List("food h", 10)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	// A tag page counts published videos, and unused tags still resolve
+	tag, err := repo.GetBySlug("food")
+	require.NoError(t, err)
+	require.NotNil(t, tag)
+	assert.Equal(t, 1, tag.VideoCount)
+	tag, err = repo.GetBySlug("unused")
+	require.NoError(t, err)
+	require.NotNil(t, tag)
+	assert.Zero(t, tag.VideoCount)
+	tag, err = repo.GetBySlug("missing")
+	require.NoError(t, err)
+	assert.Nil(t, tag)
+}
+
+func TestTag_SearchFollowsTagChanges(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	videos.SetSearchEngine(models.NewVideoSearchEngine(db, "sqlite3"))
+	ids := createVideos(t, videos, &models.Video{Title: "Clip", Creator: "Ann"}, &models.Video{Title: "Other", Creator: "Bob"})
+	repo := models.NewTagRepository(db)
+
+	search := func(text string) []int {
+		t.Helper()
+		results, _, err := videos.Search(text, models.VideoQuery{PaginationParams: firstPage})
+		require.NoError(t, err)
+		found := []int{}
+		for _, r := range results {
+			found = append(found, r.ID)
+		}
+		return found
+	}
+
+	assert.Empty(t, search("snorkeling"))
+	_, err := repo.ApplyToVideo(ids[0], models.TagChange{Add: []string{"Snorkeling"}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[0]}, search("snorkeling"))
+
+	_, err = repo.ApplyToVideo(ids[0], models.TagChange{Set: []string{"Diving"}})
+	require.NoError(t, err)
+	assert.Empty(t, search("snorkeling"))
+	assert.Equal(t, []int{ids[0]}, search("diving"))
+
+	_, err = repo.ApplyToVideo(ids[0], models.TagChange{Remove: []string{"diving"}})
+	require.NoError(t, err)
+	assert.Empty(t, search("diving"))
+}
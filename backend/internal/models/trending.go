@@ -0,0 +1,128 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Trending tuning. Views are counted in hourly buckets and each bucket is
+// decayed Hacker News style, views / (hoursAgo + 2)^gravity, so a burst of
+// recent views outranks a larger but older total.
+const (
+	TrendingLookback = 7 * 24 * time.Hour // Older views don't count
+	trendingGravity  = 1.8
+)
+
+// CategoryTrending is the top trending videos of one category
+type CategoryTrending struct {
+	Category string  `json:"category"`
+	Videos   []Video `json:"videos"`
+}
+
+// trendingScore decays hourly view counts, hourly[0] being the last hour
+func trendingScore(hourly []int) float64 {
+	score := 0.0
+	for hoursAgo, views := range hourly {
+		if views > 0 {
+			score += float64(views) / math.Pow(float64(hoursAgo+2), trendingGravity)
+		}
+	}
+	return score
+}
+
+// RefreshTrending recomputes every video's trending_score from the view logs
+// and returns how many videos are trending
+func (r *VideoRepository) RefreshTrending(now time.Time) (int, error) {
+	buckets := int(TrendingLookback / time.Hour)
+	rows, err := r.db.Query("SELECT video_id, viewed_at FROM view_logs WHERE viewed_at >= ?", sqlTime(now.Add(-TrendingLookback)))
+	if err != nil {
+		return 0, err
+	}
+	hourly := make(map[int][]int)
+	for rows.Next() {
+		var videoID int
+		var viewedAt time.Time
+		if err := rows.Scan(&videoID, &viewedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hoursAgo := int(now.Sub(viewedAt) / time.Hour)
+		if hoursAgo < 0 {
+			hoursAgo = 0
+		}
+		if hoursAgo >= buckets {
+			continue
+		}
+		if hourly[videoID] == nil {
+			hourly[videoID] = make([]int, buckets)
+		}
+		hourly[videoID][hoursAgo]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE videos SET trending_score = 0 WHERE trending_score != 0"); err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare("UPDATE videos SET trending_score = ? WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for videoID, counts := range hourly {
+		if _, err := stmt.Exec(trendingScore(counts), videoID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(hourly), tx.Commit()
+}
+
+// TrendingByCategory returns the top perCategory trending videos of each
+// category matching f, categories ordered by their top video
+func (r *VideoRepository) TrendingByCategory(f VideoFilter, perCategory int) ([]CategoryTrending, error) {
+	b := &videoQueryBuilder{}
+	f.Trending = true
+	b.filter(f, "")
+
+	rows, err := r.db.Query(
+		`SELECT `+videoColumns+` FROM `+videoSource+` WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY category ORDER BY trending_score DESC, id DESC) AS position
+				FROM `+videoSource+b.clause()+`
+			) ranked WHERE position <= ?
+		 ) ORDER BY trending_score DESC, id DESC`,
+		append(b.args, perCategory)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []CategoryTrending{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var v Video
+		if err := scanVideo(rows, &v); err != nil {
+			return nil, err
+		}
+		i, ok := index[v.Category]
+		if !ok {
+			i = len(categories)
+			index[v.Category] = i
+			categories = append(categories, CategoryTrending{Category: v.Category})
+		}
+		categories[i].Videos = append(categories[i].Videos, v)
+	}
+
+	return categories, rows.Err()
+}
@@ -0,0 +1,75 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func TestTrending_RefreshFromViewLogs(t *testing.T) {
+	db := newTestDB(t)
+	repo := models.NewVideoRepository(db)
+	categories := models.NewCategoryRepository(db)
+	require.NoError(t, categories.Create(&models.Category{ID: "music", Name: "Music", Icon: "🎵"}))
+	ids := createVideos(t, repo,
+		&models.Video{Title: "Burst", Creator: "Ann"},
+		&models.Video{Title: "Steady", Creator: "Ann", Category: "music"},
+		&models.Video{Title: "Fading", Creator: "Ann"},
+		&models.Video{Title: "Stale", Creator: "Ann"},
+		&models.Video{Title: "Unwatched", Creator: "Ann"},
+	)
+	burst, steady, fading, stale := ids[0], ids[1], ids[2], ids[3]
+
+	now := time.Now().UTC().Truncate(time.Second)
+	logViews := func(videoID, views int, ago time.Duration) {
+		t.Helper()
+		for i := 0; i < views; i++ {
+			_, err := db.Exec(
+				"INSERT INTO view_logs (video_id, ip_address, user_agent, viewed_at) VALUES (?, '10.0.0.1', '', ?)",
+				videoID, now.Add(-ago).Format("2006-01-02 15:04:05"),
+			)
+			require.NoError(t, err)
+		}
+	}
+	logViews(burst, 10, 30*time.Minute)
+	logViews(steady, 120, 30*time.Hour)
+	logViews(fading, 1, models.TrendingLookback-time.Hour)
+	logViews(stale, 500, models.TrendingLookback+time.Hour)
+
+	trending := func() []int {
+		t.Helper()
+		videos, _, err := repo.GetAll(models.VideoQuery{
+			VideoFilter:      models.VideoFilter{Trending: true},
+			PaginationParams: firstPage,
+			Sort:             "trending",
+			Order:            "desc",
+		})
+		require.NoError(t, err)
+		return videoIDs(videos)
+	}
+
+	// Recent views outrank a larger older total; views past the lookback
+	// don't count at all
+	n, err := repo.RefreshTrending(now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []int{burst, steady, fading}, trending())
+
+	byCategory, err := repo.TrendingByCategory(models.VideoFilter{}, 1)
+	require.NoError(t, err)
+	require.Len(t, byCategory, 2)
+	assert.Equal(t, "other", byCategory[0].Category)
+	assert.Equal(t, []int{burst}, videoIDs(byCategory[0].Videos))
+	assert.Equal(t, "music", byCategory[1].Category)
+	assert.Equal(t, []int{steady}, videoIDs(byCategory[1].Videos))
+
+	// Two hours on, the fading video's only view has left the window
+	n, err = repo.RefreshTrending(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int{burst, steady}, trending())
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrendingScore_RecentViewsOutweighOlderTotals(t *testing.T) {
+	burst := make([]int, 48)
+	burst[0] = 10
+
+	steady := make([]int, 48)
+	for h := 24; h < 48; h++ {
+		steady[h] = 5 // 120 views, a day ago
+	}
+
+	assert.Greater(t, trendingScore(burst), trendingScore(steady))
+	assert.Zero(t, trendingScore(make([]int, 48)))
+}
@@ -2,13 +2,73 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"titan-backend/internal/mediaprobe"
+	"titan-backend/internal/utils"
+)
+
+// Video statuses. Published videos are listed publicly and unlisted ones can
+// be watched by anyone with the link; drafts, scheduled and private videos
+// are only visible to admins. Scheduled videos are published at PublishAt by
+// a background job.
+const (
+	VideoStatusDraft     = "draft"
+	VideoStatusScheduled = "scheduled"
+	VideoStatusUnlisted  = "unlisted"
+	VideoStatusPrivate   = "private"
+	VideoStatusPublished = "published"
 )
 
+// VideoStatuses lists the valid statuses
+var VideoStatuses = []string{
+	VideoStatusDraft, VideoStatusScheduled, VideoStatusUnlisted, VideoStatusPrivate, VideoStatusPublished,
+}
+
+// ParseVideoStatus validates a requested status and publish time. A publish
+// time alone schedules the video; scheduling needs a time in the future.
+// An empty status keeps current.
+func ParseVideoStatus(status, publishAt, current string) (string, *time.Time, error) {
+	if status == "" && publishAt != "" {
+		status = VideoStatusScheduled
+	}
+	if status == "" {
+		status = current
+	}
+	if !slices.Contains(VideoStatuses, status) {
+		return "", nil, fmt.Errorf("status must be one of: %s", strings.Join(VideoStatuses, ", "))
+	}
+
+	if status != VideoStatusScheduled {
+		if publishAt != "" {
+			return "", nil, errors.New("publishAt only applies to scheduled videos")
+		}
+		return status, nil, nil
+	}
+	if publishAt == "" {
+		return "", nil, errors.New("publishAt is required to schedule a video")
+	}
+	t, err := time.Parse(time.RFC3339, publishAt)
+	if err != nil {
+		return "", nil, errors.New("publishAt must be an RFC 3339 time")
+	}
+	if !t.After(time.Now()) {
+		return "", nil, errors.New("publishAt must be in the future")
+	}
+	return status, &t, nil
+}
+
 type Video struct {
 	ID          int       `json:"id"`
 	Title       string    `json:"title"`
 	Creator     string    `json:"creator"`
+	CreatorID   int       `json:"creatorId,omitempty"`
+	CreatorSlug string    `json:"creatorSlug,omitempty"` // Links to the creator's profile
 	URL         string    `json:"url"`
 	Thumbnail   string    `json:"thumbnail,omitempty"`
 	Views       int       `json:"views"`
@@ -17,99 +77,185 @@ type Video struct {
 	Category    string    `json:"category"`
 	Duration    string    `json:"duration,omitempty"`
 	Description string    `json:"description,omitempty"`
-	Verified    bool      `json:"verified"`
+	Verified    bool      `json:"verified"` // The creator's, see Creator
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
-}
 
-type VideoRepository struct {
-	db *sql.DB
-}
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publishAt,omitempty"` // When a scheduled video goes public
 
-func NewVideoRepository(db *sql.DB) *VideoRepository {
-	return &VideoRepository{db: db}
+	// Technical metadata filled in by mediaprobe on upload
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	VideoCodec      string  `json:"videoCodec,omitempty"`
+	AudioCodec      string  `json:"audioCodec,omitempty"`
+	Bitrate         int64   `json:"bitrate,omitempty"`
+	FileSize        int64   `json:"fileSize,omitempty"`
+
+	HLSURL string `json:"hlsUrl,omitempty"` // Set once HLS packaging has finished
+
+	ExternalID string `json:"externalId,omitempty"` // ID in the catalog the video was imported from
+
+	TrendingScore float64 `json:"trendingScore,omitempty"` // Refreshed from recent views, see RefreshTrending
+
+	// Filled in by handlers that show a single video
+	Tags     []Tag     `json:"tags,omitempty"`
+	Captions []Caption `json:"captions,omitempty"`
+	Chapters []Chapter `json:"chapters,omitempty"`
 }
 
-func (r *VideoRepository) GetAll(page, limit int, sort, order, category string) ([]Video, int, error) {
-	offset := (page - 1) * limit
+// IsViewable reports whether anyone with a link to the video may watch it
+func (v *Video) IsViewable() bool {
+	return v.Status == VideoStatusPublished || v.Status == VideoStatusUnlisted
+}
 
-	// Count total
-	countQuery := "SELECT COUNT(*) FROM videos"
-	args := []interface{}{}
+// IsAudioOnly reports whether the probed file has sound but no picture, like
+// a podcast episode uploaded as .mp4
+func (v *Video) IsAudioOnly() bool {
+	return v.VideoCodec == "" && v.AudioCodec != ""
+}
 
-	if category != "" {
-		countQuery += " WHERE category = ?"
-		args = append(args, category)
+// ApplyMediaInfo copies probed container metadata onto the video.
+// The human-readable duration is only filled in if the uploader left it blank.
+func (v *Video) ApplyMediaInfo(info *mediaprobe.Info) {
+	if info == nil {
+		return
 	}
+	if info.Duration > 0 {
+		v.DurationSeconds = info.Duration
+	}
+	v.Width = info.Width
+	v.Height = info.Height
+	v.VideoCodec = info.VideoCodec
+	v.AudioCodec = info.AudioCodec
+	v.Bitrate = info.Bitrate
+	v.FileSize = info.Size
+	if v.Duration == "" && info.Duration > 0 {
+		v.Duration = mediaprobe.FormatDuration(info.Duration)
+	}
+}
 
-	var total int
-	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
+// SetDuration sets the human-readable duration. Unless the file was probed,
+// which gives the exact length, it is also parsed into DurationSeconds for
+// filtering and sorting.
+func (v *Video) SetDuration(text string) {
+	v.Duration = text
+	if v.VideoCodec == "" && v.AudioCodec == "" {
+		v.DurationSeconds, _ = mediaprobe.ParseDuration(text)
 	}
+}
 
-	// Get videos
-	query := `SELECT id, title, creator, url, thumbnail, views, likes, dislikes,
-			  category, duration, description, verified, created_at, updated_at
-			  FROM videos`
+// videoSource stands in for the videos table in queries reading
+// videoColumns: each video joined with its creator's slug and verified flag.
+// It keeps the name videos, so filters and joins written against the table
+// work unchanged.
+const videoSource = `(SELECT videos.*, creators.slug AS creator_slug, creators.verified AS verified
+	FROM videos LEFT JOIN creators ON creators.id = videos.creator_id) videos`
+
+// videoColumns is the column list matching scanVideo. Queries select it
+// from videoSource.
+const videoColumns = `id, title, creator, COALESCE(creator_id, 0), COALESCE(creator_slug, ''),
+	url, thumbnail, views, likes, dislikes,
+	category, duration, description, COALESCE(verified, 0), created_at, updated_at,
+	COALESCE(duration_seconds, 0) AS duration_seconds, COALESCE(width, 0), COALESCE(height, 0),
+	COALESCE(video_codec, ''), COALESCE(audio_codec, ''), COALESCE(bitrate, 0), COALESCE(file_size, 0),
+	COALESCE(hls_url, ''), COALESCE(trending_score, 0) AS trending_score,
+	COALESCE(status, 'published') AS status, publish_at, COALESCE(external_id, '')`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	if category != "" {
-		query += " WHERE category = ?"
-	}
+// videoScan holds the videoColumns that need converting after the scan
+type videoScan struct {
+	verified  int
+	publishAt sql.NullTime
+}
+
+// videoDest returns the scan destinations for videoColumns
+func videoDest(v *Video, extra *videoScan) []interface{} {
+	return []interface{}{&v.ID, &v.Title, &v.Creator, &v.CreatorID, &v.CreatorSlug, &v.URL, &v.Thumbnail, &v.Views,
+		&v.Likes, &v.Dislikes, &v.Category, &v.Duration, &v.Description,
+		&extra.verified, &v.CreatedAt, &v.UpdatedAt,
+		&v.DurationSeconds, &v.Width, &v.Height, &v.VideoCodec, &v.AudioCodec, &v.Bitrate, &v.FileSize,
+		&v.HLSURL, &v.TrendingScore, &v.Status, &extra.publishAt, &v.ExternalID}
+}
 
-	// Validate sort column
-	validSorts := map[string]bool{"views": true, "created_at": true, "title": true}
-	if !validSorts[sort] {
-		sort = "created_at"
+// apply copies the converted columns onto the video
+func (s *videoScan) apply(v *Video) {
+	v.Verified = s.verified == 1
+	if s.publishAt.Valid {
+		v.PublishAt = &s.publishAt.Time
 	}
+}
 
-	// Validate order
-	if order != "asc" && order != "desc" {
-		order = "desc"
+func scanVideo(row rowScanner, v *Video) error {
+	var extra videoScan
+	if err := row.Scan(videoDest(v, &extra)...); err != nil {
+		return err
 	}
+	extra.apply(v)
+	return nil
+}
+
+type VideoRepository struct {
+	db     *sql.DB
+	search VideoSearchEngine
+}
+
+func NewVideoRepository(db *sql.DB) *VideoRepository {
+	return &VideoRepository{db: db, search: &likeSearch{db: db}}
+}
+
+// SetSearchEngine replaces the default LIKE search, normally with the result
+// of NewVideoSearchEngine
+func (r *VideoRepository) SetSearchEngine(engine VideoSearchEngine) {
+	r.search = engine
+}
 
-	query += " ORDER BY " + sort + " " + order
-	query += " LIMIT ? OFFSET ?"
+// GetAll returns a filtered, sorted page of videos, newest first by default.
+// Offset pages are counted; cursor pages aren't.
+func (r *VideoRepository) GetAll(q VideoQuery) ([]Video, utils.PaginationMeta, error) {
+	b := &videoQueryBuilder{}
+	b.filter(q.VideoFilter, "")
 
-	if category != "" {
-		args = append(args, limit, offset)
-	} else {
-		args = []interface{}{limit, offset}
+	total := -1
+	if q.Cursor == nil {
+		if err := r.db.QueryRow("SELECT COUNT(*) FROM "+videoSource+b.clause(), b.args...).Scan(&total); err != nil {
+			return nil, utils.PaginationMeta{}, err
+		}
 	}
 
+	query, args := pageVideos("SELECT "+videoColumns+" FROM "+videoSource+b.clause(), b.args, q, "created_at")
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, utils.PaginationMeta{}, err
 	}
 	defer rows.Close()
 
 	videos := []Video{}
 	for rows.Next() {
 		var v Video
-		var verified int
-		err := rows.Scan(&v.ID, &v.Title, &v.Creator, &v.URL, &v.Thumbnail, &v.Views,
-			&v.Likes, &v.Dislikes, &v.Category, &v.Duration, &v.Description,
-			&verified, &v.CreatedAt, &v.UpdatedAt)
-		if err != nil {
-			return nil, 0, err
+		if err := scanVideo(rows, &v); err != nil {
+			return nil, utils.PaginationMeta{}, err
 		}
-		v.Verified = verified == 1
 		videos = append(videos, v)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.PaginationMeta{}, err
+	}
 
-	return videos, total, nil
+	videos, meta := utils.KeysetPage(videos, q.PaginationParams, total, func(v *Video) utils.Cursor {
+		return videoCursor(q, "created_at", v, 0)
+	})
+	return videos, meta, nil
 }
 
 func (r *VideoRepository) GetByID(id int) (*Video, error) {
 	v := &Video{}
-	var verified int
-	err := r.db.QueryRow(
-		`SELECT id, title, creator, url, thumbnail, views, likes, dislikes,
-		 category, duration, description, verified, created_at, updated_at
-		 FROM videos WHERE id = ?`,
-		id,
-	).Scan(&v.ID, &v.Title, &v.Creator, &v.URL, &v.Thumbnail, &v.Views,
-		&v.Likes, &v.Dislikes, &v.Category, &v.Duration, &v.Description,
-		&verified, &v.CreatedAt, &v.UpdatedAt)
+	err := scanVideo(r.db.QueryRow("SELECT "+videoColumns+" FROM "+videoSource+" WHERE id = ?", id), v)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -117,20 +263,101 @@ func (r *VideoRepository) GetByID(id int) (*Video, error) {
 	if err != nil {
 		return nil, err
 	}
-	v.Verified = verified == 1
 	return v, nil
 }
 
+// GetByIDs loads the given videos keyed by ID. Missing IDs are left out.
+func (r *VideoRepository) GetByIDs(ids []int) (map[int]*Video, error) {
+	videos := make(map[int]*Video, len(ids))
+	if len(ids) == 0 {
+		return videos, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := r.db.Query("SELECT "+videoColumns+" FROM "+videoSource+" WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v := &Video{}
+		if err := scanVideo(rows, v); err != nil {
+			return nil, err
+		}
+		videos[v.ID] = v
+	}
+	return videos, rows.Err()
+}
+
+// publishAtValue is the publish_at column value; only scheduled videos keep one
+func (v *Video) publishAtValue() interface{} {
+	if v.Status != VideoStatusScheduled || v.PublishAt == nil {
+		return nil
+	}
+	return sqlTime(*v.PublishAt)
+}
+
+// externalIDValue is the external_id column value; videos that weren't
+// imported store NULL, which the unique index ignores
+func (v *Video) externalIDValue() interface{} {
+	if v.ExternalID == "" {
+		return nil
+	}
+	return v.ExternalID
+}
+
+// Create inserts a video, creating its creator if they're new
 func (r *VideoRepository) Create(v *Video) error {
-	verified := 0
-	if v.Verified {
-		verified = 1
+	return r.CreateBatch([]*Video{v})
+}
+
+// CreateBatch inserts videos in one transaction, so either all of them are
+// created or none are
+func (r *VideoRepository) CreateBatch(videos []*Video) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	result, err := r.db.Exec(
-		`INSERT INTO videos (title, creator, url, thumbnail, category, duration, description, verified)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		v.Title, v.Creator, v.URL, v.Thumbnail, v.Category, v.Duration, v.Description, verified,
+	for _, v := range videos {
+		if err := insertVideo(tx, v); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, v := range videos {
+		v.CreatedAt = now
+		v.UpdatedAt = now
+	}
+	return nil
+}
+
+func insertVideo(tx *sql.Tx, v *Video) error {
+	if v.Status == "" {
+		v.Status = VideoStatusPublished
+	}
+	if err := ensureCreator(tx, v); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO videos (title, creator, creator_id, url, thumbnail, category, duration, description,
+		 duration_seconds, width, height, video_codec, audio_codec, bitrate, file_size, status, publish_at, external_id)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.Title, v.Creator, v.CreatorID, v.URL, v.Thumbnail, v.Category, v.Duration, v.Description,
+		v.DurationSeconds, v.Width, v.Height, v.VideoCodec, v.AudioCodec, v.Bitrate, v.FileSize,
+		v.Status, v.publishAtValue(), v.externalIDValue(),
 	)
 	if err != nil {
 		return err
@@ -141,111 +368,338 @@ func (r *VideoRepository) Create(v *Video) error {
 		return err
 	}
 	v.ID = int(id)
-	v.CreatedAt = time.Now()
-	v.UpdatedAt = time.Now()
 	return nil
 }
 
+// ExternalIDs returns the IDs of the videos imported with any of externalIDs,
+// keyed by external ID
+func (r *VideoRepository) ExternalIDs(externalIDs []string) (map[string]int, error) {
+	found := make(map[string]int)
+	if len(externalIDs) == 0 {
+		return found, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(externalIDs)), ",")
+	args := make([]interface{}, len(externalIDs))
+	for i, id := range externalIDs {
+		args[i] = id
+	}
+	rows, err := r.db.Query("SELECT external_id, id FROM videos WHERE external_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var externalID string
+		var id int
+		if err := rows.Scan(&externalID, &id); err != nil {
+			return nil, err
+		}
+		found[externalID] = id
+	}
+	return found, rows.Err()
+}
+
+// Update saves a video's details. v.Creator is matched to a creator by slug,
+// creating one if needed.
 func (r *VideoRepository) Update(v *Video) error {
-	verified := 0
-	if v.Verified {
-		verified = 1
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateVideo(tx, v); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateWithTags saves a video's details and makes a tag change, as Update
+// and TagRepository.ApplyToVideo do, in one transaction: a change that would
+// leave too many tags saves nothing. v.Tags is set to the tags afterwards.
+func (r *VideoRepository) UpdateWithTags(v *Video, change TagChange) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateVideo(tx, v); err != nil {
+		return err
+	}
+	tags, err := applyTags(tx, v.ID, change)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
+	v.Tags = tags
+	return nil
+}
 
-	_, err := r.db.Exec(
-		`UPDATE videos SET title = ?, creator = ?, category = ?, duration = ?,
-		 description = ?, verified = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		v.Title, v.Creator, v.Category, v.Duration, v.Description, verified, v.ID,
+func updateVideo(tx *sql.Tx, v *Video) error {
+	if err := ensureCreator(tx, v); err != nil {
+		return err
+	}
+	_, err := tx.Exec(
+		`UPDATE videos SET title = ?, creator = ?, creator_id = ?, category = ?, duration = ?, duration_seconds = ?,
+		 description = ?, status = ?, publish_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		v.Title, v.Creator, v.CreatorID, v.Category, v.Duration, v.DurationSeconds, v.Description,
+		v.Status, v.publishAtValue(), v.ID,
 	)
 	return err
 }
 
+// PublishScheduled publishes the scheduled videos whose time has come and
+// returns how many there were
+func (r *VideoRepository) PublishScheduled(now time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		`UPDATE videos SET status = ?, publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE status = ? AND publish_at <= ?`,
+		VideoStatusPublished, VideoStatusScheduled, sqlTime(now),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SetHLSURL records the playlist produced by HLS packaging of sourceURL. It
+// reports false, leaving the video alone, if its media was replaced while
+// packaging ran.
+func (r *VideoRepository) SetHLSURL(id int, sourceURL, hlsURL string) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE videos SET hls_url = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND url = ?",
+		hlsURL, id, sourceURL,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
 func (r *VideoRepository) Delete(id int) error {
 	_, err := r.db.Exec("DELETE FROM videos WHERE id = ?", id)
 	return err
 }
 
-func (r *VideoRepository) Search(query, category string, page, limit int) ([]Video, int, error) {
-	offset := (page - 1) * limit
-	searchPattern := "%" + query + "%"
+// Search finds videos matching a free-text query, best matches first unless
+// another sort is asked for
+func (r *VideoRepository) Search(text string, q VideoQuery) ([]SearchResult, utils.PaginationMeta, error) {
+	results, total := []SearchResult{}, 0
+	terms := searchTerms(text)
+	if len(terms) > 0 {
+		var err error
+		if results, total, err = r.search.Search(terms, q); err != nil {
+			return nil, utils.PaginationMeta{}, err
+		}
+	}
+
+	results, meta := utils.KeysetPage(results, q.PaginationParams, total, func(res *SearchResult) utils.Cursor {
+		return videoCursor(q, SortRelevance, &res.Video, res.Score)
+	})
+	if err := attachCaptionMatches(r.db, results, terms); err != nil {
+		return nil, utils.PaginationMeta{}, err
+	}
+	return results, meta, nil
+}
+
+// SearchFacets counts the videos matching a search per facet value
+func (r *VideoRepository) SearchFacets(text string, f VideoFilter) (*VideoFacets, error) {
+	facets := &VideoFacets{
+		Category: []FacetCount{},
+		Creator:  []FacetCount{},
+		Verified: []FacetCount{},
+		Duration: []FacetCount{},
+		Uploaded: []FacetCount{},
+	}
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return facets, nil
+	}
 
-	// Count total
-	countQuery := `SELECT COUNT(*) FROM videos
-				   WHERE (title LIKE ? OR creator LIKE ? OR description LIKE ?)`
-	args := []interface{}{searchPattern, searchPattern, searchPattern}
+	// Each facet matches the search and every filter but its own
+	builder := func(skip string) *videoQueryBuilder {
+		b := &videoQueryBuilder{}
+		cond, args := r.search.matchCondition(terms)
+		b.where(cond, args...)
+		b.filter(f, skip)
+		return b
+	}
+	var err error
 
-	if category != "" {
-		countQuery += " AND category = ?"
-		args = append(args, category)
+	b := builder(FacetCategory)
+	if facets.Category, err = r.countGroups("category", b, 0); err != nil {
+		return nil, err
 	}
 
-	var total int
-	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
+	b = builder(FacetCreator)
+	if facets.Creator, err = r.countGroups("creator", b, maxCreatorFacets); err != nil {
+		return nil, err
 	}
 
-	// Get videos
-	searchQuery := `SELECT id, title, creator, url, thumbnail, views, likes, dislikes,
-					category, duration, description, verified, created_at, updated_at
-					FROM videos
-					WHERE (title LIKE ? OR creator LIKE ? OR description LIKE ?)`
+	b = builder(FacetDuration)
+	bucketCase := "CASE"
+	for _, bucket := range DurationBuckets {
+		bucketCase += " WHEN " + durationCondition(bucket) + " THEN '" + bucket.Name + "'"
+	}
+	bucketCase += " END"
+	b.where("duration_seconds > 0")
+	counts, err := r.countGroups(bucketCase, b, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Report buckets in their natural order, including empty ones
+	for _, bucket := range DurationBuckets {
+		count := FacetCount{Value: bucket.Name}
+		for _, c := range counts {
+			if c.Value == bucket.Name {
+				count.Count = c.Count
+			}
+		}
+		facets.Duration = append(facets.Duration, count)
+	}
 
-	if category != "" {
-		searchQuery += " AND category = ?"
+	b = builder(FacetVerified)
+	b.where("verified = ?", true)
+	var verified int
+	if err := r.db.QueryRow(r.search.bind("SELECT COUNT(*) FROM "+videoSource+b.clause()), b.args...).Scan(&verified); err != nil {
+		return nil, err
+	}
+	facets.Verified = append(facets.Verified, FacetCount{Value: "true", Count: verified})
+
+	b = builder(FacetUploaded)
+	sums := make([]string, len(uploadedPresets))
+	args := make([]interface{}, 0, len(uploadedPresets)+len(b.args))
+	for i, preset := range uploadedPresets {
+		sums[i] = "COALESCE(SUM(CASE WHEN created_at >= ? THEN 1 ELSE 0 END), 0)"
+		args = append(args, sqlTime(time.Now().AddDate(0, 0, -UploadedWithin[preset])))
 	}
-	searchQuery += " ORDER BY views DESC LIMIT ? OFFSET ?"
+	uploaded := make([]int, len(uploadedPresets))
+	dest := make([]interface{}, len(uploaded))
+	for i := range uploaded {
+		dest[i] = &uploaded[i]
+	}
+	err = r.db.QueryRow(
+		r.search.bind("SELECT "+strings.Join(sums, ", ")+" FROM "+videoSource+b.clause()),
+		append(args, b.args...)...,
+	).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	for i, preset := range uploadedPresets {
+		facets.Uploaded = append(facets.Uploaded, FacetCount{Value: preset, Count: uploaded[i]})
+	}
+
+	return facets, nil
+}
 
-	args = append(args, limit, offset)
+// countGroups counts matching videos per value of expr, most common first.
+// A limit of 0 returns every group.
+func (r *VideoRepository) countGroups(expr string, b *videoQueryBuilder, limit int) ([]FacetCount, error) {
+	query := "SELECT " + expr + " AS value, COUNT(*) AS n FROM " + videoSource + b.clause() +
+		" GROUP BY value ORDER BY n DESC, value ASC"
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
 
-	rows, err := r.db.Query(searchQuery, args...)
+	rows, err := r.db.Query(r.search.bind(query), b.args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	videos := []Video{}
+	counts := []FacetCount{}
 	for rows.Next() {
-		var v Video
-		var verified int
-		err := rows.Scan(&v.ID, &v.Title, &v.Creator, &v.URL, &v.Thumbnail, &v.Views,
-			&v.Likes, &v.Dislikes, &v.Category, &v.Duration, &v.Description,
-			&verified, &v.CreatedAt, &v.UpdatedAt)
-		if err != nil {
-			return nil, 0, err
+		var value sql.NullString
+		var c FacetCount
+		if err := rows.Scan(&value, &c.Count); err != nil {
+			return nil, err
 		}
-		v.Verified = verified == 1
-		videos = append(videos, v)
+		c.Value = value.String
+		counts = append(counts, c)
 	}
+	return counts, rows.Err()
+}
 
-	return videos, total, nil
+// PublishedSummary counts the published videos and their creators
+type PublishedSummary struct {
+	VideoCount   int
+	CreatorCount int
+	LastUpdated  time.Time // Zero if nothing is published
 }
 
-func (r *VideoRepository) IncrementViews(id int) error {
-	_, err := r.db.Exec("UPDATE videos SET views = views + 1 WHERE id = ?", id)
-	return err
+// PublishedSummary sums up the published catalog, for listings such as
+// sitemaps that are paged over all of it and rebuilt when it changes
+func (r *VideoRepository) PublishedSummary() (*PublishedSummary, error) {
+	s := &PublishedSummary{}
+	if err := r.db.QueryRow(
+		"SELECT COUNT(*), COUNT(DISTINCT creator_id) FROM videos WHERE status = ?", VideoStatusPublished,
+	).Scan(&s.VideoCount, &s.CreatorCount); err != nil {
+		return nil, err
+	}
+
+	// Read separately so the driver parses the column as a time, which it
+	// doesn't for MAX(updated_at)
+	err := r.db.QueryRow(
+		"SELECT updated_at FROM videos WHERE status = ? ORDER BY updated_at DESC LIMIT 1", VideoStatusPublished,
+	).Scan(&s.LastUpdated)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return s, nil
 }
 
+// GetRelated blends videos often watched together with this one (from
+// video_similarity) with shared tags, same category and recency. Videos
+// without co-view data fall back to tag and category matches. Only published
+// videos are suggested.
 func (r *VideoRepository) GetRelated(videoID int, category string, limit int) ([]Video, error) {
+	var sourceTags int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM video_tags WHERE video_id = ?", videoID).Scan(&sourceTags); err != nil {
+		return nil, err
+	}
+
 	rows, err := r.db.Query(
-		`SELECT id, title, creator, url, thumbnail, views, category
-		 FROM videos WHERE category = ? AND id != ?
-		 ORDER BY views DESC LIMIT ?`,
-		category, videoID, limit,
+		`SELECT v.id, v.title, v.creator, v.url, v.thumbnail, v.views, v.category, v.created_at,
+		        COALESCE(s.score, 0), COALESCE(t.shared, 0)
+		 FROM videos v
+		 LEFT JOIN video_similarity s ON s.video_id = ? AND s.similar_video_id = v.id
+		 LEFT JOIN (
+			SELECT vt.video_id, COUNT(*) AS shared FROM video_tags vt
+			WHERE vt.tag_id IN (SELECT tag_id FROM video_tags WHERE video_id = ?)
+			GROUP BY vt.video_id
+		 ) t ON t.video_id = v.id
+		 WHERE v.id != ? AND v.status = ? AND (s.score IS NOT NULL OR t.shared > 0 OR v.category = ?)
+		 ORDER BY COALESCE(s.score, 0) DESC, COALESCE(t.shared, 0) DESC,
+		          CASE WHEN v.category = ? THEN 1 ELSE 0 END DESC, v.views DESC
+		 LIMIT ?`,
+		videoID, videoID, videoID, VideoStatusPublished, category, category, limit*relatedPoolSize,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	videos := []Video{}
+	candidates := []relatedCandidate{}
 	for rows.Next() {
-		var v Video
-		err := rows.Scan(&v.ID, &v.Title, &v.Creator, &v.URL, &v.Thumbnail, &v.Views, &v.Category)
+		c := relatedCandidate{sourceTags: sourceTags}
+		v := &c.video
+		err := rows.Scan(&v.ID, &v.Title, &v.Creator, &v.URL, &v.Thumbnail, &v.Views, &v.Category, &v.CreatedAt,
+			&c.coView, &c.sharedTags)
 		if err != nil {
 			return nil, err
 		}
-		videos = append(videos, v)
+		c.sameCategory = v.Category == category
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return videos, nil
+	return rankRelated(candidates, limit, time.Now()), nil
 }
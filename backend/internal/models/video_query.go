@@ -0,0 +1,273 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"titan-backend/internal/utils"
+)
+
+// DurationBucket is a named duration range used for filtering and facet
+// counts. Max 0 means no upper bound.
+type DurationBucket struct {
+	Name string
+	Min  float64
+	Max  float64
+}
+
+// DurationBuckets in facet order. Videos without a known duration fall in none.
+var DurationBuckets = []DurationBucket{
+	{Name: "short", Min: 0, Max: 4 * 60},
+	{Name: "medium", Min: 4 * 60, Max: 20 * 60},
+	{Name: "long", Min: 20 * 60},
+}
+
+// UploadedWithin maps the upload date presets to how many days back they reach
+var UploadedWithin = map[string]int{
+	"day":   1,
+	"week":  7,
+	"month": 30,
+	"year":  365,
+}
+
+// uploadedPresets is UploadedWithin in facet order
+var uploadedPresets = []string{"day", "week", "month", "year"}
+
+// videoSorts maps the sort options to their columns
+var videoSorts = map[string]string{
+	"created_at": "created_at",
+	"views":      "views",
+	"likes":      "likes",
+	"title":      "title",
+	"duration":   "duration_seconds",
+	"trending":   "trending_score",
+}
+
+// SortRelevance orders search results by score. It is the search default and
+// isn't available when listing.
+const SortRelevance = "relevance"
+
+// VideoFilter narrows a video listing or search. Zero values don't filter.
+type VideoFilter struct {
+	Category string     `json:"category,omitempty"`
+	Creator  string     `json:"creator,omitempty"`
+	Tag      string     `json:"tag,omitempty"` // A tag slug
+	Verified bool       `json:"verified,omitempty"`
+	Duration string     `json:"duration,omitempty"` // A DurationBuckets name
+	Uploaded string     `json:"uploaded,omitempty"` // An UploadedWithin preset
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+
+	Trending bool `json:"-"` // Only videos with recent views
+
+	// Only published videos are listed unless Status picks another one or
+	// AllStatuses is set; both are for admins
+	Status      string `json:"status,omitempty"`
+	AllStatuses bool   `json:"-"`
+}
+
+// VideoQuery is a filtered, sorted page of videos. With a cursor, the
+// cursor's sort wins over Sort and Order so the page continues the listing
+// it came from.
+type VideoQuery struct {
+	VideoFilter
+	utils.PaginationParams
+	Sort  string
+	Order string
+}
+
+// Facet names, also used to leave a facet's own filter out of its counts
+const (
+	FacetCategory = "category"
+	FacetCreator  = "creator"
+	FacetVerified = "verified"
+	FacetDuration = "duration"
+	FacetUploaded = "uploaded"
+)
+
+// FacetCount is how many videos have a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// VideoFacets holds per-facet counts for a search. Each facet is counted with
+// every other filter applied but not its own, so the UI can show what
+// choosing a different value would return.
+type VideoFacets struct {
+	Category []FacetCount `json:"category"`
+	Creator  []FacetCount `json:"creator"` // Top creators only
+	Verified []FacetCount `json:"verified"`
+	Duration []FacetCount `json:"duration"`
+	Uploaded []FacetCount `json:"uploaded"` // Cumulative: "week" includes "day"
+}
+
+// maxCreatorFacets caps how many creators the creator facet lists
+const maxCreatorFacets = 10
+
+// videoQueryBuilder collects the WHERE conditions of a videos query and their
+// arguments. GetAll, the search engines and the facet counts all build their
+// filters through it.
+type videoQueryBuilder struct {
+	conds []string
+	args  []interface{}
+}
+
+func (b *videoQueryBuilder) where(cond string, args ...interface{}) {
+	b.conds = append(b.conds, cond)
+	b.args = append(b.args, args...)
+}
+
+// filter adds the conditions for f, leaving out the one for the skip facet
+func (b *videoQueryBuilder) filter(f VideoFilter, skip string) {
+	if f.Category != "" && skip != FacetCategory {
+		b.where("category = ?", f.Category)
+	}
+	if f.Creator != "" && skip != FacetCreator {
+		b.where("creator_slug = ?", Slugify(f.Creator))
+	}
+	switch {
+	case f.Status != "":
+		b.where("status = ?", f.Status)
+	case !f.AllStatuses:
+		b.where("status = ?", VideoStatusPublished)
+	}
+	if f.Tag != "" {
+		b.where("id IN (SELECT vt.video_id FROM video_tags vt JOIN tags t ON t.id = vt.tag_id WHERE t.slug = ?)", f.Tag)
+	}
+	if f.Trending {
+		b.where("trending_score > 0")
+	}
+	if f.Verified && skip != FacetVerified {
+		b.where("verified = ?", true)
+	}
+	if skip != FacetDuration {
+		for _, bucket := range DurationBuckets {
+			if bucket.Name == f.Duration {
+				b.where(durationCondition(bucket))
+			}
+		}
+	}
+	if skip != FacetUploaded {
+		if days, ok := UploadedWithin[f.Uploaded]; ok {
+			b.where("created_at >= ?", sqlTime(time.Now().AddDate(0, 0, -days)))
+		}
+		if f.From != nil {
+			b.where("created_at >= ?", sqlTime(*f.From))
+		}
+		if f.To != nil {
+			b.where("created_at < ?", sqlTime(*f.To))
+		}
+	}
+}
+
+// clause renders the collected conditions as a WHERE clause, or "" if there are none
+func (b *videoQueryBuilder) clause() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// durationCondition selects the videos in a bucket. Bounds are literals from
+// DurationBuckets, never user input.
+func durationCondition(bucket DurationBucket) string {
+	cond := "duration_seconds > 0 AND duration_seconds >= " + strconv.FormatFloat(bucket.Min, 'f', -1, 64)
+	if bucket.Max > 0 {
+		cond += " AND duration_seconds < " + strconv.FormatFloat(bucket.Max, 'f', -1, 64)
+	}
+	return cond
+}
+
+// sqlTime formats a time the way CURRENT_TIMESTAMP stores it, so comparisons
+// against created_at work as text on SQLite. Fractional seconds are kept for
+// PostgreSQL cursors and dropped when zero, so they don't affect SQLite.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+// videoSort resolves the sort and direction of q, using fallback for unknown
+// sorts. Relevance is only allowed when it is also the fallback, i.e. for
+// searches, whose queries select a score column; it is always descending.
+func videoSort(q VideoQuery, fallback string) (string, bool) {
+	sort, order := q.Sort, q.Order
+	if q.Cursor != nil {
+		sort, order, _ = strings.Cut(q.Cursor.Sort, ":")
+	}
+	if _, ok := videoSorts[sort]; !ok && (sort != SortRelevance || fallback != SortRelevance) {
+		sort = fallback
+	}
+	return sort, sort != SortRelevance && order == "asc"
+}
+
+// pageVideos wraps inner, a query selecting videoColumns (plus score for
+// searches), in the order, cursor condition and limit of q's page. It fetches
+// one row past the limit for utils.KeysetPage.
+func pageVideos(inner string, args []interface{}, q VideoQuery, fallback string) (string, []interface{}) {
+	sort, asc := videoSort(q, fallback)
+	column := "score"
+	if sort != SortRelevance {
+		column = videoSorts[sort]
+	}
+
+	query := "SELECT * FROM (" + inner + ") v"
+	args = append([]interface{}{}, args...)
+	if q.Cursor != nil {
+		cond, keyArgs := keysetCondition(column, asc, q.Cursor)
+		query += " WHERE " + cond
+		args = append(args, keyArgs...)
+	}
+
+	direction := "DESC"
+	if asc != (q.Cursor != nil && q.Cursor.Before) {
+		direction = "ASC"
+	}
+	query += " ORDER BY " + column + " " + direction + ", id " + direction + " LIMIT ?"
+	args = append(args, q.Limit+1)
+	if q.Cursor == nil {
+		query += " OFFSET ?"
+		args = append(args, q.Offset)
+	}
+	return query, args
+}
+
+// keysetCondition selects the rows after the cursor in a listing ordered by
+// column then id, or before it when paging backwards
+func keysetCondition(column string, asc bool, c *utils.Cursor) (string, []interface{}) {
+	op := "<"
+	if asc != c.Before {
+		op = ">"
+	}
+	return "(" + column + " " + op + " ? OR (" + column + " = ? AND id " + op + " ?))",
+		[]interface{}{c.Key, c.Key, c.ID}
+}
+
+// videoCursor returns the cursor on a video for q's sort. Score is only used
+// when sorting by relevance.
+func videoCursor(q VideoQuery, fallback string, v *Video, score float64) utils.Cursor {
+	sort, asc := videoSort(q, fallback)
+	order := "desc"
+	if asc {
+		order = "asc"
+	}
+
+	var key interface{}
+	switch sort {
+	case SortRelevance:
+		key = score
+	case "views":
+		key = v.Views
+	case "likes":
+		key = v.Likes
+	case "title":
+		key = v.Title
+	case "duration":
+		key = v.DurationSeconds
+	case "trending":
+		key = v.TrendingScore
+	default:
+		key = sqlTime(v.CreatedAt)
+	}
+	return utils.Cursor{Sort: sort + ":" + order, Key: key, ID: v.ID}
+}
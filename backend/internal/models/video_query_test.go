@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"titan-backend/internal/utils"
+)
+
+func TestVideoSort_FallsBackForUnknownSorts(t *testing.T) {
+	check := func(sort, order, fallback, wantSort string, wantAsc bool) {
+		t.Helper()
+		gotSort, gotAsc := videoSort(VideoQuery{Sort: sort, Order: order}, fallback)
+		assert.Equal(t, wantSort, gotSort)
+		assert.Equal(t, wantAsc, gotAsc)
+	}
+	check("duration", "asc", "created_at", "duration", true)
+	check("views; DROP TABLE videos", "asc", "created_at", "created_at", true)
+	check(SortRelevance, "", "created_at", "created_at", false)
+	check("", "asc", SortRelevance, SortRelevance, false)
+}
+
+func TestPageVideos_CursorWinsAndReversesBackwards(t *testing.T) {
+	q := VideoQuery{Sort: "title", PaginationParams: utils.PaginationParams{
+		Limit:  10,
+		Cursor: &utils.Cursor{Sort: "views:asc", Key: int64(5), ID: 7, Before: true},
+	}}
+	query, args := pageVideos("SELECT x", []interface{}{"a"}, q, "created_at")
+
+	assert.Equal(t, "SELECT * FROM (SELECT x) v WHERE (views < ? OR (views = ? AND id < ?)) ORDER BY views DESC, id DESC LIMIT ?", query)
+	assert.Equal(t, []interface{}{"a", int64(5), int64(5), 7, 11}, args)
+}
+
+func TestVideoQueryBuilder_SkipsOwnFacet(t *testing.T) {
+	f := VideoFilter{Category: "music", Verified: true, Duration: "short", AllStatuses: true}
+
+	b := &videoQueryBuilder{}
+	b.filter(f, FacetCategory)
+	assert.Equal(t, " WHERE verified = ? AND duration_seconds > 0 AND duration_seconds >= 0 AND duration_seconds < 240", b.clause())
+	assert.Equal(t, []interface{}{true}, b.args)
+
+	b = &videoQueryBuilder{}
+	b.filter(VideoFilter{Duration: "unknown", AllStatuses: true}, "")
+	assert.Equal(t, "", b.clause())
+}
+
+func TestVideoQueryBuilder_OnlyPublishedByDefault(t *testing.T) {
+	b := &videoQueryBuilder{}
+	b.filter(VideoFilter{}, "")
+	assert.Equal(t, " WHERE status = ?", b.clause())
+	assert.Equal(t, []interface{}{VideoStatusPublished}, b.args)
+
+	b = &videoQueryBuilder{}
+	b.filter(VideoFilter{Status: VideoStatusDraft, AllStatuses: true}, "")
+	assert.Equal(t, []interface{}{VideoStatusDraft}, b.args)
+}
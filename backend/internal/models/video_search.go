@@ -0,0 +1,393 @@
+package models
+
+import (
+	"database/sql"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search ranking blends text relevance with popularity:
+// score = relevance * (1 + popularityWeight * views / (views + popularityHalfViews)).
+// The boost saturates, so a popular video can outrank a slightly better match
+// but never one that is clearly more relevant.
+const (
+	popularityWeight    = 0.5
+	popularityHalfViews = 1000.0
+)
+
+// maxSearchTerms caps how many words of a query are used
+const maxSearchTerms = 10
+
+// Highlight markers put around matched terms by the engines. They are private
+// use characters, so they can't clash with stored text, and are turned into
+// <mark> tags after the text has been HTML escaped.
+const (
+	highlightStart = "\uE000"
+	highlightEnd   = "\uE001"
+)
+
+// SearchResult is a video matching a search, with its ranking score and
+// highlighted fields. CaptionMatch is the best matching caption cue, if the
+// video's captions mention the search terms.
+type SearchResult struct {
+	Video
+	Score        float64          `json:"score"`
+	Highlights   SearchHighlights `json:"highlights"`
+	CaptionMatch *CaptionMatch    `json:"captionMatch,omitempty"`
+}
+
+// SearchHighlights holds HTML-escaped text with matches wrapped in <mark>.
+// Description is a snippet around the best match rather than the full text.
+type SearchHighlights struct {
+	Title       string `json:"title"`
+	Creator     string `json:"creator"`
+	Description string `json:"description,omitempty"`
+}
+
+// VideoSearchEngine runs free-text video searches against a database's
+// full-text index. Terms come from searchTerms. Search returns one row past
+// the limit for utils.KeysetPage, and a total of -1 for cursor pages, which
+// aren't counted.
+type VideoSearchEngine interface {
+	Search(terms []string, q VideoQuery) ([]SearchResult, int, error)
+
+	// matchCondition is a WHERE condition on videos selecting rows that match
+	// the terms, for counts that don't need ranking
+	matchCondition(terms []string) (string, []interface{})
+
+	// bind rewrites ? placeholders for the engine's database
+	bind(query string) string
+}
+
+// NewVideoSearchEngine picks the engine for the database: a tsvector index on
+// PostgreSQL, FTS5 on SQLite, and LIKE matching if SQLite was built without
+// FTS5 (see database.RunMigrations).
+func NewVideoSearchEngine(db *sql.DB, driver string) VideoSearchEngine {
+	if driver == "postgres" {
+		return &postgresSearch{db: db}
+	}
+	if _, err := db.Exec("SELECT rowid FROM videos_fts LIMIT 0"); err == nil {
+		return &fts5Search{db: db}
+	}
+	return &likeSearch{db: db}
+}
+
+// searchTerms splits a query into lowercase words, dropping punctuation and
+// operators so user input can't change the query syntax
+func searchTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// renderHighlight HTML-escapes text and turns the highlight markers into <mark> tags
+func renderHighlight(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, highlightStart, "<mark>")
+	return strings.ReplaceAll(text, highlightEnd, "</mark>")
+}
+
+// scanSearchResult reads videoColumns followed by the score and the raw
+// title, creator and description highlights
+func scanSearchResult(row rowScanner, res *SearchResult) error {
+	var extra videoScan
+	var description sql.NullString
+	dest := append(videoDest(&res.Video, &extra),
+		&res.Score, &res.Highlights.Title, &res.Highlights.Creator, &description)
+	err := row.Scan(dest...)
+	if err != nil {
+		return err
+	}
+	extra.apply(&res.Video)
+	res.Highlights.Title = renderHighlight(res.Highlights.Title)
+	res.Highlights.Creator = renderHighlight(res.Highlights.Creator)
+	res.Highlights.Description = renderHighlight(description.String)
+	return nil
+}
+
+func querySearchResults(db *sql.DB, query string, args ...interface{}) ([]SearchResult, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		if err := scanSearchResult(rows, &res); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// fts5Search uses the videos_fts table, which triggers keep in sync with videos
+type fts5Search struct {
+	db *sql.DB
+}
+
+// ftsMatchQuery quotes each term so it is matched literally (after stemming).
+// The last term also matches as a prefix for search-as-you-type.
+func ftsMatchQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	quoted[len(quoted)-1] += "*"
+	return strings.Join(quoted, " ")
+}
+
+func (s *fts5Search) matchCondition(terms []string) (string, []interface{}) {
+	return "id IN (SELECT rowid FROM videos_fts WHERE videos_fts MATCH ?)", []interface{}{ftsMatchQuery(terms)}
+}
+
+func (s *fts5Search) bind(query string) string {
+	return query
+}
+
+func (s *fts5Search) Search(terms []string, q VideoQuery) ([]SearchResult, int, error) {
+	total := -1
+	if q.Cursor == nil {
+		count := &videoQueryBuilder{}
+		cond, args := s.matchCondition(terms)
+		count.where(cond, args...)
+		count.filter(q.VideoFilter, "")
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM "+videoSource+count.clause(), count.args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The results join the match itself for ranking and highlights, so only
+	// the filters go in the WHERE clause. bm25 is negative, more so for better
+	// matches; title, creator and tag matches weigh more than the description,
+	// and caption text, which is long and conversational, weighs least.
+	b := &videoQueryBuilder{}
+	b.filter(q.VideoFilter, "")
+	query, args := pageVideos(
+		"SELECT "+videoColumns+", m.relevance * (1.0 + ? * views / (views + ?)) AS score,"+
+			" m.title_hl, m.creator_hl, m.description_hl FROM "+videoSource+" JOIN ("+
+			`SELECT rowid AS video_id, -bm25(videos_fts, 10.0, 5.0, 1.0, 5.0, 0.5) AS relevance,
+				highlight(videos_fts, 0, ?, ?) AS title_hl,
+				highlight(videos_fts, 1, ?, ?) AS creator_hl,
+				snippet(videos_fts, 2, ?, ?, '…', 24) AS description_hl
+			FROM videos_fts WHERE videos_fts MATCH ?`+
+			") m ON m.video_id = videos.id"+b.clause(),
+		append([]interface{}{popularityWeight, popularityHalfViews,
+			highlightStart, highlightEnd, highlightStart, highlightEnd, highlightStart, highlightEnd,
+			ftsMatchQuery(terms)}, b.args...),
+		q, SortRelevance,
+	)
+	results, err := querySearchResults(s.db, query, args...)
+	return results, total, err
+}
+
+// postgresSearch uses the generated search_vector column and its GIN index
+type postgresSearch struct {
+	db *sql.DB
+}
+
+// tsQuery ANDs the terms like on SQLite, with the last one as a prefix
+func tsQuery(terms []string) string {
+	return strings.Join(terms, " & ") + ":*"
+}
+
+func (s *postgresSearch) matchCondition(terms []string) (string, []interface{}) {
+	return "search_vector @@ to_tsquery('english', ?)", []interface{}{tsQuery(terms)}
+}
+
+func (s *postgresSearch) bind(query string) string {
+	return numberPlaceholders(query)
+}
+
+// numberPlaceholders numbers the ? placeholders as $1, $2, ... for pgx.
+// Queries are built from fixed fragments, so there are no ? characters inside
+// string literals.
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *postgresSearch) Search(terms []string, q VideoQuery) ([]SearchResult, int, error) {
+	tsq := tsQuery(terms)
+
+	b := &videoQueryBuilder{}
+	cond, args := s.matchCondition(terms)
+	b.where(cond, args...)
+	b.filter(q.VideoFilter, "")
+
+	total := -1
+	if q.Cursor == nil {
+		if err := s.db.QueryRow(s.bind("SELECT COUNT(*) FROM "+videoSource+b.clause()), b.args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	options := `'StartSel="` + highlightStart + `", StopSel="` + highlightEnd + `"`
+	query, args := pageVideos(
+		"SELECT "+videoColumns+
+			", ts_rank_cd(search_vector, to_tsquery('english', ?)) * (1.0 + ? * views / (views + ?)) AS score"+
+			", ts_headline('english', title, to_tsquery('english', ?), "+options+", HighlightAll=true')"+
+			", ts_headline('english', creator, to_tsquery('english', ?), "+options+", HighlightAll=true')"+
+			", ts_headline('english', COALESCE(description, ''), to_tsquery('english', ?), "+options+", MaxWords=24, MinWords=8')"+
+			" FROM "+videoSource+b.clause(),
+		append([]interface{}{tsq, popularityWeight, popularityHalfViews, tsq, tsq, tsq}, b.args...),
+		q, SortRelevance,
+	)
+	results, err := querySearchResults(s.db, s.bind(query), args...)
+	return results, total, err
+}
+
+// likeSearch is the fallback when no full-text index is available. Every term
+// must appear in the title, creator, description, tags or captions; relevance
+// is just views and highlighting happens in Go.
+type likeSearch struct {
+	db *sql.DB
+}
+
+func (s *likeSearch) matchCondition(terms []string) (string, []interface{}) {
+	conds := make([]string, len(terms))
+	args := make([]interface{}, 0, 5*len(terms))
+	for i, term := range terms {
+		pattern := "%" + term + "%"
+		conds[i] = "(title LIKE ? OR creator LIKE ? OR description LIKE ? OR search_tags LIKE ? OR search_captions LIKE ?)"
+		args = append(args, pattern, pattern, pattern, pattern, pattern)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func (s *likeSearch) bind(query string) string {
+	return query
+}
+
+func (s *likeSearch) Search(terms []string, q VideoQuery) ([]SearchResult, int, error) {
+	b := &videoQueryBuilder{}
+	cond, args := s.matchCondition(terms)
+	b.where(cond, args...)
+	b.filter(q.VideoFilter, "")
+
+	total := -1
+	if q.Cursor == nil {
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM "+videoSource+b.clause(), b.args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Without relevance the score is the view count
+	query, args := pageVideos("SELECT "+videoColumns+", views AS score FROM "+videoSource+b.clause(), b.args, q, SortRelevance)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		if err := scanVideo(scoredRow{rows, &res.Score}, &res.Video); err != nil {
+			return nil, 0, err
+		}
+		res.Highlights = SearchHighlights{
+			Title:       renderHighlight(markTerms(res.Title, terms)),
+			Creator:     renderHighlight(markTerms(res.Creator, terms)),
+			Description: renderHighlight(snippetAround(markTerms(res.Description, terms), 80)),
+		}
+		results = append(results, res)
+	}
+	return results, total, rows.Err()
+}
+
+// scoredRow scans a trailing score column after the ones scanVideo reads
+type scoredRow struct {
+	row   rowScanner
+	score *float64
+}
+
+func (s scoredRow) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.score)...)
+}
+
+// markTerms wraps case-insensitive occurrences of any term in highlight markers
+func markTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Lowercasing changed byte offsets; skip highlighting rather than
+		// mark the wrong spans
+		return text
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		matched := 0
+		for _, term := range terms {
+			if strings.HasPrefix(lower[i:], term) && len(term) > matched {
+				matched = len(term)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(highlightStart + text[i:i+matched] + highlightEnd)
+			i += matched
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(text[i : i+size])
+		i += size
+	}
+	return b.String()
+}
+
+// snippetAround cuts text down to about radius runes either side of the first
+// highlight, adding ellipses where it was cut
+func snippetAround(text string, radius int) string {
+	runes := []rune(text)
+	if len(runes) <= 2*radius {
+		return text
+	}
+
+	center := 0
+	if i := strings.Index(text, highlightStart); i >= 0 {
+		center = utf8.RuneCountInString(text[:i])
+	}
+	start := center - radius
+	if start < 0 {
+		start = 0
+	}
+	end := start + 2*radius
+	if end > len(runes) {
+		end = len(runes)
+		start = end - 2*radius
+	}
+
+	snippet := string(runes[start:end])
+	// Don't leave a highlight open or orphaned at the cut
+	if strings.Count(snippet, highlightStart) > strings.Count(snippet, highlightEnd) {
+		snippet += highlightEnd
+	}
+	if strings.Count(snippet, highlightEnd) > strings.Count(snippet, highlightStart) {
+		snippet = highlightStart + snippet
+	}
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -0,0 +1,92 @@
+//go:build sqlite_fts5
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+// FTS5 is only compiled in with the sqlite_fts5 tag, which CI sets
+
+func newFTSVideoRepository(t *testing.T) (*models.VideoRepository, *models.TagRepository) {
+	t.Helper()
+
+	db := newTestDB(t)
+	if _, err := db.Exec("SELECT rowid FROM videos_fts LIMIT 0"); err != nil {
+		t.Fatalf("videos_fts missing: %v", err)
+	}
+	repo := models.NewVideoRepository(db)
+	repo.SetSearchEngine(models.NewVideoSearchEngine(db, "sqlite3"))
+	return repo, models.NewTagRepository(db)
+}
+
+func searchIDs(t *testing.T, repo *models.VideoRepository, text string) []int {
+	t.Helper()
+
+	results, _, err := repo.Search(text, models.VideoQuery{PaginationParams: utils.PaginationParams{Page: 1, Limit: 20}})
+	require.NoError(t, err)
+	ids := []int{}
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestVideoSearch_FTS5Ranking(t *testing.T) {
+	repo, tags := newFTSVideoRepository(t)
+	ids := createVideos(t, repo,
+		&models.Video{Title: "Evening walk", Creator: "Ann", Description: "We pass a glacier on the way"},
+		&models.Video{Title: "Glacier hike", Creator: "Bob"},
+		&models.Video{Title: "Morning swim", Creator: "Cat"},
+	)
+	_, err := tags.ApplyToVideo(ids[2], models.TagChange{Set: []string{"glacier"}})
+	require.NoError(t, err)
+
+	// Title and tag matches outrank a description match; the last term is a prefix
+	assert.Equal(t, []int{ids[1], ids[2], ids[0]}, searchIDs(t, repo, "glac"))
+
+	results, _, err := repo.Search("glacier", models.VideoQuery{PaginationParams: utils.PaginationParams{Page: 1, Limit: 20}})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "<mark>Glacier</mark> hike", results[0].Highlights.Title)
+
+	// Every term has to match
+	assert.Equal(t, []int{ids[1]}, searchIDs(t, repo, "glacier hike"))
+	assert.Empty(t, searchIDs(t, repo, "glacier desert"))
+}
+
+func TestVideoSearch_FTS5FollowsChanges(t *testing.T) {
+	repo, tags := newFTSVideoRepository(t)
+	ids := createVideos(t, repo,
+		&models.Video{Title: "Mountain running", Creator: "Ann"},
+		&models.Video{Title: "City lights", Creator: "Bob"},
+	)
+	assert.Equal(t, []int{ids[0]}, searchIDs(t, repo, "mountain"))
+
+	// Edits replace the indexed text
+	video, err := repo.GetByID(ids[0])
+	require.NoError(t, err)
+	video.Title = "Desert running"
+	require.NoError(t, repo.Update(video))
+	assert.Empty(t, searchIDs(t, repo, "mountain"))
+	assert.Equal(t, []int{ids[0]}, searchIDs(t, repo, "desert"))
+
+	// So do tag changes
+	_, err = tags.ApplyToVideo(ids[1], models.TagChange{Set: []string{"nightlife"}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[1]}, searchIDs(t, repo, "nightlife"))
+	_, err = tags.ApplyToVideo(ids[1], models.TagChange{Remove: []string{"nightlife"}})
+	require.NoError(t, err)
+	assert.Empty(t, searchIDs(t, repo, "nightlife"))
+
+	// Deleted videos drop out
+	require.NoError(t, repo.Delete(ids[0]))
+	assert.Empty(t, searchIDs(t, repo, "desert"))
+	assert.Equal(t, []int{ids[1]}, searchIDs(t, repo, "city"))
+}
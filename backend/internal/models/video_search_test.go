@@ -0,0 +1,35 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms_StripsSyntax(t *testing.T) {
+	assert.Equal(t, []string{"mountain", "run", "or", "x"}, searchTerms(`Mountain "run" OR x*`))
+	assert.Empty(t, searchTerms(`*** "" -`))
+
+	long := strings.Repeat("a ", maxSearchTerms+5)
+	assert.Len(t, searchTerms(long), maxSearchTerms)
+}
+
+func TestFTSMatchQuery_QuotesTermsAndPrefixesLast(t *testing.T) {
+	assert.Equal(t, `"mountain" "run"*`, ftsMatchQuery([]string{"mountain", "run"}))
+}
+
+func TestRenderHighlight_EscapesBeforeMarking(t *testing.T) {
+	marked := markTerms("<b>Running</b> fast", []string{"run"})
+	assert.Equal(t, "&lt;b&gt;<mark>Run</mark>ning&lt;/b&gt; fast", renderHighlight(marked))
+}
+
+func TestSnippetAround_CentersOnFirstMatch(t *testing.T) {
+	text := strings.Repeat("lorem ", 40) + "target" + strings.Repeat(" ipsum", 40)
+	snippet := renderHighlight(snippetAround(markTerms(text, []string{"target"}), 20))
+
+	assert.True(t, strings.HasPrefix(snippet, "…"))
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+	assert.Contains(t, snippet, "<mark>target</mark>")
+	assert.Equal(t, strings.Count(snippet, "<mark>"), strings.Count(snippet, "</mark>"))
+}
@@ -0,0 +1,125 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+	"titan-backend/internal/utils"
+)
+
+var firstPage = utils.PaginationParams{Page: 1, Limit: 20}
+
+func videoIDs(videos []models.Video) []int {
+	ids := []int{}
+	for _, v := range videos {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+func TestVideoStatus_OnlyPublishedVideosAreListed(t *testing.T) {
+	db := newTestDB(t)
+	categories := models.NewCategoryRepository(db)
+	require.NoError(t, categories.Create(&models.Category{ID: "music", Name: "Music", Icon: "🎵"}))
+
+	repo := models.NewVideoRepository(db)
+	repo.SetSearchEngine(models.NewVideoSearchEngine(db, "sqlite3"))
+	later := time.Now().Add(time.Hour)
+	video := func(title, status string) *models.Video {
+		v := &models.Video{Title: title + " lantern", Creator: "Ann", Category: "music", Status: status}
+		if status == models.VideoStatusScheduled {
+			v.PublishAt = &later
+		}
+		return v
+	}
+	ids := createVideos(t, repo,
+		video("Published", models.VideoStatusPublished),
+		video("Also published", models.VideoStatusPublished),
+		video("Unlisted", models.VideoStatusUnlisted),
+		video("Draft", models.VideoStatusDraft),
+		video("Private", models.VideoStatusPrivate),
+		video("Scheduled", models.VideoStatusScheduled),
+	)
+	published := []int{ids[0], ids[1]}
+
+	listed, _, err := repo.GetAll(models.VideoQuery{PaginationParams: firstPage, Sort: "title", Order: "asc"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, published, videoIDs(listed))
+
+	results, _, err := repo.Search("lantern", models.VideoQuery{PaginationParams: firstPage})
+	require.NoError(t, err)
+	found := []int{}
+	for _, r := range results {
+		found = append(found, r.ID)
+	}
+	assert.ElementsMatch(t, published, found)
+
+	related, err := repo.GetRelated(ids[0], "music", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[1]}, videoIDs(related))
+
+	facets, err := repo.SearchFacets("lantern", models.VideoFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.FacetCount{{Value: "music", Count: 2}}, facets.Category)
+
+	category, err := categories.GetByID("music")
+	require.NoError(t, err)
+	assert.Equal(t, 2, category.VideoCount)
+	all, err := categories.GetAll()
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, 2, all[0].VideoCount)
+
+	// Admins can list everything, or one status
+	listed, _, err = repo.GetAll(models.VideoQuery{PaginationParams: firstPage, VideoFilter: models.VideoFilter{AllStatuses: true}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ids, videoIDs(listed))
+	listed, _, err = repo.GetAll(models.VideoQuery{PaginationParams: firstPage, VideoFilter: models.VideoFilter{Status: models.VideoStatusDraft}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[3]}, videoIDs(listed))
+
+	// Unlisted videos are only reachable by ID; the other hidden ones aren't viewable at all
+	for i, viewable := range []bool{true, true, true, false, false, false} {
+		v, err := repo.GetByID(ids[i])
+		require.NoError(t, err)
+		require.NotNil(t, v)
+		assert.Equal(t, viewable, v.IsViewable(), v.Title)
+	}
+}
+
+func TestVideoStatus_PublishScheduled(t *testing.T) {
+	repo := models.NewVideoRepository(newTestDB(t))
+	now := time.Now()
+	soon, later := now.Add(time.Hour), now.Add(3*time.Hour)
+	ids := createVideos(t, repo,
+		&models.Video{Title: "Soon", Creator: "Ann", Status: models.VideoStatusScheduled, PublishAt: &soon},
+		&models.Video{Title: "Later", Creator: "Ann", Status: models.VideoStatusScheduled, PublishAt: &later},
+	)
+
+	n, err := repo.PublishScheduled(now)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	n, err = repo.PublishScheduled(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	v, err := repo.GetByID(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, models.VideoStatusPublished, v.Status)
+	assert.Nil(t, v.PublishAt)
+
+	v, err = repo.GetByID(ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, models.VideoStatusScheduled, v.Status)
+	require.NotNil(t, v.PublishAt)
+	assert.WithinDuration(t, later, *v.PublishAt, time.Second)
+
+	listed, _, err := repo.GetAll(models.VideoQuery{PaginationParams: firstPage})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[0]}, videoIDs(listed))
+}
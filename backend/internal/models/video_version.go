@@ -0,0 +1,186 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"titan-backend/internal/mediaprobe"
+)
+
+// What a video version replaced
+const (
+	VideoVersionMedia     = "media"
+	VideoVersionThumbnail = "thumbnail"
+)
+
+// ErrVideoChanged is returned when the file being replaced was replaced by
+// another request in the meantime
+var ErrVideoChanged = errors.New("the video was changed by another request, try again")
+
+// VideoVersion is a media file or thumbnail a video used before it was
+// replaced. The file is kept until PurgeAt so links already handed out keep
+// working and a bad upload can be put back by hand.
+type VideoVersion struct {
+	ID         int       `json:"id"`
+	VideoID    int       `json:"videoId"`
+	Kind       string    `json:"kind"`
+	URL        string    `json:"url"`
+	HLSURL     string    `json:"hlsUrl,omitempty"`
+	FileSize   int64     `json:"fileSize,omitempty"`
+	ReplacedAt time.Time `json:"replacedAt"`
+	PurgeAt    time.Time `json:"purgeAt"`
+}
+
+const videoVersionColumns = "id, video_id, kind, url, COALESCE(hls_url, ''), COALESCE(file_size, 0), replaced_at, purge_at"
+
+func scanVideoVersion(row rowScanner, v *VideoVersion) error {
+	return row.Scan(&v.ID, &v.VideoID, &v.Kind, &v.URL, &v.HLSURL, &v.FileSize, &v.ReplacedAt, &v.PurgeAt)
+}
+
+// ReplaceMedia switches v to a newly stored media file, keeping the old file
+// (and its HLS packaging, which no longer matches) as a version until
+// purgeAt. The probed metadata of the new file replaces the old; the
+// human-readable duration is recomputed from it.
+func (r *VideoRepository) ReplaceMedia(v *Video, url string, info *mediaprobe.Info, purgeAt time.Time) (*VideoVersion, error) {
+	old := &VideoVersion{VideoID: v.ID, Kind: VideoVersionMedia, URL: v.URL, HLSURL: v.HLSURL, FileSize: v.FileSize, PurgeAt: purgeAt}
+	updated := *v
+	updated.URL, updated.HLSURL, updated.Duration = url, "", ""
+	updated.ApplyMediaInfo(info)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Matching on the old URL makes the switch a compare-and-swap, so two
+	// concurrent replacements can't both keep the same old file
+	result, err := tx.Exec(
+		`UPDATE videos SET url = ?, hls_url = '', duration = ?, duration_seconds = ?, width = ?, height = ?,
+		 video_codec = ?, audio_codec = ?, bitrate = ?, file_size = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND url = ?`,
+		updated.URL, updated.Duration, updated.DurationSeconds, updated.Width, updated.Height,
+		updated.VideoCodec, updated.AudioCodec, updated.Bitrate, updated.FileSize,
+		v.ID, v.URL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrVideoChanged
+	}
+
+	if err := insertVideoVersion(tx, old); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	*v = updated
+	v.UpdatedAt = time.Now()
+	return old, nil
+}
+
+// ReplaceThumbnail switches v to a newly stored thumbnail, keeping the old
+// one as a version until purgeAt
+func (r *VideoRepository) ReplaceThumbnail(v *Video, thumbnail string, purgeAt time.Time) (*VideoVersion, error) {
+	old := &VideoVersion{VideoID: v.ID, Kind: VideoVersionThumbnail, URL: v.Thumbnail, PurgeAt: purgeAt}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE videos SET thumbnail = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND thumbnail = ?",
+		thumbnail, v.ID, v.Thumbnail,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrVideoChanged
+	}
+
+	// A video without a thumbnail has nothing to keep
+	if old.URL != "" {
+		if err := insertVideoVersion(tx, old); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	v.Thumbnail = thumbnail
+	v.UpdatedAt = time.Now()
+	if old.URL == "" {
+		return nil, nil
+	}
+	return old, nil
+}
+
+func insertVideoVersion(tx *sql.Tx, v *VideoVersion) error {
+	v.ReplacedAt = time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO video_versions (video_id, kind, url, hls_url, file_size, replaced_at, purge_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		v.VideoID, v.Kind, v.URL, v.HLSURL, v.FileSize, sqlTime(v.ReplacedAt), sqlTime(v.PurgeAt),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	v.ID = int(id)
+	return nil
+}
+
+// Versions lists the kept versions of a video, most recently replaced first
+func (r *VideoRepository) Versions(videoID int) ([]VideoVersion, error) {
+	rows, err := r.db.Query(
+		"SELECT "+videoVersionColumns+" FROM video_versions WHERE video_id = ? ORDER BY replaced_at DESC, id DESC",
+		videoID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []VideoVersion{}
+	for rows.Next() {
+		var v VideoVersion
+		if err := scanVideoVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (r *VideoRepository) GetVersion(id int) (*VideoVersion, error) {
+	v := &VideoVersion{}
+	err := scanVideoVersion(r.db.QueryRow("SELECT "+videoVersionColumns+" FROM video_versions WHERE id = ?", id), v)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DeleteVersion forgets a version once its files are gone
+func (r *VideoRepository) DeleteVersion(id int) error {
+	_, err := r.db.Exec("DELETE FROM video_versions WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,120 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/mediaprobe"
+	"titan-backend/internal/models"
+)
+
+func versionURLs(versions []models.VideoVersion) []string {
+	urls := []string{}
+	for _, v := range versions {
+		urls = append(urls, v.URL)
+	}
+	return urls
+}
+
+func TestVideoVersion_ReplaceMedia(t *testing.T) {
+	repo := models.NewVideoRepository(newTestDB(t))
+	ids := createVideos(t, repo, &models.Video{Title: "Clip", Creator: "Ann", URL: "/storage/videos/a.mp4"})
+	stored, err := repo.SetHLSURL(ids[0], "/storage/videos/a.mp4", "/storage/videos/a.m3u8")
+	require.NoError(t, err)
+	require.True(t, stored)
+
+	video, err := repo.GetByID(ids[0])
+	require.NoError(t, err)
+	stale := *video
+
+	purgeAt := time.Now().Add(time.Hour)
+	old, err := repo.ReplaceMedia(video, "/storage/videos/b.mp4", &mediaprobe.Info{Duration: 12, Width: 640, Height: 360, Size: 42}, purgeAt)
+	require.NoError(t, err)
+	assert.Equal(t, models.VideoVersionMedia, old.Kind)
+	assert.Equal(t, "/storage/videos/a.mp4", old.URL)
+	assert.Equal(t, "/storage/videos/a.m3u8", old.HLSURL)
+	assert.WithinDuration(t, purgeAt, old.PurgeAt, time.Second)
+
+	// The new file's metadata replaces the old, and its HLS packaging is gone
+	video, err = repo.GetByID(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "/storage/videos/b.mp4", video.URL)
+	assert.Empty(t, video.HLSURL)
+	assert.Equal(t, float64(12), video.DurationSeconds)
+	assert.Equal(t, 640, video.Width)
+	assert.Equal(t, int64(42), video.FileSize)
+
+	// A request that still holds the old URL lost the race and keeps nothing
+	_, err = repo.ReplaceMedia(&stale, "/storage/videos/c.mp4", nil, purgeAt)
+	assert.ErrorIs(t, err, models.ErrVideoChanged)
+	video, err = repo.GetByID(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "/storage/videos/b.mp4", video.URL)
+
+	versions, err := repo.Versions(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/storage/videos/a.mp4"}, versionURLs(versions))
+}
+
+func TestVideoVersion_ReplaceThumbnail(t *testing.T) {
+	repo := models.NewVideoRepository(newTestDB(t))
+	ids := createVideos(t, repo, &models.Video{Title: "Clip", Creator: "Ann"})
+	video, err := repo.GetByID(ids[0])
+	require.NoError(t, err)
+	purgeAt := time.Now().Add(time.Hour)
+
+	// A video without a thumbnail has nothing to keep
+	old, err := repo.ReplaceThumbnail(video, "/storage/thumbnails/a.jpg", purgeAt)
+	require.NoError(t, err)
+	assert.Nil(t, old)
+	stale := *video
+
+	old, err = repo.ReplaceThumbnail(video, "/storage/thumbnails/b.jpg", purgeAt)
+	require.NoError(t, err)
+	require.NotNil(t, old)
+	assert.Equal(t, models.VideoVersionThumbnail, old.Kind)
+	assert.Equal(t, "/storage/thumbnails/a.jpg", old.URL)
+
+	_, err = repo.ReplaceThumbnail(&stale, "/storage/thumbnails/c.jpg", purgeAt)
+	assert.ErrorIs(t, err, models.ErrVideoChanged)
+
+	video, err = repo.GetByID(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "/storage/thumbnails/b.jpg", video.Thumbnail)
+	versions, err := repo.Versions(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/storage/thumbnails/a.jpg"}, versionURLs(versions))
+}
+
+func TestVideoVersion_ListNewestFirst(t *testing.T) {
+	repo := models.NewVideoRepository(newTestDB(t))
+	ids := createVideos(t, repo,
+		&models.Video{Title: "Clip", Creator: "Ann", URL: "/storage/videos/a.mp4", Thumbnail: "/storage/thumbnails/a.jpg"},
+		&models.Video{Title: "Other", Creator: "Ann"},
+	)
+	video, err := repo.GetByID(ids[0])
+	require.NoError(t, err)
+	purgeAt := time.Now().Add(time.Hour)
+
+	_, err = repo.ReplaceMedia(video, "/storage/videos/b.mp4", nil, purgeAt)
+	require.NoError(t, err)
+	_, err = repo.ReplaceThumbnail(video, "/storage/thumbnails/b.jpg", purgeAt)
+	require.NoError(t, err)
+	_, err = repo.ReplaceMedia(video, "/storage/videos/c.mp4", nil, purgeAt)
+	require.NoError(t, err)
+
+	versions, err := repo.Versions(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/storage/videos/b.mp4", "/storage/thumbnails/a.jpg", "/storage/videos/a.mp4"}, versionURLs(versions))
+
+	loaded, err := repo.GetVersion(versions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, versions[1], *loaded)
+
+	others, err := repo.Versions(ids[1])
+	require.NoError(t, err)
+	assert.Empty(t, others)
+}
@@ -2,15 +2,36 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 )
 
+// WatchedFraction is how much of a video counts as finished. Finished videos
+// drop out of "continue watching" but stay in the history.
+const WatchedFraction = 0.9
+
 type ViewLog struct {
 	ID        int       `json:"id"`
 	VideoID   int       `json:"videoId"`
 	IPAddress string    `json:"ipAddress"`
 	UserAgent string    `json:"userAgent"`
 	ViewedAt  time.Time `json:"viewedAt"`
+
+	// Playback progress, kept up to date by heartbeats from the player.
+	// ViewerID is empty for views logged without one or cleared from history.
+	ViewerID        string  `json:"-"`
+	PositionSeconds float64 `json:"positionSeconds"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// WatchProgress is a viewer's latest view of a video, as shown in their history
+type WatchProgress struct {
+	VideoID         int       `json:"videoId"`
+	PositionSeconds float64   `json:"positionSeconds"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Completed       bool      `json:"completed"`
+	WatchedAt       time.Time `json:"watchedAt"`
+	Video           *Video    `json:"video,omitempty"` // Filled in by the caller
 }
 
 type ViewLogRepository struct {
@@ -21,24 +42,45 @@ func NewViewLogRepository(db *sql.DB) *ViewLogRepository {
 	return &ViewLogRepository{db: db}
 }
 
+// Create logs a view and counts it in the video's views, together so that
+// the count matches the log the view throttle checks
 func (r *ViewLogRepository) Create(log *ViewLog) error {
-	result, err := r.db.Exec(
-		"INSERT INTO view_logs (video_id, ip_address, user_agent) VALUES (?, ?, ?)",
-		log.VideoID, log.IPAddress, log.UserAgent,
-	)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
+	result, err := tx.Exec(
+		`INSERT INTO view_logs (video_id, ip_address, user_agent, viewer_id, position_seconds, duration_seconds, completed, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		log.VideoID, log.IPAddress, log.UserAgent, nullableViewerID(log.ViewerID),
+		log.PositionSeconds, log.DurationSeconds, log.completed(),
+	)
+	if err != nil {
+		return err
+	}
 	id, err := result.LastInsertId()
 	if err != nil {
 		return err
 	}
+
+	if _, err := tx.Exec("UPDATE videos SET views = views + 1 WHERE id = ?", log.VideoID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	log.ID = int(id)
 	log.ViewedAt = time.Now()
 	return nil
 }
 
+// ViewThrottleHours is how long views of a video from the same IP aren't
+// counted again
+const ViewThrottleHours = 24
+
 func (r *ViewLogRepository) HasRecentView(videoID int, ipAddress string, hours int) (bool, error) {
 	var count int
 	err := r.db.QueryRow(
@@ -78,6 +120,131 @@ func (r *ViewLogRepository) GetRecentViewsByDay(days int) ([]DailyViews, error)
 	return views, nil
 }
 
+func nullableViewerID(viewerID string) sql.NullString {
+	return sql.NullString{String: viewerID, Valid: viewerID != ""}
+}
+
+// completed reports 1 once the position is past WatchedFraction of the duration
+func (l *ViewLog) completed() int {
+	if l.DurationSeconds > 0 && l.PositionSeconds >= l.DurationSeconds*WatchedFraction {
+		return 1
+	}
+	return 0
+}
+
+// SaveProgress records a heartbeat on the viewer's latest view of the video.
+// If the viewer has no logged view yet (the view call was throttled or never
+// made), the heartbeat is logged and counted as a new view, unless the IP has
+// viewed the video within ViewThrottleHours: every logged view counts towards
+// trending and related videos, so heartbeats mustn't get around the view
+// throttle.
+// Reports whether the progress was saved.
+func (r *ViewLogRepository) SaveProgress(log *ViewLog) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE view_logs SET position_seconds = ?, duration_seconds = ?, completed = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = (SELECT MAX(id) FROM view_logs WHERE video_id = ? AND viewer_id = ?)`,
+		log.PositionSeconds, log.DurationSeconds, log.completed(), log.VideoID, log.ViewerID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		return true, nil
+	}
+
+	recent, err := r.HasRecentView(log.VideoID, log.IPAddress, ViewThrottleHours)
+	if err != nil || recent {
+		return false, err
+	}
+	return true, r.Create(log)
+}
+
+// latestView limits a query to each video's most recent view by the viewer.
+// Views only get a viewer_id along with updated_at, so the latter is never
+// null here.
+const latestView = ` l.viewer_id = ? AND l.id = (
+	SELECT MAX(l2.id) FROM view_logs l2 WHERE l2.viewer_id = l.viewer_id AND l2.video_id = l.video_id)`
+
+const watchProgressColumns = `l.video_id, COALESCE(l.position_seconds, 0), COALESCE(l.duration_seconds, 0),
+	COALESCE(l.completed, 0), l.updated_at`
+
+func scanWatchProgress(row rowScanner, p *WatchProgress) error {
+	var completed int
+	if err := row.Scan(&p.VideoID, &p.PositionSeconds, &p.DurationSeconds, &completed, &p.WatchedAt); err != nil {
+		return err
+	}
+	p.Completed = completed == 1
+	return nil
+}
+
+// GetProgress returns the viewer's progress through a video, or nil if they
+// haven't watched it
+func (r *ViewLogRepository) GetProgress(videoID int, viewerID string) (*WatchProgress, error) {
+	p := &WatchProgress{}
+	err := scanWatchProgress(r.db.QueryRow(
+		"SELECT "+watchProgressColumns+" FROM view_logs l WHERE l.video_id = ? AND"+latestView,
+		videoID, viewerID,
+	), p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ListHistory returns a page of the videos a viewer has watched, most recent
+// first. With inProgressOnly, finished and barely started videos are left out.
+func (r *ViewLogRepository) ListHistory(viewerID string, inProgressOnly bool, page, limit int) ([]WatchProgress, int, error) {
+	offset := (page - 1) * limit
+	where := " WHERE" + latestView
+	if inProgressOnly {
+		where += " AND COALESCE(l.completed, 0) = 0 AND l.position_seconds > 0"
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM view_logs l"+where, viewerID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		"SELECT "+watchProgressColumns+" FROM view_logs l"+where+
+			" ORDER BY l.updated_at DESC, l.id DESC LIMIT ? OFFSET ?",
+		viewerID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	history := []WatchProgress{}
+	for rows.Next() {
+		var p WatchProgress
+		if err := scanWatchProgress(rows, &p); err != nil {
+			return nil, 0, err
+		}
+		history = append(history, p)
+	}
+	return history, total, rows.Err()
+}
+
+// RemoveFromHistory detaches the viewer from their views of a video. The
+// view logs themselves are kept so analytics don't change.
+func (r *ViewLogRepository) RemoveFromHistory(viewerID string, videoID int) error {
+	_, err := r.db.Exec("UPDATE view_logs SET viewer_id = NULL WHERE viewer_id = ? AND video_id = ?", viewerID, videoID)
+	return err
+}
+
+// ClearHistory detaches the viewer from all their view logs
+func (r *ViewLogRepository) ClearHistory(viewerID string) (int64, error) {
+	result, err := r.db.Exec("UPDATE view_logs SET viewer_id = NULL WHERE viewer_id = ?", viewerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 type DailyViews struct {
 	Date  string `json:"date"`
 	Views int    `json:"views"`
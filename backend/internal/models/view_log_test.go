@@ -0,0 +1,94 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func TestSaveProgress_RespectsViewThrottle(t *testing.T) {
+	db := newTestDB(t)
+	ids := createVideos(t, models.NewVideoRepository(db), &models.Video{Title: "Clip", Creator: "Ann"})
+	repo := models.NewViewLogRepository(db)
+
+	countViews := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM view_logs WHERE video_id = ?", ids[0]).Scan(&n))
+		return n
+	}
+
+	// A viewer without a view gets one
+	saved, err := repo.SaveProgress(&models.ViewLog{VideoID: ids[0], IPAddress: "10.0.0.1", ViewerID: "device:a", PositionSeconds: 5, DurationSeconds: 100})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, 1, countViews())
+
+	// Later heartbeats update it
+	saved, err = repo.SaveProgress(&models.ViewLog{VideoID: ids[0], IPAddress: "10.0.0.1", ViewerID: "device:a", PositionSeconds: 95, DurationSeconds: 100})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, 1, countViews())
+	progress, err := repo.GetProgress(ids[0], "device:a")
+	require.NoError(t, err)
+	require.NotNil(t, progress)
+	assert.Equal(t, float64(95), progress.PositionSeconds)
+	assert.True(t, progress.Completed)
+
+	// New device IDs from the same IP don't log more views
+	for _, device := range []string{"device:b", "device:c"} {
+		saved, err = repo.SaveProgress(&models.ViewLog{VideoID: ids[0], IPAddress: "10.0.0.1", ViewerID: device, PositionSeconds: 1})
+		require.NoError(t, err)
+		assert.False(t, saved, device)
+	}
+	assert.Equal(t, 1, countViews())
+
+	// Another IP does
+	saved, err = repo.SaveProgress(&models.ViewLog{VideoID: ids[0], IPAddress: "10.0.0.2", ViewerID: "device:b", PositionSeconds: 1})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, 2, countViews())
+}
+
+func TestSaveProgress_CountsTheViewItLogs(t *testing.T) {
+	db := newTestDB(t)
+	videos := models.NewVideoRepository(db)
+	ids := createVideos(t, videos,
+		&models.Video{Title: "Heartbeat first", Creator: "Ann"}, &models.Video{Title: "View first", Creator: "Ann"},
+	)
+	repo := models.NewViewLogRepository(db)
+
+	// view is what POST /videos/{id}/view does: log a view unless the IP is throttled
+	view := func(videoID int) {
+		t.Helper()
+		recent, err := repo.HasRecentView(videoID, "10.0.0.1", models.ViewThrottleHours)
+		require.NoError(t, err)
+		if !recent {
+			require.NoError(t, repo.Create(&models.ViewLog{VideoID: videoID, IPAddress: "10.0.0.1", ViewerID: "device:a"}))
+		}
+	}
+	heartbeat := func(videoID int) {
+		t.Helper()
+		_, err := repo.SaveProgress(&models.ViewLog{VideoID: videoID, IPAddress: "10.0.0.1", ViewerID: "device:a", PositionSeconds: 5})
+		require.NoError(t, err)
+	}
+	views := func(videoID int) int {
+		t.Helper()
+		v, err := videos.GetByID(videoID)
+		require.NoError(t, err)
+		return v.Views
+	}
+
+	// Either way round, the one logged view is counted once
+	heartbeat(ids[0])
+	view(ids[0])
+	heartbeat(ids[0])
+	assert.Equal(t, 1, views(ids[0]))
+
+	view(ids[1])
+	heartbeat(ids[1])
+	view(ids[1])
+	assert.Equal(t, 1, views(ids[1]))
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"titan-backend/internal/models"
+)
+
+// Job queue errors
+var (
+	ErrUnknownJobType    = errors.New("unknown job type")
+	ErrJobNotRetryable   = errors.New("only dead or cancelled jobs can be retried")
+	ErrJobNotCancellable = errors.New("only pending or running jobs can be cancelled")
+)
+
+// JobHandler processes a single job. Returning an error schedules a retry
+// with exponential backoff until the job runs out of attempts.
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// TypedJob adapts a handler that takes a decoded payload of type T.
+// Payloads that don't decode fail permanently instead of being retried.
+func TypedJob[T any](fn func(ctx context.Context, payload T) error) JobHandler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("invalid payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job is dead-lettered immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type jobSchedule struct {
+	jobType  string
+	interval time.Duration
+}
+
+// JobQueue runs jobs stored in the jobs table on a pool of workers.
+// Jobs survive restarts; a worker that dies mid-job has its job requeued
+// once the lock goes stale.
+type JobQueue struct {
+	repo        *models.JobRepository
+	workers     int
+	maxAttempts int
+
+	pollInterval time.Duration
+	jobTimeout   time.Duration
+	backoffBase  time.Duration
+	backoffMax   time.Duration
+
+	handlers  map[string]JobHandler
+	schedules []jobSchedule
+
+	running map[int64]context.CancelFunc
+	mu      sync.Mutex
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	abortCtx context.Context // cancelled when draining times out
+	abort    context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewJobQueue creates a job queue
+// workers: number of jobs processed concurrently
+// maxAttempts: default attempt budget for new jobs before they are dead-lettered
+func NewJobQueue(repo *models.JobRepository, workers, maxAttempts int) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	abortCtx, abort := context.WithCancel(context.Background())
+	return &JobQueue{
+		repo:         repo,
+		workers:      workers,
+		maxAttempts:  maxAttempts,
+		pollInterval: 2 * time.Second,
+		jobTimeout:   10 * time.Minute,
+		backoffBase:  30 * time.Second,
+		backoffMax:   1 * time.Hour,
+		handlers:     make(map[string]JobHandler),
+		running:      make(map[int64]context.CancelFunc),
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		abortCtx:     abortCtx,
+		abort:        abort,
+	}
+}
+
+// Register adds the handler for a job type. Must be called before Start.
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.handlers[jobType] = handler
+}
+
+// Schedule enqueues a job of jobType every interval, skipping a run while
+// a previous one is still pending or running. Must be called before Start.
+func (q *JobQueue) Schedule(jobType string, interval time.Duration) {
+	q.schedules = append(q.schedules, jobSchedule{jobType: jobType, interval: interval})
+}
+
+// Enqueue adds a job that should run as soon as a worker is free
+func (q *JobQueue) Enqueue(jobType string, payload interface{}) (*models.Job, error) {
+	return q.EnqueueAt(jobType, payload, time.Now())
+}
+
+// EnqueueAt adds a job that should not run before runAt
+func (q *JobQueue) EnqueueAt(jobType string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	if _, ok := q.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     data,
+		MaxAttempts: q.maxAttempts,
+		RunAt:       runAt,
+	}
+	if err := q.repo.Create(job); err != nil {
+		return nil, err
+	}
+
+	q.notify()
+	return job, nil
+}
+
+// Retry re-queues a dead or cancelled job with a fresh attempt budget
+func (q *JobQueue) Retry(id int64) error {
+	ok, err := q.repo.Retry(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrJobNotRetryable
+	}
+	q.notify()
+	return nil
+}
+
+// Cancel stops a pending job from running. A job running on this instance
+// has its context cancelled; handlers should return promptly when it is.
+func (q *JobQueue) Cancel(id int64) error {
+	ok, err := q.repo.Cancel(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrJobNotCancellable
+	}
+
+	q.mu.Lock()
+	if cancel, exists := q.running[id]; exists {
+		cancel()
+	}
+	q.mu.Unlock()
+	return nil
+}
+
+// Start launches the workers and schedulers
+func (q *JobQueue) Start() {
+	// Anything still marked running at startup was interrupted by a crash
+	if n, err := q.repo.RequeueStale(time.Now().Add(-q.jobTimeout)); err != nil {
+		log.Printf("[Jobs] ERROR: Failed to requeue stale jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("[Jobs] Requeued %d stale jobs", n)
+	}
+
+	types := make([]string, 0, len(q.handlers))
+	for t := range q.handlers {
+		types = append(types, t)
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(types)
+	}
+
+	for _, s := range q.schedules {
+		q.wg.Add(1)
+		go q.scheduler(s)
+	}
+
+	q.wg.Add(1)
+	go q.reaper()
+
+	log.Printf("[Jobs] Started %d workers for %d job types", q.workers, len(types))
+}
+
+// Shutdown stops claiming new jobs and waits for running ones to finish.
+// If ctx expires first, running jobs are cancelled and returned to the queue.
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("[Jobs] All workers stopped")
+		return nil
+	case <-ctx.Done():
+		log.Println("[Jobs] Drain timed out, cancelling running jobs")
+		q.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (q *JobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueue) worker(types []string) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.repo.Claim(types)
+		if err != nil {
+			log.Printf("[Jobs] ERROR: Failed to claim job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-q.stop:
+				return
+			case <-q.wake:
+			case <-time.After(q.pollInterval):
+			}
+			continue
+		}
+
+		q.run(job)
+	}
+}
+
+func (q *JobQueue) run(job *models.Job) {
+	ctx, cancel := context.WithTimeout(q.abortCtx, q.jobTimeout)
+	defer cancel()
+
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+
+	start := time.Now()
+	err := q.call(ctx, job)
+
+	q.mu.Lock()
+	delete(q.running, job.ID)
+	q.mu.Unlock()
+
+	switch {
+	case err == nil:
+		if err := q.repo.MarkSucceeded(job.ID); err != nil {
+			log.Printf("[Jobs] ERROR: Failed to mark job %d succeeded: %v", job.ID, err)
+		}
+		log.Printf("[Jobs] %s #%d succeeded in %v", job.Type, job.ID, time.Since(start).Round(time.Millisecond))
+
+	case q.abortCtx.Err() != nil:
+		// Interrupted by shutdown; not the job's fault
+		if err := q.repo.Release(job.ID); err != nil {
+			log.Printf("[Jobs] ERROR: Failed to release job %d: %v", job.ID, err)
+		}
+
+	case errors.Is(ctx.Err(), context.Canceled):
+		// Cancelled through the API; the row is already marked cancelled
+		log.Printf("[Jobs] %s #%d cancelled", job.Type, job.ID)
+
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		if err := q.repo.MarkDead(job.ID, err.Error()); err != nil {
+			log.Printf("[Jobs] ERROR: Failed to dead-letter job %d: %v", job.ID, err)
+		}
+		log.Printf("[Jobs] ERROR: %s #%d failed permanently after %d attempts: %v", job.Type, job.ID, job.Attempts, err)
+
+	default:
+		delay := q.retryDelay(job.Attempts)
+		delay += time.Duration(rand.Int63n(int64(delay)/10 + 1)) // jitter so failed batches don't retry in lockstep
+		if err := q.repo.MarkRetry(job.ID, err.Error(), time.Now().Add(delay)); err != nil {
+			log.Printf("[Jobs] ERROR: Failed to reschedule job %d: %v", job.ID, err)
+		}
+		log.Printf("[Jobs] %s #%d attempt %d/%d failed, retrying in %v: %v",
+			job.Type, job.ID, job.Attempts, job.MaxAttempts, delay.Round(time.Second), err)
+	}
+}
+
+// call runs the handler, turning a panic into a regular failure
+func (q *JobQueue) call(ctx context.Context, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type))
+	}
+	return handler(ctx, job)
+}
+
+// retryDelay returns the backoff before the next attempt: base * 2^(attempt-1), capped
+func (q *JobQueue) retryDelay(attempt int) time.Duration {
+	delay := q.backoffBase
+	for i := 1; i < attempt && delay < q.backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > q.backoffMax {
+		delay = q.backoffMax
+	}
+	return delay
+}
+
+func (q *JobQueue) scheduler(s jobSchedule) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		active, err := q.repo.HasActive(s.jobType)
+		if err != nil {
+			log.Printf("[Jobs] ERROR: Failed to check scheduled job %s: %v", s.jobType, err)
+		} else if !active {
+			if _, err := q.Enqueue(s.jobType, struct{}{}); err != nil {
+				log.Printf("[Jobs] ERROR: Failed to enqueue scheduled job %s: %v", s.jobType, err)
+			}
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reaper periodically requeues jobs whose worker disappeared (e.g. another instance crashed)
+func (q *JobQueue) reaper() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			// Locks older than the job timeout can't belong to a live worker
+			if n, err := q.repo.RequeueStale(time.Now().Add(-q.jobTimeout - time.Minute)); err != nil {
+				log.Printf("[Jobs] ERROR: Failed to requeue stale jobs: %v", err)
+			} else if n > 0 {
+				log.Printf("[Jobs] Requeued %d stale jobs", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "jobs.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.RunMigrations(db))
+	return db
+}
+
+func newTestQueue(t *testing.T, maxAttempts int) (*JobQueue, *models.JobRepository) {
+	t.Helper()
+	return newTestQueueOn(t, newTestDB(t), maxAttempts)
+}
+
+// newTestQueueOn creates a fast-polling queue on db
+func newTestQueueOn(t *testing.T, db *sql.DB, maxAttempts int) (*JobQueue, *models.JobRepository) {
+	t.Helper()
+
+	repo := models.NewJobRepository(db, "sqlite")
+	q := NewJobQueue(repo, 2, maxAttempts)
+	q.pollInterval = 10 * time.Millisecond
+	q.backoffBase = time.Millisecond
+	q.backoffMax = 5 * time.Millisecond
+	return q, repo
+}
+
+func waitForStatus(t *testing.T, repo *models.JobRepository, id int64, status string) *models.Job {
+	t.Helper()
+
+	var job *models.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = repo.GetByID(id)
+		return err == nil && job != nil && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestJobQueue_RetriesThenSucceeds(t *testing.T) {
+	q, repo := newTestQueue(t, 5)
+
+	var calls atomic.Int32
+	q.Register("flaky", TypedJob(func(ctx context.Context, p DeleteFilesPayload) error {
+		if calls.Add(1) < 3 {
+			return errors.New("temporary failure")
+		}
+		assert.Equal(t, []string{"/a", "/b"}, p.Paths)
+		return nil
+	}))
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	job, err := q.Enqueue("flaky", DeleteFilesPayload{Paths: []string{"/a", "/b"}})
+	require.NoError(t, err)
+
+	done := waitForStatus(t, repo, job.ID, models.JobStatusSucceeded)
+	assert.Equal(t, 3, done.Attempts)
+	assert.Empty(t, done.LastError)
+	assert.NotNil(t, done.FinishedAt)
+}
+
+func TestJobQueue_DeadLetterAndRetry(t *testing.T) {
+	q, repo := newTestQueue(t, 2)
+
+	var fail atomic.Bool
+	fail.Store(true)
+	q.Register("broken", func(ctx context.Context, job *models.Job) error {
+		if fail.Load() {
+			return errors.New("still broken")
+		}
+		return nil
+	})
+	q.Register("bad-payload", TypedJob(func(ctx context.Context, p DeleteFilesPayload) error {
+		return nil
+	}))
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	job, err := q.Enqueue("broken", nil)
+	require.NoError(t, err)
+	dead := waitForStatus(t, repo, job.ID, models.JobStatusDead)
+	assert.Equal(t, 2, dead.Attempts)
+	assert.Equal(t, "still broken", dead.LastError)
+
+	// Undecodable payloads are dead-lettered without using up retries
+	bad, err := q.Enqueue("bad-payload", "not an object")
+	require.NoError(t, err)
+	assert.Equal(t, 1, waitForStatus(t, repo, bad.ID, models.JobStatusDead).Attempts)
+
+	// A manual retry gets a fresh attempt budget
+	fail.Store(false)
+	require.NoError(t, q.Retry(job.ID))
+	waitForStatus(t, repo, job.ID, models.JobStatusSucceeded)
+
+	assert.ErrorIs(t, q.Retry(job.ID), ErrJobNotRetryable)
+}
+
+func TestJobQueue_CancelPending(t *testing.T) {
+	q, repo := newTestQueue(t, 3)
+
+	var ran atomic.Bool
+	q.Register("later", func(ctx context.Context, job *models.Job) error {
+		ran.Store(true)
+		return nil
+	})
+	q.Start()
+
+	job, err := q.EnqueueAt("later", nil, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, q.Cancel(job.ID))
+	assert.ErrorIs(t, q.Cancel(job.ID), ErrJobNotCancellable)
+
+	require.NoError(t, q.Shutdown(context.Background()))
+	assert.False(t, ran.Load())
+	waitForStatus(t, repo, job.ID, models.JobStatusCancelled)
+}
+
+func TestJobQueue_ShutdownRequeuesInterruptedJobs(t *testing.T) {
+	q, repo := newTestQueue(t, 3)
+
+	started := make(chan struct{})
+	q.Register("slow", func(ctx context.Context, job *models.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Start()
+
+	job, err := q.Enqueue("slow", nil)
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+
+	requeued := waitForStatus(t, repo, job.ID, models.JobStatusPending)
+	assert.Equal(t, 0, requeued.Attempts)
+}
+
+func TestJobQueue_RetryDelay(t *testing.T) {
+	q := NewJobQueue(nil, 1, 1)
+
+	assert.Equal(t, 30*time.Second, q.retryDelay(1))
+	assert.Equal(t, 60*time.Second, q.retryDelay(2))
+	assert.Equal(t, 4*time.Minute, q.retryDelay(4))
+	assert.Equal(t, time.Hour, q.retryDelay(20))
+}
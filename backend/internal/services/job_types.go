@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"titan-backend/internal/hls"
+	"titan-backend/internal/models"
+)
+
+// Built-in job types
+const (
+	JobDeleteFiles      = "storage.delete_files"
+	JobCleanupShares    = "shares.cleanup"
+	JobCleanupUploads   = "uploads.cleanup"
+	JobPurgeJobs        = "jobs.purge"
+	JobPackageHLS       = "video.package_hls"
+	JobRefreshRelated   = "videos.refresh_related"
+	JobRefreshTrending  = "videos.refresh_trending"
+	JobPublishScheduled = "videos.publish_scheduled"
+	JobPurgeVersion     = "videos.purge_version"
+)
+
+// finishedJobRetention is how long succeeded and cancelled jobs stay visible in /api/jobs
+const finishedJobRetention = 7 * 24 * time.Hour
+
+// DeleteFilesPayload lists storage URLs (e.g. /storage/videos/x.mp4) to remove
+type DeleteFilesPayload struct {
+	Paths []string `json:"paths"`
+}
+
+// PackageHLSPayload identifies the video to remux into HLS
+type PackageHLSPayload struct {
+	VideoID int `json:"videoId"`
+}
+
+// PurgeVersionPayload identifies a replaced video file whose grace period is over
+type PurgeVersionPayload struct {
+	VersionID int `json:"versionId"`
+}
+
+// RegisterMaintenanceJobs registers the built-in job handlers and their schedules
+func RegisterMaintenanceJobs(
+	q *JobQueue,
+	jobRepo *models.JobRepository,
+	fileRepo *models.FileRepository,
+	storageService *StorageService,
+	uploadService *UploadService,
+) {
+	q.Register(JobDeleteFiles, TypedJob(func(ctx context.Context, p DeleteFilesPayload) error {
+		for _, path := range p.Paths {
+			if err := storageService.DeleteFile(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	q.Register(JobCleanupShares, func(ctx context.Context, job *models.Job) error {
+		removed, err := fileRepo.CleanupExpiredShares()
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			log.Printf("[Jobs] Removed %d expired share links", removed)
+		}
+		return nil
+	})
+
+	q.Register(JobCleanupUploads, func(ctx context.Context, job *models.Job) error {
+		removed, err := uploadService.CleanupExpired()
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			log.Printf("[Jobs] Removed %d expired uploads", removed)
+		}
+		return nil
+	})
+
+	q.Register(JobPurgeJobs, func(ctx context.Context, job *models.Job) error {
+		removed, err := jobRepo.DeleteFinished(time.Now().Add(-finishedJobRetention))
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			log.Printf("[Jobs] Purged %d finished jobs", removed)
+		}
+		return nil
+	})
+
+	q.Schedule(JobCleanupShares, 1*time.Hour)
+	q.Schedule(JobCleanupUploads, 1*time.Hour)
+	q.Schedule(JobPurgeJobs, 24*time.Hour)
+}
+
+// RegisterVideoJobs registers job handlers that process uploaded videos and
+// publish scheduled ones
+func RegisterVideoJobs(q *JobQueue, videoRepo *models.VideoRepository, storageService *StorageService) {
+	q.Register(JobPublishScheduled, func(ctx context.Context, job *models.Job) error {
+		published, err := videoRepo.PublishScheduled(time.Now())
+		if err != nil {
+			return err
+		}
+		if published > 0 {
+			log.Printf("[Jobs] Published %d scheduled videos", published)
+		}
+		return nil
+	})
+	q.Schedule(JobPublishScheduled, 1*time.Minute)
+
+	q.Register(JobPackageHLS, TypedJob(func(ctx context.Context, p PackageHLSPayload) error {
+		video, err := videoRepo.GetByID(p.VideoID)
+		if err != nil {
+			return err
+		}
+		if video == nil {
+			return nil // deleted while queued
+		}
+
+		start := time.Now()
+		hlsURL, err := storageService.PackageHLS(ctx, video.URL)
+		if errors.Is(err, hls.ErrUnsupported) || errors.Is(err, hls.ErrInvalidMP4) {
+			return Permanent(err)
+		}
+		if err != nil {
+			return fmt.Errorf("package video %d: %w", video.ID, err)
+		}
+
+		stored, err := videoRepo.SetHLSURL(video.ID, video.URL, hlsURL)
+		if err != nil || !stored {
+			storageService.DeleteFile(hlsURL)
+			return err
+		}
+		log.Printf("[Jobs] Packaged video %d as HLS in %v", video.ID, time.Since(start).Round(time.Millisecond))
+		return nil
+	}))
+
+	q.Register(JobPurgeVersion, TypedJob(func(ctx context.Context, p PurgeVersionPayload) error {
+		version, err := videoRepo.GetVersion(p.VersionID)
+		if err != nil {
+			return err
+		}
+		if version == nil {
+			return nil // the video was deleted, along with its versions
+		}
+
+		for _, path := range []string{version.URL, version.HLSURL} {
+			if !strings.HasPrefix(path, "/storage/") {
+				continue // external links and empty HLS URLs
+			}
+			if err := storageService.DeleteFile(path); err != nil {
+				return err
+			}
+		}
+		return videoRepo.DeleteVersion(version.ID)
+	}))
+}
+
+// PurgeVersionLater schedules deletion of a replaced file once its grace
+// period is over
+func PurgeVersionLater(q *JobQueue, version *models.VideoVersion) {
+	if _, err := q.EnqueueAt(JobPurgeVersion, PurgeVersionPayload{VersionID: version.ID}, version.PurgeAt); err != nil {
+		log.Printf("[Jobs] ERROR: Failed to schedule purge of video %d version %d: %v", version.VideoID, version.ID, err)
+	}
+}
+
+// RegisterRecommendationJobs registers the job that rebuilds co-view related
+// videos from the view logs, run every interval
+func RegisterRecommendationJobs(q *JobQueue, similarityRepo *models.SimilarityRepository, interval time.Duration) {
+	q.Register(JobRefreshRelated, func(ctx context.Context, job *models.Job) error {
+		start := time.Now()
+		stored, err := similarityRepo.Rebuild(start.Add(-models.CoViewLookback))
+		if err != nil {
+			return err
+		}
+		log.Printf("[Jobs] Rebuilt %d co-view related videos in %v", stored, time.Since(start).Round(time.Millisecond))
+		return nil
+	})
+
+	q.Schedule(JobRefreshRelated, interval)
+}
+
+// RegisterTrendingJobs registers the job that recomputes trending scores
+// from recent views, run every interval
+func RegisterTrendingJobs(q *JobQueue, videoRepo *models.VideoRepository, interval time.Duration) {
+	q.Register(JobRefreshTrending, func(ctx context.Context, job *models.Job) error {
+		trending, err := videoRepo.RefreshTrending(time.Now())
+		if err != nil {
+			return err
+		}
+		log.Printf("[Jobs] Refreshed trending scores, %d videos trending", trending)
+		return nil
+	})
+
+	q.Schedule(JobRefreshTrending, interval)
+}
+
+// DeleteFilesInBackground queues removal of storage files so request handlers
+// don't block on the filesystem. Falls back to deleting inline if the job
+// can't be queued.
+func DeleteFilesInBackground(q *JobQueue, storageService *StorageService, paths ...string) {
+	var toDelete []string
+	for _, p := range paths {
+		if p != "" {
+			toDelete = append(toDelete, p)
+		}
+	}
+	if len(toDelete) == 0 {
+		return
+	}
+
+	if _, err := q.Enqueue(JobDeleteFiles, DeleteFilesPayload{Paths: toDelete}); err != nil {
+		log.Printf("[Jobs] ERROR: Failed to queue file deletion, deleting inline: %v", err)
+		for _, p := range toDelete {
+			storageService.DeleteFile(p)
+		}
+	}
+}
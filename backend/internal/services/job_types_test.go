@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/models"
+)
+
+func TestPurgeVersion_DeletesReplacedFilesAfterPurgeAt(t *testing.T) {
+	// Stored URLs are relative to the working directory
+	t.Chdir(t.TempDir())
+	storage := NewStorageService("storage/videos", "storage/thumbnails", "storage/ads", "storage/captions")
+	for _, path := range []string{
+		"storage/videos/a.mp4", "storage/videos/a.m3u8", "storage/videos/a_hls/0.m4s", "storage/videos/b.mp4",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+	}
+
+	db := newTestDB(t)
+	videoRepo := models.NewVideoRepository(db)
+	video := &models.Video{Title: "Clip", Creator: "Ann", Category: "other", URL: "/storage/videos/a.mp4"}
+	require.NoError(t, videoRepo.Create(video))
+	_, err := videoRepo.SetHLSURL(video.ID, video.URL, "/storage/videos/a.m3u8")
+	require.NoError(t, err)
+	video.HLSURL = "/storage/videos/a.m3u8"
+
+	q, _ := newTestQueueOn(t, db, 3)
+	RegisterVideoJobs(q, videoRepo, storage)
+	version, err := videoRepo.ReplaceMedia(video, "/storage/videos/b.mp4", nil, time.Now().Add(400*time.Millisecond))
+	require.NoError(t, err)
+	PurgeVersionLater(q, version)
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	// The old files outlive the replacement until purge_at
+	time.Sleep(100 * time.Millisecond)
+	assert.FileExists(t, "storage/videos/a.mp4")
+	assert.FileExists(t, "storage/videos/a.m3u8")
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat("storage/videos/a.mp4")
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		kept, err := videoRepo.GetVersion(version.ID)
+		return err == nil && kept == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoFileExists(t, "storage/videos/a.m3u8")
+	assert.NoDirExists(t, "storage/videos/a_hls")
+	assert.FileExists(t, "storage/videos/b.mp4")
+	current, err := videoRepo.GetByID(video.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "/storage/videos/b.mp4", current.URL)
+}
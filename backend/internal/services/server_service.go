@@ -278,7 +278,7 @@ func (s *ServerService) ExecuteCommand(command string) *models.ConsoleCommand {
 				limit = n
 			}
 		}
-		logs, err := s.logRepo.GetRecent(limit)
+		logs, _, err := s.logRepo.GetRecent(limit, nil)
 		if err != nil {
 			result.Output = "Error fetching logs: " + err.Error()
 			result.Success = false
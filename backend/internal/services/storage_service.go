@@ -1,65 +1,250 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif" // Decoders for ImageSize
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"titan-backend/internal/hls"
+	"titan-backend/internal/mediaprobe"
+
 	"github.com/google/uuid"
 )
 
+// ErrInvalidMedia is returned when an uploaded video fails container validation
+var ErrInvalidMedia = errors.New("invalid video file")
+
 type StorageService struct {
 	videoPath     string
 	thumbnailPath string
 	adPath        string
+	captionPath   string
 }
 
-func NewStorageService(videoPath, thumbnailPath, adPath string) *StorageService {
+func NewStorageService(videoPath, thumbnailPath, adPath, captionPath string) *StorageService {
 	// Ensure directories exist
 	os.MkdirAll(videoPath, 0755)
 	os.MkdirAll(thumbnailPath, 0755)
 	os.MkdirAll(adPath, 0755)
+	os.MkdirAll(captionPath, 0755)
 
 	return &StorageService{
 		videoPath:     videoPath,
 		thumbnailPath: thumbnailPath,
 		adPath:        adPath,
+		captionPath:   captionPath,
 	}
 }
 
-func (s *StorageService) SaveVideo(file multipart.File, header *multipart.FileHeader) (string, error) {
-	return s.saveFile(file, header, s.videoPath, []string{".mp4", ".webm", ".mov", ".avi"})
+// Allowed upload extensions per media type
+var (
+	videoExtensions = []string{".mp4", ".webm", ".mov", ".avi"}
+	imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+)
+
+// SaveVideo stores an uploaded video and probes its container. Files whose
+// contents don't match their extension are removed and rejected with ErrInvalidMedia.
+func (s *StorageService) SaveVideo(file multipart.File, header *multipart.FileHeader) (string, *mediaprobe.Info, error) {
+	url, err := s.saveFile(file, header, s.videoPath, videoExtensions)
+	if err != nil {
+		return "", nil, err
+	}
+
+	filePath := strings.TrimPrefix(url, "/")
+	info, err := probeVideo(filePath, header.Filename)
+	if err != nil {
+		os.Remove(filePath)
+		return "", nil, err
+	}
+	return url, info, nil
 }
 
 func (s *StorageService) SaveThumbnail(file multipart.File, header *multipart.FileHeader) (string, error) {
-	return s.saveFile(file, header, s.thumbnailPath, []string{".jpg", ".jpeg", ".png", ".gif", ".webp"})
+	return s.saveFile(file, header, s.thumbnailPath, imageExtensions)
 }
 
 func (s *StorageService) SaveAdImage(file multipart.File, header *multipart.FileHeader) (string, error) {
-	return s.saveFile(file, header, s.adPath, []string{".jpg", ".jpeg", ".png", ".gif", ".webp"})
+	return s.saveFile(file, header, s.adPath, imageExtensions)
 }
 
-func (s *StorageService) saveFile(file multipart.File, header *multipart.FileHeader, basePath string, allowedExts []string) (string, error) {
-	// Get file extension
-	ext := strings.ToLower(filepath.Ext(header.Filename))
+// SaveCaption stores a WebVTT caption track, named after the uploaded file
+func (s *StorageService) SaveCaption(vtt []byte, originalName string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(originalName), filepath.Ext(originalName)) + ".vtt"
+	filePath := filepath.Join(s.captionPath, uniqueFilename(name))
+	if err := os.WriteFile(filePath, vtt, 0644); err != nil {
+		return "", err
+	}
+	return "/" + filepath.ToSlash(filePath), nil
+}
+
+// IsAllowedVideo reports whether filename has an extension accepted for videos
+func (s *StorageService) IsAllowedVideo(filename string) bool {
+	return hasAllowedExtension(filename, videoExtensions)
+}
+
+// ImportVideo moves an already-written file (e.g. a finished resumable upload)
+// into the video directory and returns its relative URL path.
+// originalName is only used for the extension check and the stored filename.
+func (s *StorageService) ImportVideo(srcPath, originalName string) (string, *mediaprobe.Info, error) {
+	if !hasAllowedExtension(originalName, videoExtensions) {
+		return "", nil, fmt.Errorf("invalid file type: %s", strings.ToLower(filepath.Ext(originalName)))
+	}
 
+	// Probe before moving so a rejected upload never lands in storage
+	info, err := probeVideo(srcPath, originalName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	filePath := filepath.Join(s.videoPath, uniqueFilename(originalName))
+	if err := moveFile(srcPath, filePath); err != nil {
+		return "", nil, err
+	}
+
+	return "/" + filePath, info, nil
+}
+
+// CheckVideo checks that the file at path is a video that would be accepted
+// as an upload and returns its probed metadata
+func (s *StorageService) CheckVideo(path string) (*mediaprobe.Info, error) {
+	if !hasAllowedExtension(path, videoExtensions) {
+		return nil, fmt.Errorf("invalid file type: %s", strings.ToLower(filepath.Ext(path)))
+	}
+	return probeVideo(path, path)
+}
+
+// CopyVideo copies a file checked with CheckVideo into the video directory,
+// leaving the original in place, and returns its relative URL path
+func (s *StorageService) CopyVideo(srcPath string) (string, error) {
+	return s.copyInto(srcPath, s.videoPath, videoExtensions)
+}
+
+// IsAllowedImage reports whether filename has an extension accepted for images
+func (s *StorageService) IsAllowedImage(filename string) bool {
+	return hasAllowedExtension(filename, imageExtensions)
+}
+
+// CopyThumbnail copies an image into the thumbnail directory, leaving the
+// original in place, and returns its relative URL path
+func (s *StorageService) CopyThumbnail(srcPath string) (string, error) {
+	return s.copyInto(srcPath, s.thumbnailPath, imageExtensions)
+}
+
+// ImageSize reads the dimensions of a stored image from its URL path. WebP
+// images aren't decoded and return an error.
+func (s *StorageService) ImageSize(url string) (int, int, error) {
+	file, err := os.Open(strings.TrimPrefix(url, "/"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+func (s *StorageService) copyInto(srcPath, basePath string, allowedExts []string) (string, error) {
+	if !hasAllowedExtension(srcPath, allowedExts) {
+		return "", fmt.Errorf("invalid file type: %s", strings.ToLower(filepath.Ext(srcPath)))
+	}
+
+	filePath := filepath.Join(basePath, uniqueFilename(filepath.Base(srcPath)))
+	if err := copyFile(srcPath, filePath); err != nil {
+		return "", err
+	}
+	return "/" + filePath, nil
+}
+
+// Containers the probe may report for each accepted extension
+var containersByExtension = map[string][]string{
+	".mp4":  {mediaprobe.ContainerMP4, mediaprobe.ContainerMOV},
+	".mov":  {mediaprobe.ContainerMP4, mediaprobe.ContainerMOV},
+	".webm": {mediaprobe.ContainerWebM, mediaprobe.ContainerMatroska},
+	".avi":  {mediaprobe.ContainerAVI},
+}
+
+// probeVideo reads the container headers of path and checks them against the
+// extension of originalName. Probe failures are wrapped in ErrInvalidMedia.
+func probeVideo(path, originalName string) (*mediaprobe.Info, error) {
+	info, err := mediaprobe.ProbeFile(path)
+	if errors.Is(err, mediaprobe.ErrUnknownFormat) || errors.Is(err, mediaprobe.ErrInvalidContainer) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(originalName))
+	matches := false
+	for _, c := range containersByExtension[ext] {
+		if c == info.Container {
+			matches = true
+			break
+		}
+	}
+	if !matches {
+		return nil, fmt.Errorf("%w: %s file contains %s data", ErrInvalidMedia, ext, info.Container)
+	}
+	if !info.HasVideo() {
+		return nil, fmt.Errorf("%w: no video track found", ErrInvalidMedia)
+	}
+
+	return info, nil
+}
+
+func (s *StorageService) saveFile(file multipart.File, header *multipart.FileHeader, basePath string, allowedExts []string) (string, error) {
 	// Validate extension
-	valid := false
+	if !hasAllowedExtension(header.Filename, allowedExts) {
+		return "", fmt.Errorf("invalid file type: %s", strings.ToLower(filepath.Ext(header.Filename)))
+	}
+
+	filePath := filepath.Join(basePath, uniqueFilename(header.Filename))
+
+	// Create destination file
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	// Copy file contents
+	if _, err := io.Copy(dst, file); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+
+	// Return relative URL path
+	return "/" + filePath, nil
+}
+
+func hasAllowedExtension(filename string, allowedExts []string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
 	for _, allowed := range allowedExts {
 		if ext == allowed {
-			valid = true
-			break
+			return true
 		}
 	}
-	if !valid {
-		return "", fmt.Errorf("invalid file type: %s", ext)
-	}
+	return false
+}
+
+// uniqueFilename builds a sanitized, collision-free name: originalname_uuid.ext
+func uniqueFilename(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
 
 	// Get the original filename without extension
-	originalName := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	originalName := strings.TrimSuffix(filename, filepath.Ext(filename))
 	// Sanitize the filename (remove special characters, spaces, etc.)
 	sanitizedName := strings.Map(func(r rune) rune {
 		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
@@ -76,26 +261,45 @@ func (s *StorageService) saveFile(file multipart.File, header *multipart.FileHea
 		sanitizedName = "video"
 	}
 
-	// Generate unique filename with original name: originalname_uuid.ext
 	uniqueID := uuid.New().String()[:8] // Use first 8 chars of UUID for brevity
-	filename := fmt.Sprintf("%s_%s%s", sanitizedName, uniqueID, ext)
-	filePath := filepath.Join(basePath, filename)
+	return fmt.Sprintf("%s_%s%s", sanitizedName, uniqueID, ext)
+}
 
-	// Create destination file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return "", err
+// moveFile renames src to dst, falling back to copy+remove when the two
+// paths live on different filesystems (e.g. separate Docker volumes)
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
 	}
-	defer dst.Close()
 
-	// Copy file contents
-	if _, err := io.Copy(dst, file); err != nil {
-		os.Remove(filePath)
-		return "", err
+	if err := copyFile(src, dst); err != nil {
+		return err
 	}
+	return os.Remove(src)
+}
 
-	// Return relative URL path
-	return "/" + filePath, nil
+// copyFile copies src to a new file dst, removing dst if the copy fails
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
 }
 
 func (s *StorageService) DeleteFile(filePath string) error {
@@ -104,6 +308,13 @@ func (s *StorageService) DeleteFile(filePath string) error {
 		filePath = filePath[1:]
 	}
 
+	// An HLS playlist owns the segment directory next to it
+	if strings.HasSuffix(filePath, ".m3u8") {
+		if err := os.RemoveAll(hlsSegmentDir(filePath)); err != nil {
+			return err
+		}
+	}
+
 	// Only delete if file exists
 	if _, err := os.Stat(filePath); err == nil {
 		return os.Remove(filePath)
@@ -111,6 +322,68 @@ func (s *StorageService) DeleteFile(filePath string) error {
 	return nil
 }
 
+// CanPackageHLS reports whether a probed upload can be remuxed to HLS without re-encoding
+func CanPackageHLS(info *mediaprobe.Info) bool {
+	if info == nil {
+		return false
+	}
+	container := info.Container == mediaprobe.ContainerMP4 || info.Container == mediaprobe.ContainerMOV
+	audio := info.AudioCodec == "" || info.AudioCodec == "aac"
+	return container && info.VideoCodec == "h264" && audio
+}
+
+// PackageHLS remuxes a stored video into CMAF segments and writes an HLS
+// playlist next to it: videos/name.mp4 gets videos/name.m3u8 plus a
+// videos/name_hls/ segment directory. Returns the playlist URL.
+func (s *StorageService) PackageHLS(ctx context.Context, videoURL string) (string, error) {
+	srcPath := strings.TrimPrefix(videoURL, "/")
+	if !strings.HasPrefix(filepath.Clean(srcPath), filepath.Clean(s.videoPath)+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is not a stored video", hls.ErrUnsupported, videoURL)
+	}
+
+	playlistPath := strings.TrimSuffix(srcPath, filepath.Ext(srcPath)) + ".m3u8"
+	segmentDir := hlsSegmentDir(playlistPath)
+
+	// Package into a scratch directory so a failed or repeated run never leaves a half-written playlist
+	tmpDir := segmentDir + ".tmp"
+	os.RemoveAll(tmpDir)
+	playlist, err := hls.Package(ctx, srcPath, tmpDir, hls.Options{})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+
+	os.RemoveAll(segmentDir)
+	if err := os.Rename(tmpDir, segmentDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+
+	tmpPlaylist := playlistPath + ".tmp"
+	f, err := os.Create(tmpPlaylist)
+	if err != nil {
+		return "", err
+	}
+	err = playlist.Encode(f, filepath.Base(segmentDir)+"/")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPlaylist, playlistPath)
+	}
+	if err != nil {
+		os.Remove(tmpPlaylist)
+		return "", err
+	}
+
+	return "/" + filepath.ToSlash(playlistPath), nil
+}
+
+// hlsSegmentDir returns the segment directory belonging to a playlist path
+func hlsSegmentDir(playlistPath string) string {
+	return strings.TrimSuffix(playlistPath, ".m3u8") + "_hls"
+}
+
 func (s *StorageService) GetVideoPath() string {
 	return s.videoPath
 }
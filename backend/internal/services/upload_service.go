@@ -0,0 +1,319 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Resumable upload errors
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+	ErrUploadLocked         = errors.New("upload is locked by another request")
+	ErrUploadExpired        = errors.New("upload has expired")
+	ErrUploadTooLarge       = errors.New("upload exceeds maximum size")
+)
+
+// Upload describes the state of a resumable (tus) upload.
+// The state is stored as a JSON file next to the partial data so uploads
+// survive server restarts.
+type Upload struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	VideoID   int               `json:"videoId,omitempty"` // Set once the video record exists
+}
+
+// IsComplete reports whether all bytes of the upload have been received
+func (u *Upload) IsComplete() bool {
+	return u.Offset == u.Length
+}
+
+// UploadService stores partial uploads on disk and tracks their offsets
+type UploadService struct {
+	uploadPath string
+	maxSize    int64
+	ttl        time.Duration
+	locks      map[string]bool
+	mu         sync.Mutex
+}
+
+// NewUploadService creates a new upload service
+// maxSize: largest accepted upload in bytes
+// ttl: how long an upload may sit idle before it is discarded
+func NewUploadService(uploadPath string, maxSize int64, ttl time.Duration) *UploadService {
+	os.MkdirAll(uploadPath, 0755)
+
+	s := &UploadService{
+		uploadPath: uploadPath,
+		maxSize:    maxSize,
+		ttl:        ttl,
+		locks:      make(map[string]bool),
+	}
+
+	return s
+}
+
+// MaxSize returns the largest accepted upload in bytes
+func (s *UploadService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Create registers a new upload and allocates an empty data file
+func (s *UploadService) Create(length int64, metadata map[string]string) (*Upload, error) {
+	if length > s.maxSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	now := time.Now()
+	upload := &Upload{
+		ID:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	f, err := os.Create(s.DataPath(upload.ID))
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	if err := s.save(upload); err != nil {
+		os.Remove(s.DataPath(upload.ID))
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// Get loads an upload by ID
+func (s *UploadService) Get(id string) (*Upload, error) {
+	if !isValidUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &Upload{}
+	if err := json.Unmarshal(data, upload); err != nil {
+		return nil, err
+	}
+
+	if !upload.IsComplete() && time.Now().After(upload.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+
+	return upload, nil
+}
+
+// WriteChunk appends data at offset and returns the updated upload.
+// Bytes received before a connection drop are kept, so the client can
+// resume from the new offset.
+func (s *UploadService) WriteChunk(id string, offset int64, r io.Reader) (*Upload, error) {
+	if !s.lock(id) {
+		return nil, ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	upload, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != upload.Offset {
+		return upload, ErrUploadOffsetMismatch
+	}
+
+	// Nothing is left to write, and a finished upload has no data file
+	if upload.IsComplete() {
+		return upload, nil
+	}
+
+	f, err := os.OpenFile(s.DataPath(id), os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Never write past the declared length
+	written, copyErr := io.Copy(f, io.LimitReader(r, upload.Length-upload.Offset))
+
+	upload.Offset += written
+	upload.ExpiresAt = time.Now().Add(s.ttl)
+	if err := s.save(upload); err != nil {
+		return nil, err
+	}
+
+	if copyErr != nil {
+		log.Printf("[Upload] Chunk interrupted: id=%s, offset=%d/%d: %v", id, upload.Offset, upload.Length, copyErr)
+		return upload, copyErr
+	}
+
+	return upload, nil
+}
+
+// Finish creates the video of a complete upload while holding its lock, so
+// that requests racing to deliver the last chunk, such as a retried final
+// PATCH, create one video between them. create returns the new video's ID,
+// which is recorded before the data file is removed. Uploads that are
+// incomplete or already finished are returned as they are.
+func (s *UploadService) Finish(id string, create func(*Upload) (int, error)) (*Upload, error) {
+	if !s.lock(id) {
+		return nil, ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	upload, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !upload.IsComplete() || upload.VideoID != 0 {
+		return upload, nil
+	}
+
+	videoID, err := create(upload)
+	if err != nil {
+		return upload, err
+	}
+
+	upload.VideoID = videoID
+	upload.ExpiresAt = time.Now().Add(s.ttl)
+	if err := s.save(upload); err != nil {
+		// The video exists either way, and removing the data below keeps a
+		// retry from creating another
+		log.Printf("[Upload] ERROR: Failed to record video %d for upload %s: %v", videoID, id, err)
+	}
+	os.Remove(s.DataPath(id))
+	return upload, nil
+}
+
+// Terminate deletes an upload and its data
+func (s *UploadService) Terminate(id string) error {
+	if !s.lock(id) {
+		return ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	if _, err := s.Get(id); err != nil && err != ErrUploadExpired {
+		return err
+	}
+
+	s.remove(id)
+	return nil
+}
+
+// DataPath returns the on-disk path holding the upload's bytes
+func (s *UploadService) DataPath(id string) string {
+	return filepath.Join(s.uploadPath, id+".bin")
+}
+
+// CleanupExpired removes uploads whose expiry has passed and returns how many were removed
+func (s *UploadService) CleanupExpired() (int, error) {
+	entries, err := os.ReadDir(s.uploadPath)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	now := time.Now()
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !isValidUploadID(id) {
+			continue
+		}
+
+		data, err := os.ReadFile(s.infoPath(id))
+		if err != nil {
+			continue
+		}
+		var upload Upload
+		if err := json.Unmarshal(data, &upload); err != nil {
+			continue
+		}
+
+		if now.After(upload.ExpiresAt) && s.lock(id) {
+			s.remove(id)
+			s.unlock(id)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func (s *UploadService) save(upload *Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated info file
+	tmpPath := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.infoPath(upload.ID))
+}
+
+func (s *UploadService) remove(id string) {
+	os.Remove(s.DataPath(id))
+	os.Remove(s.infoPath(id))
+}
+
+func (s *UploadService) infoPath(id string) string {
+	return filepath.Join(s.uploadPath, id+".json")
+}
+
+func (s *UploadService) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locks[id] {
+		return false
+	}
+	s.locks[id] = true
+	return true
+}
+
+func (s *UploadService) unlock(id string) {
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+}
+
+// isValidUploadID guards against path traversal through the upload ID
+func isValidUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, r := range id {
+		if !((r >= '0' && r <= '9') || (r >= 'a' && r <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUploads(t *testing.T, ttl time.Duration) *UploadService {
+	t.Helper()
+	return NewUploadService(t.TempDir(), 100, ttl)
+}
+
+func TestUploadService_ChunksFollowOffsets(t *testing.T) {
+	s := newTestUploads(t, time.Hour)
+
+	_, err := s.Create(101, nil)
+	assert.ErrorIs(t, err, ErrUploadTooLarge)
+
+	upload, err := s.Create(10, map[string]string{"filename": "clip.mp4"})
+	require.NoError(t, err)
+
+	upload, err = s.WriteChunk(upload.ID, 0, strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Offset)
+	assert.False(t, upload.IsComplete())
+
+	// A chunk at the wrong offset is refused, reporting the current one
+	upload, err = s.WriteChunk(upload.ID, 3, strings.NewReader("xx"))
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	assert.Equal(t, int64(5), upload.Offset)
+
+	// Bytes past the declared length are dropped
+	upload, err = s.WriteChunk(upload.ID, 5, strings.NewReader("world, and more"))
+	require.NoError(t, err)
+	assert.True(t, upload.IsComplete())
+	data, err := os.ReadFile(s.DataPath(upload.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld", string(data))
+
+	// The state survives a restart
+	restarted := NewUploadService(s.uploadPath, 100, time.Hour)
+	loaded, err := restarted.Get(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), loaded.Offset)
+	assert.Equal(t, "clip.mp4", loaded.Metadata["filename"])
+
+	_, err = s.Get("../../etc/passwd")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestUploadService_Locking(t *testing.T) {
+	s := newTestUploads(t, time.Hour)
+	upload, err := s.Create(10, nil)
+	require.NoError(t, err)
+
+	require.True(t, s.lock(upload.ID))
+	_, err = s.WriteChunk(upload.ID, 0, strings.NewReader("hello"))
+	assert.ErrorIs(t, err, ErrUploadLocked)
+	assert.ErrorIs(t, s.Terminate(upload.ID), ErrUploadLocked)
+	_, err = s.Finish(upload.ID, func(*Upload) (int, error) { return 1, nil })
+	assert.ErrorIs(t, err, ErrUploadLocked)
+	s.unlock(upload.ID)
+
+	require.NoError(t, s.Terminate(upload.ID))
+	_, err = s.Get(upload.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.NoFileExists(t, s.DataPath(upload.ID))
+}
+
+func TestUploadService_Expiry(t *testing.T) {
+	s := newTestUploads(t, 20*time.Millisecond)
+
+	idle, err := s.Create(10, nil)
+	require.NoError(t, err)
+	complete, err := s.Create(2, nil)
+	require.NoError(t, err)
+	_, err = s.WriteChunk(complete.ID, 0, strings.NewReader("ok"))
+	require.NoError(t, err)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = s.Get(idle.ID)
+	assert.ErrorIs(t, err, ErrUploadExpired)
+	_, err = s.WriteChunk(idle.ID, 0, strings.NewReader("late"))
+	assert.ErrorIs(t, err, ErrUploadExpired)
+
+	// Complete uploads can still be finished
+	_, err = s.Get(complete.ID)
+	assert.NoError(t, err)
+
+	removed, err := s.CleanupExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	_, err = s.Get(idle.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.NoFileExists(t, s.DataPath(idle.ID))
+}
+
+func TestUploadService_FinishCreatesOneVideo(t *testing.T) {
+	s := newTestUploads(t, time.Hour)
+	upload, err := s.Create(2, nil)
+	require.NoError(t, err)
+
+	// Incomplete uploads aren't finished
+	var calls atomic.Int32
+	create := func(u *Upload) (int, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return 42, nil
+	}
+	unfinished, err := s.Finish(upload.ID, create)
+	require.NoError(t, err)
+	assert.Zero(t, unfinished.VideoID)
+	assert.Zero(t, calls.Load())
+
+	_, err = s.WriteChunk(upload.ID, 0, strings.NewReader("ok"))
+	require.NoError(t, err)
+
+	// A failed attempt leaves the data for a retry
+	_, err = s.Finish(upload.ID, func(*Upload) (int, error) { return 0, errors.New("database is down") })
+	assert.Error(t, err)
+	assert.FileExists(t, s.DataPath(upload.ID))
+
+	// Requests racing to finish the upload create one video between them
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			finished, err := s.Finish(upload.ID, create)
+			if err != nil {
+				assert.ErrorIs(t, err, ErrUploadLocked)
+				return
+			}
+			assert.Equal(t, 42, finished.VideoID)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	finished, err := s.Finish(upload.ID, create)
+	require.NoError(t, err)
+	assert.Equal(t, 42, finished.VideoID)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.NoFileExists(t, s.DataPath(upload.ID))
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"titan-backend/internal/models"
+)
+
+var (
+	// ErrURLExpired is returned for a correctly signed URL past its expiry
+	ErrURLExpired = errors.New("signed url has expired")
+	// ErrURLInvalid is returned for missing, malformed or forged signatures
+	ErrURLInvalid = errors.New("invalid url signature")
+)
+
+// Query parameters carried by signed URLs
+const (
+	signExpiresParam = "exp"
+	signatureParam   = "sig"
+	signScopeParam   = "scope"
+	signIPParam      = "ip"
+)
+
+const (
+	storageURLPrefix = "/storage/"
+
+	// Expiry times are rounded up to this step so repeated API calls hand out
+	// identical URLs and browsers/CDNs can keep caching the media
+	signExpiryStep = 5 * time.Minute
+
+	// How long the public directory list from settings is reused before re-reading it
+	publicPathsTTL = 30 * time.Second
+)
+
+// URLSigner issues expiring HMAC-signed links for files under /storage and
+// verifies them when they are requested
+type URLSigner struct {
+	secret       []byte
+	ttl          time.Duration
+	bindIP       bool
+	settingsRepo *models.SettingsRepository
+
+	mu           sync.RWMutex
+	publicPaths  []string
+	publicLoaded time.Time
+	now          func() time.Time
+}
+
+// NewURLSigner creates a signer. When bindIP is set, links only work from the
+// client IP they were issued to. settingsRepo supplies the directories that
+// stay public; it may be nil, in which case nothing is public.
+func NewURLSigner(secret string, ttl time.Duration, bindIP bool, settingsRepo *models.SettingsRepository) *URLSigner {
+	return &URLSigner{
+		secret:       []byte(secret),
+		ttl:          ttl,
+		bindIP:       bindIP,
+		settingsRepo: settingsRepo,
+		now:          time.Now,
+	}
+}
+
+// SignURL signs a /storage URL for exactly that file. External URLs, empty
+// strings and files in public directories are returned unchanged.
+func (s *URLSigner) SignURL(rawURL, clientIP string) string {
+	return s.sign(rawURL, false, clientIP)
+}
+
+// sign signs rawURL. With hls set the signature also covers the playlist's
+// segment directory, so one query string authorizes the playlist and all of
+// its segments.
+func (s *URLSigner) sign(rawURL string, hls bool, clientIP string) string {
+	if !strings.HasPrefix(rawURL, storageURLPrefix) || s.IsPublic(rawURL) {
+		return rawURL
+	}
+
+	target := strings.SplitN(rawURL, "?", 2)[0]
+	key := target
+	if hls {
+		key = hlsSegmentDir(target) + "/"
+	}
+
+	step := int64(signExpiryStep / time.Second)
+	exp := (s.now().Add(s.ttl).Unix() + step - 1) / step * step
+
+	q := url.Values{}
+	q.Set(signExpiresParam, strconv.FormatInt(exp, 10))
+	if hls {
+		q.Set(signScopeParam, target)
+	}
+	ip := ""
+	if s.bindIP {
+		ip = clientIP
+		q.Set(signIPParam, "1")
+	}
+	q.Set(signatureParam, s.signature(key, exp, ip))
+
+	return target + "?" + q.Encode()
+}
+
+// SignVideo replaces a video's stored /storage paths with signed links. Only
+// call it on values that won't be written back to the database.
+func (s *URLSigner) SignVideo(v *models.Video, clientIP string) {
+	v.URL = s.SignURL(v.URL, clientIP)
+	v.Thumbnail = s.SignURL(v.Thumbnail, clientIP)
+	v.HLSURL = s.SignHLSURL(v.HLSURL, clientIP)
+	s.SignCaptions(v.Captions, clientIP)
+	s.SignChapters(v.Chapters, clientIP)
+}
+
+// SignHLSURL signs an HLS playlist so that one signature covers the playlist
+// and its _hls/ segment directory, whose URIs carry the playlist's query.
+// Other files sharing the playlist's name, such as the source video, aren't
+// covered.
+func (s *URLSigner) SignHLSURL(hlsURL, clientIP string) string {
+	if !strings.HasSuffix(strings.SplitN(hlsURL, "?", 2)[0], ".m3u8") {
+		return s.SignURL(hlsURL, clientIP)
+	}
+	return s.sign(hlsURL, true, clientIP)
+}
+
+// SignChapters replaces chapter thumbnails' stored /storage paths with signed links
+func (s *URLSigner) SignChapters(list []models.Chapter, clientIP string) {
+	for i := range list {
+		list[i].Thumbnail = s.SignURL(list[i].Thumbnail, clientIP)
+	}
+}
+
+// SignCaptions replaces caption tracks' stored /storage paths with signed links
+func (s *URLSigner) SignCaptions(list []models.Caption, clientIP string) {
+	for i := range list {
+		list[i].URL = s.SignURL(list[i].URL, clientIP)
+	}
+}
+
+// Verify checks the signature carried in query for the request path p
+func (s *URLSigner) Verify(p string, query url.Values, clientIP string) error {
+	sig := query.Get(signatureParam)
+	exp, err := strconv.ParseInt(query.Get(signExpiresParam), 10, 64)
+	if sig == "" || err != nil {
+		return ErrURLInvalid
+	}
+
+	// A scope is an HLS playlist: the signature covers it and the files in
+	// its segment directory, and is made over that directory
+	p = path.Clean(p)
+	key := p
+	if scope := query.Get(signScopeParam); scope != "" {
+		dir := hlsSegmentDir(scope)
+		if !strings.HasSuffix(scope, ".m3u8") || (p != scope && !strings.HasPrefix(p, dir+"/")) {
+			return ErrURLInvalid
+		}
+		key = dir + "/"
+	}
+
+	ip := ""
+	if query.Get(signIPParam) != "" {
+		ip = clientIP
+	}
+
+	expected := s.signature(key, exp, ip)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return ErrURLInvalid
+	}
+	if s.now().Unix() > exp {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) signature(key string, exp int64, ip string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(exp, 10) + "\n" + ip))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IsPublic reports whether a /storage path lies in a directory configured to
+// be served without a signature
+func (s *URLSigner) IsPublic(p string) bool {
+	rel := strings.TrimPrefix(path.Clean(strings.SplitN(p, "?", 2)[0]), storageURLPrefix)
+	for _, dir := range s.publicDirs() {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidatePublicPaths drops the cached public directory list so a settings
+// change takes effect immediately
+func (s *URLSigner) InvalidatePublicPaths() {
+	s.mu.Lock()
+	s.publicLoaded = time.Time{}
+	s.mu.Unlock()
+}
+
+func (s *URLSigner) publicDirs() []string {
+	if s.settingsRepo == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	dirs, loaded := s.publicPaths, s.publicLoaded
+	s.mu.RUnlock()
+	if !loaded.IsZero() && time.Since(loaded) < publicPathsTTL {
+		return dirs
+	}
+
+	settings, err := s.settingsRepo.GetAll()
+	if err != nil {
+		// Keep the last known list rather than locking everyone out
+		log.Printf("[URLSigner] ERROR: Failed to load public storage paths: %v", err)
+		return dirs
+	}
+
+	s.mu.Lock()
+	s.publicPaths = settings.PublicStoragePaths
+	s.publicLoaded = time.Now()
+	s.mu.Unlock()
+	return settings.PublicStoragePaths
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"titan-backend/internal/database"
+	"titan-backend/internal/models"
+)
+
+func newTestSigner(t *testing.T, bindIP bool) (*URLSigner, *models.SettingsRepository) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "settings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.RunMigrations(db))
+
+	repo := models.NewSettingsRepository(db)
+	return NewURLSigner("test-secret", time.Hour, bindIP, repo), repo
+}
+
+func splitSigned(t *testing.T, signed string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	return u.Path, u.Query()
+}
+
+func TestURLSigner_SignAndVerify(t *testing.T) {
+	s, _ := newTestSigner(t, false)
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+
+	signed := s.SignURL("/storage/videos/clip_1a2b3c4d.mp4", "1.2.3.4")
+	p, q := splitSigned(t, signed)
+	assert.Equal(t, "/storage/videos/clip_1a2b3c4d.mp4", p)
+	assert.NoError(t, s.Verify(p, q, "5.6.7.8"), "not IP bound")
+
+	// Expiry is rounded up so repeated calls return the same link
+	s.now = func() time.Time { return now.Add(time.Second) }
+	assert.Equal(t, signed, s.SignURL("/storage/videos/clip_1a2b3c4d.mp4", "1.2.3.4"))
+
+	assert.ErrorIs(t, s.Verify("/storage/videos/other.mp4", q, ""), ErrURLInvalid)
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_1a2b3c4d.mp4", url.Values{}, ""), ErrURLInvalid)
+
+	tampered := url.Values{"exp": {"9999999999"}, "sig": q["sig"]}
+	assert.ErrorIs(t, s.Verify(p, tampered, ""), ErrURLInvalid)
+
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	assert.ErrorIs(t, s.Verify(p, q, ""), ErrURLExpired)
+}
+
+func TestURLSigner_BindIP(t *testing.T) {
+	s, _ := newTestSigner(t, true)
+
+	p, q := splitSigned(t, s.SignURL("/storage/ads/banner.png", "1.2.3.4"))
+	assert.Equal(t, "1", q.Get("ip"))
+	assert.NoError(t, s.Verify(p, q, "1.2.3.4"))
+	assert.ErrorIs(t, s.Verify(p, q, "5.6.7.8"), ErrURLInvalid)
+
+	// Dropping the ip flag changes the signed message
+	q.Del("ip")
+	assert.ErrorIs(t, s.Verify(p, q, "5.6.7.8"), ErrURLInvalid)
+}
+
+func TestURLSigner_HLSScope(t *testing.T) {
+	s, _ := newTestSigner(t, false)
+
+	signed := s.SignHLSURL("/storage/videos/clip_1a2b3c4d.m3u8", "")
+	_, q := splitSigned(t, signed)
+	assert.Equal(t, "/storage/videos/clip_1a2b3c4d.m3u8", q.Get("scope"))
+
+	// The playlist and its segments share the signature
+	assert.NoError(t, s.Verify("/storage/videos/clip_1a2b3c4d.m3u8", q, ""))
+	assert.NoError(t, s.Verify("/storage/videos/clip_1a2b3c4d_hls/seg_00001.m4s", q, ""))
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_1a2b3c4d_hls/../../ads/x.png", q, ""), ErrURLInvalid)
+	assert.ErrorIs(t, s.Verify("/storage/videos/other.mp4", q, ""), ErrURLInvalid)
+
+	// Files that only share the playlist's name aren't covered
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_1a2b3c4d.mp4", q, ""), ErrURLInvalid)
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_1a2b3c4d_x_5f0e.mp4", q, ""), ErrURLInvalid)
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_1a2b3c4d_hls_old/seg_00001.m4s", q, ""), ErrURLInvalid)
+
+	// The scope can't be widened, or added to a single-file signature
+	q.Set("scope", "/storage/videos/clip.m3u8")
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_hls/seg_00001.m4s", q, ""), ErrURLInvalid)
+	q.Set("scope", "/storage/")
+	assert.ErrorIs(t, s.Verify("/storage/videos/other.mp4", q, ""), ErrURLInvalid)
+
+	_, q = splitSigned(t, s.SignURL("/storage/videos/clip_1a2b3c4d.m3u8", ""))
+	assert.NoError(t, s.Verify("/storage/videos/clip_1a2b3c4d.m3u8", q, ""))
+	q.Set("scope", "/storage/videos/clip_1a2b3c4d.m3u8")
+	assert.ErrorIs(t, s.Verify("/storage/videos/clip_1a2b3c4d_hls/seg_00001.m4s", q, ""), ErrURLInvalid)
+
+	assert.Empty(t, s.SignHLSURL("", ""))
+}
+
+func TestURLSigner_PublicPaths(t *testing.T) {
+	s, repo := newTestSigner(t, false)
+
+	// Thumbnails are public by default
+	assert.Equal(t, "/storage/thumbnails/a.jpg", s.SignURL("/storage/thumbnails/a.jpg", ""))
+	assert.True(t, s.IsPublic("/storage/thumbnails/a.jpg"))
+	assert.False(t, s.IsPublic("/storage/thumbnails-private/a.jpg"))
+	assert.False(t, s.IsPublic("/storage/thumbnails/../videos/a.mp4"))
+	assert.True(t, strings.Contains(s.SignURL("/storage/videos/a.mp4", ""), "sig="))
+
+	// External URLs are left alone
+	assert.Equal(t, "https://cdn.example.com/a.mp4", s.SignURL("https://cdn.example.com/a.mp4", ""))
+	assert.Equal(t, "", s.SignURL("", ""))
+
+	settings, err := repo.GetAll()
+	require.NoError(t, err)
+	settings.PublicStoragePaths = []string{"ads"}
+	require.NoError(t, repo.Update(settings))
+
+	// Cached until invalidated
+	assert.True(t, s.IsPublic("/storage/thumbnails/a.jpg"))
+	s.InvalidatePublicPaths()
+	assert.False(t, s.IsPublic("/storage/thumbnails/a.jpg"))
+	assert.True(t, s.IsPublic("/storage/ads/banner.png"))
+}
@@ -0,0 +1,182 @@
+// Package sitemap renders XML sitemaps (sitemaps.org 0.9) and sitemap
+// indexes, with Google's video extension for pages that show a video.
+//
+// A sitemap may list at most MaxURLs URLs and be at most 50MB uncompressed;
+// Render enforces the first and truncates video descriptions so that a
+// sitemap of MaxVideoURLs video pages stays under the second.
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxURLs is the most URLs a sitemap, or sitemaps an index, may list
+	MaxURLs = 50000
+	// MaxVideoURLs is the most URLs with video entries to put in a sitemap.
+	// A video entry can take a few KB, so a full sitemap of them would pass
+	// the 50MB limit.
+	MaxVideoURLs = 10000
+
+	// Limits of the video extension
+	maxVideoDescription = 2048
+	maxVideoDuration    = 8 * time.Hour
+)
+
+// ErrTooManyURLs is returned for sitemaps and indexes longer than MaxURLs
+var ErrTooManyURLs = errors.New("sitemap: more than 50000 URLs")
+
+// Namespaces of the documents
+const (
+	nsSitemap = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	nsVideo   = "http://www.google.com/schemas/sitemap-video/1.1"
+)
+
+// ContentType is the media type of sitemaps and indexes
+const ContentType = "application/xml; charset=utf-8"
+
+// Entry is a sitemap listed in an index
+type Entry struct {
+	Loc     string
+	LastMod time.Time // Zero if unknown
+}
+
+// URL is a page listed in a sitemap
+type URL struct {
+	Loc     string
+	LastMod time.Time // Zero if unknown
+	Video   *Video    // The video the page shows, if any
+}
+
+// Video describes the video of a page. Google requires the thumbnail, title,
+// description and either the content or the player location.
+type Video struct {
+	ThumbnailLoc    string
+	Title           string
+	Description     string
+	ContentLoc      string // The media file
+	PlayerLoc       string // An embeddable player page
+	Duration        time.Duration
+	ViewCount       int
+	PublicationDate time.Time
+	Uploader        string
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	XMLNS    string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Video   string       `xml:"xmlns:video,attr,omitempty"`
+	URLs    []urlElement `xml:"url"`
+}
+
+type urlElement struct {
+	Loc     string        `xml:"loc"`
+	LastMod string        `xml:"lastmod,omitempty"`
+	Video   *videoElement `xml:"video:video"`
+}
+
+type videoElement struct {
+	ThumbnailLoc    string `xml:"video:thumbnail_loc"`
+	Title           string `xml:"video:title"`
+	Description     string `xml:"video:description"`
+	ContentLoc      string `xml:"video:content_loc,omitempty"`
+	PlayerLoc       string `xml:"video:player_loc,omitempty"`
+	Duration        int    `xml:"video:duration,omitempty"`
+	ViewCount       int    `xml:"video:view_count"`
+	PublicationDate string `xml:"video:publication_date,omitempty"`
+	Uploader        string `xml:"video:uploader,omitempty"`
+}
+
+// RenderIndex writes a sitemap index listing entries
+func RenderIndex(entries []Entry) ([]byte, error) {
+	if len(entries) > MaxURLs {
+		return nil, ErrTooManyURLs
+	}
+	doc := &sitemapIndex{XMLNS: nsSitemap, Sitemaps: make([]sitemapEntry, len(entries))}
+	for i, entry := range entries {
+		doc.Sitemaps[i] = sitemapEntry{Loc: entry.Loc, LastMod: lastMod(entry.LastMod)}
+	}
+	return encode(doc)
+}
+
+// Render writes a sitemap listing urls. Video entries missing a thumbnail,
+// title or location are left out, as Google would reject them; their pages
+// are still listed.
+func Render(urls []URL) ([]byte, error) {
+	if len(urls) > MaxURLs {
+		return nil, ErrTooManyURLs
+	}
+	doc := &urlSet{XMLNS: nsSitemap, URLs: make([]urlElement, len(urls))}
+	for i := range urls {
+		u := &urls[i]
+		doc.URLs[i] = urlElement{Loc: u.Loc, LastMod: lastMod(u.LastMod)}
+		if v := u.Video; v != nil && v.ThumbnailLoc != "" && v.Title != "" && (v.ContentLoc != "" || v.PlayerLoc != "") {
+			doc.Video = nsVideo
+			doc.URLs[i].Video = videoEntry(v)
+		}
+	}
+	return encode(doc)
+}
+
+func videoEntry(v *Video) *videoElement {
+	// The description is required; the title stands in for a missing one
+	description := strings.Join(strings.Fields(v.Description), " ")
+	if description == "" {
+		description = v.Title
+	}
+	if utf8.RuneCountInString(description) > maxVideoDescription {
+		description = string([]rune(description)[:maxVideoDescription])
+	}
+
+	e := &videoElement{
+		ThumbnailLoc: v.ThumbnailLoc,
+		Title:        v.Title,
+		Description:  description,
+		ContentLoc:   v.ContentLoc,
+		PlayerLoc:    v.PlayerLoc,
+		ViewCount:    v.ViewCount,
+		Uploader:     v.Uploader,
+	}
+	if v.Duration >= time.Second && v.Duration <= maxVideoDuration {
+		e.Duration = int(v.Duration.Seconds())
+	}
+	if !v.PublicationDate.IsZero() {
+		e.PublicationDate = v.PublicationDate.UTC().Format(time.RFC3339)
+	}
+	return e
+}
+
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func encode(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
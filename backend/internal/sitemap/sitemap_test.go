@@ -0,0 +1,103 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderIndex(t *testing.T) {
+	body, err := RenderIndex([]Entry{
+		{Loc: "https://api.example.com/sitemaps/videos-1.xml", LastMod: time.Date(2024, 3, 1, 13, 0, 0, 0, time.FixedZone("", 3600))},
+		{Loc: "https://api.example.com/sitemaps/categories-1.xml"},
+	})
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"sitemap"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	require.Len(t, doc.Sitemaps, 2)
+	assert.Equal(t, "2024-03-01T12:00:00Z", doc.Sitemaps[0].LastMod)
+	assert.NotContains(t, string(body), "<lastmod></lastmod>")
+
+	_, err = RenderIndex(make([]Entry, MaxURLs+1))
+	assert.ErrorIs(t, err, ErrTooManyURLs)
+}
+
+func TestRender(t *testing.T) {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	body, err := Render([]URL{
+		{
+			Loc:     "https://videos.example.com/watch/2",
+			LastMod: published.Add(time.Hour),
+			Video: &Video{
+				ThumbnailLoc:    "https://api.example.com/feeds/media/2/thumbnail.jpg",
+				Title:           "<Live> set",
+				Description:     "Recorded\n  live",
+				ContentLoc:      "https://api.example.com/feeds/media/2/video.mp4",
+				PlayerLoc:       "https://api.example.com/embed/2",
+				Duration:        95 * time.Second,
+				ViewCount:       7,
+				PublicationDate: published,
+				Uploader:        "Ann",
+			},
+		},
+		// No thumbnail, so no video entry
+		{Loc: "https://videos.example.com/watch/1", Video: &Video{Title: "First", PlayerLoc: "https://api.example.com/embed/1"}},
+		{Loc: "https://videos.example.com/?category=music"},
+	})
+	require.NoError(t, err)
+
+	out := string(body)
+	assert.Contains(t, out, `xmlns:video="http://www.google.com/schemas/sitemap-video/1.1"`)
+	assert.Equal(t, 1, strings.Count(out, "<video:video>"))
+
+	var doc struct {
+		URLs []struct {
+			Loc   string `xml:"loc"`
+			Video *struct {
+				Title           string `xml:"title"`
+				Description     string `xml:"description"`
+				Duration        int    `xml:"duration"`
+				ViewCount       int    `xml:"view_count"`
+				PublicationDate string `xml:"publication_date"`
+			} `xml:"video"`
+		} `xml:"url"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	require.Len(t, doc.URLs, 3)
+	require.NotNil(t, doc.URLs[0].Video)
+	assert.Equal(t, "<Live> set", doc.URLs[0].Video.Title)
+	assert.Equal(t, "Recorded live", doc.URLs[0].Video.Description)
+	assert.Equal(t, 95, doc.URLs[0].Video.Duration)
+	assert.Equal(t, 7, doc.URLs[0].Video.ViewCount)
+	assert.Equal(t, "2024-03-01T12:00:00Z", doc.URLs[0].Video.PublicationDate)
+	assert.Nil(t, doc.URLs[1].Video)
+
+	_, err = Render(make([]URL, MaxURLs+1))
+	assert.ErrorIs(t, err, ErrTooManyURLs)
+}
+
+func TestRender_NoVideos(t *testing.T) {
+	body, err := Render([]URL{{Loc: "https://videos.example.com/?category=music"}})
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "xmlns:video")
+}
+
+func TestVideoEntry(t *testing.T) {
+	e := videoEntry(&Video{Title: "Untitled", Duration: 9 * time.Hour})
+	assert.Equal(t, "Untitled", e.Description)
+	assert.Zero(t, e.Duration)
+
+	e = videoEntry(&Video{Title: "Long", Description: strings.Repeat("é", 3000)})
+	assert.Equal(t, maxVideoDescription, len([]rune(e.Description)))
+}
@@ -6,42 +6,74 @@ import (
 )
 
 type Config struct {
-	Port             string
-	Host             string
-	Env              string
-	DatabaseURL      string // PostgreSQL connection URL
-	DatabasePath     string // SQLite path (fallback for local dev)
-	JWTSecret        string
-	JWTExpiryHours   int
-	AllowedOrigins   string
-	MaxVideoSizeMB   int
-	MaxImageSizeMB   int
-	StoragePath      string
-	VideoPath        string
-	ThumbnailPath    string
-	AdPath           string
-	DefaultAdminUser string
-	DefaultAdminPass string
+	Port                   string
+	Host                   string
+	Env                    string
+	DatabaseURL            string // PostgreSQL connection URL
+	DatabasePath           string // SQLite path (fallback for local dev)
+	JWTSecret              string
+	JWTExpiryHours         int
+	AllowedOrigins         string
+	MaxVideoSizeMB         int
+	MaxImageSizeMB         int
+	StoragePath            string
+	VideoPath              string
+	ThumbnailPath          string
+	AdPath                 string
+	CaptionPath            string
+	UploadPath             string // Partial resumable (tus) uploads, kept outside /storage
+	ImportPath             string // Files named in manifests uploaded to /api/videos/import, kept outside /storage
+	UploadTTLHours         int    // Incomplete uploads are discarded after this many idle hours
+	JobWorkers             int    // Background jobs processed concurrently
+	JobMaxAttempts         int    // Attempts before a failing job is dead-lettered
+	RelatedRefreshMinutes  int    // How often co-view related videos are rebuilt
+	TrendingRefreshMinutes int    // How often trending scores are recomputed
+	VersionGraceHours      int    // How long replaced media files and thumbnails are kept
+	URLSigningSecret       string // HMAC key for /storage links, defaults to JWTSecret
+	URLExpiryMinutes       int    // Lifetime of signed /storage links
+	URLBindClientIP        bool   // Signed links only work from the IP they were issued to
+	TrustedProxies         string // Comma-separated proxy IPs/CIDRs whose X-Forwarded-* headers are believed
+	PublicURL              string // Base URL of this API for absolute links, e.g. in feeds; defaults to the request's host
+	FrontendURL            string // Base URL of the site, where feed items link to; defaults to PublicURL
+	SitemapCacheMinutes    int    // How long built sitemaps are kept when the catalog doesn't change
+	DefaultAdminUser       string
+	DefaultAdminPass       string
 }
 
 func LoadConfig() *Config {
 	return &Config{
-		Port:             getEnv("PORT", "5000"),
-		Host:             getEnv("HOST", "localhost"),
-		Env:              getEnv("ENV", "development"),
-		DatabaseURL:      getEnv("DATABASE_URL", ""),
-		DatabasePath:     getEnv("DATABASE_PATH", "./titan.db"),
-		JWTSecret:        getEnv("JWT_SECRET", "default-secret-change-me"),
-		JWTExpiryHours:   getEnvAsInt("JWT_EXPIRY_HOURS", 24),
-		AllowedOrigins:   getEnv("ALLOWED_ORIGINS", "*"),
-		MaxVideoSizeMB:   getEnvAsInt("MAX_VIDEO_SIZE_MB", 2048),
-		MaxImageSizeMB:   getEnvAsInt("MAX_IMAGE_SIZE_MB", 5),
-		StoragePath:      getEnv("STORAGE_PATH", "./storage"),
-		VideoPath:        getEnv("VIDEO_PATH", "./storage/videos"),
-		ThumbnailPath:    getEnv("THUMBNAIL_PATH", "./storage/thumbnails"),
-		AdPath:           getEnv("AD_PATH", "./storage/ads"),
-		DefaultAdminUser: getEnv("DEFAULT_ADMIN_USER", "admin"),
-		DefaultAdminPass: getEnv("DEFAULT_ADMIN_PASS", "admin123"),
+		Port:                   getEnv("PORT", "5000"),
+		Host:                   getEnv("HOST", "localhost"),
+		Env:                    getEnv("ENV", "development"),
+		DatabaseURL:            getEnv("DATABASE_URL", ""),
+		DatabasePath:           getEnv("DATABASE_PATH", "./titan.db"),
+		JWTSecret:              getEnv("JWT_SECRET", "default-secret-change-me"),
+		JWTExpiryHours:         getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+		AllowedOrigins:         getEnv("ALLOWED_ORIGINS", "*"),
+		MaxVideoSizeMB:         getEnvAsInt("MAX_VIDEO_SIZE_MB", 2048),
+		MaxImageSizeMB:         getEnvAsInt("MAX_IMAGE_SIZE_MB", 5),
+		StoragePath:            getEnv("STORAGE_PATH", "./storage"),
+		VideoPath:              getEnv("VIDEO_PATH", "./storage/videos"),
+		ThumbnailPath:          getEnv("THUMBNAIL_PATH", "./storage/thumbnails"),
+		AdPath:                 getEnv("AD_PATH", "./storage/ads"),
+		CaptionPath:            getEnv("CAPTION_PATH", "./storage/captions"),
+		UploadPath:             getEnv("UPLOAD_PATH", "./uploads"),
+		ImportPath:             getEnv("IMPORT_PATH", "./imports"),
+		UploadTTLHours:         getEnvAsInt("UPLOAD_TTL_HOURS", 24),
+		JobWorkers:             getEnvAsInt("JOB_WORKERS", 4),
+		JobMaxAttempts:         getEnvAsInt("JOB_MAX_ATTEMPTS", 5),
+		RelatedRefreshMinutes:  getEnvAsInt("RELATED_REFRESH_MINUTES", 60),
+		TrendingRefreshMinutes: getEnvAsInt("TRENDING_REFRESH_MINUTES", 15),
+		VersionGraceHours:      getEnvAsInt("VIDEO_VERSION_GRACE_HOURS", 72),
+		URLSigningSecret:       getEnv("URL_SIGNING_SECRET", ""),
+		URLExpiryMinutes:       getEnvAsInt("URL_EXPIRY_MINUTES", 360),
+		URLBindClientIP:        getEnvAsBool("URL_BIND_CLIENT_IP", false),
+		TrustedProxies:         getEnv("TRUSTED_PROXIES", ""),
+		PublicURL:              getEnv("PUBLIC_URL", ""),
+		FrontendURL:            getEnv("FRONTEND_URL", ""),
+		SitemapCacheMinutes:    getEnvAsInt("SITEMAP_CACHE_MINUTES", 60),
+		DefaultAdminUser:       getEnv("DEFAULT_ADMIN_USER", "admin"),
+		DefaultAdminPass:       getEnv("DEFAULT_ADMIN_PASS", "admin123"),
 	}
 }
 
@@ -60,3 +92,12 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
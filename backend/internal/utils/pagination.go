@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
 )
 
@@ -9,15 +14,65 @@ type PaginationParams struct {
 	Page   int
 	Limit  int
 	Offset int
+	Cursor *Cursor // Set in cursor mode, where Page and Offset are unused
 }
 
+// PaginationMeta describes a page. Offset mode fills in the page number and
+// totals; cursor mode skips the count, so only the cursors tell where the
+// page is. Both modes return cursors, so a client can switch to cursor mode
+// after its first page.
 type PaginationMeta struct {
-	Page       int  `json:"page"`
-	Limit      int  `json:"limit"`
-	Total      int  `json:"total"`
-	TotalPages int  `json:"totalPages"`
-	HasNext    bool `json:"hasNext"`
-	HasPrev    bool `json:"hasPrev"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      *int   `json:"total,omitempty"`
+	TotalPages int    `json:"totalPages,omitempty"`
+	HasNext    bool   `json:"hasNext"`
+	HasPrev    bool   `json:"hasPrev"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// Cursor marks a row in a keyset-paginated listing by its sort key and ID.
+// Clients only see it as an opaque token.
+type Cursor struct {
+	Sort   string      `json:"s,omitempty"` // The listing's sort and order, e.g. "views:desc"
+	Key    interface{} `json:"k,omitempty"` // Sort key of the row
+	ID     int         `json:"i,omitempty"` // Tie-break for rows with equal keys
+	Before bool        `json:"b,omitempty"` // Page backwards from the row
+}
+
+// ErrInvalidCursor is returned for cursor tokens that don't decode
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode renders the cursor as a URL-safe token
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token made by Cursor.Encode. Numeric keys come back
+// as int64 or float64 so they bind like the column they were read from.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var c Cursor
+	if err := decoder.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if n, ok := c.Key.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			c.Key = i
+		} else if f, err := n.Float64(); err == nil {
+			c.Key = f
+		} else {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
 }
 
 func GetPaginationParams(r *http.Request) PaginationParams {
@@ -43,6 +98,20 @@ func GetPaginationParams(r *http.Request) PaginationParams {
 	}
 }
 
+// GetKeysetParams reads the pagination parameters plus an optional cursor
+// token, which switches the listing to cursor mode
+func GetKeysetParams(r *http.Request) (PaginationParams, error) {
+	params := GetPaginationParams(r)
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, err := DecodeCursor(token)
+		if err != nil {
+			return params, err
+		}
+		params.Cursor = cursor
+	}
+	return params, nil
+}
+
 func CalculatePaginationMeta(page, limit, total int) PaginationMeta {
 	totalPages := (total + limit - 1) / limit
 	if totalPages < 1 {
@@ -52,13 +121,52 @@ func CalculatePaginationMeta(page, limit, total int) PaginationMeta {
 	return PaginationMeta{
 		Page:       page,
 		Limit:      limit,
-		Total:      total,
+		Total:      &total,
 		TotalPages: totalPages,
 		HasNext:    page < totalPages,
 		HasPrev:    page > 1,
 	}
 }
 
+// KeysetPage finishes a page fetched with one row past the limit, which
+// tells whether more rows follow in the direction fetched. It drops that row,
+// puts a page fetched backwards back in display order and builds the
+// metadata, with cursors on the page's first and last rows. A total below
+// zero means the listing wasn't counted, so the metadata uses cursor mode
+// even for a first page.
+func KeysetPage[T any](rows []T, p PaginationParams, total int, cursor func(*T) Cursor) ([]T, PaginationMeta) {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+	backward := p.Cursor != nil && p.Cursor.Before
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	var meta PaginationMeta
+	switch {
+	case p.Cursor == nil && total >= 0:
+		meta = CalculatePaginationMeta(p.Page, p.Limit, total)
+	case backward:
+		meta = PaginationMeta{Limit: p.Limit, HasNext: true, HasPrev: more}
+	default:
+		meta = PaginationMeta{Limit: p.Limit, HasNext: more, HasPrev: p.Cursor != nil}
+	}
+
+	if len(rows) > 0 {
+		if meta.HasNext {
+			meta.NextCursor = cursor(&rows[len(rows)-1]).Encode()
+		}
+		if meta.HasPrev {
+			first := cursor(&rows[0])
+			first.Before = true
+			meta.PrevCursor = first.Encode()
+		}
+	}
+	return rows, meta
+}
+
 func getQueryParamAsInt(r *http.Request, key string, defaultValue int) int {
 	value := r.URL.Query().Get(key)
 	if value == "" {
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCursor_RoundTripsKeys(t *testing.T) {
+	for _, key := range []interface{}{int64(42), 0.125, "2024-01-02 03:04:05"} {
+		c, err := DecodeCursor(Cursor{Sort: "views:desc", Key: key, ID: 7}.Encode())
+		require.NoError(t, err)
+		assert.Equal(t, Cursor{Sort: "views:desc", Key: key, ID: 7}, *c)
+	}
+
+	_, err := DecodeCursor("not a cursor!")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestKeysetPage_Directions(t *testing.T) {
+	cursor := func(n *int) Cursor { return Cursor{ID: *n} }
+
+	// First page in offset mode: counted, with a cursor to continue from
+	rows, meta := KeysetPage([]int{1, 2, 3}, PaginationParams{Page: 1, Limit: 2}, 5, cursor)
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.Equal(t, 5, *meta.Total)
+	assert.True(t, meta.HasNext)
+	assert.Empty(t, meta.PrevCursor)
+
+	next, _ := DecodeCursor(meta.NextCursor)
+	assert.Equal(t, 2, next.ID)
+
+	// Forward from a cursor: not counted, and there is always a previous page
+	rows, meta = KeysetPage([]int{3, 4}, PaginationParams{Limit: 2, Cursor: next}, -1, cursor)
+	assert.Equal(t, []int{3, 4}, rows)
+	assert.Nil(t, meta.Total)
+	assert.False(t, meta.HasNext)
+	assert.True(t, meta.HasPrev)
+
+	prev, _ := DecodeCursor(meta.PrevCursor)
+	assert.Equal(t, Cursor{ID: 3, Before: true}, *prev)
+
+	// Backwards rows arrive nearest first and are put back in order
+	rows, meta = KeysetPage([]int{2, 1}, PaginationParams{Limit: 2, Cursor: prev}, -1, cursor)
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.True(t, meta.HasNext)
+	assert.False(t, meta.HasPrev)
+}
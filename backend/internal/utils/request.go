@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// trustedProxies are the addresses whose forwarding headers ClientIP believes
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the reverse proxies, as comma-separated IPs or CIDR
+// ranges, whose X-Forwarded-For, X-Real-IP and CF-Connecting-IP headers
+// ClientIP believes. With none, those headers are ignored.
+func SetTrustedProxies(list string) error {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+// fromTrustedProxy reports whether the request was made by a trusted proxy,
+// so that its forwarding headers can be believed
+func fromTrustedProxy(r *http.Request) bool {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	return isTrustedProxy(remote)
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP extracts the real client IP from the request. Proxy headers are
+// only honoured when the request comes from a trusted proxy, and then the
+// client is the rightmost X-Forwarded-For hop that isn't one: entries left
+// of it were written by the client and can't be believed.
+func ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	// Check X-Forwarded-For header (common for proxies/load balancers)
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop != "" && !isTrustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+
+	// Check X-Real-IP header
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return xri
+	}
+
+	// Check CF-Connecting-IP (Cloudflare)
+	if cfIP := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); cfIP != "" {
+		return cfIP
+	}
+
+	return remote
+}
+
+// ParseDate accepts a plain date (YYYY-MM-DD) or an RFC 3339 timestamp, as
+// given for from/to filters. A plain "to" date is exclusive, so
+// to=2024-02-01 covers all of January.
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// NotModified sets the ETag and Last-Modified headers of a response and
+// reports whether the request's conditions show the client already has it,
+// in which case it has written a 304 and the body must not be sent. As in
+// RFC 9110, If-Modified-Since is ignored when If-None-Match is present. A zero
+// modified time leaves Last-Modified out.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	notModified := false
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				notModified = true
+				break
+			}
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.IsZero() {
+		// Last-Modified only has whole seconds
+		notModified = !modified.Truncate(time.Second).After(since)
+	}
+
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return notModified
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP_TrustedProxies(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxies("") })
+
+	request := func(remote string, headers ...string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Add(headers[i], headers[i+1])
+		}
+		return ClientIP(r)
+	}
+
+	// Without trusted proxies the headers are ignored
+	require.NoError(t, SetTrustedProxies(""))
+	assert.Equal(t, "203.0.113.9", request("203.0.113.9:5123", "X-Forwarded-For", "198.51.100.1"))
+	assert.Equal(t, "203.0.113.9", request("203.0.113.9:5123", "X-Real-IP", "198.51.100.1"))
+
+	require.NoError(t, SetTrustedProxies("10.0.0.0/8, 192.0.2.7"))
+
+	// Only a trusted proxy's headers count
+	assert.Equal(t, "203.0.113.9", request("203.0.113.9:5123", "X-Forwarded-For", "198.51.100.1"))
+	assert.Equal(t, "198.51.100.1", request("192.0.2.7:5123", "X-Forwarded-For", "198.51.100.1"))
+
+	// Hops the client added in front of the proxy's are skipped
+	assert.Equal(t, "198.51.100.1", request("10.1.2.3:5123", "X-Forwarded-For", "1.2.3.4, 198.51.100.1, 10.0.0.5"))
+	assert.Equal(t, "198.51.100.1", request("10.1.2.3:5123", "X-Forwarded-For", "1.2.3.4", "X-Forwarded-For", "198.51.100.1"))
+
+	assert.Equal(t, "198.51.100.2", request("10.1.2.3:5123", "X-Real-IP", "198.51.100.2"))
+	assert.Equal(t, "10.1.2.3", request("10.1.2.3:5123"))
+
+	assert.Error(t, SetTrustedProxies("not-an-ip"))
+	assert.Error(t, SetTrustedProxies("10.0.0.0/99"))
+}
@@ -1,10 +1,36 @@
 package utils
 
 import (
+	"net/http"
 	"net/url"
 	"strings"
 )
 
+// BaseURL returns configured without a trailing slash or, when it's empty,
+// the scheme and host the request came in on. X-Forwarded-Proto and
+// X-Forwarded-Host are only believed from trusted proxies (see
+// SetTrustedProxies), so clients can't point links at another host.
+func BaseURL(r *http.Request, configured string) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if !fromTrustedProxy(r) {
+		return scheme + "://" + r.Host
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
 // NormalizeStorageURL ensures URLs are stored as relative paths only
 // This makes them portable across different environments (localhost, IPs, domains)
 func NormalizeStorageURL(rawURL string) string {
@@ -12,9 +38,9 @@ func NormalizeStorageURL(rawURL string) string {
 		return ""
 	}
 
-	// If it's already a relative path, return as-is
+	// If it's already a relative path, return as-is (minus any link signature)
 	if strings.HasPrefix(rawURL, "/") {
-		return rawURL
+		return StripStorageSignature(rawURL)
 	}
 
 	// Parse the URL
@@ -29,6 +55,17 @@ func NormalizeStorageURL(rawURL string) string {
 	return parsedURL.Path
 }
 
+// StripStorageSignature removes the query string from /storage URLs so signed
+// links handed out by the API are never persisted
+func StripStorageSignature(rawURL string) string {
+	if strings.HasPrefix(rawURL, "/storage/") {
+		if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+			return rawURL[:i]
+		}
+	}
+	return rawURL
+}
+
 // NormalizeShareURL ensures share URLs are stored as relative paths
 func NormalizeShareURL(rawURL string) string {
 	if rawURL == "" {
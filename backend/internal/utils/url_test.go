@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseURL_ForwardedHeadersNeedTrustedProxy(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxies("") })
+
+	request := func(remote string) string {
+		r := httptest.NewRequest("GET", "http://api.example.com/feed.xml", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Forwarded-Host", "evil.example.net, api.example.com")
+		return BaseURL(r, "")
+	}
+
+	require.NoError(t, SetTrustedProxies(""))
+	assert.Equal(t, "http://api.example.com", request("203.0.113.9:5123"))
+
+	require.NoError(t, SetTrustedProxies("10.0.0.0/8"))
+	assert.Equal(t, "http://api.example.com", request("203.0.113.9:5123"))
+	assert.Equal(t, "https://evil.example.net", request("10.1.2.3:5123"))
+
+	// A configured URL always wins
+	r := httptest.NewRequest("GET", "/", nil)
+	assert.Equal(t, "https://videos.example.com", BaseURL(r, "https://videos.example.com/"))
+}
@@ -0,0 +1,7 @@
+ALTER TABLE videos DROP COLUMN IF EXISTS file_size;
+ALTER TABLE videos DROP COLUMN IF EXISTS bitrate;
+ALTER TABLE videos DROP COLUMN IF EXISTS audio_codec;
+ALTER TABLE videos DROP COLUMN IF EXISTS video_codec;
+ALTER TABLE videos DROP COLUMN IF EXISTS height;
+ALTER TABLE videos DROP COLUMN IF EXISTS width;
+ALTER TABLE videos DROP COLUMN IF EXISTS duration_seconds;
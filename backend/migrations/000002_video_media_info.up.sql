@@ -0,0 +1,8 @@
+-- Technical metadata extracted from uploaded video containers
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS duration_seconds DOUBLE PRECISION;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS width INTEGER;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS height INTEGER;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS video_codec VARCHAR(32);
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS audio_codec VARCHAR(32);
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS bitrate BIGINT;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS file_size BIGINT;
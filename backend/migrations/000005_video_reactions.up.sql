@@ -0,0 +1,12 @@
+-- Video reactions table (one like/dislike per viewer per video)
+CREATE TABLE IF NOT EXISTS video_reactions (
+    video_id BIGINT NOT NULL,
+    viewer_id TEXT NOT NULL,
+    reaction TEXT NOT NULL CHECK(reaction IN ('like', 'dislike')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (video_id, viewer_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_reactions_viewer ON video_reactions(viewer_id);
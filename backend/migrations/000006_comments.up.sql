@@ -0,0 +1,32 @@
+-- Comments table (threaded via parent_id, soft deleted via deleted_at)
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    video_id BIGINT NOT NULL,
+    parent_id BIGINT,
+    author_id TEXT NOT NULL,
+    author_name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'approved' CHECK(status IN ('pending', 'approved', 'rejected')),
+    pinned INTEGER DEFAULT 0,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_video ON comments(video_id, parent_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id, status);
+CREATE INDEX IF NOT EXISTS idx_comments_status ON comments(status, created_at);
+
+-- Previous versions of edited comments
+CREATE TABLE IF NOT EXISTS comment_edits (
+    id BIGSERIAL PRIMARY KEY,
+    comment_id BIGINT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_edits_comment ON comment_edits(comment_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_view_logs_viewer;
+ALTER TABLE view_logs DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE view_logs DROP COLUMN IF EXISTS completed;
+ALTER TABLE view_logs DROP COLUMN IF EXISTS duration_seconds;
+ALTER TABLE view_logs DROP COLUMN IF EXISTS position_seconds;
+ALTER TABLE view_logs DROP COLUMN IF EXISTS viewer_id;
@@ -0,0 +1,8 @@
+-- Per-viewer watch progress on view logs (viewer_id is user:<id> or device:<id>)
+ALTER TABLE view_logs ADD COLUMN IF NOT EXISTS viewer_id TEXT;
+ALTER TABLE view_logs ADD COLUMN IF NOT EXISTS position_seconds DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE view_logs ADD COLUMN IF NOT EXISTS duration_seconds DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE view_logs ADD COLUMN IF NOT EXISTS completed INTEGER DEFAULT 0;
+ALTER TABLE view_logs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_view_logs_viewer ON view_logs(viewer_id, video_id);
@@ -0,0 +1,10 @@
+-- Full-text search over videos. The generated column keeps the vector in sync
+-- on insert and update; title matches weigh most, then creator, then description.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(creator, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN (search_vector);
@@ -0,0 +1,2 @@
+-- The parsed durations are left in place; they match the text column
+DROP INDEX IF EXISTS idx_videos_duration;
@@ -0,0 +1,18 @@
+-- Duration filters and sorting use duration_seconds. Videos added without a
+-- probe (external URLs, older uploads) only have the text duration, so parse
+-- it: SS, M:SS or H:MM:SS.
+UPDATE videos
+SET duration_seconds = CASE array_length(d.parts, 1)
+        WHEN 1 THEN d.parts[1]::int
+        WHEN 2 THEN d.parts[1]::int * 60 + d.parts[2]::int
+        ELSE d.parts[1]::int * 3600 + d.parts[2]::int * 60 + d.parts[3]::int
+    END
+FROM (
+    SELECT id, string_to_array(trim(duration), ':') AS parts
+    FROM videos
+    WHERE COALESCE(duration_seconds, 0) = 0
+      AND trim(duration) ~ '^\d+(:[0-5]\d){0,2}$'
+) d
+WHERE videos.id = d.id;
+
+CREATE INDEX IF NOT EXISTS idx_videos_duration ON videos(duration_seconds);
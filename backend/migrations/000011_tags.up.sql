@@ -0,0 +1,33 @@
+-- Tags, identified by their normalized slug
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    slug TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS video_tags (
+    video_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (video_id, tag_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_tags_tag ON video_tags(tag_id, video_id);
+
+-- Tag names copied onto the video so they are searchable, weighted like the creator
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS search_tags TEXT DEFAULT '';
+
+DROP INDEX IF EXISTS idx_videos_search;
+ALTER TABLE videos DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE videos ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(creator, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(search_tags, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN (search_vector);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_view_logs_viewed_at;
+DROP TABLE IF EXISTS video_similarity;
@@ -0,0 +1,13 @@
+-- Co-view recommendations, rebuilt from view_logs by a background job
+CREATE TABLE IF NOT EXISTS video_similarity (
+    video_id BIGINT NOT NULL,
+    similar_video_id BIGINT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    co_viewers INTEGER NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (video_id, similar_video_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+    FOREIGN KEY (similar_video_id) REFERENCES videos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_view_logs_viewed_at ON view_logs(viewed_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_videos_trending;
+ALTER TABLE videos DROP COLUMN IF EXISTS trending_score;
@@ -0,0 +1,4 @@
+-- Decayed recent views, refreshed by a background job
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS trending_score DOUBLE PRECISION DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_videos_trending ON videos(trending_score);
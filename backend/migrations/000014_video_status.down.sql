@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_videos_status;
+ALTER TABLE videos DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE videos DROP COLUMN IF EXISTS status;
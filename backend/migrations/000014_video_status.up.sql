@@ -0,0 +1,5 @@
+-- Publishing lifecycle; existing videos stay public
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_videos_status ON videos(status, publish_at);
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_videos_search;
+ALTER TABLE videos DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE videos ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(creator, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(search_tags, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN (search_vector);
+
+ALTER TABLE videos DROP COLUMN IF EXISTS search_captions;
+DROP TABLE IF EXISTS video_caption_cues;
+DROP TABLE IF EXISTS video_captions;
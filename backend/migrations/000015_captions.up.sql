@@ -0,0 +1,45 @@
+-- Caption tracks, stored as WebVTT files
+CREATE TABLE IF NOT EXISTS video_captions (
+    id BIGSERIAL PRIMARY KEY,
+    video_id BIGINT NOT NULL,
+    language TEXT NOT NULL,
+    label TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'subtitles',
+    is_default INTEGER DEFAULT 0,
+    url TEXT NOT NULL,
+    cue_count INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (video_id, language, kind),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+);
+
+-- Cue text without markup, for searching inside a video
+CREATE TABLE IF NOT EXISTS video_caption_cues (
+    id BIGSERIAL PRIMARY KEY,
+    caption_id BIGINT NOT NULL,
+    video_id BIGINT NOT NULL,
+    start_ms BIGINT NOT NULL,
+    end_ms BIGINT NOT NULL,
+    text TEXT NOT NULL,
+    FOREIGN KEY (caption_id) REFERENCES video_captions(id) ON DELETE CASCADE,
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_caption_cues_caption ON video_caption_cues(caption_id);
+CREATE INDEX IF NOT EXISTS idx_video_caption_cues_video ON video_caption_cues(video_id, start_ms);
+
+-- Caption text copied onto the video so it is searchable, weighted below the description
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS search_captions TEXT DEFAULT '';
+
+DROP INDEX IF EXISTS idx_videos_search;
+ALTER TABLE videos DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE videos ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(creator, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(search_tags, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'C') ||
+        setweight(to_tsvector('english', COALESCE(search_captions, '')), 'D')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN (search_vector);
@@ -0,0 +1,11 @@
+-- Chapters set through the API; without them they come from the description
+CREATE TABLE IF NOT EXISTS video_chapters (
+    id BIGSERIAL PRIMARY KEY,
+    video_id BIGINT NOT NULL,
+    start_seconds DOUBLE PRECISION NOT NULL,
+    title TEXT NOT NULL,
+    thumbnail TEXT DEFAULT '',
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_chapters_video ON video_chapters(video_id, start_seconds);
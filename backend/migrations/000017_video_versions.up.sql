@@ -0,0 +1,14 @@
+-- Media files and thumbnails replaced in place, kept until purge_at
+CREATE TABLE IF NOT EXISTS video_versions (
+    id BIGSERIAL PRIMARY KEY,
+    video_id BIGINT NOT NULL,
+    kind TEXT NOT NULL,
+    url TEXT NOT NULL,
+    hls_url TEXT DEFAULT '',
+    file_size BIGINT DEFAULT 0,
+    replaced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    purge_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_versions_video ON video_versions(video_id, replaced_at);
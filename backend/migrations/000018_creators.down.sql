@@ -0,0 +1,6 @@
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS verified BOOLEAN DEFAULT FALSE;
+UPDATE videos SET verified = c.verified FROM creators c WHERE c.id = videos.creator_id;
+
+DROP INDEX IF EXISTS idx_videos_creator_id;
+ALTER TABLE videos DROP COLUMN IF EXISTS creator_id;
+DROP TABLE IF EXISTS creators;
@@ -0,0 +1,37 @@
+-- Creators, identified by the slug of their name
+CREATE TABLE IF NOT EXISTS creators (
+    id BIGSERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    avatar TEXT DEFAULT '',
+    bio TEXT DEFAULT '',
+    verified BOOLEAN DEFAULT FALSE,
+    links TEXT DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The video's creator; the creator column keeps a copy of their name
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS creator_id BIGINT REFERENCES creators(id);
+
+-- Names with the same slug ("John Doe", "john doe") become one creator, named
+-- by their most used spelling and verified if any of their videos was. The
+-- slug expression matches models.Slugify.
+WITH named AS (
+    SELECT id, trim(creator) AS name, verified,
+           COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(creator), '[^[:alnum:]]+', '-', 'g')), ''), 'unknown') AS slug
+    FROM videos
+)
+INSERT INTO creators (slug, name, verified)
+SELECT slug, mode() WITHIN GROUP (ORDER BY name), bool_or(COALESCE(verified, FALSE))
+FROM named GROUP BY slug
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE videos SET creator_id = c.id, creator = c.name
+FROM creators c
+WHERE c.slug = COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(videos.creator), '[^[:alnum:]]+', '-', 'g')), ''), 'unknown');
+
+-- Verified now belongs to the creator
+ALTER TABLE videos DROP COLUMN IF EXISTS verified;
+
+CREATE INDEX IF NOT EXISTS idx_videos_creator_id ON videos(creator_id, status);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_videos_external_id;
+ALTER TABLE videos DROP COLUMN IF EXISTS external_id;
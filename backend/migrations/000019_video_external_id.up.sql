@@ -0,0 +1,5 @@
+-- ID of the video in the catalog it was imported from, so re-imports skip it.
+-- NULL for videos that weren't imported; unique indexes allow many NULLs.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_videos_external_id ON videos(external_id);